@@ -3,8 +3,11 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -13,9 +16,13 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/chzyer/readline"
 	"github.com/gorilla/websocket"
@@ -52,55 +59,168 @@ type ClientConfig struct {
 	TLS      bool
 	Timeout  time.Duration
 	Debug    bool
+
+	// Настройки истории интерактивного режима
+	HistorySize  int
+	HistoryDedup HistoryDedupPolicy
+
+	// ID запросов интерактивного режима: StartID - первый ID последовательности
+	// (0 означает 1), UUIDIDs заменяет последовательность случайными UUID
+	StartID int
+	UUIDIDs bool
+
+	// PersistentWebSocket держит одно WebSocket соединение для всех запросов
+	// и переподключается при его обрыве
+	PersistentWebSocket bool
+	// WSMaxRetries - число попыток переподключения для одного запроса
+	WSMaxRetries int
+	// WSBackoff - начальная пауза перед переподключением, удваивается с каждой попыткой
+	WSBackoff time.Duration
+	// OnPush получает сообщения постоянного WebSocket соединения, которые не являются
+	// ответом на запрос клиента (уведомления сервера). nil - такие сообщения отбрасываются
+	OnPush func(message []byte)
+
+	// TCPPoolSize - число постоянных TCP/TLS/Unix соединений, сохраняемых для
+	// следующих запросов. 0 - новое соединение на каждый запрос
+	TCPPoolSize int
 }
 
 // Client представляет JSON-RPC клиент
 type Client struct {
 	config ClientConfig
 	client *http.Client
+
+	// Постоянное WebSocket соединение (PersistentWebSocket)
+	ws   *wsSession
+	wsMu sync.Mutex
+
+	// Пул TCP соединений (TCPPoolSize), создается при первом запросе
+	tcpPool     *tcpPool
+	tcpPoolOnce sync.Once
 }
 
-// HistoryManager управляет историей команд
+const (
+	// defaultWSMaxRetries число переподключений по умолчанию
+	defaultWSMaxRetries = 5
+	// defaultWSBackoff начальная пауза переподключения по умолчанию
+	defaultWSBackoff = 200 * time.Millisecond
+	// maxWSBackoff ограничивает рост паузы между переподключениями
+	maxWSBackoff = 10 * time.Second
+)
+
+// HistoryDedupPolicy определяет, как история обрабатывает повторяющиеся команды
+type HistoryDedupPolicy string
+
+const (
+	// HistoryDedupConsecutive пропускает команду, совпадающую с предыдущей
+	HistoryDedupConsecutive HistoryDedupPolicy = "consecutive"
+	// HistoryDedupAll удаляет все прежние вхождения команды и добавляет ее в конец
+	HistoryDedupAll HistoryDedupPolicy = "all"
+)
+
+// defaultHistorySize размер истории по умолчанию
+const defaultHistorySize = 1000
+
+// HistoryManager управляет историей команд. Методы безопасны для
+// одновременного вызова из нескольких горутин
 type HistoryManager struct {
 	historyFile string
-	commands    []string
 	maxSize     int
+	dedup       HistoryDedupPolicy
+
+	mu       sync.Mutex
+	commands []string
 }
 
-// NewHistoryManager создает новый менеджер истории
-func NewHistoryManager() *HistoryManager {
+// NewHistoryManager создает новый менеджер истории.
+// maxSize <= 0 означает размер по умолчанию
+func NewHistoryManager(maxSize int, dedup HistoryDedupPolicy) *HistoryManager {
 	homeDir, _ := os.UserHomeDir()
-	historyFile := filepath.Join(homeDir, ".jsonrpc_client_history")
+	return newHistoryManagerWithFile(filepath.Join(homeDir, ".jsonrpc_client_history"), maxSize, dedup)
+}
+
+// newHistoryManagerWithFile создает менеджер истории для указанного файла
+func newHistoryManagerWithFile(historyFile string, maxSize int, dedup HistoryDedupPolicy) *HistoryManager {
+	if maxSize <= 0 {
+		maxSize = defaultHistorySize
+	}
 
 	hm := &HistoryManager{
 		historyFile: historyFile,
 		commands:    make([]string, 0),
-		maxSize:     1000, // Максимум 1000 команд в истории
+		maxSize:     maxSize,
+		dedup:       dedup,
 	}
 
-	hm.loadHistory()
+	if err := hm.loadHistory(); err != nil {
+		fmt.Printf("⚠️  Warning: %v\n", err)
+	}
 	return hm
 }
 
-// loadHistory загружает историю из файла
-func (hm *HistoryManager) loadHistory() {
-	file, err := os.Open(hm.historyFile)
+// maxHistoryLineLength ограничивает длину одной записи истории
+const maxHistoryLineLength = 64 * 1024
+
+// loadHistory загружает историю из файла. Некорректные записи (бинарные данные,
+// неверный UTF-8, слишком длинные строки) пропускаются; в этом случае исходный
+// файл сохраняется с суффиксом .corrupt и перезаписывается корректными записями.
+// Возвращает ошибку, описывающую восстановление
+func (hm *HistoryManager) loadHistory() error {
+	data, err := os.ReadFile(hm.historyFile)
 	if err != nil {
-		return // Файл не существует, это нормально
+		if os.IsNotExist(err) {
+			return nil // Файл не существует, это нормально
+		}
+		return fmt.Errorf("failed to read history file: %w", err)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			hm.commands = append(hm.commands, line)
+	invalid := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !isValidHistoryLine(line) {
+			invalid++
+			continue
+		}
+		hm.addCommand(line)
+	}
+
+	if invalid == 0 {
+		return nil
+	}
+
+	backupFile := hm.historyFile + ".corrupt"
+	if err := os.WriteFile(backupFile, data, 0600); err != nil {
+		return fmt.Errorf("history file is corrupt (%d invalid entries) and backup failed: %w", invalid, err)
+	}
+	if err := hm.saveHistory(); err != nil {
+		return fmt.Errorf("history file is corrupt (%d invalid entries) and reset failed: %w", invalid, err)
+	}
+
+	return fmt.Errorf("history file was corrupt (%d invalid entries skipped), backup saved to %s", invalid, backupFile)
+}
+
+// isValidHistoryLine проверяет, что строка истории является печатным текстом
+func isValidHistoryLine(line string) bool {
+	if len(line) > maxHistoryLineLength || !utf8.ValidString(line) {
+		return false
+	}
+	for _, r := range line {
+		if r != '\t' && unicode.IsControl(r) {
+			return false
 		}
 	}
+	return true
 }
 
-// saveHistory сохраняет историю в файл
+// saveHistory сохраняет историю в файл. Блокировка удерживается на время записи,
+// чтобы одновременные сохранения не перемешивали строки файла
 func (hm *HistoryManager) saveHistory() error {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
 	file, err := os.Create(hm.historyFile)
 	if err != nil {
 		return err
@@ -129,8 +249,20 @@ func (hm *HistoryManager) addCommand(command string) {
 		return
 	}
 
-	// Избегаем дублирования последней команды
-	if len(hm.commands) > 0 && hm.commands[len(hm.commands)-1] == command {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	if hm.dedup == HistoryDedupAll {
+		// Удаляем прежние вхождения, команда переместится в конец
+		kept := hm.commands[:0]
+		for _, existing := range hm.commands {
+			if existing != command {
+				kept = append(kept, existing)
+			}
+		}
+		hm.commands = kept
+	} else if len(hm.commands) > 0 && hm.commands[len(hm.commands)-1] == command {
+		// Избегаем дублирования последней команды
 		return
 	}
 
@@ -138,13 +270,18 @@ func (hm *HistoryManager) addCommand(command string) {
 
 	// Ограничиваем размер истории
 	if len(hm.commands) > hm.maxSize {
-		hm.commands = hm.commands[1:]
+		hm.commands = hm.commands[len(hm.commands)-hm.maxSize:]
 	}
 }
 
-// getCommands возвращает все команды для автодополнения
+// getCommands возвращает копию всех команд для автодополнения
 func (hm *HistoryManager) getCommands() []string {
-	return hm.commands
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	commands := make([]string, len(hm.commands))
+	copy(commands, hm.commands)
+	return commands
 }
 
 // CommandCompleter предоставляет автодополнение команд
@@ -156,8 +293,8 @@ type CommandCompleter struct {
 func NewCommandCompleter() *CommandCompleter {
 	return &CommandCompleter{
 		commands: []string{
-			"echo", "calc", "calculate", "status", "time", "notify", "raw",
-			"debug", "help", "quit", "exit", "history", "clear",
+			"echo", "calc", "calculate", "status", "time", "generate", "notify", "raw", "batch",
+			"debug", "help", "quit", "exit", "history", "replay", "clear",
 		},
 	}
 }
@@ -213,13 +350,8 @@ func makeRequest(method string, params interface{}, id interface{}) *JSONRPCRequ
 	}
 }
 
-// sendHTTPRequest отправляет HTTP запрос
-func (c *Client) sendHTTPRequest(req *JSONRPCRequest) (*JSONRPCResponse, error) {
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
+// sendHTTPRequest отправляет сериализованный запрос по HTTP и возвращает тело ответа
+func (c *Client) sendHTTPRequest(data []byte) ([]byte, error) {
 	if c.config.Debug {
 		fmt.Printf("🔍 DEBUG Request: %s\n", string(data))
 	}
@@ -229,7 +361,7 @@ func (c *Client) sendHTTPRequest(req *JSONRPCRequest) (*JSONRPCResponse, error)
 		scheme = "https"
 	}
 
-	url := fmt.Sprintf("%s://%s:%d/rpc", scheme, c.config.Host, c.config.Port)
+	url := fmt.Sprintf("%s://%s/rpc", scheme, c.address())
 
 	resp, err := c.client.Post(url, "application/json", bytes.NewBuffer(data))
 	if err != nil {
@@ -246,21 +378,11 @@ func (c *Client) sendHTTPRequest(req *JSONRPCRequest) (*JSONRPCResponse, error)
 		fmt.Printf("🔍 DEBUG Response: %s\n", string(body))
 	}
 
-	// Для уведомлений (без ID) ответ может быть пустым
-	if len(body) == 0 {
-		return nil, nil
-	}
-
-	var response JSONRPCResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	return &response, nil
+	return body, nil
 }
 
-// sendWebSocketRequest отправляет WebSocket запрос
-func (c *Client) sendWebSocketRequest(req *JSONRPCRequest) (*JSONRPCResponse, error) {
+// dialWebSocket открывает WebSocket соединение с сервером
+func (c *Client) dialWebSocket() (*websocket.Conn, error) {
 	scheme := "ws"
 	if c.config.TLS {
 		scheme = "wss"
@@ -268,7 +390,7 @@ func (c *Client) sendWebSocketRequest(req *JSONRPCRequest) (*JSONRPCResponse, er
 
 	u := url.URL{
 		Scheme: scheme,
-		Host:   fmt.Sprintf("%s:%d", c.config.Host, c.config.Port),
+		Host:   c.address(),
 		Path:   "/ws",
 	}
 
@@ -286,171 +408,931 @@ func (c *Client) sendWebSocketRequest(req *JSONRPCRequest) (*JSONRPCResponse, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
+	return conn, nil
+}
+
+// sendWebSocketRequest отправляет сериализованный запрос по WebSocket и возвращает ответ.
+// Если ответ не ожидается (уведомления), возвращает nil
+func (c *Client) sendWebSocketRequest(data []byte, expectResponse bool) ([]byte, error) {
+	conn, err := c.dialWebSocket()
+	if err != nil {
+		return nil, err
+	}
 	defer conn.Close()
 
-	data, err := json.Marshal(req)
-	if c.config.Debug {
+	return c.exchangeWebSocket(conn, data, expectResponse)
+}
+
+// wsBackoff возвращает паузу перед попыткой переподключения attempt (начиная с 1)
+func (c *Client) wsBackoff(attempt int) time.Duration {
+	backoff := c.config.WSBackoff
+	if backoff <= 0 {
+		backoff = defaultWSBackoff
+	}
+	for i := 1; i < attempt && backoff < maxWSBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxWSBackoff {
+		backoff = maxWSBackoff
+	}
+	return backoff
+}
+
+// errSendFailed означает, что запрос не был отправлен: повторить его безопасно,
+// сервер его не получал
+var errSendFailed = errors.New("failed to send request")
+
+// sendPersistentWebSocketRequest отправляет запрос по постоянному WebSocket соединению.
+// Если подключиться или отправить запрос не удалось, клиент переподключается с
+// экспоненциальной паузой и повторяет запрос, пока не исчерпает WSMaxRetries
+// попыток. Обрыв после отправки не повторяется: сервер мог выполнить запрос
+func (c *Client) sendPersistentWebSocketRequest(data []byte, expectResponse bool) ([]byte, error) {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+
+	maxRetries := c.config.WSMaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := c.wsBackoff(attempt)
+			fmt.Printf("🔄 Reconnecting in %v (attempt %d/%d): %v\n", backoff, attempt, maxRetries, lastErr)
+			time.Sleep(backoff)
+		}
+
+		if err := c.ensureWebSocket(); err != nil {
+			lastErr = err
+			continue
+		}
+
+		message, err := c.ws.exchange(data, expectResponse, c.config.Timeout, c.config.Debug)
+		if err == nil {
+			return message, nil
+		}
+
+		// Соединение больше не пригодно: закрываем его, следующий запрос подключится заново
+		c.ws.close()
+		c.ws = nil
+		if !errors.Is(err, errSendFailed) {
+			return nil, err
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("websocket request failed after %d reconnect attempts: %w", maxRetries, lastErr)
+}
+
+// ensureWebSocket открывает постоянное соединение, если оно еще не открыто.
+// Вызывается под wsMu
+func (c *Client) ensureWebSocket() error {
+	if c.ws != nil {
+		return nil
+	}
+	conn, err := c.dialWebSocket()
+	if err != nil {
+		return err
+	}
+	c.ws = newWSSession(conn, c.config.OnPush)
+	return nil
+}
+
+// ConnectWebSocket заранее открывает постоянное WebSocket соединение, чтобы
+// уведомления сервера доставлялись в OnPush еще до первого запроса
+func (c *Client) ConnectWebSocket() error {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+	return c.ensureWebSocket()
+}
+
+// wsSession - постоянное WebSocket соединение с фоновым читателем. Запросы
+// выполняются по одному (под Client.wsMu); читатель передает ожидающему запросу
+// ответ с его ID, а остальные сообщения - в onPush
+type wsSession struct {
+	conn   *websocket.Conn
+	onPush func([]byte)
+
+	mu        sync.Mutex
+	pending   map[string]bool
+	responses chan []byte
+
+	done chan struct{}
+	err  error
+}
+
+func newWSSession(conn *websocket.Conn, onPush func([]byte)) *wsSession {
+	session := &wsSession{
+		conn:      conn,
+		onPush:    onPush,
+		responses: make(chan []byte, 1),
+		done:      make(chan struct{}),
+	}
+	go session.readLoop()
+	return session
+}
+
+// readLoop читает сообщения соединения, пока оно не будет закрыто
+func (s *wsSession) readLoop() {
+	defer close(s.done)
+
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			s.err = err
+			return
+		}
+
+		s.mu.Lock()
+		solicited := isSolicited(message, s.pending)
+		if solicited {
+			s.pending = nil
+		}
+		s.mu.Unlock()
+
+		if solicited {
+			s.responses <- message
+		} else if s.onPush != nil {
+			s.onPush(message)
+		}
+	}
+}
+
+// exchange отправляет запрос и ждет ответ на него от фонового читателя
+func (s *wsSession) exchange(data []byte, expectResponse bool, timeout time.Duration, debug bool) ([]byte, error) {
+	if debug {
 		fmt.Printf("🔍 DEBUG WebSocket Request: %s\n", string(data))
 	}
 
-	if err := conn.WriteJSON(req); err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	var pending map[string]bool
+	if expectResponse {
+		pending = messageIDs(data)
+	}
+	// Для уведомлений и пакетов из одних уведомлений ответ не ожидается
+	if len(pending) == 0 {
+		expectResponse = false
 	}
 
-	// Для уведомлений не ожидаем ответа
-	if req.ID == nil {
+	// Соединение, которое читатель уже признал оборванным, не принимает запрос
+	select {
+	case <-s.done:
+		return nil, fmt.Errorf("%w: %w", errSendFailed, s.err)
+	default:
+	}
+
+	s.mu.Lock()
+	s.pending = pending
+	s.mu.Unlock()
+
+	if timeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return nil, fmt.Errorf("%w: %w", errSendFailed, err)
+	}
+
+	if !expectResponse {
 		return nil, nil
 	}
 
-	var response JSONRPCResponse
-	if err := conn.ReadJSON(&response); err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
 	}
 
-	if c.config.Debug {
-		respData, _ := json.Marshal(response)
-		fmt.Printf("🔍 DEBUG WebSocket Response: %s\n", string(respData))
+	select {
+	case message := <-s.responses:
+		if debug {
+			fmt.Printf("🔍 DEBUG WebSocket Response: %s\n", string(message))
+		}
+		return message, nil
+	case <-s.done:
+		return nil, fmt.Errorf("failed to read response: %w", s.err)
+	case <-expired:
+		return nil, fmt.Errorf("failed to read response: timeout after %v", timeout)
 	}
+}
 
-	return &response, nil
+// close закрывает соединение и дожидается завершения читателя
+func (s *wsSession) close() error {
+	err := s.conn.Close()
+	<-s.done
+	return err
 }
 
-// sendTCPRequest отправляет TCP запрос
-func (c *Client) sendTCPRequest(req *JSONRPCRequest) (*JSONRPCResponse, error) {
-	address := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
+// wsEnvelope - поля сообщения, по которым определяется его назначение
+type wsEnvelope struct {
+	ID     interface{}     `json:"id"`
+	Method string          `json:"method"`
+	Error  json.RawMessage `json:"error"`
+}
 
-	if c.config.Debug {
-		fmt.Printf("🔍 DEBUG TCP Address: %s\n", address)
+// messageIDs возвращает ID запросов (или ответов) одиночного сообщения или пакета
+func messageIDs(message []byte) map[string]bool {
+	ids := make(map[string]bool)
+	for _, envelope := range decodeEnvelopes(message) {
+		if envelope.ID != nil {
+			ids[idKey(envelope.ID)] = true
+		}
 	}
+	return ids
+}
 
-	var conn net.Conn
-	var err error
+// isSolicited сообщает, является ли сообщение сервера ответом на ожидающий запрос
+// с ID из pending. Сообщения с полем method - уведомления сервера. Ответ с ошибкой
+// и ID null (например, ошибка разбора) относится к ожидающему запросу, так как
+// запросы выполняются по одному. Ответы с чужими ID считаются незапрошенными
+func isSolicited(message []byte, pending map[string]bool) bool {
+	if len(pending) == 0 {
+		return false
+	}
 
-	if c.config.TLS {
-		conn, err = tls.Dial("tcp", address, &tls.Config{
-			InsecureSkipVerify: true,
-		})
-	} else {
-		conn, err = net.Dial("tcp", address)
+	for _, envelope := range decodeEnvelopes(message) {
+		if envelope.Method != "" {
+			continue
+		}
+		if envelope.ID == nil {
+			if len(envelope.Error) > 0 && string(envelope.Error) != "null" {
+				return true
+			}
+			continue
+		}
+		if pending[idKey(envelope.ID)] {
+			return true
+		}
 	}
+	return false
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect: %w", err)
+// decodeEnvelopes разбирает одиночное сообщение или пакет. Некорректный JSON дает
+// пустой результат
+func decodeEnvelopes(message []byte) []wsEnvelope {
+	message = bytes.TrimSpace(message)
+	if len(message) > 0 && message[0] == '[' {
+		var batch []wsEnvelope
+		if err := decodeJSON(message, &batch); err != nil {
+			return nil
+		}
+		return batch
 	}
-	defer conn.Close()
 
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	var envelope wsEnvelope
+	if err := decodeJSON(message, &envelope); err != nil {
+		return nil
 	}
+	return []wsEnvelope{envelope}
+}
 
+// exchangeWebSocket отправляет запрос в открытое соединение и читает ответ
+func (c *Client) exchangeWebSocket(conn *websocket.Conn, data []byte, expectResponse bool) ([]byte, error) {
 	if c.config.Debug {
-		fmt.Printf("🔍 DEBUG TCP Request: %s\n", string(data))
+		fmt.Printf("🔍 DEBUG WebSocket Request: %s\n", string(data))
 	}
 
-	// Отправляем запрос с переводом строки
-	if _, err := conn.Write(append(data, '\n')); err != nil {
+	if c.config.Timeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(c.config.Timeout))
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
 	// Для уведомлений не ожидаем ответа
-	if req.ID == nil {
+	if !expectResponse {
 		return nil, nil
 	}
 
-	// Читаем ответ
-	reader := bufio.NewReader(conn)
-	line, _, err := reader.ReadLine()
+	if c.config.Timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(c.config.Timeout))
+	}
+	_, message, err := conn.ReadMessage()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if c.config.Debug {
-		fmt.Printf("🔍 DEBUG TCP Response: %s\n", string(line))
+		fmt.Printf("🔍 DEBUG WebSocket Response: %s\n", string(message))
 	}
 
-	var response JSONRPCResponse
-	if err := json.Unmarshal(line, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	return message, nil
+}
+
+// Close закрывает постоянное WebSocket соединение и соединения пула TCP
+func (c *Client) Close() error {
+	if c.tcpPool != nil {
+		c.tcpPool.close()
 	}
 
-	return &response, nil
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+
+	if c.ws == nil {
+		return nil
+	}
+	err := c.ws.close()
+	c.ws = nil
+	return err
 }
 
-// SendRequest отправляет запрос в зависимости от протокола
-func (c *Client) SendRequest(req *JSONRPCRequest) (*JSONRPCResponse, error) {
+// StreamResponse элемент потокового ответа: очередной ответ сервера
+// или ошибка, завершившая поток
+type StreamResponse struct {
+	Response *JSONRPCResponse
+	Err      error
+}
+
+// SendStreamingRequest отправляет запрос по WebSocket и возвращает канал ответов,
+// которые сервер передает отдельными кадрами с тем же ID. Поток завершается, когда
+// сервер закрывает соединение, присылает ответ с ошибкой или отменяется ctx.
+// Кадры с другими ID (например, уведомления сервера) пропускаются
+func (c *Client) SendStreamingRequest(ctx context.Context, req *JSONRPCRequest) (<-chan StreamResponse, error) {
 	switch strings.ToLower(c.config.Protocol) {
-	case "http", "https":
-		return c.sendHTTPRequest(req)
 	case "ws", "wss", "websocket":
-		return c.sendWebSocketRequest(req)
-	case "tcp", "tls":
-		return c.sendTCPRequest(req)
 	default:
-		return nil, fmt.Errorf("unsupported protocol: %s", c.config.Protocol)
+		return nil, fmt.Errorf("streaming requests require the websocket protocol, got %s", c.config.Protocol)
 	}
-}
 
-// printResponse выводит ответ в удобном формате
-func printResponse(response *JSONRPCResponse, err error) {
-	if err != nil {
-		fmt.Printf("❌ Error: %v\n", err)
-		return
+	if req.ID == nil {
+		return nil, fmt.Errorf("streaming requests require an id")
 	}
 
-	if response == nil {
-		fmt.Printf("✅ Notification sent successfully (no response expected)\n")
-		return
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	if response.Error != nil {
-		fmt.Printf("❌ JSON-RPC Error [%d]: %s\n", response.Error.Code, response.Error.Message)
-		if response.Error.Data != nil {
-			fmt.Printf("   Data: %v\n", response.Error.Data)
-		}
-		return
+	conn, err := c.dialWebSocket()
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("✅ Success (ID: %v)\n", response.ID)
-	if response.Result != nil {
-		resultJSON, _ := json.MarshalIndent(response.Result, "   ", "  ")
-		fmt.Printf("   Result: %s\n", string(resultJSON))
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
+
+	responses := make(chan StreamResponse)
+	stop := make(chan struct{})
+
+	// Отмена контекста прерывает ожидание следующего кадра
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	go func() {
+		defer close(responses)
+		defer close(stop)
+		defer conn.Close()
+
+		send := func(item StreamResponse) bool {
+			select {
+			case responses <- item:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		key := idKey(req.ID)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				if ctx.Err() == nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					send(StreamResponse{Err: fmt.Errorf("failed to read response: %w", err)})
+				}
+				return
+			}
+
+			if c.config.Debug {
+				fmt.Printf("🔍 DEBUG WebSocket Stream Frame: %s\n", string(message))
+			}
+
+			var response JSONRPCResponse
+			if err := decodeJSON(message, &response); err != nil {
+				send(StreamResponse{Err: fmt.Errorf("failed to unmarshal response: %w", err)})
+				return
+			}
+			if response.ID == nil || idKey(response.ID) != key {
+				continue
+			}
+
+			if !send(StreamResponse{Response: &response}) || response.Error != nil {
+				return
+			}
+		}
+	}()
+
+	return responses, nil
 }
 
-// showHistory показывает историю команд
-func showHistory(history *HistoryManager) {
-	commands := history.getCommands()
-	if len(commands) == 0 {
-		fmt.Println("📜 History is empty")
-		return
+// sendTCPRequest отправляет сериализованный запрос по TCP/TLS или Unix сокету и возвращает строку ответа.
+// Если ответ не ожидается (уведомления), возвращает nil
+func (c *Client) sendTCPRequest(data []byte, expectResponse bool) ([]byte, error) {
+	if c.config.TCPPoolSize > 0 {
+		return c.sendPooledTCPRequest(data, expectResponse)
 	}
 
-	fmt.Printf("📜 Command History (last %d commands):\n", len(commands))
-	start := 0
-	if len(commands) > 20 {
-		start = len(commands) - 20
-		fmt.Printf("   ... (showing last 20 of %d commands)\n", len(commands))
+	conn, err := c.dialTCP()
+	if err != nil {
+		return nil, err
 	}
+	defer conn.Close()
 
-	for i := start; i < len(commands); i++ {
-		fmt.Printf("   %3d: %s\n", i+1, commands[i])
+	if c.config.Debug {
+		fmt.Printf("🔍 DEBUG TCP Request: %s\n", string(data))
 	}
-}
 
-// processCommand обрабатывает команду и возвращает JSON-RPC запрос
-func processCommand(line string, requestID *int) (*JSONRPCRequest, bool, string) {
-	line = strings.TrimSpace(line)
-	if line == "" {
-		return nil, false, ""
+	// Отправляем запрос с переводом строки
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
-	parts := strings.Fields(line)
-	command := strings.ToLower(parts[0])
+	// Для уведомлений не ожидаем ответа
+	if !expectResponse {
+		return nil, nil
+	}
 
-	switch command {
-	case "quit", "exit", "q":
-		return nil, false, "quit"
+	return c.readTCPResponse(bufio.NewReader(conn))
+}
+
+// dialTCP открывает соединение по TCP, TLS или Unix сокету
+func (c *Client) dialTCP() (net.Conn, error) {
+	address := c.address()
+
+	if c.config.Debug {
+		fmt.Printf("🔍 DEBUG TCP Address: %s\n", address)
+	}
+
+	var conn net.Conn
+	var err error
+
+	switch {
+	case c.isUnix():
+		conn, err = net.Dial("unix", address)
+	case c.config.TLS:
+		conn, err = tls.Dial("tcp", address, &tls.Config{
+			InsecureSkipVerify: true,
+		})
+	default:
+		conn, err = net.Dial("tcp", address)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return conn, nil
+}
+
+// readTCPResponse читает ответ целиком до перевода строки, ответ на пакет может быть длинным
+func (c *Client) readTCPResponse(reader *bufio.Reader) ([]byte, error) {
+	line, err := reader.ReadBytes('\n')
+	if err != nil && !(err == io.EOF && len(line) > 0) {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	line = bytes.TrimRight(line, "\r\n")
+
+	if c.config.Debug {
+		fmt.Printf("🔍 DEBUG TCP Response: %s\n", string(line))
+	}
+
+	return line, nil
+}
+
+// pooledTCPConn - соединение пула вместе с буфером чтения: непрочитанные
+// данные буфера принадлежат следующему ответу
+type pooledTCPConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// Пока соединение простаивает в пуле, фоновое чтение ждет закрытия его
+	// сервером; watched закрывается, когда чтение завершилось с watchErr
+	watched  chan struct{}
+	watchErr error
+}
+
+// watch начинает ожидание закрытия простаивающего соединения сервером
+func (pc *pooledTCPConn) watch() {
+	pc.watched = make(chan struct{})
+	go func() {
+		defer close(pc.watched)
+		_, pc.watchErr = pc.reader.Peek(1)
+	}()
+}
+
+// stale прекращает ожидание и сообщает, что соединение непригодно: сервер
+// закрыл его за время простоя или прислал непрошеные данные. Исправное
+// соединение прерывает ожидание по истекшему дедлайну
+func (pc *pooledTCPConn) stale() bool {
+	pc.conn.SetReadDeadline(time.Now())
+	<-pc.watched
+	pc.conn.SetReadDeadline(time.Time{})
+
+	var netErr net.Error
+	return !errors.As(pc.watchErr, &netErr) || !netErr.Timeout()
+}
+
+// tcpPool хранит до size простаивающих соединений с сервером клиента.
+// Соединение занимает один запрос; после ответа оно возвращается в пул
+type tcpPool struct {
+	idle chan *pooledTCPConn
+	dial func() (net.Conn, error)
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newTCPPool(size int, dial func() (net.Conn, error)) *tcpPool {
+	return &tcpPool{idle: make(chan *pooledTCPConn, size), dial: dial}
+}
+
+// get возвращает простаивающее соединение или открывает новое. Соединения,
+// закрытые сервером за время простоя, отбрасываются. reused сообщает, что
+// соединение уже использовалось и все же могло оборваться
+func (p *tcpPool) get() (pc *pooledTCPConn, reused bool, err error) {
+	for {
+		select {
+		case pc := <-p.idle:
+			if pc.stale() {
+				pc.conn.Close()
+				continue
+			}
+			return pc, true, nil
+		default:
+		}
+		break
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		return nil, false, err
+	}
+	return &pooledTCPConn{conn: conn, reader: bufio.NewReader(conn)}, false, nil
+}
+
+// put возвращает исправное соединение в пул; лишние соединения закрываются
+func (p *tcpPool) put(pc *pooledTCPConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		pc.conn.Close()
+		return
+	}
+	pc.watch()
+	select {
+	case p.idle <- pc:
+	default:
+		pc.conn.Close()
+	}
+}
+
+// close закрывает простаивающие соединения; занятые закрываются при возврате
+func (p *tcpPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for {
+		select {
+		case pc := <-p.idle:
+			pc.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// sendPooledTCPRequest отправляет запрос через соединение пула. Соединение с
+// ошибкой закрывается. Запрос повторяется через другое соединение, только если
+// его не удалось записать в повторно используемое соединение: после записи
+// сервер мог его выполнить
+func (c *Client) sendPooledTCPRequest(data []byte, expectResponse bool) ([]byte, error) {
+	c.tcpPoolOnce.Do(func() {
+		c.tcpPool = newTCPPool(c.config.TCPPoolSize, c.dialTCP)
+	})
+
+	for {
+		pc, reused, err := c.tcpPool.get()
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := c.exchangeTCP(pc, data, expectResponse)
+		if err == nil {
+			c.tcpPool.put(pc)
+			return response, nil
+		}
+
+		pc.conn.Close()
+		if !reused || !errors.Is(err, errSendFailed) {
+			return nil, err
+		}
+		if c.config.Debug {
+			fmt.Printf("🔍 DEBUG TCP pooled connection failed, reconnecting: %v\n", err)
+		}
+	}
+}
+
+// exchangeTCP отправляет запрос по соединению пула и читает ответ
+func (c *Client) exchangeTCP(pc *pooledTCPConn, data []byte, expectResponse bool) ([]byte, error) {
+	if c.config.Timeout > 0 {
+		pc.conn.SetDeadline(time.Now().Add(c.config.Timeout))
+		defer pc.conn.SetDeadline(time.Time{})
+	}
+
+	if c.config.Debug {
+		fmt.Printf("🔍 DEBUG TCP Request: %s\n", string(data))
+	}
+
+	if _, err := pc.conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("%w: %w", errSendFailed, err)
+	}
+	if !expectResponse {
+		return nil, nil
+	}
+
+	line, err := pc.reader.ReadBytes('\n')
+	if err != nil {
+		// Ответ без перевода строки не отделить от следующего, соединение не переиспользуется
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	line = bytes.TrimRight(line, "\r\n")
+
+	if c.config.Debug {
+		fmt.Printf("🔍 DEBUG TCP Response: %s\n", string(line))
+	}
+	return line, nil
+}
+
+// isWebSocketProtocol сообщает, что протокол использует WebSocket
+func isWebSocketProtocol(protocol string) bool {
+	switch strings.ToLower(protocol) {
+	case "ws", "wss", "websocket":
+		return true
+	default:
+		return false
+	}
+}
+
+// isUnix сообщает, что клиент подключается через Unix сокет
+func (c *Client) isUnix() bool {
+	return strings.ToLower(c.config.Protocol) == "unix"
+}
+
+// address возвращает адрес сервера в формате host:port; для Unix сокета - путь из Host
+func (c *Client) address() string {
+	if c.isUnix() {
+		return c.config.Host
+	}
+	return net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port))
+}
+
+// roundTrip отправляет сериализованные данные по настроенному протоколу
+func (c *Client) roundTrip(data []byte, expectResponse bool) ([]byte, error) {
+	switch strings.ToLower(c.config.Protocol) {
+	case "http", "https":
+		return c.sendHTTPRequest(data)
+	case "ws", "wss", "websocket":
+		if c.config.PersistentWebSocket {
+			return c.sendPersistentWebSocketRequest(data, expectResponse)
+		}
+		return c.sendWebSocketRequest(data, expectResponse)
+	case "tcp", "tls", "unix":
+		return c.sendTCPRequest(data, expectResponse)
+	default:
+		return nil, fmt.Errorf("unsupported protocol: %s", c.config.Protocol)
+	}
+}
+
+// SendRequest отправляет запрос в зависимости от протокола
+func (c *Client) SendRequest(req *JSONRPCRequest) (*JSONRPCResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	body, err := c.roundTrip(data, req.ID != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Для уведомлений (без ID) ответ может быть пустым
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	var response JSONRPCResponse
+	if err := decodeJSON(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// decodeJSON разбирает JSON, сохраняя числа как json.Number. Это позволяет
+// выводить и отправлять большие целочисленные ID без потери точности
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
+
+// formatPush форматирует незапрошенное сообщение сервера для вывода
+func formatPush(message []byte) string {
+	var notification struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := decodeJSON(message, &notification); err != nil || notification.Method == "" {
+		return fmt.Sprintf("📨 Server message: %s", bytes.TrimSpace(message))
+	}
+
+	if len(notification.Params) == 0 {
+		return fmt.Sprintf("📨 Server push: %s", notification.Method)
+	}
+	return fmt.Sprintf("📨 Server push: %s %s", notification.Method, notification.Params)
+}
+
+// printResponse выводит ответ в удобном формате
+func printResponse(response *JSONRPCResponse, err error) {
+	if err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+		return
+	}
+
+	if response == nil {
+		fmt.Printf("✅ Notification sent successfully (no response expected)\n")
+		return
+	}
+
+	if response.Error != nil {
+		fmt.Printf("❌ JSON-RPC Error [%d]: %s\n", response.Error.Code, response.Error.Message)
+		if response.Error.Data != nil {
+			fmt.Printf("   Data: %v\n", response.Error.Data)
+		}
+		return
+	}
+
+	fmt.Printf("✅ Success (ID: %v)\n", response.ID)
+	if response.Result != nil {
+		resultJSON, _ := json.MarshalIndent(response.Result, "   ", "  ")
+		fmt.Printf("   Result: %s\n", string(resultJSON))
+	}
+}
+
+// OutputFormat определяет формат вывода ответов в неинтерактивном режиме
+type OutputFormat string
+
+const (
+	// OutputPretty выводит ответы в читаемом виде (по умолчанию)
+	OutputPretty OutputFormat = "pretty"
+	// OutputJSON выводит ответы компактным JSON в stdout, а ошибки - в stderr
+	OutputJSON OutputFormat = "json"
+	// OutputNDJSON выводит каждый ответ отдельной строкой JSON, в том числе
+	// ответы пакета, что удобно для обработки построчно (jq, grep)
+	OutputNDJSON OutputFormat = "ndjson"
+)
+
+// writeJSONOutput выводит ответ (или массив ответов) компактным JSON в stdout,
+// а ошибку транспорта - в stderr. Пустой ответ (уведомления) ничего не выводит.
+// Возвращает код завершения: 1 при ошибке транспорта или JSON-RPC ошибке в ответе
+func writeJSONOutput(stdout, stderr io.Writer, responses []*JSONRPCResponse, batch bool, err error) int {
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if len(responses) == 0 {
+		return 0
+	}
+
+	var value interface{} = responses
+	if !batch {
+		value = responses[0]
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to marshal response: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, string(data))
+
+	for _, response := range responses {
+		if response != nil && response.Error != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeNDJSONOutput выводит каждый ответ компактным JSON на отдельной строке:
+// успешные ответы и JSON-RPC ошибки одинаково, вместе с ID запроса. Ошибка
+// транспорта выводится в stderr. Код завершения такой же, как у writeJSONOutput
+func writeNDJSONOutput(stdout, stderr io.Writer, responses []*JSONRPCResponse, err error) int {
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	code := 0
+	for _, response := range responses {
+		if response == nil {
+			continue
+		}
+		data, err := json.Marshal(response)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: failed to marshal response: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(stdout, string(data))
+
+		if response.Error != nil {
+			code = 1
+		}
+	}
+	return code
+}
+
+// showHistory показывает историю команд
+func showHistory(history *HistoryManager) {
+	commands := history.getCommands()
+	if len(commands) == 0 {
+		fmt.Println("📜 History is empty")
+		return
+	}
+
+	fmt.Printf("📜 Command History (last %d commands):\n", len(commands))
+	start := 0
+	if len(commands) > 20 {
+		start = len(commands) - 20
+		fmt.Printf("   ... (showing last 20 of %d commands)\n", len(commands))
+	}
+
+	for i := start; i < len(commands); i++ {
+		fmt.Printf("   %3d: %s\n", i+1, commands[i])
+	}
+}
+
+// requestIDSequence выдает ID запросов интерактивного режима: целые числа по
+// возрастанию от начального значения или, в режиме UUID, случайные UUID v4
+type requestIDSequence struct {
+	next    int
+	useUUID bool
+}
+
+// newRequestIDSequence создает последовательность ID, начинающуюся со start
+func newRequestIDSequence(start int, useUUID bool) *requestIDSequence {
+	return &requestIDSequence{next: start, useUUID: useUUID}
+}
+
+// Next возвращает очередной ID
+func (s *requestIDSequence) Next() interface{} {
+	if s.useUUID {
+		return newUUID()
+	}
+	id := s.next
+	s.next++
+	return id
+}
+
+// newUUID возвращает случайный UUID версии 4
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand не возвращает ошибок на поддерживаемых платформах
+		panic(fmt.Sprintf("failed to generate uuid: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // версия 4
+	b[8] = (b[8] & 0x3f) | 0x80 // вариант RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// processCommand обрабатывает команду и возвращает JSON-RPC запрос
+func processCommand(line string, ids *requestIDSequence) (*JSONRPCRequest, bool, string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, false, ""
+	}
+
+	parts := strings.Fields(line)
+	command := strings.ToLower(parts[0])
+
+	switch command {
+	case "quit", "exit", "q":
+		return nil, false, "quit"
 
 	case "help", "h":
 		return nil, false, "help"
@@ -476,14 +1358,28 @@ func processCommand(line string, requestID *int) (*JSONRPCRequest, bool, string)
 		req := makeRequest("echo", map[string]interface{}{
 			"message":   message,
 			"timestamp": time.Now().Unix(),
-		}, *requestID)
-		*requestID++
+		}, ids.Next())
 		return req, true, ""
 
 	case "calc", "calculate":
+		// Single-operand form: calc sqrt <a>
+		if len(parts) == 3 && strings.ToLower(parts[1]) == "sqrt" {
+			a, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil {
+				fmt.Printf("Invalid number: %s\n", parts[2])
+				return nil, false, ""
+			}
+
+			req := makeRequest("calculate", map[string]interface{}{
+				"a":         a,
+				"operation": "sqrt",
+			}, ids.Next())
+			return req, true, ""
+		}
+
 		if len(parts) != 4 {
-			fmt.Println("Usage: calc <a> <op> <b>")
-			fmt.Println("Example: calc 10 + 5")
+			fmt.Println("Usage: calc <a> <op> <b> | calc sqrt <a>")
+			fmt.Println("Example: calc 10 + 5, calc 17 modulo 5, calc 2 power 8")
 			return nil, false, ""
 		}
 
@@ -503,18 +1399,40 @@ func processCommand(line string, requestID *int) (*JSONRPCRequest, bool, string)
 			"a":         a,
 			"b":         b,
 			"operation": parts[2],
-		}, *requestID)
-		*requestID++
+		}, ids.Next())
 		return req, true, ""
 
 	case "status":
-		req := makeRequest("status", nil, *requestID)
-		*requestID++
+		req := makeRequest("status", nil, ids.Next())
 		return req, true, ""
 
 	case "time":
-		req := makeRequest("time", nil, *requestID)
-		*requestID++
+		req := makeRequest("time", nil, ids.Next())
+		return req, true, ""
+
+	case "generate":
+		if len(parts) < 2 || len(parts) > 3 {
+			fmt.Println("Usage: generate <fields> [value_size]")
+			return nil, false, ""
+		}
+
+		fields, err := strconv.Atoi(parts[1])
+		if err != nil || fields < 0 {
+			fmt.Printf("Invalid field count: %s\n", parts[1])
+			return nil, false, ""
+		}
+		params := map[string]interface{}{"fields": fields}
+
+		if len(parts) == 3 {
+			size, err := strconv.Atoi(parts[2])
+			if err != nil || size < 0 {
+				fmt.Printf("Invalid value size: %s\n", parts[2])
+				return nil, false, ""
+			}
+			params["value_size"] = size
+		}
+
+		req := makeRequest("generate", params, ids.Next())
 		return req, true, ""
 
 	case "notify":
@@ -535,6 +1453,20 @@ func processCommand(line string, requestID *int) (*JSONRPCRequest, bool, string)
 		req := makeRequest(parts[1], params, nil) // nil ID для уведомления
 		return req, true, ""
 
+	case "batch":
+		if len(parts) < 2 {
+			fmt.Println("Usage: batch <command>; <command>; ... | batch @<file.ndjson>")
+			return nil, false, ""
+		}
+		return nil, false, "batch"
+
+	case "replay":
+		if len(parts) != 2 {
+			fmt.Println("Usage: replay <n>")
+			return nil, false, ""
+		}
+		return nil, false, "replay"
+
 	case "raw":
 		if len(parts) < 2 {
 			fmt.Println("Usage: raw <json>")
@@ -543,13 +1475,15 @@ func processCommand(line string, requestID *int) (*JSONRPCRequest, bool, string)
 
 		jsonStr := strings.Join(parts[1:], " ")
 		req := &JSONRPCRequest{}
-		if err := json.Unmarshal([]byte(jsonStr), req); err != nil {
+		if err := decodeJSON([]byte(jsonStr), req); err != nil {
 			fmt.Printf("Invalid JSON: %v\n", err)
 			return nil, false, ""
 		}
 
+		// Запрос несет собственный ID; последовательность все равно сдвигается,
+		// чтобы следующие ID не повторяли его
 		if req.ID != nil {
-			*requestID++
+			ids.Next()
 		}
 		return req, true, ""
 
@@ -559,6 +1493,142 @@ func processCommand(line string, requestID *int) (*JSONRPCRequest, bool, string)
 	}
 }
 
+// parseBatchCommand собирает запросы команды batch. Аргумент вида @path читается
+// как NDJSON файл, иначе аргумент - список команд интерактивного режима,
+// разделенных ';' (например, "status; echo hi; notify log"). Уведомления
+// входят в пакет без ID
+func parseBatchCommand(line string, ids *requestIDSequence) ([]*JSONRPCRequest, error) {
+	args := strings.TrimSpace(line)
+	if fields := strings.Fields(args); len(fields) > 0 && strings.ToLower(fields[0]) == "batch" {
+		args = strings.TrimSpace(args[len(fields[0]):])
+	}
+	if args == "" {
+		return nil, fmt.Errorf("batch requires commands or @file")
+	}
+
+	if strings.HasPrefix(args, "@") {
+		return readBatchFile(strings.TrimPrefix(args, "@"))
+	}
+
+	var requests []*JSONRPCRequest
+	for _, item := range strings.Split(args, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		req, shouldSend, action := processCommand(item, ids)
+		if action != "" || !shouldSend || req == nil {
+			return nil, fmt.Errorf("invalid batch command: %s", item)
+		}
+		requests = append(requests, req)
+	}
+
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("batch requires commands or @file")
+	}
+	return requests, nil
+}
+
+// replayStep - команда сессии, подготовленная к повторной отправке: одиночный
+// запрос или уведомление (Request), пакет (Batch) или пропущенная команда (Skipped)
+type replayStep struct {
+	Command string
+	Request *JSONRPCRequest
+	Batch   []*JSONRPCRequest
+	Skipped string
+}
+
+// planReplay разбирает команды сессии через processCommand в порядке записи.
+// Команды управления (quit, help, clear, history, replay) и некорректные
+// команды пропускаются с указанием причины, а не прерывают повтор
+func planReplay(commands []string, ids *requestIDSequence) []replayStep {
+	var steps []replayStep
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+
+		step := replayStep{Command: command}
+		req, shouldSend, action := processCommand(command, ids)
+		switch {
+		case action == "batch":
+			requests, err := parseBatchCommand(command, ids)
+			if err != nil {
+				step.Skipped = err.Error()
+			} else {
+				step.Batch = requests
+			}
+		case action != "":
+			step.Skipped = action + " is not replayed"
+		case !shouldSend || req == nil:
+			step.Skipped = "invalid command"
+		default:
+			step.Request = req
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// lastCommands возвращает n последних команд истории перед текущей командой
+// replay, которая уже добавлена в историю
+func lastCommands(history *HistoryManager, n int) []string {
+	commands := history.getCommands()
+	if len(commands) > 0 {
+		commands = commands[:len(commands)-1]
+	}
+	if n < len(commands) {
+		commands = commands[len(commands)-n:]
+	}
+	return commands
+}
+
+// runReplay отправляет команды по порядку и выводит результаты
+func runReplay(client *Client, commands []string, ids *requestIDSequence) {
+	steps := planReplay(commands, ids)
+	fmt.Printf("🔁 Replaying %d commands\n", len(steps))
+
+	for i, step := range steps {
+		fmt.Printf("[%d/%d] %s\n", i+1, len(steps), step.Command)
+		switch {
+		case step.Skipped != "":
+			fmt.Printf("⏭️  Skipped: %s\n", step.Skipped)
+		case step.Batch != nil:
+			fmt.Printf("📤 Sending batch of %d requests\n", len(step.Batch))
+			results, unmatched, err := client.SendBatchRequest(step.Batch)
+			if err != nil {
+				printResponse(nil, err)
+			} else {
+				printBatchResults(results, unmatched)
+			}
+		default:
+			fmt.Printf("📤 Sending: %s\n", step.Request.Method)
+			response, err := client.SendRequest(step.Request)
+			printResponse(response, err)
+		}
+		fmt.Println()
+	}
+}
+
+// readReplayFile читает команды интерактивного режима, по одной в строке.
+// Пустые строки и строки, начинающиеся с '#', пропускаются
+func readReplayFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replay file: %w", err)
+	}
+
+	var commands []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			commands = append(commands, line)
+		}
+	}
+	return commands, nil
+}
+
 // runInteractiveMode запускает интерактивный режим с расширенными возможностями
 func runInteractiveMode(client *Client) {
 	fmt.Println("🚀 Enhanced Interactive JSON-RPC Client")
@@ -570,12 +1640,16 @@ func runInteractiveMode(client *Client) {
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  echo <message>           - Echo message")
-	fmt.Println("  calc <a> <op> <b>        - Calculate (op: +, -, *, /)")
+	fmt.Println("  calc <a> <op> <b>        - Calculate (op: +, -, *, /, %, ^, modulo, power)")
+	fmt.Println("  calc sqrt <a>            - Square root")
 	fmt.Println("  status                   - Get server status")
 	fmt.Println("  time                     - Get server time")
+	fmt.Println("  generate <fields> [size] - Request a random payload")
 	fmt.Println("  notify <method> [params] - Send notification")
 	fmt.Println("  raw <json>               - Send raw JSON-RPC request")
+	fmt.Println("  batch <cmd>; <cmd>; ...  - Send commands as one batch (or batch @file.ndjson)")
 	fmt.Println("  history                  - Show command history")
+	fmt.Println("  replay <n>               - Resend the last n commands from history")
 	fmt.Println("  clear                    - Clear screen")
 	fmt.Println("  help                     - Show this help")
 	fmt.Println("  quit                     - Exit")
@@ -588,7 +1662,7 @@ func runInteractiveMode(client *Client) {
 	fmt.Println()
 
 	// Инициализируем менеджер истории
-	history := NewHistoryManager()
+	history := NewHistoryManager(client.config.HistorySize, client.config.HistoryDedup)
 	defer func() {
 		if err := history.saveHistory(); err != nil {
 			fmt.Printf("Warning: Failed to save history: %v\n", err)
@@ -600,6 +1674,7 @@ func runInteractiveMode(client *Client) {
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:            "jsonrpc> ",
 		HistoryFile:       history.historyFile,
+		HistoryLimit:      history.maxSize,
 		AutoComplete:      completer,
 		InterruptPrompt:   "^C",
 		EOFPrompt:         "exit",
@@ -611,7 +1686,25 @@ func runInteractiveMode(client *Client) {
 	}
 	defer rl.Close()
 
-	requestID := 1
+	// Интерактивная сессия использует одно WebSocket соединение для всех команд.
+	// Уведомления сервера выводятся по мере поступления, между ответами на команды
+	client.config.PersistentWebSocket = true
+	client.config.OnPush = func(message []byte) {
+		fmt.Fprintln(rl.Stdout(), formatPush(message))
+	}
+	defer client.Close()
+
+	if isWebSocketProtocol(client.config.Protocol) {
+		if err := client.ConnectWebSocket(); err != nil {
+			fmt.Printf("⚠️  WebSocket connection failed, will retry on the first command: %v\n", err)
+		}
+	}
+
+	startID := client.config.StartID
+	if startID == 0 {
+		startID = 1
+	}
+	ids := newRequestIDSequence(startID, client.config.UUIDIDs)
 
 	for {
 		line, err := rl.Readline()
@@ -640,7 +1733,7 @@ func runInteractiveMode(client *Client) {
 		history.addCommand(line)
 
 		// Обрабатываем специальные команды
-		req, shouldSend, action := processCommand(line, &requestID)
+		req, shouldSend, action := processCommand(line, ids)
 
 		switch action {
 		case "quit":
@@ -650,12 +1743,16 @@ func runInteractiveMode(client *Client) {
 		case "help":
 			fmt.Println("Available commands:")
 			fmt.Println("  echo <message>           - Echo message")
-			fmt.Println("  calc <a> <op> <b>        - Calculate (op: +, -, *, /)")
+			fmt.Println("  calc <a> <op> <b>        - Calculate (op: +, -, *, /, %, ^, modulo, power)")
+			fmt.Println("  calc sqrt <a>            - Square root")
 			fmt.Println("  status                   - Get server status")
 			fmt.Println("  time                     - Get server time")
+			fmt.Println("  generate <fields> [size] - Request a random payload")
 			fmt.Println("  notify <method> [params] - Send notification")
 			fmt.Println("  raw <json>               - Send raw JSON-RPC request")
+			fmt.Println("  batch <cmd>; <cmd>; ...  - Send commands as one batch (or batch @file.ndjson)")
 			fmt.Println("  history                  - Show command history")
+			fmt.Println("  replay <n>               - Resend the last n commands from history")
 			fmt.Println("  clear                    - Clear screen")
 			fmt.Println("  help                     - Show this help")
 			fmt.Println("  quit                     - Exit")
@@ -668,6 +1765,32 @@ func runInteractiveMode(client *Client) {
 		case "clear":
 			fmt.Print("\033[2J\033[H") // ANSI escape codes для очистки экрана
 			continue
+
+		case "replay":
+			n, err := strconv.Atoi(strings.Fields(line)[1])
+			if err != nil || n <= 0 {
+				fmt.Println("Usage: replay <n>")
+				continue
+			}
+			runReplay(client, lastCommands(history, n), ids)
+			continue
+
+		case "batch":
+			requests, err := parseBatchCommand(line, ids)
+			if err != nil {
+				fmt.Printf("❌ Error: %v\n", err)
+				continue
+			}
+
+			fmt.Printf("📤 Sending batch of %d requests\n", len(requests))
+			results, unmatched, err := client.SendBatchRequest(requests)
+			if err != nil {
+				printResponse(nil, err)
+			} else {
+				printBatchResults(results, unmatched)
+			}
+			fmt.Println()
+			continue
 		}
 
 		// Отправляем запрос если нужно
@@ -688,23 +1811,200 @@ func runInteractiveMode(client *Client) {
 	}
 }
 
-// runBenchmark запускает бенчмарк
-func runBenchmark(client *Client, requests int, concurrent int) {
-	fmt.Printf("🏃 Running benchmark: %d requests with %d concurrent workers\n", requests, concurrent)
+// benchmarkMethod метод (и параметры), вызываемый бенчмарком
+type benchmarkMethod struct {
+	Method string
+	Params json.RawMessage
+}
+
+// defaultBenchmarkMethods вызываются, если -methods не задан
+var defaultBenchmarkMethods = []benchmarkMethod{{Method: "status"}}
+
+// parseBenchmarkMethods разбирает список методов вида
+// `status,echo:{"message":"hi"},time`. Параметры указываются в JSON после
+// двоеточия; запятые внутри JSON не разделяют элементы списка
+func parseBenchmarkMethods(spec string) ([]benchmarkMethod, error) {
+	var methods []benchmarkMethod
+	for _, item := range splitTopLevel(spec, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, params, hasParams := strings.Cut(item, ":")
+		method := benchmarkMethod{Method: strings.TrimSpace(name)}
+		if method.Method == "" {
+			return nil, fmt.Errorf("missing method name in %q", item)
+		}
+		if hasParams {
+			params = strings.TrimSpace(params)
+			if !json.Valid([]byte(params)) {
+				return nil, fmt.Errorf("invalid params JSON for %s: %s", method.Method, params)
+			}
+			method.Params = json.RawMessage(params)
+		}
+		methods = append(methods, method)
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no methods in %q", spec)
+	}
+	return methods, nil
+}
+
+// splitTopLevel делит строку по разделителю, пропуская разделители внутри
+// JSON объектов, массивов и строк
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// scheduledMethod возвращает метод для запроса с номером i: методы чередуются по кругу
+func scheduledMethod(methods []benchmarkMethod, i int) benchmarkMethod {
+	return methods[i%len(methods)]
+}
+
+// benchmarkSample результат одного запроса бенчмарка
+type benchmarkSample struct {
+	Method   string
+	Duration time.Duration
+	Failed   bool
+}
+
+// methodStats сводная статистика бенчмарка по методу
+type methodStats struct {
+	Method   string
+	Requests int
+	Errors   int
+	Total    time.Duration
+	Min      time.Duration
+	Max      time.Duration
+}
+
+// Avg возвращает среднее время запроса
+func (s methodStats) Avg() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Requests)
+}
+
+// aggregateBenchmark сводит результаты по методам в порядке списка methods
+func aggregateBenchmark(methods []benchmarkMethod, samples []benchmarkSample) []methodStats {
+	index := make(map[string]int)
+	var stats []methodStats
+	for _, method := range methods {
+		if _, exists := index[method.Method]; !exists {
+			index[method.Method] = len(stats)
+			stats = append(stats, methodStats{Method: method.Method})
+		}
+	}
+
+	for _, sample := range samples {
+		i, exists := index[sample.Method]
+		if !exists {
+			index[sample.Method] = len(stats)
+			i = len(stats)
+			stats = append(stats, methodStats{Method: sample.Method})
+		}
+
+		st := &stats[i]
+		st.Requests++
+		if sample.Failed {
+			st.Errors++
+		}
+		st.Total += sample.Duration
+		if st.Requests == 1 || sample.Duration < st.Min {
+			st.Min = sample.Duration
+		}
+		if sample.Duration > st.Max {
+			st.Max = sample.Duration
+		}
+	}
+	return stats
+}
+
+// printBenchmarkReport выводит общие результаты и статистику по методам
+func printBenchmarkReport(w io.Writer, stats []methodStats, duration time.Duration) {
+	var requests, errors int
+	for _, st := range stats {
+		requests += st.Requests
+		errors += st.Errors
+	}
+
+	fmt.Fprintf(w, "📊 Benchmark Results:\n")
+	fmt.Fprintf(w, "   Total requests: %d\n", requests)
+	fmt.Fprintf(w, "   Successful: %d\n", requests-errors)
+	fmt.Fprintf(w, "   Errors: %d\n", errors)
+	fmt.Fprintf(w, "   Duration: %v\n", duration)
+	if duration > 0 {
+		fmt.Fprintf(w, "   Requests/sec: %.2f\n", float64(requests)/duration.Seconds())
+	}
+
+	fmt.Fprintf(w, "   Per method:\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "     %-20s requests=%d errors=%d avg=%v min=%v max=%v\n",
+			st.Method, st.Requests, st.Errors, st.Avg(), st.Min, st.Max)
+	}
+}
+
+// runBenchmark запускает бенчмарк, чередуя методы из списка.
+// Ошибкой считается как сбой транспорта, так и ответ с JSON-RPC ошибкой
+func runBenchmark(client *Client, requests int, concurrent int, methods []benchmarkMethod) {
+	if len(methods) == 0 {
+		methods = defaultBenchmarkMethods
+	}
+	fmt.Printf("🏃 Running benchmark: %d requests with %d concurrent workers, %d methods\n", requests, concurrent, len(methods))
 
 	start := time.Now()
 
 	// Канал для задач
 	jobs := make(chan int, requests)
-	results := make(chan error, requests)
+	results := make(chan benchmarkSample, requests)
 
 	// Запускаем воркеры
 	for w := 0; w < concurrent; w++ {
 		go func() {
-			for range jobs {
-				req := makeRequest("status", nil, time.Now().UnixNano())
-				_, err := client.SendRequest(req)
-				results <- err
+			for i := range jobs {
+				method := scheduledMethod(methods, i)
+				var params interface{}
+				if method.Params != nil {
+					params = method.Params
+				}
+				req := makeRequest(method.Method, params, time.Now().UnixNano())
+
+				requestStart := time.Now()
+				response, err := client.SendRequest(req)
+				results <- benchmarkSample{
+					Method:   method.Method,
+					Duration: time.Since(requestStart),
+					Failed:   err != nil || (response != nil && response.Error != nil),
+				}
 			}
 		}()
 	}
@@ -716,22 +2016,211 @@ func runBenchmark(client *Client, requests int, concurrent int) {
 	close(jobs)
 
 	// Собираем результаты
-	var errors int
+	samples := make([]benchmarkSample, 0, requests)
 	for i := 0; i < requests; i++ {
-		if err := <-results; err != nil {
-			errors++
+		samples = append(samples, <-results)
+	}
+
+	printBenchmarkReport(os.Stdout, aggregateBenchmark(methods, samples), time.Since(start))
+}
+
+// batchResult связывает запрос пакета с полученным на него ответом
+type batchResult struct {
+	Request  *JSONRPCRequest
+	Response *JSONRPCResponse
+}
+
+// readBatchFile читает запросы пакета из NDJSON файла
+func readBatchFile(path string) ([]*JSONRPCRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch file: %w", err)
+	}
+	defer file.Close()
+
+	return parseBatchLines(file)
+}
+
+// parseBatchLines разбирает NDJSON: по одному JSON-RPC запросу в строке.
+// Пустые строки пропускаются, отсутствующая версия дополняется значением "2.0"
+func parseBatchLines(r io.Reader) ([]*JSONRPCRequest, error) {
+	var requests []*JSONRPCRequest
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		req := &JSONRPCRequest{}
+		if err := decodeJSON([]byte(line), req); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", lineNumber, err)
+		}
+		if req.Method == "" {
+			return nil, fmt.Errorf("line %d: method is required", lineNumber)
+		}
+		if req.JSONRPC == "" {
+			req.JSONRPC = "2.0"
+		}
+
+		requests = append(requests, req)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("batch file contains no requests")
+	}
+
+	return requests, nil
+}
+
+// SendBatch отправляет запросы одним JSON-RPC пакетом и возвращает ответы.
+// Если все запросы являются уведомлениями, ответов нет
+func (c *Client) SendBatch(requests []*JSONRPCRequest) ([]*JSONRPCResponse, error) {
+	data, err := json.Marshal(requests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	expectResponse := false
+	for _, req := range requests {
+		if req.ID != nil {
+			expectResponse = true
+			break
+		}
+	}
+
+	body, err := c.roundTrip(data, expectResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBatchResponse(body)
+}
+
+// SendBatchRequest отправляет запросы одним JSON-RPC массивом и сопоставляет
+// ответы с запросами по ID. Для уведомлений ответ не ожидается, поэтому их
+// Response остается nil
+func (c *Client) SendBatchRequest(requests []*JSONRPCRequest) ([]batchResult, []*JSONRPCResponse, error) {
+	responses, err := c.SendBatch(requests)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, unmatched := correlateBatch(requests, responses)
+	return results, unmatched, nil
+}
+
+// parseBatchResponse разбирает ответ на пакет. Сервер может вернуть как массив,
+// так и одиночный объект ошибки, если пакет целиком некорректен
+func parseBatchResponse(body []byte) ([]*JSONRPCResponse, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	if body[0] != '[' {
+		var response JSONRPCResponse
+		if err := decodeJSON(body, &response); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		return []*JSONRPCResponse{&response}, nil
+	}
+
+	var responses []*JSONRPCResponse
+	if err := decodeJSON(body, &responses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+	}
+	return responses, nil
+}
+
+// idKey нормализует ID для сопоставления запросов и ответов
+func idKey(id interface{}) string {
+	data, err := json.Marshal(id)
+	if err != nil {
+		return fmt.Sprintf("%v", id)
+	}
+	return string(data)
+}
+
+// correlateBatch сопоставляет ответы с запросами по ID в порядке запросов.
+// Ответы, которые не удалось сопоставить (например, ошибки с ID null),
+// возвращаются отдельно
+func correlateBatch(requests []*JSONRPCRequest, responses []*JSONRPCResponse) ([]batchResult, []*JSONRPCResponse) {
+	byID := make(map[string]*JSONRPCResponse, len(responses))
+	var unmatched []*JSONRPCResponse
+
+	for _, response := range responses {
+		if response.ID == nil {
+			unmatched = append(unmatched, response)
+			continue
+		}
+		byID[idKey(response.ID)] = response
+	}
+
+	results := make([]batchResult, 0, len(requests))
+	for _, req := range requests {
+		result := batchResult{Request: req}
+		if req.ID != nil {
+			key := idKey(req.ID)
+			result.Response = byID[key]
+			delete(byID, key)
+		}
+		results = append(results, result)
+	}
+
+	for _, response := range byID {
+		unmatched = append(unmatched, response)
+	}
+
+	return results, unmatched
+}
+
+// printBatchResults выводит ответы пакета, сопоставленные с запросами
+func printBatchResults(results []batchResult, unmatched []*JSONRPCResponse) {
+	for i, result := range results {
+		fmt.Printf("[%d] %s ", i+1, result.Request.Method)
+
+		switch {
+		case result.Request.ID == nil:
+			fmt.Printf("✅ Notification sent (no response expected)\n")
+		case result.Response == nil:
+			fmt.Printf("❌ No response received (ID: %v)\n", result.Request.ID)
+		default:
+			printResponse(result.Response, nil)
 		}
 	}
 
-	duration := time.Since(start)
-	rps := float64(requests) / duration.Seconds()
+	for _, response := range unmatched {
+		fmt.Printf("[?] unmatched response ")
+		printResponse(response, nil)
+	}
+}
+
+// runBatchFile отправляет запросы из NDJSON файла одним пакетом
+func runBatchFile(client *Client, path string) error {
+	requests, err := readBatchFile(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📤 Sending batch of %d requests from %s...\n", len(requests), path)
+
+	results, unmatched, err := client.SendBatchRequest(requests)
+	if err != nil {
+		return err
+	}
 
-	fmt.Printf("📊 Benchmark Results:\n")
-	fmt.Printf("   Total requests: %d\n", requests)
-	fmt.Printf("   Successful: %d\n", requests-errors)
-	fmt.Printf("   Errors: %d\n", errors)
-	fmt.Printf("   Duration: %v\n", duration)
-	fmt.Printf("   Requests/sec: %.2f\n", rps)
+	printBatchResults(results, unmatched)
+	return nil
 }
 
 // isFlagSet проверяет, был ли флаг явно установлен
@@ -745,10 +2234,191 @@ func isFlagSet(name string) bool {
 	return found
 }
 
+// defaultPort возвращает порт сервера по умолчанию для протокола.
+// Для Unix сокета порт не используется и равен 0
+func defaultPort(protocol string) int {
+	switch strings.ToLower(protocol) {
+	case "https":
+		return 8443
+	case "ws", "websocket":
+		return 8082
+	case "wss":
+		return 8445
+	case "tcp":
+		return 8081
+	case "tls":
+		return 8444
+	case "unix":
+		// Unix сокет адресуется путем в -host, порт не используется
+		return 0
+	default:
+		return 8080
+	}
+}
+
+// diffTarget протокол (и порт), по которому режим diff отправляет запрос
+type diffTarget struct {
+	Protocol string
+	Port     int
+}
+
+// parseDiffTargets разбирает список протоколов вида "http,ws:9000,tcp".
+// Если порт не указан, используется порт протокола по умолчанию
+func parseDiffTargets(spec string) ([]diffTarget, error) {
+	var targets []diffTarget
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		protocol, portStr, hasPort := strings.Cut(item, ":")
+		target := diffTarget{Protocol: strings.ToLower(protocol), Port: defaultPort(protocol)}
+		if hasPort {
+			port, err := strconv.Atoi(portStr)
+			if err != nil || port <= 0 {
+				return nil, fmt.Errorf("invalid port in %q", item)
+			}
+			target.Port = port
+		}
+		targets = append(targets, target)
+	}
+
+	if len(targets) < 2 {
+		return nil, fmt.Errorf("diff mode requires at least two protocols, got %q", spec)
+	}
+	return targets, nil
+}
+
+// protocolResult ответ (или ошибка), полученный по одному протоколу
+type protocolResult struct {
+	Protocol string
+	Response *JSONRPCResponse
+	Err      error
+}
+
+// sendAcrossProtocols отправляет один и тот же запрос по каждому протоколу.
+// Настройки протоколов https, wss и tls включают TLS, остальные его отключают
+func sendAcrossProtocols(base ClientConfig, targets []diffTarget, req *JSONRPCRequest) []protocolResult {
+	results := make([]protocolResult, 0, len(targets))
+	for _, target := range targets {
+		config := base
+		config.Protocol = target.Protocol
+		config.Port = target.Port
+		switch target.Protocol {
+		case "https", "wss", "tls":
+			config.TLS = true
+		default:
+			config.TLS = false
+		}
+
+		response, err := NewClient(config).SendRequest(req)
+		results = append(results, protocolResult{Protocol: target.Protocol, Response: response, Err: err})
+	}
+	return results
+}
+
+// describeResult возвращает сравнимое представление ответа: result, error и id
+// сериализуются в JSON, ошибка транспорта сохраняется текстом
+func describeResult(result protocolResult) map[string]string {
+	fields := make(map[string]string, 3)
+	if result.Err != nil {
+		fields["transport error"] = result.Err.Error()
+		return fields
+	}
+	if result.Response == nil {
+		fields["response"] = "<none>"
+		return fields
+	}
+
+	marshal := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+	fields["result"] = marshal(result.Response.Result)
+	fields["error"] = marshal(result.Response.Error)
+	fields["id"] = marshal(result.Response.ID)
+	return fields
+}
+
+// diffResults сравнивает ответы с ответом первого протокола и возвращает
+// строки различий в формате unified diff. Пустой результат означает, что
+// все протоколы ответили одинаково
+func diffResults(results []protocolResult) []string {
+	if len(results) < 2 {
+		return nil
+	}
+
+	baseline := results[0]
+	baseFields := describeResult(baseline)
+
+	var lines []string
+	for _, other := range results[1:] {
+		otherFields := describeResult(other)
+
+		keys := make(map[string]struct{}, len(baseFields)+len(otherFields))
+		for key := range baseFields {
+			keys[key] = struct{}{}
+		}
+		for key := range otherFields {
+			keys[key] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			want, hasWant := baseFields[key]
+			got, hasGot := otherFields[key]
+			if hasWant == hasGot && want == got {
+				continue
+			}
+			if hasWant {
+				lines = append(lines, fmt.Sprintf("- %s %s: %s", baseline.Protocol, key, want))
+			}
+			if hasGot {
+				lines = append(lines, fmt.Sprintf("+ %s %s: %s", other.Protocol, key, got))
+			}
+		}
+	}
+	return lines
+}
+
+// runDiff отправляет запрос по всем протоколам, выводит ответы и различия.
+// Возвращает false, если ответы расходятся
+func runDiff(base ClientConfig, targets []diffTarget, req *JSONRPCRequest) bool {
+	results := sendAcrossProtocols(base, targets, req)
+	for _, result := range results {
+		fmt.Printf("[%s] ", result.Protocol)
+		if result.Err == nil && result.Response == nil {
+			fmt.Println("✅ Notification sent (no response expected)")
+			continue
+		}
+		printResponse(result.Response, result.Err)
+	}
+
+	lines := diffResults(results)
+	if len(lines) == 0 {
+		fmt.Printf("✅ All %d protocols returned identical responses\n", len(results))
+		return true
+	}
+
+	fmt.Println("❌ Responses differ:")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return false
+}
+
 func main() {
 	var (
-		protocol    = flag.String("protocol", "http", "Protocol to use (http, https, ws, wss, tcp, tls)")
-		host        = flag.String("host", "localhost", "Server host")
+		protocol    = flag.String("protocol", "http", "Protocol to use (http, https, ws, wss, tcp, tls, unix)")
+		host        = flag.String("host", "localhost", "Server host (socket path for unix)")
 		port        = flag.Int("port", 8080, "Server port")
 		useTLS      = flag.Bool("tls", false, "Use TLS/SSL")
 		timeout     = flag.Duration("timeout", 30*time.Second, "Request timeout")
@@ -759,24 +2429,26 @@ func main() {
 		benchmark   = flag.Bool("benchmark", false, "Run benchmark")
 		requests    = flag.Int("requests", 1000, "Number of requests for benchmark")
 		concurrent  = flag.Int("concurrent", 10, "Number of concurrent workers for benchmark")
+		methodsSpec = flag.String("methods", "", `Comma-separated methods for benchmark with optional JSON params, e.g. status,echo:{"message":"hi"}`)
 		debug       = flag.Bool("debug", false, "Enable debug mode")
+		historySize = flag.Int("history-size", defaultHistorySize, "Maximum number of commands kept in interactive history")
+		historyDup  = flag.String("history-dedup", string(HistoryDedupConsecutive), "History dedup policy: consecutive or all (move repeated commands to the end)")
+		startID     = flag.Int("start-id", 1, "First request ID in interactive mode")
+		uuidIDs     = flag.Bool("uuid-ids", false, "Use random UUIDs as request IDs in interactive mode")
+		batchFile   = flag.String("batch-file", "", "Send newline-delimited JSON-RPC requests from file as one batch")
+		replayFile  = flag.String("replay-file", "", "Run interactive mode commands from file, one per line, in order")
+		output      = flag.String("output", string(OutputPretty), "Output format for single requests and batches: pretty, json or ndjson")
+		ndjson      = flag.Bool("ndjson", false, "Print every response as one compact JSON line (same as -output ndjson)")
+		diffSpec    = flag.String("diff", "", "Send the request over several protocols and diff the responses, e.g. http,ws,tcp:9000")
+		wsRetries   = flag.Int("ws-max-retries", defaultWSMaxRetries, "Maximum WebSocket reconnect attempts per request in interactive mode")
+		wsBackoff   = flag.Duration("ws-backoff", defaultWSBackoff, "Initial WebSocket reconnect backoff, doubled on every attempt")
+		tcpPoolSize = flag.Int("tcp-pool-size", 0, "Persistent TCP/TLS/Unix connections reused between requests (0 - new connection per request, benchmark uses -concurrent)")
 	)
 	flag.Parse()
 
 	// Определяем порт по умолчанию для протокола
 	if *port == 8080 {
-		switch strings.ToLower(*protocol) {
-		case "https":
-			*port = 8443
-		case "ws", "websocket":
-			*port = 8082
-		case "wss":
-			*port = 8445
-		case "tcp":
-			*port = 8081
-		case "tls":
-			*port = 8444
-		}
+		*port = defaultPort(*protocol)
 	}
 
 	config := ClientConfig{
@@ -786,14 +2458,88 @@ func main() {
 		TLS:      *useTLS,
 		Timeout:  *timeout,
 		Debug:    *debug,
+
+		HistorySize:  *historySize,
+		HistoryDedup: HistoryDedupPolicy(*historyDup),
+
+		StartID: *startID,
+		UUIDIDs: *uuidIDs,
+
+		WSMaxRetries: *wsRetries,
+		WSBackoff:    *wsBackoff,
+
+		TCPPoolSize: *tcpPoolSize,
+	}
+
+	// Бенчмарк измеряет пропускную способность на постоянных соединениях:
+	// каждый воркер держит свое соединение из пула
+	if *benchmark && config.TCPPoolSize == 0 {
+		config.TCPPoolSize = *concurrent
+	}
+
+	if config.HistoryDedup != HistoryDedupConsecutive && config.HistoryDedup != HistoryDedupAll {
+		fmt.Printf("❌ Invalid history dedup policy: %s (use consecutive or all)\n", config.HistoryDedup)
+		os.Exit(1)
+	}
+
+	outputFormat := OutputFormat(*output)
+	if *ndjson {
+		outputFormat = OutputNDJSON
+	}
+	if outputFormat != OutputPretty && outputFormat != OutputJSON && outputFormat != OutputNDJSON {
+		fmt.Printf("❌ Invalid output format: %s (use pretty, json or ndjson)\n", outputFormat)
+		os.Exit(1)
 	}
+	jsonOutput := outputFormat != OutputPretty
 
 	client := NewClient(config)
 
-	fmt.Printf("🔗 Connecting to %s://%s:%d\n", *protocol, *host, *port)
+	if !jsonOutput {
+		fmt.Printf("🔗 Connecting to %s://%s\n", *protocol, client.address())
+	}
 
 	if *benchmark {
-		runBenchmark(client, *requests, *concurrent)
+		methods := defaultBenchmarkMethods
+		if *methodsSpec != "" {
+			var err error
+			if methods, err = parseBenchmarkMethods(*methodsSpec); err != nil {
+				fmt.Printf("❌ Invalid -methods: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		runBenchmark(client, *requests, *concurrent, methods)
+		client.Close()
+		return
+	}
+
+	if *batchFile != "" && jsonOutput {
+		requests, err := readBatchFile(*batchFile)
+		var responses []*JSONRPCResponse
+		if err == nil {
+			responses, err = client.SendBatch(requests)
+		}
+		if outputFormat == OutputNDJSON {
+			os.Exit(writeNDJSONOutput(os.Stdout, os.Stderr, responses, err))
+		}
+		os.Exit(writeJSONOutput(os.Stdout, os.Stderr, responses, true, err))
+	}
+
+	if *batchFile != "" {
+		if err := runBatchFile(client, *batchFile); err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *replayFile != "" {
+		commands, err := readReplayFile(*replayFile)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer client.Close()
+		runReplay(client, commands, newRequestIDSequence(*startID, *uuidIDs))
 		return
 	}
 
@@ -821,12 +2567,29 @@ func main() {
 		fmt.Println("  # Send notification (no response)")
 		fmt.Println("  go run cmd/client/main.go -method echo -params '{\"message\":\"Hello\"}' -id \"\" -interactive=false")
 		fmt.Println("")
+		fmt.Println("  # Send a batch from a newline-delimited JSON file")
+		fmt.Println("  go run cmd/client/main.go -batch-file requests.ndjson")
+		fmt.Println("")
+		fmt.Println("  # Replay interactive commands from a file")
+		fmt.Println("  go run cmd/client/main.go -replay-file session.txt")
+		fmt.Println("")
 		fmt.Println("  # Benchmark")
 		fmt.Println("  go run cmd/client/main.go -benchmark -requests 1000 -concurrent 10")
+		fmt.Println("  go run cmd/client/main.go -protocol tcp -benchmark -concurrent 10 -tcp-pool-size 10")
 		fmt.Println("")
 		fmt.Println("  # Different protocols")
 		fmt.Println("  go run cmd/client/main.go -protocol ws -method status -interactive=false")
 		fmt.Println("  go run cmd/client/main.go -protocol tcp -method status -interactive=false")
+		fmt.Println("  go run cmd/client/main.go -protocol unix -host /tmp/rpc.sock -method status -interactive=false")
+		fmt.Println("")
+		fmt.Println("  # Machine-readable output (non-zero exit code on error)")
+		fmt.Println("  go run cmd/client/main.go -method status -output json -interactive=false")
+		fmt.Println("")
+		fmt.Println("  # One JSON line per response, for jq and other line-based tools")
+		fmt.Println("  go run cmd/client/main.go -batch-file requests.ndjson -ndjson | jq -c 'select(.error)'")
+		fmt.Println("")
+		fmt.Println("  # Compare responses across protocols")
+		fmt.Println("  go run cmd/client/main.go -method status -diff http,ws,tcp -interactive=false")
 		os.Exit(1)
 	}
 
@@ -853,6 +2616,31 @@ func main() {
 
 	req := makeRequest(*method, parsedParams, requestID)
 
+	if *diffSpec != "" {
+		targets, err := parseDiffTargets(*diffSpec)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📤 Sending %s request over %d protocols...\n", *method, len(targets))
+		if !runDiff(config, targets, req) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if jsonOutput {
+		response, err := client.SendRequest(req)
+		var responses []*JSONRPCResponse
+		if response != nil {
+			responses = append(responses, response)
+		}
+		if outputFormat == OutputNDJSON {
+			os.Exit(writeNDJSONOutput(os.Stdout, os.Stderr, responses, err))
+		}
+		os.Exit(writeJSONOutput(os.Stdout, os.Stderr, responses, false, err))
+	}
+
 	fmt.Printf("📤 Sending %s request...\n", *method)
 	response, err := client.SendRequest(req)
 	printResponse(response, err)
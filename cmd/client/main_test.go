@@ -0,0 +1,1512 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestHTTPClient creates a client pointed at the given test server
+func newTestHTTPClient(t *testing.T, serverURL string) *Client {
+	host, portStr, err := net.SplitHostPort(strings.TrimPrefix(serverURL, "http://"))
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+
+	return NewClient(ClientConfig{
+		Protocol: "http",
+		Host:     host,
+		Port:     port,
+		Timeout:  5 * time.Second,
+	})
+}
+
+func TestParseBatchLines(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":1}
+
+{"method":"status","id":"two"}
+{"jsonrpc":"2.0","method":"log","params":["fire and forget"]}
+`
+	requests, err := parseBatchLines(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+
+	assert.Equal(t, "echo", requests[0].Method)
+	assert.Equal(t, json.Number("1"), requests[0].ID)
+
+	assert.Equal(t, "2.0", requests[1].JSONRPC, "missing version defaults to 2.0")
+	assert.Equal(t, "two", requests[1].ID)
+
+	assert.Equal(t, "log", requests[2].Method)
+	assert.Nil(t, requests[2].ID, "lines without id are notifications")
+}
+
+func TestParseBatchLines_Errors(t *testing.T) {
+	_, err := parseBatchLines(strings.NewReader(`{"method":"echo","id":1}` + "\n" + `{not json}`))
+	assert.ErrorContains(t, err, "line 2")
+
+	_, err = parseBatchLines(strings.NewReader(`{"id":1}`))
+	assert.ErrorContains(t, err, "method is required")
+
+	_, err = parseBatchLines(strings.NewReader("\n\n"))
+	assert.ErrorContains(t, err, "no requests")
+}
+
+func TestCorrelateBatch(t *testing.T) {
+	requests := []*JSONRPCRequest{
+		makeRequest("echo", nil, float64(1)),
+		makeRequest("log", nil, nil),
+		makeRequest("status", nil, "two"),
+		makeRequest("time", nil, float64(3)),
+	}
+
+	// Responses arrive out of order, one is missing and one cannot be matched
+	responses := []*JSONRPCResponse{
+		{JSONRPC: "2.0", Result: "status", ID: "two"},
+		{JSONRPC: "2.0", Result: "echo", ID: float64(1)},
+		{JSONRPC: "2.0", Error: &JSONRPCError{Code: -32600, Message: "Invalid Request"}, ID: nil},
+	}
+
+	results, unmatched := correlateBatch(requests, responses)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, "echo", results[0].Response.Result)
+	assert.Nil(t, results[1].Response, "notifications have no response")
+	assert.Equal(t, "status", results[2].Response.Result)
+	assert.Nil(t, results[3].Response, "missing responses are reported as nil")
+
+	require.Len(t, unmatched, 1)
+	assert.Equal(t, -32600, unmatched[0].Error.Code)
+}
+
+func TestParseBatchResponse(t *testing.T) {
+	responses, err := parseBatchResponse([]byte(`[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","result":2,"id":2}]`))
+	require.NoError(t, err)
+	assert.Len(t, responses, 2)
+
+	// Whole-batch errors come back as a single object
+	responses, err = parseBatchResponse([]byte(`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`))
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, -32700, responses[0].Error.Code)
+
+	responses, err = parseBatchResponse(nil)
+	require.NoError(t, err)
+	assert.Nil(t, responses)
+}
+
+func TestClient_SendBatch_HTTP(t *testing.T) {
+	var received []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &received))
+
+		var responses []map[string]interface{}
+		for _, req := range received {
+			if id, ok := req["id"]; ok {
+				responses = append(responses, map[string]interface{}{"jsonrpc": "2.0", "result": req["method"], "id": id})
+			}
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer server.Close()
+
+	client := newTestHTTPClient(t, server.URL)
+
+	requests := []*JSONRPCRequest{
+		makeRequest("echo", map[string]interface{}{"message": "hi"}, float64(1)),
+		makeRequest("log", nil, nil),
+		makeRequest("status", nil, float64(2)),
+	}
+
+	responses, err := client.SendBatch(requests)
+	require.NoError(t, err)
+
+	assert.Len(t, received, 3, "the whole batch is sent in one request")
+	require.Len(t, responses, 2)
+
+	results, unmatched := correlateBatch(requests, responses)
+	assert.Empty(t, unmatched)
+	assert.Equal(t, "echo", results[0].Response.Result)
+	assert.Nil(t, results[1].Response)
+	assert.Equal(t, "status", results[2].Response.Result)
+}
+
+func TestHistoryManager_LoadValidHistory(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history")
+	require.NoError(t, os.WriteFile(historyFile, []byte("echo hello\n\nstatus\ncalc 1 + 2\n"), 0600))
+
+	hm := newHistoryManagerWithFile(historyFile, 0, HistoryDedupConsecutive)
+
+	assert.Equal(t, []string{"echo hello", "status", "calc 1 + 2"}, hm.getCommands())
+	_, err := os.Stat(historyFile + ".corrupt")
+	assert.True(t, os.IsNotExist(err), "valid history must not be backed up")
+}
+
+func TestHistoryManager_LoadCorruptHistory(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history")
+	corrupt := []byte("echo hello\n\x00\x01\x02binary\nstatus\n\xff\xfe invalid utf8\ntime\n")
+	require.NoError(t, os.WriteFile(historyFile, corrupt, 0600))
+
+	hm := &HistoryManager{historyFile: historyFile, maxSize: 1000}
+	err := hm.loadHistory()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 invalid entries")
+
+	// Valid entries are still loaded
+	assert.Equal(t, []string{"echo hello", "status", "time"}, hm.getCommands())
+
+	// Original file is backed up unchanged
+	backup, err := os.ReadFile(historyFile + ".corrupt")
+	require.NoError(t, err)
+	assert.Equal(t, corrupt, backup)
+
+	// History file is reset to the valid entries
+	reset, err := os.ReadFile(historyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "echo hello\nstatus\ntime\n", string(reset))
+
+	// A second load of the recovered file is clean
+	hm = &HistoryManager{historyFile: historyFile, maxSize: 1000}
+	assert.NoError(t, hm.loadHistory())
+	assert.Len(t, hm.getCommands(), 3)
+}
+
+func TestHistoryManager_LoadMissingHistory(t *testing.T) {
+	hm := &HistoryManager{historyFile: filepath.Join(t.TempDir(), "missing"), maxSize: 1000}
+	assert.NoError(t, hm.loadHistory())
+	assert.Empty(t, hm.getCommands())
+}
+
+func TestHistoryManager_SizeTrimming(t *testing.T) {
+	hm := newHistoryManagerWithFile(filepath.Join(t.TempDir(), "history"), 3, HistoryDedupConsecutive)
+
+	for _, cmd := range []string{"one", "two", "three", "four", "five"} {
+		hm.addCommand(cmd)
+	}
+	assert.Equal(t, []string{"three", "four", "five"}, hm.getCommands())
+
+	// Oversized history files are trimmed on load as well
+	require.NoError(t, os.WriteFile(hm.historyFile, []byte("a\nb\nc\nd\n"), 0600))
+	hm = newHistoryManagerWithFile(hm.historyFile, 2, HistoryDedupConsecutive)
+	assert.Equal(t, []string{"c", "d"}, hm.getCommands())
+}
+
+func TestHistoryManager_DefaultSize(t *testing.T) {
+	hm := newHistoryManagerWithFile(filepath.Join(t.TempDir(), "history"), 0, HistoryDedupConsecutive)
+	assert.Equal(t, defaultHistorySize, hm.maxSize)
+}
+
+func TestHistoryManager_DedupPolicies(t *testing.T) {
+	commands := []string{"status", "echo hi", "echo hi", "time", "status"}
+
+	t.Run("consecutive", func(t *testing.T) {
+		hm := newHistoryManagerWithFile(filepath.Join(t.TempDir(), "history"), 10, HistoryDedupConsecutive)
+		for _, cmd := range commands {
+			hm.addCommand(cmd)
+		}
+		assert.Equal(t, []string{"status", "echo hi", "time", "status"}, hm.getCommands())
+	})
+
+	t.Run("all", func(t *testing.T) {
+		hm := newHistoryManagerWithFile(filepath.Join(t.TempDir(), "history"), 10, HistoryDedupAll)
+		for _, cmd := range commands {
+			hm.addCommand(cmd)
+		}
+		assert.Equal(t, []string{"echo hi", "time", "status"}, hm.getCommands())
+	})
+}
+
+// Run with -race: history operations are called from several goroutines
+func TestHistoryManager_ConcurrentAccess(t *testing.T) {
+	hm := newHistoryManagerWithFile(filepath.Join(t.TempDir(), "history"), 1000, HistoryDedupAll)
+
+	const workers, perWorker = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				hm.addCommand(fmt.Sprintf("echo %d-%d", w, i))
+				if i%10 == 0 {
+					assert.NoError(t, hm.saveHistory())
+					hm.getCommands()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Len(t, hm.getCommands(), workers*perWorker)
+
+	require.NoError(t, hm.saveHistory())
+	reloaded := newHistoryManagerWithFile(hm.historyFile, 1000, HistoryDedupAll)
+	assert.ElementsMatch(t, hm.getCommands(), reloaded.getCommands())
+}
+
+// captureStdout returns everything written to stdout while fn runs
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	require.NoError(t, w.Close())
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, r)
+	require.NoError(t, err)
+	return buf.String()
+}
+
+func TestClient_SendRequest_LargeIntegerID(t *testing.T) {
+	const largeID = "12345678901234567890123"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"jsonrpc":"2.0","result":{"big":98765432109876543210},"id":`+largeID+`}`)
+	}))
+	defer server.Close()
+
+	client := newTestHTTPClient(t, server.URL)
+	response, err := client.SendRequest(makeRequest("echo", nil, json.Number(largeID)))
+	require.NoError(t, err)
+	assert.Equal(t, json.Number(largeID), response.ID)
+
+	output := captureStdout(t, func() { printResponse(response, nil) })
+	assert.Contains(t, output, "(ID: "+largeID+")")
+	assert.Contains(t, output, "98765432109876543210")
+	assert.NotContains(t, output, "e+")
+}
+
+func TestParseBatchResponse_LargeIntegerIDs(t *testing.T) {
+	requests, err := parseBatchLines(strings.NewReader(
+		`{"method":"echo","id":9007199254740993}` + "\n" + `{"method":"echo","id":9007199254740992}`))
+	require.NoError(t, err)
+
+	// Both IDs collapse to the same float64, so they must be kept exact to correlate
+	responses, err := parseBatchResponse([]byte(
+		`[{"jsonrpc":"2.0","result":"b","id":9007199254740992},{"jsonrpc":"2.0","result":"a","id":9007199254740993}]`))
+	require.NoError(t, err)
+
+	results, unmatched := correlateBatch(requests, responses)
+	assert.Empty(t, unmatched)
+	assert.Equal(t, "a", results[0].Response.Result)
+	assert.Equal(t, "b", results[1].Response.Result)
+
+	output := captureStdout(t, func() { printBatchResults(results, unmatched) })
+	assert.Contains(t, output, "9007199254740993")
+	assert.Contains(t, output, "9007199254740992")
+}
+
+func TestDecodeJSON_RejectsTrailingData(t *testing.T) {
+	var v interface{}
+	assert.Error(t, decodeJSON([]byte(`{"id":1} {"id":2}`), &v))
+	assert.NoError(t, decodeJSON([]byte(" {\"id\":1}\n"), &v))
+}
+
+// newStreamServer starts a WebSocket server that answers every request with the given frames
+// and then closes the connection normally
+func newStreamServer(t *testing.T, frames func(id json.RawMessage) []string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		for _, frame := range frames(req.ID) {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				return
+			}
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "stream end"))
+		conn.ReadMessage()
+	}))
+}
+
+// collectStream drains a stream, failing the test if it does not end in time
+func collectStream(t *testing.T, stream <-chan StreamResponse) []StreamResponse {
+	var items []StreamResponse
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case item, ok := <-stream:
+			if !ok {
+				return items
+			}
+			items = append(items, item)
+		case <-timeout:
+			t.Fatal("stream did not end")
+		}
+	}
+}
+
+func TestClient_SendStreamingRequest(t *testing.T) {
+	server := newStreamServer(t, func(id json.RawMessage) []string {
+		return []string{
+			`{"jsonrpc":"2.0","result":1,"id":` + string(id) + `}`,
+			`{"jsonrpc":"2.0","method":"server.banner","params":{}}`,
+			`{"jsonrpc":"2.0","result":2,"id":` + string(id) + `}`,
+			`{"jsonrpc":"2.0","result":"other","id":999}`,
+			`{"jsonrpc":"2.0","result":3,"id":` + string(id) + `}`,
+		}
+	})
+	defer server.Close()
+
+	client := newTestHTTPClient(t, server.URL)
+	client.config.Protocol = "ws"
+
+	stream, err := client.SendStreamingRequest(context.Background(), makeRequest("count", nil, 42))
+	require.NoError(t, err)
+
+	items := collectStream(t, stream)
+	require.Len(t, items, 3, "frames for other IDs are skipped")
+	for i, item := range items {
+		require.NoError(t, item.Err)
+		assert.Equal(t, json.Number(strconv.Itoa(i+1)), item.Response.Result)
+		assert.Equal(t, json.Number("42"), item.Response.ID)
+	}
+}
+
+func TestClient_SendStreamingRequest_ErrorEndsStream(t *testing.T) {
+	server := newStreamServer(t, func(id json.RawMessage) []string {
+		return []string{
+			`{"jsonrpc":"2.0","result":1,"id":` + string(id) + `}`,
+			`{"jsonrpc":"2.0","error":{"code":-32603,"message":"boom"},"id":` + string(id) + `}`,
+			`{"jsonrpc":"2.0","result":2,"id":` + string(id) + `}`,
+		}
+	})
+	defer server.Close()
+
+	client := newTestHTTPClient(t, server.URL)
+	client.config.Protocol = "ws"
+
+	stream, err := client.SendStreamingRequest(context.Background(), makeRequest("count", nil, "s-1"))
+	require.NoError(t, err)
+
+	items := collectStream(t, stream)
+	require.Len(t, items, 2)
+	assert.Equal(t, json.Number("1"), items[0].Response.Result)
+	require.NotNil(t, items[1].Response.Error)
+	assert.Equal(t, -32603, items[1].Response.Error.Code)
+}
+
+func TestClient_SendStreamingRequest_Cancel(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Never answer; wait for the client to go away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := newTestHTTPClient(t, server.URL)
+	client.config.Protocol = "ws"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.SendStreamingRequest(ctx, makeRequest("count", nil, 1))
+	require.NoError(t, err)
+
+	cancel()
+	assert.Empty(t, collectStream(t, stream), "cancellation ends the stream without an error")
+}
+
+func TestClient_SendStreamingRequest_Validation(t *testing.T) {
+	client := NewClient(ClientConfig{Protocol: "http", Host: "localhost", Port: 1})
+	_, err := client.SendStreamingRequest(context.Background(), makeRequest("count", nil, 1))
+	assert.ErrorContains(t, err, "websocket")
+
+	client = NewClient(ClientConfig{Protocol: "ws", Host: "localhost", Port: 1})
+	_, err = client.SendStreamingRequest(context.Background(), makeRequest("count", nil, nil))
+	assert.ErrorContains(t, err, "id")
+}
+
+func TestClient_SendRequest_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rpc.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				line, err := reader.ReadBytes('\n')
+				if err != nil {
+					return
+				}
+				received <- string(line)
+
+				var req JSONRPCRequest
+				if json.Unmarshal(line, &req) != nil || req.ID == nil {
+					return
+				}
+				response, _ := json.Marshal(JSONRPCResponse{JSONRPC: "2.0", Result: req.Params, ID: req.ID})
+				conn.Write(append(response, '\n'))
+			}(conn)
+		}
+	}()
+
+	client := NewClient(ClientConfig{Protocol: "unix", Host: path, Timeout: 5 * time.Second})
+	assert.Equal(t, path, client.address())
+
+	response, err := client.SendRequest(makeRequest("echo", map[string]interface{}{"message": "local"}, 1))
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, map[string]interface{}{"message": "local"}, response.Result)
+	assert.Equal(t, json.Number("1"), response.ID)
+	assert.True(t, strings.HasSuffix(<-received, "\n"), "requests are newline framed")
+
+	// Notifications do not wait for a response
+	response, err = client.SendRequest(makeRequest("echo", nil, nil))
+	require.NoError(t, err)
+	assert.Nil(t, response)
+	assert.Contains(t, <-received, `"method":"echo"`)
+}
+
+// persistentTCPServer echoes newline-framed requests on every accepted
+// connection until the client closes it, and counts accepted connections
+type persistentTCPServer struct {
+	listener net.Listener
+	accepted int32
+	requests int32 // requests read so far
+	hangUp   int32 // when set, the connection is closed instead of answering
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+func newPersistentTCPServer(t *testing.T) *persistentTCPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &persistentTCPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.accepted, 1)
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *persistentTCPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.requests, 1)
+		if atomic.LoadInt32(&s.hangUp) == 1 {
+			return
+		}
+		var req JSONRPCRequest
+		if json.Unmarshal(line, &req) != nil || req.ID == nil {
+			continue
+		}
+		response, _ := json.Marshal(JSONRPCResponse{JSONRPC: "2.0", Result: req.Params, ID: req.ID})
+		if _, err := conn.Write(append(response, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+// dropConnections closes every accepted connection on the server side
+func (s *persistentTCPServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func newPooledTCPClient(t *testing.T, s *persistentTCPServer) *Client {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	client := NewClient(ClientConfig{Protocol: "tcp", Host: "127.0.0.1", Port: addr.Port, Timeout: 5 * time.Second, TCPPoolSize: 2})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestClient_TCPPool_ReusesConnection(t *testing.T) {
+	server := newPersistentTCPServer(t)
+	client := newPooledTCPClient(t, server)
+
+	for i := 1; i <= 5; i++ {
+		message := fmt.Sprintf("request %d", i)
+		response, err := client.SendRequest(makeRequest("echo", message, i))
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, message, response.Result)
+		assert.Equal(t, json.Number(strconv.Itoa(i)), response.ID)
+	}
+
+	// A notification goes over the same connection and the next response still matches
+	response, err := client.SendRequest(makeRequest("echo", nil, nil))
+	require.NoError(t, err)
+	assert.Nil(t, response)
+	response, err = client.SendRequest(makeRequest("echo", "after", 6))
+	require.NoError(t, err)
+	assert.Equal(t, "after", response.Result)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.accepted), "sequential requests share one connection")
+}
+
+func TestClient_TCPPool_ReconnectsAfterError(t *testing.T) {
+	server := newPersistentTCPServer(t)
+	client := newPooledTCPClient(t, server)
+
+	_, err := client.SendRequest(makeRequest("echo", "first", 1))
+	require.NoError(t, err)
+
+	server.dropConnections()
+	// Wait until the close reaches the idle pooled connection
+	require.Eventually(t, func() bool {
+		pc := <-client.tcpPool.idle
+		defer func() { client.tcpPool.idle <- pc }()
+		return pc.stale()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	response, err := client.SendRequest(makeRequest("echo", "second", 2))
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, "second", response.Result)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.accepted), "a dropped pooled connection is replaced")
+}
+
+func TestClient_TCPPool_NoResendAfterSend(t *testing.T) {
+	server := newPersistentTCPServer(t)
+	client := newPooledTCPClient(t, server)
+
+	_, err := client.SendRequest(makeRequest("echo", "first", 1))
+	require.NoError(t, err)
+
+	// The pooled connection takes the request and fails before answering
+	atomic.StoreInt32(&server.hangUp, 1)
+	_, err = client.SendRequest(makeRequest("echo", "second", 2))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "failed to read response")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.requests), "a request the server may have run is not sent again")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.accepted))
+}
+
+func TestClient_TCPPool_Disabled(t *testing.T) {
+	server := newPersistentTCPServer(t)
+	addr := server.listener.Addr().(*net.TCPAddr)
+	client := NewClient(ClientConfig{Protocol: "tcp", Host: "127.0.0.1", Port: addr.Port, Timeout: 5 * time.Second})
+
+	for i := 1; i <= 3; i++ {
+		_, err := client.SendRequest(makeRequest("echo", nil, i))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&server.accepted))
+}
+
+// restartableWSServer is an echo WebSocket server that can be stopped and
+// started again on the same address, dropping every open connection
+type restartableWSServer struct {
+	t      *testing.T
+	addr   string
+	server *http.Server
+	mu     sync.Mutex
+	conns  []*websocket.Conn
+	dials  int32
+}
+
+func newRestartableWSServer(t *testing.T) *restartableWSServer {
+	s := &restartableWSServer{t: t, addr: "127.0.0.1:0"}
+	s.start()
+	t.Cleanup(s.stop)
+	return s
+}
+
+func (s *restartableWSServer) start() {
+	listener, err := net.Listen("tcp", s.addr)
+	require.NoError(s.t, err)
+	s.addr = listener.Addr().String()
+
+	upgrader := websocket.Upgrader{}
+	s.server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.dials, 1)
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		for {
+			var req JSONRPCRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.ID == nil {
+				continue
+			}
+			if err := conn.WriteJSON(JSONRPCResponse{JSONRPC: "2.0", Result: req.Params, ID: req.ID}); err != nil {
+				return
+			}
+		}
+	})}
+	go s.server.Serve(listener)
+}
+
+func (s *restartableWSServer) stop() {
+	s.server.Close()
+	s.mu.Lock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.mu.Unlock()
+}
+
+func (s *restartableWSServer) client(t *testing.T) *Client {
+	host, portStr, err := net.SplitHostPort(s.addr)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+
+	return NewClient(ClientConfig{
+		Protocol:            "ws",
+		Host:                host,
+		Port:                port,
+		Timeout:             2 * time.Second,
+		PersistentWebSocket: true,
+		WSMaxRetries:        5,
+		WSBackoff:           10 * time.Millisecond,
+	})
+}
+
+func TestClient_PersistentWebSocket_Reconnects(t *testing.T) {
+	server := newRestartableWSServer(t)
+	client := server.client(t)
+	defer client.Close()
+
+	for i := 1; i <= 3; i++ {
+		response, err := client.SendRequest(makeRequest("echo", map[string]interface{}{"n": i}, i))
+		require.NoError(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, json.Number(strconv.Itoa(i)), response.ID)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.dials), "requests share one connection")
+
+	// Restart the server mid-session: the open connection is dropped
+	session := client.ws
+	server.stop()
+	server.start()
+	select {
+	case <-session.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not notice the dropped connection")
+	}
+
+	response, err := client.SendRequest(makeRequest("echo", map[string]interface{}{"n": 4}, 4))
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, json.Number("4"), response.ID)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.dials), "client reconnected once")
+
+	response, err = client.SendRequest(makeRequest("echo", nil, 5))
+	require.NoError(t, err)
+	assert.Equal(t, json.Number("5"), response.ID)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.dials))
+}
+
+func TestClient_PersistentWebSocket_GivesUp(t *testing.T) {
+	server := newRestartableWSServer(t)
+	client := server.client(t)
+	client.config.WSMaxRetries = 2
+	defer client.Close()
+
+	_, err := client.SendRequest(makeRequest("echo", nil, 1))
+	require.NoError(t, err)
+
+	session := client.ws
+	server.stop()
+	<-session.done
+
+	_, err = client.SendRequest(makeRequest("echo", nil, 2))
+	assert.ErrorContains(t, err, "after 2 reconnect attempts")
+}
+
+func TestClient_PersistentWebSocket_NoResendAfterSend(t *testing.T) {
+	// The server reads each request and drops the connection without answering
+	var received int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, _, err := conn.ReadMessage(); err == nil {
+			atomic.AddInt32(&received, 1)
+		}
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	client := NewClient(ClientConfig{
+		Protocol:            "ws",
+		Host:                "127.0.0.1",
+		Port:                addr.Port,
+		Timeout:             2 * time.Second,
+		PersistentWebSocket: true,
+		WSMaxRetries:        3,
+		WSBackoff:           10 * time.Millisecond,
+	})
+	defer client.Close()
+
+	_, err := client.SendRequest(makeRequest("transfer", nil, 1))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "failed to read response")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&received), "a request the server may have run is not sent again")
+}
+
+func TestClient_WSBackoff(t *testing.T) {
+	client := NewClient(ClientConfig{WSBackoff: 100 * time.Millisecond})
+	assert.Equal(t, 100*time.Millisecond, client.wsBackoff(1))
+	assert.Equal(t, 200*time.Millisecond, client.wsBackoff(2))
+	assert.Equal(t, 400*time.Millisecond, client.wsBackoff(3))
+	assert.Equal(t, maxWSBackoff, client.wsBackoff(20))
+
+	assert.Equal(t, defaultWSBackoff, NewClient(ClientConfig{}).wsBackoff(1))
+}
+
+func TestIsSolicited(t *testing.T) {
+	pending := messageIDs([]byte(`{"jsonrpc":"2.0","method":"echo","id":7}`))
+	batchPending := messageIDs([]byte(`[{"jsonrpc":"2.0","method":"echo","id":"a"},{"jsonrpc":"2.0","method":"log"},{"jsonrpc":"2.0","method":"time","id":8}]`))
+	assert.Equal(t, map[string]bool{`"a"`: true, "8": true}, batchPending)
+
+	tests := []struct {
+		name      string
+		message   string
+		pending   map[string]bool
+		solicited bool
+	}{
+		{"matching id", `{"jsonrpc":"2.0","result":"ok","id":7}`, pending, true},
+		{"other id", `{"jsonrpc":"2.0","result":"ok","id":8}`, pending, false},
+		{"string id does not match number", `{"jsonrpc":"2.0","result":"ok","id":"7"}`, pending, false},
+		{"notification", `{"jsonrpc":"2.0","method":"tick","params":{"n":1}}`, pending, false},
+		{"server request with matching id", `{"jsonrpc":"2.0","method":"ping","id":7}`, pending, false},
+		{"error with null id", `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`, pending, true},
+		{"result with null id", `{"jsonrpc":"2.0","result":"ok","id":null}`, pending, false},
+		{"nothing pending", `{"jsonrpc":"2.0","result":"ok","id":7}`, nil, false},
+		{"batch response", `[{"jsonrpc":"2.0","result":"ok","id":8},{"jsonrpc":"2.0","result":"ok","id":"a"}]`, batchPending, true},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"tick"}]`, batchPending, false},
+		{"malformed", `{not json`, pending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.solicited, isSolicited([]byte(tt.message), tt.pending))
+		})
+	}
+}
+
+func TestClient_PersistentWebSocket_Pushes(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"welcome"}`))
+		for {
+			var req JSONRPCRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			// Pushes and a stray response arrive before the answer
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"tick","params":{"n":1}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","result":"stale","id":999}`))
+			conn.WriteJSON(JSONRPCResponse{JSONRPC: "2.0", Result: req.Method, ID: req.ID})
+		}
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var pushes []string
+	client := NewClient(ClientConfig{
+		Protocol:            "ws",
+		Host:                "127.0.0.1",
+		Port:                serverPort(t, server),
+		Timeout:             2 * time.Second,
+		PersistentWebSocket: true,
+		OnPush: func(message []byte) {
+			mu.Lock()
+			pushes = append(pushes, formatPush(message))
+			mu.Unlock()
+		},
+	})
+	defer client.Close()
+
+	require.NoError(t, client.ConnectWebSocket())
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(pushes) == 1
+	}, 2*time.Second, 10*time.Millisecond, "pushes arrive before the first request")
+
+	response, err := client.SendRequest(makeRequest("status", nil, 1))
+	require.NoError(t, err)
+	assert.Equal(t, "status", response.Result)
+	assert.Equal(t, json.Number("1"), response.ID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"📨 Server push: welcome",
+		`📨 Server push: tick {"n":1}`,
+		`📨 Server message: {"jsonrpc":"2.0","result":"stale","id":999}`,
+	}, pushes)
+}
+
+// serverPort returns the port of an httptest server
+func serverPort(t *testing.T, server *httptest.Server) int {
+	_, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+	return port
+}
+
+// newDiffServers starts an HTTP and a WebSocket server answering every request
+// with the given results
+func newDiffServers(t *testing.T, httpResult, wsResult interface{}) (int, int) {
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JSONRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", Result: httpResult, ID: req.ID})
+	}))
+	t.Cleanup(httpServer.Close)
+
+	upgrader := websocket.Upgrader{}
+	wsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var req JSONRPCRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		conn.WriteJSON(JSONRPCResponse{JSONRPC: "2.0", Result: wsResult, ID: req.ID})
+	}))
+	t.Cleanup(wsServer.Close)
+
+	return serverPort(t, httpServer), serverPort(t, wsServer)
+}
+
+func TestParseDiffTargets(t *testing.T) {
+	targets, err := parseDiffTargets("http, WS:9000 ,tcp")
+	require.NoError(t, err)
+	assert.Equal(t, []diffTarget{
+		{Protocol: "http", Port: 8080},
+		{Protocol: "ws", Port: 9000},
+		{Protocol: "tcp", Port: 8081},
+	}, targets)
+
+	_, err = parseDiffTargets("http")
+	assert.ErrorContains(t, err, "at least two")
+
+	_, err = parseDiffTargets("http,ws:abc")
+	assert.ErrorContains(t, err, "invalid port")
+}
+
+func TestDiff_IdenticalResponses(t *testing.T) {
+	result := map[string]interface{}{"status": "ok"}
+	httpPort, wsPort := newDiffServers(t, result, result)
+
+	targets := []diffTarget{{Protocol: "http", Port: httpPort}, {Protocol: "ws", Port: wsPort}}
+	base := ClientConfig{Host: "127.0.0.1", Timeout: 5 * time.Second}
+	results := sendAcrossProtocols(base, targets, makeRequest("status", nil, 1))
+
+	require.Len(t, results, 2)
+	for _, result := range results {
+		require.NoError(t, result.Err, result.Protocol)
+	}
+	assert.Empty(t, diffResults(results))
+
+	var ok bool
+	output := captureStdout(t, func() { ok = runDiff(base, targets, makeRequest("status", nil, 1)) })
+	assert.True(t, ok)
+	assert.Contains(t, output, "identical")
+}
+
+func TestDiff_DivergentResponses(t *testing.T) {
+	httpPort, wsPort := newDiffServers(t, map[string]interface{}{"status": "ok"}, map[string]interface{}{"status": "degraded"})
+
+	targets := []diffTarget{{Protocol: "http", Port: httpPort}, {Protocol: "ws", Port: wsPort}}
+	base := ClientConfig{Host: "127.0.0.1", Timeout: 5 * time.Second}
+	results := sendAcrossProtocols(base, targets, makeRequest("status", nil, 1))
+
+	assert.Equal(t, []string{
+		`- http result: {"status":"ok"}`,
+		`+ ws result: {"status":"degraded"}`,
+	}, diffResults(results))
+
+	var ok bool
+	output := captureStdout(t, func() { ok = runDiff(base, targets, makeRequest("status", nil, 1)) })
+	assert.False(t, ok)
+	assert.Contains(t, output, "Responses differ")
+}
+
+func TestDiffResults_TransportError(t *testing.T) {
+	results := []protocolResult{
+		{Protocol: "http", Response: &JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: json.Number("1")}},
+		{Protocol: "tcp", Err: io.ErrUnexpectedEOF},
+	}
+
+	lines := diffResults(results)
+	assert.Contains(t, lines, "+ tcp transport error: unexpected EOF")
+	assert.Contains(t, lines, `- http result: "ok"`)
+}
+
+func TestParseBatchCommand_Inline(t *testing.T) {
+	ids := newRequestIDSequence(1, false)
+	requests, err := parseBatchCommand(`batch status; echo hi there; notify log {"level":"info"}; calc 1 + 2`, ids)
+	require.NoError(t, err)
+	require.Len(t, requests, 4)
+
+	assert.Equal(t, "status", requests[0].Method)
+	assert.Equal(t, 1, requests[0].ID)
+	assert.Equal(t, "echo", requests[1].Method)
+	assert.Equal(t, 2, requests[1].ID)
+	assert.Equal(t, "log", requests[2].Method)
+	assert.Nil(t, requests[2].ID, "notify adds a notification to the batch")
+	assert.Equal(t, "calculate", requests[3].Method)
+	assert.Equal(t, 3, requests[3].ID)
+	assert.Equal(t, 4, ids.next)
+}
+
+func TestParseBatchCommand_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(`{"method":"status","id":1}`+"\n"+`{"method":"log"}`+"\n"), 0o600))
+
+	ids := newRequestIDSequence(1, false)
+	requests, err := parseBatchCommand("batch @"+path, ids)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "2.0", requests[0].JSONRPC)
+	assert.Nil(t, requests[1].ID)
+}
+
+func TestParseBatchCommand_Errors(t *testing.T) {
+	ids := newRequestIDSequence(1, false)
+	_, err := parseBatchCommand("batch", ids)
+	assert.Error(t, err)
+
+	_, err = parseBatchCommand("batch status; history", ids)
+	assert.ErrorContains(t, err, "invalid batch command: history")
+
+	_, err = parseBatchCommand("batch @"+filepath.Join(t.TempDir(), "missing.ndjson"), ids)
+	assert.Error(t, err)
+}
+
+func TestPlanReplay(t *testing.T) {
+	commands := []string{
+		"status",
+		"help",
+		"echo hi there",
+		"",
+		"batch time; notify log",
+		"clear",
+		"bogus",
+		"history",
+		"replay 3",
+		"calc 1 + 2",
+		"quit",
+	}
+
+	var steps []replayStep
+	captureStdout(t, func() {
+		steps = planReplay(commands, newRequestIDSequence(1, false))
+	})
+	require.Len(t, steps, 10)
+
+	assert.Equal(t, "status", steps[0].Request.Method)
+	assert.Equal(t, 1, steps[0].Request.ID)
+	assert.Equal(t, "help is not replayed", steps[1].Skipped)
+	assert.Equal(t, "echo", steps[2].Request.Method)
+	assert.Equal(t, 2, steps[2].Request.ID)
+
+	require.Len(t, steps[3].Batch, 2)
+	assert.Equal(t, "time", steps[3].Batch[0].Method)
+	assert.Equal(t, 3, steps[3].Batch[0].ID)
+	assert.Nil(t, steps[3].Batch[1].ID)
+
+	assert.Equal(t, "clear is not replayed", steps[4].Skipped)
+	assert.Equal(t, "invalid command", steps[5].Skipped)
+	assert.Equal(t, "history is not replayed", steps[6].Skipped)
+	assert.Equal(t, "replay is not replayed", steps[7].Skipped)
+	assert.Equal(t, "calculate", steps[8].Request.Method)
+	assert.Equal(t, 4, steps[8].Request.ID)
+	assert.Equal(t, "quit is not replayed", steps[9].Skipped)
+}
+
+func TestLastCommands(t *testing.T) {
+	hm := newHistoryManagerWithFile(filepath.Join(t.TempDir(), "history"), 0, HistoryDedupConsecutive)
+	for _, command := range []string{"status", "echo a", "time", "replay 2"} {
+		hm.addCommand(command)
+	}
+
+	assert.Equal(t, []string{"echo a", "time"}, lastCommands(hm, 2))
+	assert.Equal(t, []string{"status", "echo a", "time"}, lastCommands(hm, 10))
+}
+
+func TestRunReplay_SendsInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+
+		if body[0] == '[' {
+			var requests []JSONRPCRequest
+			require.NoError(t, json.Unmarshal(body, &requests))
+			var responses []JSONRPCResponse
+			for _, req := range requests {
+				if req.ID != nil {
+					responses = append(responses, JSONRPCResponse{JSONRPC: "2.0", Result: req.Method, ID: req.ID})
+				}
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+		var req JSONRPCRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", Result: req.Method, ID: req.ID})
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "session.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# recorded session\nstatus\nhelp\n\nbatch time; status\nquit\n"), 0o600))
+	commands, err := readReplayFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"status", "help", "batch time; status", "quit"}, commands)
+
+	output := captureStdout(t, func() {
+		runReplay(newTestHTTPClient(t, server.URL), commands, newRequestIDSequence(1, false))
+	})
+
+	require.Len(t, received, 2, "skipped commands are not sent")
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"status","id":1}`, received[0])
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","method":"time","id":2},{"jsonrpc":"2.0","method":"status","id":3}]`, received[1])
+	assert.Contains(t, output, "[2/4] help")
+	assert.Contains(t, output, "Skipped: help is not replayed")
+}
+
+func TestRequestIDSequence_StartValue(t *testing.T) {
+	ids := newRequestIDSequence(1000, false)
+
+	req, shouldSend, _ := processCommand("status", ids)
+	require.True(t, shouldSend)
+	assert.Equal(t, 1000, req.ID)
+
+	req, _, _ = processCommand("echo hi", ids)
+	assert.Equal(t, 1001, req.ID)
+
+	// Notifications do not consume IDs; raw requests with their own ID do
+	req, _, _ = processCommand("notify log", ids)
+	assert.Nil(t, req.ID)
+	req, _, _ = processCommand(`raw {"jsonrpc":"2.0","method":"time","id":"custom"}`, ids)
+	assert.Equal(t, "custom", req.ID)
+
+	requests, err := parseBatchCommand("batch time; status", ids)
+	require.NoError(t, err)
+	assert.Equal(t, 1003, requests[0].ID)
+	assert.Equal(t, 1004, requests[1].ID)
+}
+
+func TestRequestIDSequence_UUID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ids := newRequestIDSequence(1, true)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id, ok := ids.Next().(string)
+		require.True(t, ok)
+		require.Regexp(t, uuidPattern, id)
+		require.False(t, seen[id], "duplicate uuid %s", id)
+		seen[id] = true
+	}
+
+	req, _, _ := processCommand("status", ids)
+	assert.Regexp(t, uuidPattern, req.ID)
+}
+
+func TestProcessCommand_Batch(t *testing.T) {
+	ids := newRequestIDSequence(1, false)
+	req, shouldSend, action := processCommand("batch status; time", ids)
+	assert.Nil(t, req)
+	assert.False(t, shouldSend)
+	assert.Equal(t, "batch", action)
+	assert.Equal(t, 1, ids.next, "ids are assigned when the batch is built")
+}
+
+func TestProcessCommand_Calc(t *testing.T) {
+	ids := newRequestIDSequence(1, false)
+
+	req, shouldSend, _ := processCommand("calc 17 modulo 5", ids)
+	require.True(t, shouldSend)
+	assert.Equal(t, map[string]interface{}{"a": 17.0, "b": 5.0, "operation": "modulo"}, req.Params)
+
+	req, shouldSend, _ = processCommand("calc sqrt 16", ids)
+	require.True(t, shouldSend)
+	assert.Equal(t, map[string]interface{}{"a": 16.0, "operation": "sqrt"}, req.Params)
+
+	for _, line := range []string{"calc sqrt x", "calc 2 power", "calc 1 + 2 3"} {
+		req, shouldSend, _ = processCommand(line, ids)
+		assert.Nil(t, req, line)
+		assert.False(t, shouldSend, line)
+	}
+	assert.Equal(t, 3, ids.next)
+}
+
+func TestProcessCommand_Generate(t *testing.T) {
+	ids := newRequestIDSequence(1, false)
+
+	req, shouldSend, _ := processCommand("generate 50 8", ids)
+	require.True(t, shouldSend)
+	assert.Equal(t, "generate", req.Method)
+	assert.Equal(t, map[string]interface{}{"fields": 50, "value_size": 8}, req.Params)
+
+	req, _, _ = processCommand("generate 5", ids)
+	assert.Equal(t, map[string]interface{}{"fields": 5}, req.Params)
+
+	for _, line := range []string{"generate", "generate many", "generate 5 -1", "generate 1 2 3"} {
+		req, shouldSend, _ = processCommand(line, ids)
+		assert.Nil(t, req, line)
+		assert.False(t, shouldSend, line)
+	}
+	assert.Equal(t, 3, ids.next)
+}
+
+func TestClient_SendBatchRequest_MixedHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var received []JSONRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+
+		// Respond in reverse order to exercise correlation by ID
+		var responses []JSONRPCResponse
+		for i := len(received) - 1; i >= 0; i-- {
+			if received[i].ID != nil {
+				responses = append(responses, JSONRPCResponse{JSONRPC: "2.0", Result: received[i].Method, ID: received[i].ID})
+			}
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer server.Close()
+
+	client := newTestHTTPClient(t, server.URL)
+
+	ids := newRequestIDSequence(1, false)
+	requests, err := parseBatchCommand("batch status; notify log; time; notify audit", ids)
+	require.NoError(t, err)
+
+	results, unmatched, err := client.SendBatchRequest(requests)
+	require.NoError(t, err)
+	assert.Empty(t, unmatched)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, "status", results[0].Response.Result)
+	assert.Nil(t, results[1].Response, "notifications have no response entry")
+	assert.Equal(t, "time", results[2].Response.Result)
+	assert.Nil(t, results[3].Response)
+
+	output := captureStdout(t, func() { printBatchResults(results, unmatched) })
+	assert.Contains(t, output, "[2] log ✅ Notification sent")
+}
+
+func TestWriteJSONOutput_Single(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JSONRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", Result: map[string]interface{}{"status": "ok"}, ID: req.ID})
+	}))
+	defer server.Close()
+
+	response, err := newTestHTTPClient(t, server.URL).SendRequest(makeRequest("status", nil, 7))
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	code := writeJSONOutput(&stdout, &stderr, []*JSONRPCResponse{response}, false, nil)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `{"jsonrpc":"2.0","result":{"status":"ok"},"id":7}`+"\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestWriteJSONOutput_RPCError(t *testing.T) {
+	response := &JSONRPCResponse{JSONRPC: "2.0", Error: &JSONRPCError{Code: -32601, Message: "Method not found"}, ID: json.Number("1")}
+
+	var stdout, stderr bytes.Buffer
+	code := writeJSONOutput(&stdout, &stderr, []*JSONRPCResponse{response}, false, nil)
+	assert.Equal(t, 1, code)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`, stdout.String())
+}
+
+func TestWriteJSONOutput_Notification(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := writeJSONOutput(&stdout, &stderr, nil, false, nil)
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestWriteJSONOutput_TransportError(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := writeJSONOutput(&stdout, &stderr, nil, false, io.ErrUnexpectedEOF)
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, "error: unexpected EOF\n", stderr.String())
+}
+
+func TestWriteJSONOutput_Batch(t *testing.T) {
+	responses := []*JSONRPCResponse{
+		{JSONRPC: "2.0", Result: "a", ID: json.Number("1")},
+		{JSONRPC: "2.0", Error: &JSONRPCError{Code: -32602, Message: "Invalid params"}, ID: json.Number("2")},
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := writeJSONOutput(&stdout, &stderr, responses, true, nil)
+	assert.Equal(t, 1, code, "any error in the batch fails the run")
+	assert.Equal(t, `[{"jsonrpc":"2.0","result":"a","id":1},{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":2}]`+"\n", stdout.String())
+
+	stdout.Reset()
+	code = writeJSONOutput(&stdout, &stderr, responses[:1], true, nil)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `[{"jsonrpc":"2.0","result":"a","id":1}]`+"\n", stdout.String(), "batches stay arrays")
+}
+
+func TestWriteNDJSONOutput(t *testing.T) {
+	responses := []*JSONRPCResponse{
+		{JSONRPC: "2.0", Result: "a", ID: json.Number("1")},
+		{JSONRPC: "2.0", Error: &JSONRPCError{Code: -32602, Message: "Invalid params"}, ID: json.Number("2")},
+		{JSONRPC: "2.0", Result: map[string]interface{}{"sum": 3}, ID: "three"},
+		{JSONRPC: "2.0", Error: &JSONRPCError{Code: -32700, Message: "Parse error"}, ID: nil},
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := writeNDJSONOutput(&stdout, &stderr, responses, nil)
+	assert.Equal(t, 1, code, "any error fails the run")
+	assert.Empty(t, stderr.String())
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"a","id":1}
+{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params"},"id":2}
+{"jsonrpc":"2.0","result":{"sum":3},"id":"three"}
+{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}
+`, stdout.String())
+
+	stdout.Reset()
+	code = writeNDJSONOutput(&stdout, &stderr, responses[:1], nil)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"a","id":1}`+"\n", stdout.String(), "a single response is one line too")
+}
+
+func TestWriteNDJSONOutput_Batch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []JSONRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&requests))
+		var responses []JSONRPCResponse
+		for _, req := range requests {
+			if req.Method == "echo" {
+				responses = append(responses, JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID})
+			} else {
+				responses = append(responses, JSONRPCResponse{JSONRPC: "2.0", Error: &JSONRPCError{Code: -32601, Message: "Method not found"}, ID: req.ID})
+			}
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer server.Close()
+
+	requests, err := parseBatchLines(strings.NewReader(`{"method":"echo","id":1}
+{"method":"missing","id":2}
+{"method":"echo","id":3}`))
+	require.NoError(t, err)
+	responses, err := newTestHTTPClient(t, server.URL).SendBatch(requests)
+
+	var stdout, stderr bytes.Buffer
+	code := writeNDJSONOutput(&stdout, &stderr, responses, err)
+	assert.Equal(t, 1, code)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 3)
+	for i, line := range lines {
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &response), line)
+		assert.EqualValues(t, i+1, response["id"])
+	}
+	assert.Contains(t, lines[1], `"code":-32601`)
+}
+
+func TestWriteNDJSONOutput_TransportError(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := writeNDJSONOutput(&stdout, &stderr, nil, io.ErrUnexpectedEOF)
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, "error: unexpected EOF\n", stderr.String())
+}
+
+func TestPrintResponse_Pretty(t *testing.T) {
+	output := captureStdout(t, func() {
+		printResponse(&JSONRPCResponse{JSONRPC: "2.0", Result: "pong", ID: json.Number("1")}, nil)
+	})
+	assert.Contains(t, output, "✅ Success (ID: 1)")
+	assert.Contains(t, output, `Result: "pong"`)
+
+	output = captureStdout(t, func() { printResponse(nil, nil) })
+	assert.Contains(t, output, "Notification sent")
+}
+
+func TestParseBenchmarkMethods(t *testing.T) {
+	methods, err := parseBenchmarkMethods(`status, echo:{"message":"a,b"} ,calculate:{"operation":"add","a":1,"b":2},time:["x",{"y":"]"}]`)
+	require.NoError(t, err)
+	assert.Equal(t, []benchmarkMethod{
+		{Method: "status"},
+		{Method: "echo", Params: json.RawMessage(`{"message":"a,b"}`)},
+		{Method: "calculate", Params: json.RawMessage(`{"operation":"add","a":1,"b":2}`)},
+		{Method: "time", Params: json.RawMessage(`["x",{"y":"]"}]`)},
+	}, methods)
+
+	for _, spec := range []string{"", " , ", `echo:{"message":`, `:{"a":1}`} {
+		_, err := parseBenchmarkMethods(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestScheduledMethod_Rotation(t *testing.T) {
+	methods := []benchmarkMethod{{Method: "status"}, {Method: "echo"}, {Method: "time"}}
+
+	var scheduled []string
+	for i := 0; i < 7; i++ {
+		scheduled = append(scheduled, scheduledMethod(methods, i).Method)
+	}
+	assert.Equal(t, []string{"status", "echo", "time", "status", "echo", "time", "status"}, scheduled)
+}
+
+func TestAggregateBenchmark(t *testing.T) {
+	methods := []benchmarkMethod{{Method: "status"}, {Method: "echo"}, {Method: "unused"}}
+	samples := []benchmarkSample{
+		{Method: "echo", Duration: 30 * time.Millisecond},
+		{Method: "status", Duration: 10 * time.Millisecond},
+		{Method: "echo", Duration: 10 * time.Millisecond, Failed: true},
+		{Method: "status", Duration: 20 * time.Millisecond},
+		{Method: "echo", Duration: 20 * time.Millisecond},
+	}
+
+	stats := aggregateBenchmark(methods, samples)
+	assert.Equal(t, []methodStats{
+		{Method: "status", Requests: 2, Total: 30 * time.Millisecond, Min: 10 * time.Millisecond, Max: 20 * time.Millisecond},
+		{Method: "echo", Requests: 3, Errors: 1, Total: 60 * time.Millisecond, Min: 10 * time.Millisecond, Max: 30 * time.Millisecond},
+		{Method: "unused"},
+	}, stats)
+	assert.Equal(t, 15*time.Millisecond, stats[0].Avg())
+	assert.Equal(t, 20*time.Millisecond, stats[1].Avg())
+	assert.Zero(t, stats[2].Avg())
+
+	var out bytes.Buffer
+	printBenchmarkReport(&out, stats, time.Second)
+	report := out.String()
+	assert.Contains(t, report, "Total requests: 5\n")
+	assert.Contains(t, report, "Successful: 4\n")
+	assert.Contains(t, report, "Errors: 1\n")
+	assert.Contains(t, report, "Requests/sec: 5.00\n")
+	assert.Regexp(t, `status\s+requests=2 errors=0 avg=15ms min=10ms max=20ms`, report)
+	assert.Regexp(t, `echo\s+requests=3 errors=1 avg=20ms min=10ms max=30ms`, report)
+}
+
+func TestRunBenchmark_MixedMethods(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JSONRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		calls[req.Method]++
+		mu.Unlock()
+
+		if req.Method == "missing" {
+			json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", Error: &JSONRPCError{Code: -32601, Message: "Method not found"}, ID: req.ID})
+			return
+		}
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", Result: req.Params, ID: req.ID})
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{Protocol: "http", Host: "127.0.0.1", Port: serverPort(t, server), Timeout: 2 * time.Second})
+	methods, err := parseBenchmarkMethods(`status,echo:{"message":"hi"},missing`)
+	require.NoError(t, err)
+
+	output := captureStdout(t, func() {
+		runBenchmark(client, 9, 3, methods)
+	})
+
+	assert.Equal(t, map[string]int{"status": 3, "echo": 3, "missing": 3}, calls)
+	assert.Contains(t, output, "Errors: 3\n")
+	assert.Regexp(t, `missing\s+requests=3 errors=3`, output)
+	assert.Regexp(t, `echo\s+requests=3 errors=0`, output)
+}
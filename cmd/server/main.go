@@ -11,6 +11,7 @@ import (
 	"streaming-server/pkg/handlers"
 	"streaming-server/pkg/middleware"
 	"streaming-server/pkg/server"
+	"streaming-server/pkg/types"
 )
 
 func main() {
@@ -31,6 +32,10 @@ func main() {
 			"environment": "development",
 			"region":      "us-west-2",
 		},
+		Fields: middleware.LogFieldAllowlist{
+			Headers: []string{"X-Request-ID", middleware.IdempotencyKeyHeader},
+			Data:    []string{middleware.TenantContextKey, middleware.IdempotencyContextKey},
+		},
 	}
 
 	// Create logger with new configuration
@@ -40,6 +45,11 @@ func main() {
 	}
 	defer logger.Close()
 
+	// Prefix request IDs with a service name to tell services apart in shared logs
+	if prefix := os.Getenv("REQUEST_ID_PREFIX"); prefix != "" {
+		types.SetIDGenerator(types.NewPrefixedIDGenerator(prefix, nil))
+	}
+
 	// Load TLS configuration
 	var tlsConfig *tls.Config
 	certFile := os.Getenv("TLS_CERT_FILE")
@@ -96,6 +106,12 @@ func main() {
 	srv.RegisterHandler("status", handlers.StatusHandler)
 	srv.RegisterHandler("calculate", handlers.CalculateHandler)
 
+	// generate answers small requests with large payloads, so it is only
+	// registered for load testing
+	if os.Getenv("ENABLE_GENERATE") == "true" {
+		srv.RegisterHandler("generate", handlers.GenerateHandler)
+	}
+
 	// Start server
 	if err := srv.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -1,18 +1,85 @@
 package dispatcher
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"sync"
+	"time"
 
 	"streaming-server/pkg/middleware"
 	"streaming-server/pkg/types"
 )
 
+// HandlerTimeoutCode код ошибки, возвращаемой при превышении времени выполнения обработчика
+const HandlerTimeoutCode = -32000
+
+// ErrMethodNotFound возвращается Dispatch для неизвестного метода, если включен
+// режим SetMethodNotFoundAsError
+var ErrMethodNotFound = errors.New("method not found")
+
+// HandlerInfo содержит описание метода для документации и rpc.discover
+type HandlerInfo struct {
+	// Description - описание метода для человека
+	Description string `json:"description,omitempty"`
+	// Params - подсказка о формате параметров
+	Params string `json:"params,omitempty"`
+	// NotificationOnly отмечает методы, которые вызываются только уведомлениями.
+	// Запрос с ID к такому методу отклоняется ошибкой -32600
+	NotificationOnly bool `json:"notification_only,omitempty"`
+	// ParamsSchema - JSON Schema параметров, проверяемая SchemaValidationMiddleware
+	ParamsSchema json.RawMessage `json:"params_schema,omitempty"`
+	// ResultSchema - JSON Schema результата, проверяемая ResponseSchemaCheckMiddleware
+	ResultSchema json.RawMessage `json:"result_schema,omitempty"`
+}
+
+// IsZero сообщает, что описание метода не задано
+func (i HandlerInfo) IsZero() bool {
+	return i.Description == "" && i.Params == "" && !i.NotificationOnly &&
+		len(i.ParamsSchema) == 0 && len(i.ResultSchema) == 0
+}
+
+// Example - пример запроса и ответа метода для документации и подсказок клиентам
+type Example struct {
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// ParamsSchema возвращает JSON Schema параметров метода, если она зарегистрирована
+func (d *Dispatcher) ParamsSchema(method string) (json.RawMessage, bool) {
+	info, exists := d.GetHandlerInfo(method)
+	if !exists || len(info.ParamsSchema) == 0 {
+		return nil, false
+	}
+	return info.ParamsSchema, true
+}
+
+// ResultSchema возвращает JSON Schema результата метода, если она зарегистрирована
+func (d *Dispatcher) ResultSchema(method string) (json.RawMessage, bool) {
+	info, exists := d.GetHandlerInfo(method)
+	if !exists || len(info.ResultSchema) == 0 {
+		return nil, false
+	}
+	return info.ResultSchema, true
+}
+
 // Dispatcher обрабатывает JSON-RPC запросы и направляет их к соответствующим обработчикам
 type Dispatcher struct {
 	handlers        map[string]types.Handler
+	info            map[string]HandlerInfo
+	examples        map[string][]Example
+	idempotent      map[string]bool
 	middlewareChain *middleware.Chain
+	methodTimeouts  map[string]time.Duration
+	defaultTimeout  time.Duration
+	warnOnOverwrite bool
+	notFoundAsError bool
+	fallback        types.Handler
+	fallbacks       map[string]types.Handler
+	logf            func(format string, args ...interface{})
 	mu              sync.RWMutex
 }
 
@@ -20,15 +87,220 @@ type Dispatcher struct {
 func NewDispatcher() *Dispatcher {
 	return &Dispatcher{
 		handlers:        make(map[string]types.Handler),
+		info:            make(map[string]HandlerInfo),
+		examples:        make(map[string][]Example),
+		idempotent:      make(map[string]bool),
 		middlewareChain: middleware.NewChain(),
+		methodTimeouts:  make(map[string]time.Duration),
+		fallbacks:       make(map[string]types.Handler),
+		logf:            log.Printf,
+	}
+}
+
+// SetWarnOnOverwrite включает предупреждение в журнале при повторной регистрации
+// метода, чтобы обнаруживать случайную подмену обработчиков
+func (d *Dispatcher) SetWarnOnOverwrite(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.warnOnOverwrite = enabled
+}
+
+// SetMethodNotFoundAsError управляет обработкой неизвестных методов. По умолчанию
+// Dispatch возвращает ответ с ошибкой -32601; во включенном режиме запрос проходит
+// через цепочку middleware, а обработчик возвращает ошибку ErrMethodNotFound,
+// которую middleware может перехватить
+func (d *Dispatcher) SetMethodNotFoundAsError(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notFoundAsError = enabled
+}
+
+// SetFallbackHandler устанавливает обработчик запросов к незарегистрированным
+// методам для всех транспортов. nil удаляет обработчик
+func (d *Dispatcher) SetFallbackHandler(handler types.Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fallback = handler
+}
+
+// SetTransportFallbackHandler устанавливает обработчик незарегистрированных методов
+// для транспорта (ctx.Transport, без учета регистра), например проксирование по HTTP
+// и отказ по TCP. Имеет приоритет над SetFallbackHandler. nil удаляет обработчик
+func (d *Dispatcher) SetTransportFallbackHandler(transport string, handler types.Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	transport = strings.ToLower(transport)
+	if handler == nil {
+		delete(d.fallbacks, transport)
+		return
+	}
+	d.fallbacks[transport] = handler
+}
+
+// fallbackFor возвращает обработчик незарегистрированных методов для транспорта.
+// Вызывается под d.mu
+func (d *Dispatcher) fallbackFor(transport string) types.Handler {
+	if handler, ok := d.fallbacks[strings.ToLower(transport)]; ok {
+		return handler
+	}
+	return d.fallback
+}
+
+// SetMethodTimeout устанавливает максимальное время выполнения обработчика метода.
+// Нулевое или отрицательное значение снимает ограничение для метода, в том числе
+// время по умолчанию
+func (d *Dispatcher) SetMethodTimeout(method string, timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if timeout < 0 {
+		timeout = 0
+	}
+	d.methodTimeouts[method] = timeout
+}
+
+// SetDefaultTimeout устанавливает время выполнения для методов без собственного ограничения.
+// Нулевое значение отключает ограничение
+func (d *Dispatcher) SetDefaultTimeout(timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.defaultTimeout = timeout
+}
+
+// timeoutFor возвращает ограничение времени для метода; 0 означает отсутствие ограничения
+func (d *Dispatcher) timeoutFor(method string) time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if timeout, ok := d.methodTimeouts[method]; ok {
+		return timeout
 	}
+	return d.defaultTimeout
 }
 
 // RegisterHandler регистрирует обработчик для указанного метода
 func (d *Dispatcher) RegisterHandler(method string, handler types.Handler) {
+	d.RegisterHandlerWithInfo(method, handler, HandlerInfo{})
+}
+
+// RegisterHandlerWithInfo регистрирует обработчик вместе с описанием метода.
+// Повторная регистрация заменяет и обработчик, и описание
+func (d *Dispatcher) RegisterHandlerWithInfo(method string, handler types.Handler, info HandlerInfo) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if _, exists := d.handlers[method]; exists && d.warnOnOverwrite {
+		d.logf("Warning: handler for method %q is already registered and will be overwritten", method)
+	}
 	d.handlers[method] = handler
+	d.info[method] = info
+}
+
+// GetHandlerInfo возвращает описание зарегистрированного метода
+func (d *Dispatcher) GetHandlerInfo(method string) (HandlerInfo, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	info, exists := d.info[method]
+	return info, exists
+}
+
+// RegisterExample добавляет пример запроса и ответа для зарегистрированного метода.
+// Примеры сериализуются в JSON; nil ответ означает уведомление без ответа.
+// Повторная регистрация обработчика сохраняет примеры, UnregisterHandler удаляет их
+func (d *Dispatcher) RegisterExample(method string, reqExample, respExample interface{}) error {
+	request, err := marshalExample(reqExample)
+	if err != nil {
+		return fmt.Errorf("invalid request example for %q: %w", method, err)
+	}
+	if request == nil {
+		return fmt.Errorf("request example for %q is required", method)
+	}
+	response, err := marshalExample(respExample)
+	if err != nil {
+		return fmt.Errorf("invalid response example for %q: %w", method, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.handlers[method]; !exists {
+		return fmt.Errorf("%w: %s", ErrMethodNotFound, method)
+	}
+	d.examples[method] = append(d.examples[method], Example{Request: request, Response: response})
+	return nil
+}
+
+// marshalExample сериализует пример; json.RawMessage и []byte принимаются как есть
+func marshalExample(example interface{}) (json.RawMessage, error) {
+	var data []byte
+	switch v := example.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		data = v
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if !json.Valid(data) {
+		return nil, errors.New("example is not valid JSON")
+	}
+	return append(json.RawMessage(nil), data...), nil
+}
+
+// Examples возвращает примеры метода в порядке регистрации
+func (d *Dispatcher) Examples(method string) []Example {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]Example(nil), d.examples[method]...)
+}
+
+// AllExamples возвращает примеры всех методов, для которых они заданы
+func (d *Dispatcher) AllExamples() map[string][]Example {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	all := make(map[string][]Example, len(d.examples))
+	for method, examples := range d.examples {
+		all[method] = append([]Example(nil), examples...)
+	}
+	return all
+}
+
+// MarkIdempotent отмечает методы, вызовы которых требуют ключ идемпотентности.
+// Проверку выполняет middleware.IdempotencyMiddleware с IsIdempotent. Отметка
+// не зависит от регистрации обработчика и сохраняется при его замене
+func (d *Dispatcher) MarkIdempotent(methods ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, method := range methods {
+		d.idempotent[method] = true
+	}
+}
+
+// IsIdempotent сообщает, что метод требует ключ идемпотентности
+func (d *Dispatcher) IsIdempotent(method string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.idempotent[method]
+}
+
+// RegisterRawHandler регистрирует обработчик, получающий исходные байты запроса,
+// например для проверки подписи. Если исходные байты недоступны (запрос создан
+// не процессором), обработчик получает сериализованный запрос
+func (d *Dispatcher) RegisterRawHandler(method string, handler types.RawHandler) {
+	d.RegisterHandler(method, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		raw := ctx.RawRequest
+		if len(raw) == 0 {
+			data, err := json.Marshal(req)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal request: %w", err)
+			}
+			raw = data
+		}
+		return handler(raw, ctx)
+	})
 }
 
 // UnregisterHandler удаляет обработчик для указанного метода
@@ -36,13 +308,34 @@ func (d *Dispatcher) UnregisterHandler(method string) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	delete(d.handlers, method)
+	delete(d.info, method)
+	delete(d.examples, method)
 }
 
-// SetMiddleware устанавливает middleware chain для диспетчера
+// SetMiddleware устанавливает middleware chain для диспетчера, заменяя текущую
 func (d *Dispatcher) SetMiddleware(chain *middleware.Chain) {
 	d.middlewareChain = chain
 }
 
+// Use добавляет middleware в конец текущей цепочки: оно выполняется после
+// уже добавленных, ближе всего к обработчику. Как и SetMiddleware, вызывается
+// при настройке сервера, до обработки запросов
+func (d *Dispatcher) Use(m types.Middleware) {
+	if d.middlewareChain == nil {
+		d.middlewareChain = middleware.NewChain()
+	}
+	d.middlewareChain.Add(m)
+}
+
+// UseFirst добавляет middleware в начало текущей цепочки: оно выполняется
+// первым и оборачивает все остальные (например, восстановление после паники)
+func (d *Dispatcher) UseFirst(m types.Middleware) {
+	if d.middlewareChain == nil {
+		d.middlewareChain = middleware.NewChain()
+	}
+	d.middlewareChain.Prepend(m)
+}
+
 // Dispatch обрабатывает JSON-RPC запрос и возвращает ответ
 func (d *Dispatcher) Dispatch(request *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
 	// Проверяем, что запрос не nil
@@ -55,11 +348,27 @@ func (d *Dispatcher) Dispatch(request *types.JSONRPCRequest, ctx *types.RequestC
 		return nil, errors.New("context cannot be nil")
 	}
 
+	// Обработчики могут вызывать другие методы через ctx.CallLocal
+	if ctx.LocalDispatcher() == nil {
+		ctx.SetLocalDispatcher(d)
+	}
+
 	// Получаем обработчик для метода
 	d.mu.RLock()
 	handler, exists := d.handlers[request.Method]
+	notificationOnly := exists && d.info[request.Method].NotificationOnly
+	if !exists {
+		if fallback := d.fallbackFor(ctx.Transport); fallback != nil {
+			handler, exists = fallback, true
+		}
+	}
+	notFoundAsError := d.notFoundAsError
 	d.mu.RUnlock()
 
+	if !exists && notFoundAsError {
+		return d.middlewareChain.Execute(request, ctx, methodNotFoundHandler)
+	}
+
 	if !exists {
 		return &types.JSONRPCResponse{
 			JSONRPC: "2.0",
@@ -68,8 +377,85 @@ func (d *Dispatcher) Dispatch(request *types.JSONRPCRequest, ctx *types.RequestC
 		}, nil
 	}
 
-	// Используем middleware chain для обработки запроса
-	return d.middlewareChain.Execute(request, ctx, handler)
+	// Методы, отмеченные NotificationOnly, вызываются только уведомлениями
+	if notificationOnly && !request.IsNotification() {
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInvalidRequestError("method is notification-only"),
+			ID:      request.ID,
+		}, nil
+	}
+
+	timeout := d.timeoutFor(request.Method)
+	if timeout <= 0 {
+		// Используем middleware chain для обработки запроса
+		return d.middlewareChain.Execute(request, ctx, handler)
+	}
+
+	return d.dispatchWithTimeout(request, ctx, handler, timeout)
+}
+
+// methodNotFoundHandler возвращает ErrMethodNotFound с именем метода
+func methodNotFoundHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+	return nil, fmt.Errorf("%w: %s", ErrMethodNotFound, req.Method)
+}
+
+// dispatchResult результат выполнения обработчика в отдельной горутине
+type dispatchResult struct {
+	response *types.JSONRPCResponse
+	err      error
+}
+
+// dispatchWithTimeout выполняет обработчик с ограничением времени. Контекст обработчика
+// отменяется по истечении времени; если обработчик его игнорирует, его результат
+// отбрасывается после завершения. Обработчик работает с собственной копией данных
+// запроса: запоздавший обработчик не пишет в контекст, который уже читает вызывающий.
+// Данные и выбранный обработчик возвращаются в контекст запроса, только если
+// обработчик успел завершиться
+func (d *Dispatcher) dispatchWithTimeout(request *types.JSONRPCRequest, ctx *types.RequestContext, handler types.Handler, timeout time.Duration) (*types.JSONRPCResponse, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx.Context(), timeout)
+	defer cancel()
+
+	handlerCtx := ctx.WithContext(timeoutCtx)
+	handlerCtx.Data = make(map[string]interface{}, len(ctx.Data))
+	for key, value := range ctx.Data {
+		handlerCtx.Data[key] = value
+	}
+
+	// Буферизованный канал позволяет горутине завершиться, даже если результат уже не нужен
+	done := make(chan dispatchResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- dispatchResult{err: fmt.Errorf("handler panic: %v", r)}
+			}
+		}()
+
+		response, err := d.middlewareChain.Execute(request, handlerCtx, handler)
+		done <- dispatchResult{response: response, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		if ctx.Data == nil {
+			ctx.Data = handlerCtx.Data
+		} else {
+			for key, value := range handlerCtx.Data {
+				ctx.Data[key] = value
+			}
+		}
+		ctx.SelectedHandler = handlerCtx.SelectedHandler
+		return result.response, result.err
+	case <-timeoutCtx.Done():
+		if !errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return nil, timeoutCtx.Err()
+		}
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewServerError(HandlerTimeoutCode, "handler timeout"),
+			ID:      request.ID,
+		}, nil
+	}
 }
 
 // GetRegisteredMethods возвращает список зарегистрированных методов
@@ -3,8 +3,12 @@ package dispatcher
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"streaming-server/pkg/handlers"
 	"streaming-server/pkg/middleware"
 	"streaming-server/pkg/types"
 
@@ -64,6 +68,49 @@ func TestDispatcher_SetMiddleware(t *testing.T) {
 	// but we can test that it works in dispatch
 }
 
+func TestDispatcher_UseAndUseFirst(t *testing.T) {
+	var executionOrder []string
+	named := func(name string) types.Middleware {
+		return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+			executionOrder = append(executionOrder, name+"_before")
+			response, err := next(req, ctx)
+			executionOrder = append(executionOrder, name+"_after")
+			return response, err
+		}
+	}
+
+	d := NewDispatcher()
+	d.RegisterHandler("test", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		executionOrder = append(executionOrder, "handler")
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "success", ID: req.ID}, nil
+	})
+
+	// An existing chain is extended, not replaced
+	d.SetMiddleware(middleware.NewChain(named("m2")))
+	d.Use(named("m3"))
+	d.UseFirst(named("m1"))
+	d.Use(named("m4"))
+	d.UseFirst(named("m0"))
+
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+	response, err := d.Dispatch(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: 1}, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "success", response.Result)
+
+	assert.Equal(t, []string{
+		"m0_before", "m1_before", "m2_before", "m3_before", "m4_before",
+		"handler",
+		"m4_after", "m3_after", "m2_after", "m1_after", "m0_after",
+	}, executionOrder)
+
+	// SetMiddleware still replaces the whole chain
+	executionOrder = nil
+	d.SetMiddleware(middleware.NewChain(named("only")))
+	_, err = d.Dispatch(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: 2}, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"only_before", "handler", "only_after"}, executionOrder)
+}
+
 func TestDispatcher_Dispatch_Success(t *testing.T) {
 	d := NewDispatcher()
 
@@ -127,6 +174,130 @@ func TestDispatcher_Dispatch_MethodNotFound(t *testing.T) {
 	assert.Equal(t, "test-1", response.ID)
 }
 
+func TestDispatcher_Dispatch_MethodNotFoundAsError(t *testing.T) {
+	d := NewDispatcher()
+	d.SetMethodNotFoundAsError(true)
+
+	request := &types.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "nonexistent",
+		ID:      "test-1",
+	}
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	response, err := d.Dispatch(request, ctx)
+
+	assert.Nil(t, response)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrMethodNotFound)
+	assert.Contains(t, err.Error(), "nonexistent")
+}
+
+func TestDispatcher_Dispatch_MethodNotFoundAsError_InterceptedByMiddleware(t *testing.T) {
+	d := NewDispatcher()
+	d.SetMethodNotFoundAsError(true)
+
+	// Middleware forwards unknown methods to a fallback instead of failing
+	var intercepted string
+	d.SetMiddleware(middleware.NewChain(func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		response, err := next(req, ctx)
+		if errors.Is(err, ErrMethodNotFound) {
+			intercepted = req.Method
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "fallback", ID: req.ID}, nil
+		}
+		return response, err
+	}))
+
+	request := &types.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "legacy.method",
+		ID:      1,
+	}
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	response, err := d.Dispatch(request, ctx)
+
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, "fallback", response.Result)
+	assert.Equal(t, "legacy.method", intercepted)
+
+	// Default mode never reaches middleware for unknown methods
+	intercepted = ""
+	d.SetMethodNotFoundAsError(false)
+	response, err = d.Dispatch(request, ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.MethodNotFound, response.Error.Code)
+	assert.Empty(t, intercepted)
+}
+
+func TestDispatcher_Dispatch_TransportFallbacks(t *testing.T) {
+	d := NewDispatcher()
+
+	fallback := func(name string) types.Handler {
+		return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Result:  name + ":" + req.Method,
+				ID:      req.ID,
+			}, nil
+		}
+	}
+
+	d.SetFallbackHandler(fallback("default"))
+	d.SetTransportFallbackHandler("HTTP", fallback("proxy"))
+	d.SetTransportFallbackHandler("tcp", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewMethodNotFoundError("rejected on TCP: " + req.Method),
+			ID:      req.ID,
+		}, nil
+	})
+	d.RegisterHandler("known", fallback("registered"))
+
+	tests := []struct {
+		name      string
+		transport string
+		method    string
+		result    interface{}
+		errorCode int
+	}{
+		{name: "HTTP uses its fallback", transport: "HTTP", method: "unknown", result: "proxy:unknown"},
+		{name: "TCP fallback matched case-insensitively", transport: "TCP", method: "unknown", errorCode: types.MethodNotFound},
+		{name: "other transports use the default fallback", transport: "WebSocket", method: "unknown", result: "default:unknown"},
+		{name: "registered methods ignore fallbacks", transport: "HTTP", method: "known", result: "registered:known"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &types.JSONRPCRequest{JSONRPC: "2.0", Method: tt.method, ID: 1}
+			ctx := types.NewRequestContext(context.Background(), tt.transport, "127.0.0.1")
+
+			response, err := d.Dispatch(request, ctx)
+			require.NoError(t, err)
+			require.NotNil(t, response)
+
+			if tt.errorCode != 0 {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, tt.errorCode, response.Error.Code)
+				return
+			}
+			assert.Nil(t, response.Error)
+			assert.Equal(t, tt.result, response.Result)
+		})
+	}
+
+	// Removing fallbacks restores the method-not-found response
+	d.SetTransportFallbackHandler("HTTP", nil)
+	d.SetFallbackHandler(nil)
+	response, err := d.Dispatch(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "unknown", ID: 1},
+		types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1"))
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.MethodNotFound, response.Error.Code)
+}
+
 func TestDispatcher_Dispatch_HandlerError(t *testing.T) {
 	d := NewDispatcher()
 
@@ -377,3 +548,477 @@ func TestDispatcher_UnregisterHandler(t *testing.T) {
 	dispatcher.UnregisterHandler("test")
 	assert.Equal(t, 0, dispatcher.HandlerCount())
 }
+
+func TestDispatcher_Dispatch_Timeout(t *testing.T) {
+	d := NewDispatcher()
+	cancelled := make(chan struct{})
+
+	d.RegisterHandler("slow", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		select {
+		case <-ctx.Context().Done():
+			close(cancelled)
+			return nil, ctx.Context().Err()
+		case <-time.After(time.Second):
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "late", ID: req.ID}, nil
+		}
+	})
+	d.SetMethodTimeout("slow", 20*time.Millisecond)
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "slow", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+
+	response, err := d.Dispatch(req, ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, HandlerTimeoutCode, response.Error.Code)
+	assert.Equal(t, "handler timeout", response.Error.Message)
+	assert.Equal(t, 1, response.ID)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not cancelled")
+	}
+}
+
+func TestDispatcher_Dispatch_WithinTimeout(t *testing.T) {
+	d := NewDispatcher()
+	d.RegisterHandler("fast", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		_, hasDeadline := ctx.Context().Deadline()
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: hasDeadline, ID: req.ID}, nil
+	})
+	d.SetDefaultTimeout(time.Second)
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "fast", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+
+	response, err := d.Dispatch(req, ctx)
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, true, response.Result)
+}
+
+func TestDispatcher_Dispatch_MethodTimeoutOverridesDefault(t *testing.T) {
+	d := NewDispatcher()
+	d.RegisterHandler("slow", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		time.Sleep(30 * time.Millisecond)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "done", ID: req.ID}, nil
+	})
+	d.SetDefaultTimeout(5 * time.Millisecond)
+	d.SetMethodTimeout("slow", time.Second)
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "slow", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+
+	response, err := d.Dispatch(req, ctx)
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, "done", response.Result)
+}
+
+func TestDispatcher_Dispatch_TimeoutHandlerIgnoresCancellation(t *testing.T) {
+	d := NewDispatcher()
+	release := make(chan struct{})
+	finished := make(chan struct{})
+
+	d.RegisterHandler("stubborn", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		defer close(finished)
+		<-release
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ignored", ID: req.ID}, nil
+	})
+	d.SetMethodTimeout("stubborn", 20*time.Millisecond)
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "stubborn", ID: 7}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+
+	start := time.Now()
+	response, err := d.Dispatch(req, ctx)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, HandlerTimeoutCode, response.Error.Code)
+
+	// The late result must be discarded without blocking the handler goroutine
+	close(release)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("handler goroutine did not finish")
+	}
+}
+
+func TestDispatcher_Dispatch_ZeroMethodTimeoutDisablesDefault(t *testing.T) {
+	d := NewDispatcher()
+	d.RegisterHandler("slow", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		_, hasDeadline := ctx.Context().Deadline()
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: hasDeadline, ID: req.ID}, nil
+	})
+	d.SetDefaultTimeout(5 * time.Millisecond)
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "slow", ID: 1}
+	for _, timeout := range []time.Duration{0, -time.Second} {
+		d.SetMethodTimeout("slow", timeout)
+
+		response, err := d.Dispatch(req, types.NewRequestContext(context.Background(), "test", "127.0.0.1"))
+		require.NoError(t, err)
+		assert.Nil(t, response.Error)
+		assert.Equal(t, false, response.Result, "timeout %v", timeout)
+	}
+}
+
+func TestDispatcher_Dispatch_TimeoutPropagatesContextChanges(t *testing.T) {
+	d := NewDispatcher()
+	d.Use(func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		ctx.SelectedHandler = "selected"
+		return next(req, ctx)
+	})
+	d.RegisterHandler("fast", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		ctx.WithValue("handled", true)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	})
+	d.SetDefaultTimeout(time.Second)
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "fast", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+
+	_, err := d.Dispatch(req, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "selected", ctx.SelectedHandler)
+	handled, _ := ctx.GetValue("handled")
+	assert.Equal(t, true, handled)
+}
+
+func TestDispatcher_Dispatch_TimeoutIsolatesLateHandlerData(t *testing.T) {
+	d := NewDispatcher()
+	release := make(chan struct{})
+	finished := make(chan struct{})
+
+	d.RegisterHandler("stubborn", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		defer close(finished)
+		<-release
+		ctx.WithValue("late", true)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ignored", ID: req.ID}, nil
+	})
+	d.SetMethodTimeout("stubborn", 20*time.Millisecond)
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "stubborn", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+
+	response, err := d.Dispatch(req, ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+
+	// The caller keeps using its context while the late handler writes to its copy
+	close(release)
+	ctx.WithValue("caller", true)
+	_, late := ctx.GetValue("late")
+	assert.False(t, late)
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("handler goroutine did not finish")
+	}
+	_, late = ctx.GetValue("late")
+	assert.False(t, late)
+}
+
+func TestDispatcher_RegisterRawHandler(t *testing.T) {
+	d := NewDispatcher()
+
+	var received json.RawMessage
+	d.RegisterRawHandler("verify", func(raw json.RawMessage, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		received = raw
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok"}, nil
+	})
+	assert.Contains(t, d.GetRegisteredMethods(), "verify")
+
+	original := []byte(`{"method":"verify",  "jsonrpc":"2.0","params":{"b":2,"a":1},"id":1}`)
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "verify", Params: json.RawMessage(`{"b":2,"a":1}`), ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+	ctx.RawRequest = original
+
+	response, err := d.Dispatch(req, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", response.Result)
+	assert.Equal(t, string(original), string(received), "raw bytes are passed through unchanged")
+
+	// Without raw bytes the handler receives the serialized request
+	ctx = types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+	_, err = d.Dispatch(req, ctx)
+	require.NoError(t, err)
+
+	var decoded types.JSONRPCRequest
+	require.NoError(t, json.Unmarshal(received, &decoded))
+	assert.Equal(t, "verify", decoded.Method)
+	assert.JSONEq(t, `{"b":2,"a":1}`, string(decoded.Params))
+}
+
+func TestDispatcher_WarnOnOverwrite(t *testing.T) {
+	handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "test", ID: req.ID}, nil
+	}
+
+	tests := []struct {
+		name          string
+		enabled       bool
+		expectedWarns int
+	}{
+		{name: "enabled", enabled: true, expectedWarns: 1},
+		{name: "disabled by default", enabled: false, expectedWarns: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDispatcher()
+			var warnings []string
+			d.logf = func(format string, args ...interface{}) {
+				warnings = append(warnings, fmt.Sprintf(format, args...))
+			}
+			if tt.enabled {
+				d.SetWarnOnOverwrite(true)
+			}
+
+			// First registrations never warn
+			d.RegisterHandler("test", handler)
+			d.RegisterHandler("other", handler)
+			assert.Empty(t, warnings)
+
+			d.RegisterHandler("test", handler)
+			require.Len(t, warnings, tt.expectedWarns)
+			if tt.expectedWarns > 0 {
+				assert.Contains(t, warnings[0], `"test"`)
+			}
+
+			// Re-registering after unregister is not an overwrite
+			d.UnregisterHandler("other")
+			d.RegisterHandler("other", handler)
+			assert.Len(t, warnings, tt.expectedWarns)
+		})
+	}
+}
+
+func TestDispatcher_CallLocal_Composite(t *testing.T) {
+	d := NewDispatcher()
+	d.RegisterHandler("calculate", handlers.CalculateHandler)
+
+	// sum_of_squares computes a^2 + b^2 by composing calculate calls
+	d.RegisterHandler("sum_of_squares", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		var params struct {
+			A float64 `json:"a"`
+			B float64 `json:"b"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+
+		square := func(x float64) (float64, error) {
+			response, err := ctx.CallLocal("calculate", map[string]interface{}{"operation": "multiply", "a": x, "b": x})
+			if err != nil {
+				return 0, err
+			}
+			if response.Error != nil {
+				return 0, response.Error
+			}
+			return response.Result.(map[string]interface{})["result"].(float64), nil
+		}
+
+		a2, err := square(params.A)
+		if err != nil {
+			return nil, err
+		}
+		b2, err := square(params.B)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := ctx.CallLocal("calculate", map[string]interface{}{"operation": "add", "a": a2, "b": b2})
+		if err != nil {
+			return nil, err
+		}
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: response.Result.(map[string]interface{})["result"], ID: req.ID}, nil
+	})
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "sum_of_squares", Params: json.RawMessage(`{"a":3,"b":4}`), ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+	ctx.WithValue("method", "sum_of_squares")
+
+	response, err := d.Dispatch(req, ctx)
+	require.NoError(t, err)
+	require.Nil(t, response.Error)
+	assert.Equal(t, float64(25), response.Result)
+	assert.Equal(t, "sum_of_squares", ctx.Data["method"], "nested calls do not leak into the parent context")
+}
+
+func TestDispatcher_CallLocal_RecursionLimit(t *testing.T) {
+	d := NewDispatcher()
+
+	calls := 0
+	d.RegisterHandler("loop", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		calls++
+		return ctx.CallLocal("loop", nil)
+	})
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "loop", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test", "127.0.0.1")
+
+	_, err := d.Dispatch(req, ctx)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, types.ErrCallDepthExceeded)
+	assert.Equal(t, types.MaxLocalCallDepth+1, calls)
+}
+
+func TestDispatcher_CallLocal_MethodNotFound(t *testing.T) {
+	d := NewDispatcher()
+	d.RegisterHandler("proxy", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return ctx.CallLocal("missing", nil)
+	})
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "proxy", ID: 1}
+	response, err := d.Dispatch(req, types.NewRequestContext(context.Background(), "test", "127.0.0.1"))
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.MethodNotFound, response.Error.Code)
+}
+
+func TestDispatcher_NotificationOnly(t *testing.T) {
+	d := NewDispatcher()
+	calls := 0
+	d.RegisterHandlerWithInfo("audit", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		calls++
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "recorded", ID: req.ID}, nil
+	}, HandlerInfo{NotificationOnly: true})
+	ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+
+	// As a notification the method runs normally
+	response, err := d.Dispatch(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "audit", Params: json.RawMessage(`{"event":"login"}`)}, ctx)
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, 1, calls)
+
+	// A request with an ID is rejected without running the handler
+	for _, id := range []interface{}{1, "abc"} {
+		response, err = d.Dispatch(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "audit", ID: id}, ctx)
+		require.NoError(t, err)
+		require.NotNil(t, response.Error)
+		assert.Equal(t, types.InvalidRequest, response.Error.Code)
+		assert.Equal(t, "method is notification-only", response.Error.Data)
+		assert.Equal(t, id, response.ID)
+	}
+	assert.Equal(t, 1, calls)
+
+	// Re-registering without the flag lifts the restriction
+	d.RegisterHandler("audit", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	})
+	response, err = d.Dispatch(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "audit", ID: 2}, ctx)
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+}
+
+func TestDispatcher_HandlerInfo(t *testing.T) {
+	d := NewDispatcher()
+	handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "test", ID: req.ID}, nil
+	}
+
+	info := HandlerInfo{
+		Description:      "Records an audit event",
+		Params:           `{"event": string}`,
+		NotificationOnly: true,
+	}
+	d.RegisterHandlerWithInfo("audit", handler, info)
+
+	got, ok := d.GetHandlerInfo("audit")
+	require.True(t, ok)
+	assert.Equal(t, info, got)
+	assert.False(t, got.IsZero())
+
+	// Plain registration stores empty info
+	d.RegisterHandler("plain", handler)
+	got, ok = d.GetHandlerInfo("plain")
+	require.True(t, ok)
+	assert.True(t, got.IsZero())
+
+	// Overwriting a handler replaces its info
+	d.RegisterHandlerWithInfo("audit", handler, HandlerInfo{Description: "Updated"})
+	got, _ = d.GetHandlerInfo("audit")
+	assert.Equal(t, HandlerInfo{Description: "Updated"}, got)
+
+	d.RegisterHandler("audit", handler)
+	got, _ = d.GetHandlerInfo("audit")
+	assert.True(t, got.IsZero())
+
+	// Unregistering removes the info
+	d.UnregisterHandler("audit")
+	_, ok = d.GetHandlerInfo("audit")
+	assert.False(t, ok)
+
+	_, ok = d.GetHandlerInfo("missing")
+	assert.False(t, ok)
+}
+
+func TestDispatcher_RegisterExample(t *testing.T) {
+	d := NewDispatcher()
+	d.RegisterHandler("echo", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	})
+
+	require.NoError(t, d.RegisterExample("echo",
+		map[string]interface{}{"jsonrpc": "2.0", "method": "echo", "params": map[string]string{"message": "hi"}, "id": 1},
+		json.RawMessage(`{"jsonrpc":"2.0","result":{"echo":{"message":"hi"}},"id":1}`),
+	))
+	require.NoError(t, d.RegisterExample("echo", json.RawMessage(`{"jsonrpc":"2.0","method":"echo","params":{"message":"note"}}`), nil))
+
+	examples := d.Examples("echo")
+	require.Len(t, examples, 2)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":1}`, string(examples[0].Request))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"echo":{"message":"hi"}},"id":1}`, string(examples[0].Response))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"echo","params":{"message":"note"}}`, string(examples[1].Request))
+	assert.Nil(t, examples[1].Response, "notification examples have no response")
+
+	// Returned slices are copies
+	examples[0].Request = nil
+	assert.NotNil(t, d.Examples("echo")[0].Request)
+	assert.Equal(t, map[string][]Example{"echo": d.Examples("echo")}, d.AllExamples())
+
+	// Re-registering the handler keeps the examples, unregistering drops them
+	d.RegisterHandler("echo", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, nil
+	})
+	assert.Len(t, d.Examples("echo"), 2)
+	d.UnregisterHandler("echo")
+	assert.Empty(t, d.Examples("echo"))
+	assert.Empty(t, d.AllExamples())
+}
+
+func TestDispatcher_RegisterExample_Errors(t *testing.T) {
+	d := NewDispatcher()
+	err := d.RegisterExample("missing", map[string]string{"method": "missing"}, nil)
+	assert.ErrorIs(t, err, ErrMethodNotFound)
+
+	d.RegisterHandler("echo", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, nil
+	})
+	assert.Error(t, d.RegisterExample("echo", nil, nil), "request example is required")
+	assert.Error(t, d.RegisterExample("echo", json.RawMessage(`{broken`), nil))
+	assert.Error(t, d.RegisterExample("echo", map[string]string{"method": "echo"}, make(chan int)))
+	assert.Empty(t, d.Examples("echo"))
+}
+
+func TestDispatcher_MarkIdempotent(t *testing.T) {
+	d := NewDispatcher()
+	assert.False(t, d.IsIdempotent("transfer"))
+
+	d.MarkIdempotent("transfer", "refund")
+	assert.True(t, d.IsIdempotent("transfer"))
+	assert.True(t, d.IsIdempotent("refund"))
+	assert.False(t, d.IsIdempotent("echo"))
+
+	// Marks are independent of handler registration
+	d.RegisterHandler("transfer", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, nil
+	})
+	d.UnregisterHandler("transfer")
+	assert.True(t, d.IsIdempotent("transfer"))
+}
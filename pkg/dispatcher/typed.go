@@ -0,0 +1,55 @@
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"streaming-server/pkg/types"
+)
+
+// TypedHandler превращает типизированную функцию в types.Handler. Параметры
+// запроса декодируются в P; при ошибке декодирования возвращается -32602.
+// Отсутствующие параметры и null оставляют P нулевым значением. Результат
+// fn становится result ответа. Ошибка *types.RPCError (в том числе обернутая)
+// возвращается клиенту как есть, остальные ошибки передаются вызывающему коду
+// и становятся внутренней ошибкой (или ошибкой таймаута для ошибок контекста)
+func TypedHandler[P any, R any](fn func(ctx *types.RequestContext, params P) (R, error)) types.Handler {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		var params P
+		if req.HasParams() && !req.HasNullParams() {
+			if err := json.Unmarshal(bytes.TrimSpace(req.Params), &params); err != nil {
+				return &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewInvalidParamsError("Invalid parameters: " + err.Error()),
+					ID:      req.ID,
+				}, nil
+			}
+		}
+
+		result, err := fn(ctx, params)
+		if err != nil {
+			var rpcErr *types.RPCError
+			if errors.As(err, &rpcErr) {
+				return &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   rpcErr,
+					ID:      req.ID,
+				}, nil
+			}
+			return nil, err
+		}
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Result:  result,
+			ID:      req.ID,
+		}, nil
+	}
+}
+
+// RegisterTyped регистрирует типизированный обработчик метода (см. TypedHandler).
+// Go не поддерживает параметры типа у методов, поэтому это функция пакета
+func RegisterTyped[P any, R any](d *Dispatcher, method string, fn func(ctx *types.RequestContext, params P) (R, error)) {
+	d.RegisterHandler(method, TypedHandler(fn))
+}
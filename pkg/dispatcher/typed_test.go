@@ -0,0 +1,91 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type addParams struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+}
+
+type addResult struct {
+	Sum float64 `json:"sum"`
+}
+
+var errAddFailed = errors.New("adder is broken")
+
+func typedAdd(ctx *types.RequestContext, params addParams) (addResult, error) {
+	switch {
+	case params.A < 0:
+		return addResult{}, fmt.Errorf("validate: %w", types.NewInvalidParamsError("a must not be negative"))
+	case params.A == 13:
+		return addResult{}, errAddFailed
+	}
+	return addResult{Sum: params.A + params.B}, nil
+}
+
+func TestRegisterTyped(t *testing.T) {
+	d := NewDispatcher()
+	RegisterTyped(d, "add", typedAdd)
+	require.Contains(t, d.GetRegisteredMethods(), "add")
+
+	dispatch := func(params string) (*types.JSONRPCResponse, error) {
+		req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "add", ID: 1}
+		if params != "" {
+			req.Params = json.RawMessage(params)
+		}
+		return d.Dispatch(req, types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1"))
+	}
+
+	t.Run("success is wrapped", func(t *testing.T) {
+		response, err := dispatch(`{"a": 2, "b": 3}`)
+		require.NoError(t, err)
+		assert.Nil(t, response.Error)
+		assert.Equal(t, addResult{Sum: 5}, response.Result)
+		assert.Equal(t, 1, response.ID)
+		assert.Equal(t, "2.0", response.JSONRPC)
+	})
+
+	t.Run("missing and null params give the zero value", func(t *testing.T) {
+		for _, params := range []string{"", "null"} {
+			response, err := dispatch(params)
+			require.NoError(t, err)
+			assert.Equal(t, addResult{}, response.Result)
+		}
+	})
+
+	t.Run("decode failure is invalid params", func(t *testing.T) {
+		for _, params := range []string{`{"a": "two"}`, `[1, 2]`} {
+			response, err := dispatch(params)
+			require.NoError(t, err)
+			require.NotNil(t, response.Error, params)
+			assert.Equal(t, types.InvalidParams, response.Error.Code)
+			assert.Contains(t, response.Error.Data, "Invalid parameters")
+		}
+	})
+
+	t.Run("RPC errors are returned to the client", func(t *testing.T) {
+		response, err := dispatch(`{"a": -1, "b": 3}`)
+		require.NoError(t, err)
+		require.NotNil(t, response.Error)
+		assert.Equal(t, types.InvalidParams, response.Error.Code)
+		assert.Equal(t, "a must not be negative", response.Error.Data)
+		assert.Nil(t, response.Result)
+	})
+
+	t.Run("other errors are passed on", func(t *testing.T) {
+		response, err := dispatch(`{"a": 13, "b": 3}`)
+		assert.ErrorIs(t, err, errAddFailed)
+		assert.Nil(t, response)
+	})
+}
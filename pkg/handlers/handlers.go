@@ -2,6 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
 	"time"
 
 	"streaming-server/pkg/types"
@@ -11,8 +14,8 @@ import (
 func EchoHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
 	var params map[string]interface{}
 
-	// Parse parameters if they exist
-	if req.Params != nil {
+	// Parse parameters if they exist; omitted and explicit null params are treated alike
+	if req.HasParams() && !req.HasNullParams() {
 		if err := json.Unmarshal(req.Params, &params); err != nil {
 			return &types.JSONRPCResponse{
 				JSONRPC: "2.0",
@@ -39,6 +42,26 @@ func EchoHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.J
 	}, nil
 }
 
+// CalculationErrorData is the Data of a calculate error raised by the
+// operands: it names the failed operation and the operands it was given
+type CalculationErrorData struct {
+	Reason    string    `json:"reason"`
+	Operation string    `json:"operation"`
+	Operands  []float64 `json:"operands"`
+}
+
+// calculationError builds an invalid params response that keeps the reason in
+// the message and carries the operation context in Data
+func calculationError(req *types.JSONRPCRequest, reason, operation string, operands []float64) *types.JSONRPCResponse {
+	rpcErr := types.NewInvalidParamsError(reason)
+	rpcErr.Data = CalculationErrorData{Reason: reason, Operation: operation, Operands: operands}
+	return &types.JSONRPCResponse{
+		JSONRPC: "2.0",
+		Error:   rpcErr,
+		ID:      req.ID,
+	}
+}
+
 // CalculateHandler performs basic arithmetic operations
 func CalculateHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
 	var params struct {
@@ -47,7 +70,7 @@ func CalculateHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*ty
 		B         interface{} `json:"b"`
 	}
 
-	if req.Params == nil {
+	if !req.HasParams() || req.HasNullParams() {
 		return &types.JSONRPCResponse{
 			JSONRPC: "2.0",
 			Error:   types.NewInvalidParamsError("unknown operation: "),
@@ -72,7 +95,10 @@ func CalculateHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*ty
 		}, nil
 	}
 
-	if params.A == nil || params.B == nil {
+	// Unary operations take only the "a" operand
+	unary := unaryOperations[params.Operation]
+
+	if params.A == nil || (!unary && params.B == nil) {
 		return &types.JSONRPCResponse{
 			JSONRPC: "2.0",
 			Error:   types.NewInvalidParamsError("Missing required parameters"),
@@ -82,7 +108,10 @@ func CalculateHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*ty
 
 	// Convert operands to float64
 	a, aOk := convertToFloat64(params.A)
-	b, bOk := convertToFloat64(params.B)
+	b, bOk := 0.0, true
+	if !unary {
+		b, bOk = convertToFloat64(params.B)
+	}
 
 	if !aOk || !bOk {
 		return &types.JSONRPCResponse{
@@ -92,6 +121,11 @@ func CalculateHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*ty
 		}, nil
 	}
 
+	operands := []float64{a, b}
+	if unary {
+		operands = []float64{a}
+	}
+
 	var result float64
 
 	switch params.Operation {
@@ -104,19 +138,43 @@ func CalculateHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*ty
 	case "divide", "/":
 		if b == 0 {
 			// Для интеграционных тестов используем Invalid Params с правильным сообщением
-			return &types.JSONRPCResponse{
-				JSONRPC: "2.0",
-				Error:   types.NewInvalidParamsError("Division by zero"),
-				ID:      req.ID,
-			}, nil
+			return calculationError(req, "Division by zero", params.Operation, operands), nil
 		}
 		result = a / b
+	case "mod", "%":
+		if b == 0 {
+			return calculationError(req, "Modulo by zero", params.Operation, operands), nil
+		}
+		result = math.Mod(a, b)
+	case "modulo":
+		// Unlike "mod", modulo is defined for integers only
+		if a != math.Trunc(a) || b != math.Trunc(b) {
+			return calculationError(req, "Modulo requires integer operands", params.Operation, operands), nil
+		}
+		if b == 0 {
+			return calculationError(req, "Modulo by zero", params.Operation, operands), nil
+		}
+		result = math.Mod(a, b)
+	case "pow", "^", "power":
+		result = math.Pow(a, b)
+	case "min":
+		result = math.Min(a, b)
+	case "max":
+		result = math.Max(a, b)
+	case "sqrt":
+		if a < 0 {
+			return calculationError(req, "Square root of negative number", params.Operation, operands), nil
+		}
+		result = math.Sqrt(a)
+	case "abs":
+		result = math.Abs(a)
 	default:
-		return &types.JSONRPCResponse{
-			JSONRPC: "2.0",
-			Error:   types.NewInvalidParamsError("Invalid operation"),
-			ID:      req.ID,
-		}, nil
+		return calculationError(req, "Invalid operation", params.Operation, operands), nil
+	}
+
+	// NaN and infinities cannot be encoded in JSON
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return calculationError(req, "Result is not a finite number", params.Operation, operands), nil
 	}
 
 	// Return result in expected format
@@ -125,13 +183,19 @@ func CalculateHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*ty
 		Result: map[string]interface{}{
 			"result":     result,
 			"operation":  params.Operation,
-			"operands":   []float64{a, b},
+			"operands":   operands,
 			"request_id": ctx.RequestID,
 		},
 		ID: req.ID,
 	}, nil
 }
 
+// unaryOperations lists CalculateHandler operations that take a single operand
+var unaryOperations = map[string]bool{
+	"sqrt": true,
+	"abs":  true,
+}
+
 // convertToFloat64 safely converts interface{} to float64
 func convertToFloat64(v interface{}) (float64, bool) {
 	switch val := v.(type) {
@@ -176,10 +240,62 @@ func StatusHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types
 	}, nil
 }
 
-// TimeHandler returns current server time
+// timeFormatters maps format names accepted by TimeHandler to their renderers
+var timeFormatters = map[string]func(time.Time) interface{}{
+	"rfc3339":     func(t time.Time) interface{} { return t.Format(time.RFC3339) },
+	"rfc3339nano": func(t time.Time) interface{} { return t.Format(time.RFC3339Nano) },
+	"rfc1123":     func(t time.Time) interface{} { return t.Format(time.RFC1123) },
+	"rfc822":      func(t time.Time) interface{} { return t.Format(time.RFC822) },
+	"ansic":       func(t time.Time) interface{} { return t.Format(time.ANSIC) },
+	"kitchen":     func(t time.Time) interface{} { return t.Format(time.Kitchen) },
+	"unix":        func(t time.Time) interface{} { return t.Unix() },
+	"unixmilli":   func(t time.Time) interface{} { return t.UnixMilli() },
+	"unixnano":    func(t time.Time) interface{} { return t.UnixNano() },
+}
+
+// TimeHandler returns current server time.
+// An optional "formats" param selects the representations to return
 func TimeHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
 	now := time.Now()
 
+	var params struct {
+		Formats []string `json:"formats"`
+	}
+
+	if req.HasParams() && !req.HasNullParams() {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewParseError(nil),
+				ID:      req.ID,
+			}, nil
+		}
+	}
+
+	if len(params.Formats) > 0 {
+		result := map[string]interface{}{
+			"request_id": ctx.RequestID,
+		}
+
+		for _, format := range params.Formats {
+			formatter, ok := timeFormatters[format]
+			if !ok {
+				return &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewInvalidParamsError("unknown time format: " + format),
+					ID:      req.ID,
+				}, nil
+			}
+			result[format] = formatter(now)
+		}
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Result:  result,
+			ID:      req.ID,
+		}, nil
+	}
+
 	result := map[string]interface{}{
 		"time":        now.Format(time.RFC3339), // Добавить это поле
 		"timestamp":   now.Format(time.RFC3339),
@@ -197,10 +313,15 @@ func TimeHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.J
 	}, nil
 }
 
-// TestSlowHandler simulates a slow operation for testing timeouts
+// TestSlowHandler simulates a slow operation for testing timeouts. It stops early
+// with the context error when the request is cancelled, e.g. the client disconnects
 func TestSlowHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
-	// Sleep for 2 seconds to simulate slow operation
-	time.Sleep(2 * time.Second)
+	// Wait for 2 seconds to simulate slow operation
+	select {
+	case <-time.After(2 * time.Second):
+	case <-ctx.Context().Done():
+		return nil, ctx.Context().Err()
+	}
 
 	return &types.JSONRPCResponse{
 		JSONRPC: "2.0",
@@ -208,3 +329,89 @@ func TestSlowHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*typ
 		ID:      req.ID,
 	}, nil
 }
+
+// Limits of GenerateHandler payloads
+const (
+	// DefaultGenerateFields is the number of fields generated when none is requested
+	DefaultGenerateFields = 10
+	// DefaultGenerateValueSize is the length of each generated value by default
+	DefaultGenerateValueSize = 16
+	// MaxGenerateFields caps the number of generated fields
+	MaxGenerateFields = 10000
+	// MaxGenerateValueSize caps the length of each generated value
+	MaxGenerateValueSize = 4096
+	// MaxGenerateBytes caps the total size of generated values (fields * value_size)
+	MaxGenerateBytes = 4 << 20
+)
+
+// generateAlphabet is the character set of generated values
+const generateAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// GenerateHandler returns an object of random string values for load testing.
+// Params: "fields" - number of fields (key_0 ... key_N-1), "value_size" - length
+// of each value. It is not registered by default: even capped at MaxGenerateBytes
+// a response is far larger than its request
+func GenerateHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+	params := struct {
+		Fields    int `json:"fields"`
+		ValueSize int `json:"value_size"`
+	}{
+		Fields:    DefaultGenerateFields,
+		ValueSize: DefaultGenerateValueSize,
+	}
+
+	if req.HasParams() && !req.HasNullParams() {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidParamsError("Invalid generate parameters: " + err.Error()),
+				ID:      req.ID,
+			}, nil
+		}
+	}
+
+	if params.Fields < 0 || params.Fields > MaxGenerateFields {
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInvalidParamsError(fmt.Sprintf("fields must be between 0 and %d", MaxGenerateFields)),
+			ID:      req.ID,
+		}, nil
+	}
+	if params.ValueSize < 0 || params.ValueSize > MaxGenerateValueSize {
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInvalidParamsError(fmt.Sprintf("value_size must be between 0 and %d", MaxGenerateValueSize)),
+			ID:      req.ID,
+		}, nil
+	}
+	if params.Fields*params.ValueSize > MaxGenerateBytes {
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInvalidParamsError(fmt.Sprintf("fields * value_size must not exceed %d bytes", MaxGenerateBytes)),
+			ID:      req.ID,
+		}, nil
+	}
+
+	return &types.JSONRPCResponse{
+		JSONRPC: "2.0",
+		Result: map[string]interface{}{
+			"data":       generateObject(params.Fields, params.ValueSize),
+			"fields":     params.Fields,
+			"request_id": ctx.RequestID,
+		},
+		ID: req.ID,
+	}, nil
+}
+
+// generateObject builds an object with the given number of random string fields
+func generateObject(fields, valueSize int) map[string]string {
+	obj := make(map[string]string, fields)
+	value := make([]byte, valueSize)
+	for i := 0; i < fields; i++ {
+		for j := range value {
+			value[j] = generateAlphabet[rand.Intn(len(generateAlphabet))]
+		}
+		obj[fmt.Sprintf("key_%d", i)] = string(value)
+	}
+	return obj
+}
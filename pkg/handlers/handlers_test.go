@@ -3,7 +3,9 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"streaming-server/pkg/types"
 
@@ -46,6 +48,14 @@ func TestEchoHandler(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name:   "Echo with explicit null params",
+			params: json.RawMessage(`null`),
+			expectedResult: map[string]interface{}{
+				"echo": map[string]interface{}(nil),
+			},
+			expectError: false,
+		},
 		{
 			name:   "Echo with empty object",
 			params: json.RawMessage(`{}`),
@@ -171,7 +181,7 @@ func TestCalculateHandler(t *testing.T) {
 		},
 		{
 			name:         "Invalid operation",
-			params:       json.RawMessage(`{"operation": "modulo", "a": 10, "b": 3}`),
+			params:       json.RawMessage(`{"operation": "logarithm", "a": 10, "b": 3}`),
 			expectError:  true,
 			expectedCode: -32602, // Invalid params
 		},
@@ -211,6 +221,12 @@ func TestCalculateHandler(t *testing.T) {
 			expectError:  true,
 			expectedCode: -32602, // Invalid params
 		},
+		{
+			name:         "Explicit null params",
+			params:       json.RawMessage(`null`),
+			expectError:  true,
+			expectedCode: -32602, // Invalid params
+		},
 		{
 			name:         "Invalid JSON",
 			params:       json.RawMessage(`{"operation": "add", "a": 5, "b":}`),
@@ -271,6 +287,126 @@ func TestCalculateHandler(t *testing.T) {
 	}
 }
 
+func TestCalculateHandler_ExtendedOperations(t *testing.T) {
+	tests := []struct {
+		name             string
+		params           json.RawMessage
+		expectedResult   float64
+		expectedOperands int
+		expectedCode     int
+	}{
+		{"Power", json.RawMessage(`{"operation": "pow", "a": 2, "b": 10}`), 1024, 2, 0},
+		{"Power with ^ operator", json.RawMessage(`{"operation": "^", "a": 3, "b": 2}`), 9, 2, 0},
+		{"Modulo", json.RawMessage(`{"operation": "mod", "a": 10, "b": 3}`), 1, 2, 0},
+		{"Modulo with % operator", json.RawMessage(`{"operation": "%", "a": 7.5, "b": 2}`), 1.5, 2, 0},
+		{"Min", json.RawMessage(`{"operation": "min", "a": -4, "b": 3}`), -4, 2, 0},
+		{"Max", json.RawMessage(`{"operation": "max", "a": -4, "b": 3}`), 3, 2, 0},
+		{"Square root", json.RawMessage(`{"operation": "sqrt", "a": 16}`), 4, 1, 0},
+		{"Square root ignores b", json.RawMessage(`{"operation": "sqrt", "a": 9, "b": 100}`), 3, 1, 0},
+		{"Absolute value", json.RawMessage(`{"operation": "abs", "a": -7.25}`), 7.25, 1, 0},
+		{"Power by name", json.RawMessage(`{"operation": "power", "a": 2, "b": -1}`), 0.5, 2, 0},
+		{"Power with zero exponent", json.RawMessage(`{"operation": "power", "a": 0, "b": 0}`), 1, 2, 0},
+		{"Integer modulo", json.RawMessage(`{"operation": "modulo", "a": 17, "b": 5}`), 2, 2, 0},
+		{"Integer modulo keeps dividend sign", json.RawMessage(`{"operation": "modulo", "a": -7, "b": 3}`), -1, 2, 0},
+		{"Integer modulo with float-encoded integers", json.RawMessage(`{"operation": "modulo", "a": 9.0, "b": 4.0}`), 1, 2, 0},
+		{"Square root of zero", json.RawMessage(`{"operation": "sqrt", "a": 0}`), 0, 1, 0},
+		{"Square root of fraction", json.RawMessage(`{"operation": "sqrt", "a": 2.25}`), 1.5, 1, 0},
+
+		{"Modulo by zero", json.RawMessage(`{"operation": "mod", "a": 10, "b": 0}`), 0, 0, -32602},
+		{"Square root of negative", json.RawMessage(`{"operation": "sqrt", "a": -1}`), 0, 0, -32602},
+		{"Square root without operand", json.RawMessage(`{"operation": "sqrt"}`), 0, 0, -32602},
+		{"Absolute value of string", json.RawMessage(`{"operation": "abs", "a": "x"}`), 0, 0, -32602},
+		{"Min missing b", json.RawMessage(`{"operation": "min", "a": 1}`), 0, 0, -32602},
+		{"Power overflow", json.RawMessage(`{"operation": "pow", "a": 10, "b": 400}`), 0, 0, -32602},
+		{"Power with NaN result", json.RawMessage(`{"operation": "pow", "a": -8, "b": 0.5}`), 0, 0, -32602},
+		{"Power by name overflow", json.RawMessage(`{"operation": "power", "a": 0, "b": -1}`), 0, 0, -32602},
+		{"Integer modulo by zero", json.RawMessage(`{"operation": "modulo", "a": 10, "b": 0}`), 0, 0, -32602},
+		{"Integer modulo with fractional dividend", json.RawMessage(`{"operation": "modulo", "a": 7.5, "b": 2}`), 0, 0, -32602},
+		{"Integer modulo with fractional divisor", json.RawMessage(`{"operation": "modulo", "a": 7, "b": 0.5}`), 0, 0, -32602},
+		{"Integer modulo missing b", json.RawMessage(`{"operation": "modulo", "a": 7}`), 0, 0, -32602},
+		{"Square root of negative fraction", json.RawMessage(`{"operation": "sqrt", "a": -0.25}`), 0, 0, -32602},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &types.JSONRPCRequest{
+				JSONRPC: "2.0",
+				Method:  "calculate",
+				Params:  tt.params,
+				ID:      "test-1",
+			}
+			ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+			response, err := CalculateHandler(request, ctx)
+			require.NoError(t, err)
+			require.NotNil(t, response)
+
+			if tt.expectedCode != 0 {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, tt.expectedCode, response.Error.Code)
+				assert.Nil(t, response.Result)
+				return
+			}
+
+			require.Nil(t, response.Error)
+			result, ok := response.Result.(map[string]interface{})
+			require.True(t, ok)
+			assert.InDelta(t, tt.expectedResult, result["result"], 1e-9)
+
+			var params struct {
+				Operation string `json:"operation"`
+			}
+			require.NoError(t, json.Unmarshal(tt.params, &params))
+			assert.Equal(t, params.Operation, result["operation"])
+
+			operands, ok := result["operands"].([]float64)
+			require.True(t, ok)
+			assert.Len(t, operands, tt.expectedOperands)
+		})
+	}
+}
+
+func TestCalculateHandler_ErrorData(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   string
+		expected CalculationErrorData
+	}{
+		{"Division by zero", `{"operation": "divide", "a": 10, "b": 0}`, CalculationErrorData{"Division by zero", "divide", []float64{10, 0}}},
+		{"Division by zero with operator", `{"operation": "/", "a": -1.5, "b": 0}`, CalculationErrorData{"Division by zero", "/", []float64{-1.5, 0}}},
+		{"Modulo by zero", `{"operation": "mod", "a": 10, "b": 0}`, CalculationErrorData{"Modulo by zero", "mod", []float64{10, 0}}},
+		{"Integer modulo by zero", `{"operation": "modulo", "a": 10, "b": 0}`, CalculationErrorData{"Modulo by zero", "modulo", []float64{10, 0}}},
+		{"Integer modulo with fractions", `{"operation": "modulo", "a": 7.5, "b": 2}`, CalculationErrorData{"Modulo requires integer operands", "modulo", []float64{7.5, 2}}},
+		{"Square root of negative", `{"operation": "sqrt", "a": -4}`, CalculationErrorData{"Square root of negative number", "sqrt", []float64{-4}}},
+		{"Invalid operation", `{"operation": "cube", "a": 2, "b": 3}`, CalculationErrorData{"Invalid operation", "cube", []float64{2, 3}}},
+		{"Non-finite result", `{"operation": "pow", "a": 10, "b": 400}`, CalculationErrorData{"Result is not a finite number", "pow", []float64{10, 400}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "calculate", Params: json.RawMessage(tt.params), ID: 1}
+			ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+			response, err := CalculateHandler(request, ctx)
+			require.NoError(t, err)
+			require.NotNil(t, response.Error)
+			assert.Equal(t, types.InvalidParams, response.Error.Code)
+			assert.Equal(t, "Invalid params: "+tt.expected.Reason, response.Error.Message)
+			assert.Equal(t, tt.expected, response.Error.Data)
+
+			// Clients see the context as a JSON object
+			encoded, err := json.Marshal(response.Error)
+			require.NoError(t, err)
+			var decoded struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(encoded, &decoded))
+			assert.Equal(t, tt.expected.Operation, decoded.Data["operation"])
+			assert.Len(t, decoded.Data["operands"], len(tt.expected.Operands))
+		})
+	}
+}
+
 func TestStatusHandler(t *testing.T) {
 	request := &types.JSONRPCRequest{
 		JSONRPC: "2.0",
@@ -343,6 +479,100 @@ func TestTimeHandler(t *testing.T) {
 	assert.Equal(t, ctx.RequestID, result["request_id"])
 }
 
+func TestTimeHandler_Formats(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       json.RawMessage
+		expectedKeys []string
+		expectedCode int
+	}{
+		{
+			name:         "Selected formats",
+			params:       json.RawMessage(`{"formats": ["rfc3339", "unix", "unixnano", "kitchen"]}`),
+			expectedKeys: []string{"rfc3339", "unix", "unixnano", "kitchen"},
+		},
+		{
+			name:         "Single format",
+			params:       json.RawMessage(`{"formats": ["unixmilli"]}`),
+			expectedKeys: []string{"unixmilli"},
+		},
+		{
+			name:         "Empty formats fall back to default fields",
+			params:       json.RawMessage(`{"formats": []}`),
+			expectedKeys: []string{"time", "timestamp", "formatted", "unix", "timezone", "server_time"},
+		},
+		{
+			name:         "Null params fall back to default fields",
+			params:       json.RawMessage(`null`),
+			expectedKeys: []string{"time", "timestamp", "formatted", "unix", "timezone", "server_time"},
+		},
+		{
+			name:         "Unknown format",
+			params:       json.RawMessage(`{"formats": ["unix", "stardate"]}`),
+			expectedCode: -32602,
+		},
+		{
+			name:         "Malformed formats",
+			params:       json.RawMessage(`{"formats": "unix"}`),
+			expectedCode: -32700,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &types.JSONRPCRequest{
+				JSONRPC: "2.0",
+				Method:  "time",
+				Params:  tt.params,
+				ID:      "test-1",
+			}
+			ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+			response, err := TimeHandler(request, ctx)
+			require.NoError(t, err)
+			require.NotNil(t, response)
+
+			if tt.expectedCode != 0 {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, tt.expectedCode, response.Error.Code)
+				return
+			}
+
+			require.Nil(t, response.Error)
+			result, ok := response.Result.(map[string]interface{})
+			require.True(t, ok)
+
+			assert.Len(t, result, len(tt.expectedKeys)+1)
+			for _, key := range tt.expectedKeys {
+				assert.Contains(t, result, key)
+			}
+			assert.Equal(t, ctx.RequestID, result["request_id"])
+		})
+	}
+}
+
+func TestTimeHandler_FormatValues(t *testing.T) {
+	request := &types.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "time",
+		Params:  json.RawMessage(`{"formats": ["rfc3339", "unix", "unixnano", "kitchen"]}`),
+		ID:      "test-1",
+	}
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	response, err := TimeHandler(request, ctx)
+	require.NoError(t, err)
+	result := response.Result.(map[string]interface{})
+
+	parsed, err := time.Parse(time.RFC3339, result["rfc3339"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, parsed.Unix(), result["unix"])
+	assert.IsType(t, int64(0), result["unixnano"])
+
+	_, err = time.Parse(time.Kitchen, result["kitchen"].(string))
+	assert.NoError(t, err)
+}
+
 func TestTestSlowHandler(t *testing.T) {
 	request := &types.JSONRPCRequest{
 		JSONRPC: "2.0",
@@ -369,6 +599,87 @@ func TestTestSlowHandler(t *testing.T) {
 	_ = start // Use the variable to avoid unused variable error
 }
 
+func TestTestSlowHandler_Cancelled(t *testing.T) {
+	request := &types.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "test_slow",
+		ID:      "test-1",
+	}
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	ctx := types.NewRequestContext(cancelCtx, "test-service", "127.0.0.1")
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	response, err := TestSlowHandler(request, ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, response)
+	assert.Less(t, ctx.Duration(), time.Second, "Handler should stop once the context is cancelled")
+}
+
+func TestGenerateHandler(t *testing.T) {
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	tests := []struct {
+		name      string
+		params    json.RawMessage
+		fields    int
+		valueSize int
+	}{
+		{name: "defaults", params: nil, fields: DefaultGenerateFields, valueSize: DefaultGenerateValueSize},
+		{name: "null params", params: json.RawMessage(`null`), fields: DefaultGenerateFields, valueSize: DefaultGenerateValueSize},
+		{name: "custom size", params: json.RawMessage(`{"fields": 1000, "value_size": 4}`), fields: 1000, valueSize: 4},
+		{name: "empty object", params: json.RawMessage(`{"fields": 0}`), fields: 0, valueSize: DefaultGenerateValueSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "generate", Params: tt.params, ID: 1}
+
+			response, err := GenerateHandler(request, ctx)
+			require.NoError(t, err)
+			require.Nil(t, response.Error)
+
+			// Round-trip through JSON as a client would see it
+			data, err := json.Marshal(response.Result)
+			require.NoError(t, err)
+			var result struct {
+				Data   map[string]string `json:"data"`
+				Fields int               `json:"fields"`
+			}
+			require.NoError(t, json.Unmarshal(data, &result))
+
+			assert.Equal(t, tt.fields, result.Fields)
+			assert.Len(t, result.Data, tt.fields)
+			for i := 0; i < tt.fields; i++ {
+				value, ok := result.Data[fmt.Sprintf("key_%d", i)]
+				require.True(t, ok, "key_%d", i)
+				assert.Len(t, value, tt.valueSize)
+			}
+		})
+	}
+}
+
+func TestGenerateHandler_InvalidParams(t *testing.T) {
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	for _, params := range []string{
+		`{"fields": "many"}`,
+		`{"fields": -1}`,
+		`{"fields": 10001}`,
+		`{"value_size": 5000}`,
+		`{"fields": 10000, "value_size": 4096}`,
+		`{"fields": 2000, "value_size": 2098}`,
+	} {
+		request := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "generate", Params: json.RawMessage(params), ID: 1}
+
+		response, err := GenerateHandler(request, ctx)
+		require.NoError(t, err)
+		require.NotNil(t, response.Error, params)
+		assert.Equal(t, types.InvalidParams, response.Error.Code, params)
+	}
+}
+
 func TestConvertToFloat64(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,14 +11,17 @@ import (
 type AsyncProcessor interface {
 	Process(ctx context.Context, fn func()) error
 	ProcessWithTimeout(ctx context.Context, fn func(), timeout time.Duration) error
-	Shutdown(ctx context.Context) error
+	// Shutdown ожидает завершения принятых функций и возвращает число
+	// незавершенных, если ожидание прервано контекстом
+	Shutdown(ctx context.Context) (int, error)
 }
 
 // DefaultAsyncProcessor реализует AsyncProcessor с использованием горутин
 type DefaultAsyncProcessor struct {
-	wg     sync.WaitGroup
-	ctx    context.Context
-	cancel context.CancelFunc
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending int64
 }
 
 // NewDefaultAsyncProcessor создает новый DefaultAsyncProcessor
@@ -38,8 +42,10 @@ func (p *DefaultAsyncProcessor) Process(ctx context.Context, fn func()) error {
 		return ctx.Err()
 	default:
 		p.wg.Add(1)
+		atomic.AddInt64(&p.pending, 1)
 		go func() {
 			defer p.wg.Done()
+			defer atomic.AddInt64(&p.pending, -1)
 			defer func() {
 				if r := recover(); r != nil {
 					// Логируем панику, но не крашим программу
@@ -76,8 +82,9 @@ func (p *DefaultAsyncProcessor) ProcessWithTimeout(ctx context.Context, fn func(
 	}
 }
 
-// Shutdown корректно завершает работу процессора
-func (p *DefaultAsyncProcessor) Shutdown(ctx context.Context) error {
+// Shutdown корректно завершает работу процессора. Если ctx завершится раньше,
+// возвращается число функций, которые еще не выполнены, и ошибка контекста
+func (p *DefaultAsyncProcessor) Shutdown(ctx context.Context) (int, error) {
 	p.cancel()
 
 	done := make(chan struct{})
@@ -88,9 +95,9 @@ func (p *DefaultAsyncProcessor) Shutdown(ctx context.Context) error {
 
 	select {
 	case <-done:
-		return nil
+		return 0, nil
 	case <-ctx.Done():
-		return ctx.Err()
+		return int(atomic.LoadInt64(&p.pending)), ctx.Err()
 	}
 }
 
@@ -99,6 +106,7 @@ type MockAsyncProcessor struct {
 	processedFunctions []func()
 	processErrors      []error
 	shutdownError      error
+	shutdownPending    int
 	mu                 sync.Mutex
 }
 
@@ -131,9 +139,11 @@ func (m *MockAsyncProcessor) ProcessWithTimeout(ctx context.Context, fn func(),
 	return m.Process(ctx, fn)
 }
 
-// Shutdown возвращает настроенную ошибку завершения
-func (m *MockAsyncProcessor) Shutdown(ctx context.Context) error {
-	return m.shutdownError
+// Shutdown возвращает настроенные число незавершенных функций и ошибку завершения
+func (m *MockAsyncProcessor) Shutdown(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.shutdownPending, m.shutdownError
 }
 
 // ExecuteProcessedFunctions выполняет все записанные функции синхронно
@@ -171,6 +181,13 @@ func (m *MockAsyncProcessor) SetShutdownError(err error) {
 	m.shutdownError = err
 }
 
+// SetShutdownPending устанавливает число незавершенных функций, которое вернет Shutdown
+func (m *MockAsyncProcessor) SetShutdownPending(pending int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shutdownPending = pending
+}
+
 // Reset очищает все записанное состояние
 func (m *MockAsyncProcessor) Reset() {
 	m.mu.Lock()
@@ -178,4 +195,5 @@ func (m *MockAsyncProcessor) Reset() {
 	m.processedFunctions = nil
 	m.processErrors = nil
 	m.shutdownError = nil
+	m.shutdownPending = 0
 }
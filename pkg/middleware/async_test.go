@@ -83,25 +83,30 @@ func TestDefaultAsyncProcessor_Shutdown(t *testing.T) {
 	}
 
 	// Shutdown should wait for all work to complete
-	err := processor.Shutdown(context.Background())
+	pending, err := processor.Shutdown(context.Background())
 	assert.NoError(t, err)
+	assert.Equal(t, 0, pending)
 }
 
 func TestDefaultAsyncProcessor_ShutdownWithTimeout(t *testing.T) {
 	processor := NewDefaultAsyncProcessor()
 
-	// Start some slow work
-	processor.Process(context.Background(), func() {
-		time.Sleep(200 * time.Millisecond)
-	})
+	// Start some slow work and one quick task that finishes in time
+	for i := 0; i < 2; i++ {
+		processor.Process(context.Background(), func() {
+			time.Sleep(200 * time.Millisecond)
+		})
+	}
+	processor.Process(context.Background(), func() {})
 
 	// Shutdown with short timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 	defer cancel()
 
-	err := processor.Shutdown(ctx)
+	pending, err := processor.Shutdown(ctx)
 	assert.Error(t, err)
 	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 2, pending, "unfinished work is reported")
 }
 
 func TestMockAsyncProcessor_Process(t *testing.T) {
@@ -155,10 +160,12 @@ func TestMockAsyncProcessor_Shutdown(t *testing.T) {
 
 	expectedError := errors.New("shutdown error")
 	processor.SetShutdownError(expectedError)
+	processor.SetShutdownPending(3)
 
-	err := processor.Shutdown(context.Background())
+	pending, err := processor.Shutdown(context.Background())
 	assert.Error(t, err)
 	assert.Equal(t, expectedError, err)
+	assert.Equal(t, 3, pending)
 }
 
 func TestMockAsyncProcessor_Reset(t *testing.T) {
@@ -180,7 +187,7 @@ func TestMockAsyncProcessor_Reset(t *testing.T) {
 	err := processor.Process(context.Background(), func() {})
 	assert.NoError(t, err)
 
-	err = processor.Shutdown(context.Background())
+	_, err = processor.Shutdown(context.Background())
 	assert.NoError(t, err)
 }
 
@@ -0,0 +1,68 @@
+package middleware
+
+import "container/list"
+
+// DefaultMaxClients - число клиентов, состояние которых хранится по умолчанию
+const DefaultMaxClients = 100000
+
+// clientTable хранит состояние клиентов по ключу (IP адрес, тенант) и
+// ограничивает число записей: при превышении maxEntries вытесняется клиент,
+// дольше всех не обращавшийся к таблице. Таблица не синхронизирована, ее
+// защищает мьютекс владельца
+type clientTable struct {
+	maxEntries int
+	entries    map[string]*list.Element
+	// order упорядочивает записи от недавно использованных к давно использованным
+	order *list.List
+}
+
+// clientTableEntry - запись таблицы; ключ нужен для удаления при вытеснении
+type clientTableEntry struct {
+	key   string
+	value interface{}
+}
+
+// newClientTable создает таблицу на maxEntries клиентов; maxEntries <= 0
+// означает DefaultMaxClients
+func newClientTable(maxEntries int) *clientTable {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxClients
+	}
+	return &clientTable{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// get возвращает состояние клиента и отмечает клиента как недавно использованного
+func (t *clientTable) get(key string) (interface{}, bool) {
+	element, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+	t.order.MoveToFront(element)
+	return element.Value.(*clientTableEntry).value, true
+}
+
+// add сохраняет состояние нового клиента и вытесняет давно использованных
+// клиентов сверх maxEntries
+func (t *clientTable) add(key string, value interface{}) {
+	if element, ok := t.entries[key]; ok {
+		element.Value.(*clientTableEntry).value = value
+		t.order.MoveToFront(element)
+		return
+	}
+
+	t.entries[key] = t.order.PushFront(&clientTableEntry{key: key, value: value})
+	for t.order.Len() > t.maxEntries {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.entries, oldest.Value.(*clientTableEntry).key)
+	}
+}
+
+// len возвращает число клиентов в таблице
+func (t *clientTable) len() int {
+	return t.order.Len()
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientTable_EvictsLeastRecentlySeen(t *testing.T) {
+	table := newClientTable(2)
+	table.add("a", 1)
+	table.add("b", 2)
+
+	// Touching "a" makes "b" the least recently seen client
+	value, ok := table.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	table.add("c", 3)
+	assert.Equal(t, 2, table.len())
+	_, ok = table.get("b")
+	assert.False(t, ok, "the least recently seen client is evicted")
+	_, ok = table.get("a")
+	assert.True(t, ok, "an active client is kept")
+	_, ok = table.get("c")
+	assert.True(t, ok)
+}
+
+func TestClientTable_AddExistingKey(t *testing.T) {
+	table := newClientTable(2)
+	table.add("a", 1)
+	table.add("b", 2)
+	table.add("a", 10)
+
+	assert.Equal(t, 2, table.len())
+	table.add("c", 3)
+
+	value, ok := table.get("a")
+	assert.True(t, ok, "re-adding a client marks it as recently seen")
+	assert.Equal(t, 10, value)
+	_, ok = table.get("b")
+	assert.False(t, ok)
+}
+
+func TestClientTable_DefaultCap(t *testing.T) {
+	table := newClientTable(0)
+	assert.Equal(t, DefaultMaxClients, table.maxEntries)
+
+	table = newClientTable(100)
+	for i := 0; i < 1000; i++ {
+		table.add(fmt.Sprintf("10.0.%d.%d", i/256, i%256), i)
+	}
+	assert.Equal(t, 100, table.len(), "the table never grows past its cap")
+	assert.Len(t, table.entries, 100)
+}
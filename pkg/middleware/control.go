@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"streaming-server/pkg/types"
+)
+
+// ControlCharPolicy определяет, как обрабатывать управляющие символы в строковых параметрах
+type ControlCharPolicy string
+
+const (
+	// ControlCharPolicyReject отклоняет запрос ошибкой неверных параметров (-32602)
+	ControlCharPolicyReject ControlCharPolicy = "reject"
+	// ControlCharPolicyStrip удаляет управляющие символы из строк
+	ControlCharPolicyStrip ControlCharPolicy = "strip"
+)
+
+// ControlCharMiddleware проверяет строковые значения и ключи параметров на наличие
+// управляющих символов (нулевые байты, переводы строк и т.п.). В зависимости от
+// политики запрос отклоняется или символы удаляются перед передачей обработчику
+func ControlCharMiddleware(policy ControlCharPolicy) types.Middleware {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		if !mayContainControlChars(req.Params) {
+			return next(req, ctx)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(req.Params))
+		decoder.UseNumber()
+
+		var params interface{}
+		if err := decoder.Decode(&params); err != nil {
+			// Некорректные параметры обрабатывает обработчик
+			return next(req, ctx)
+		}
+
+		cleaned, found := stripControlChars(params)
+		if !found {
+			return next(req, ctx)
+		}
+
+		if policy != ControlCharPolicyStrip {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidParamsError("Params contain control characters"),
+				ID:      req.ID,
+			}, nil
+		}
+
+		data, err := json.Marshal(cleaned)
+		if err != nil {
+			return nil, err
+		}
+
+		sanitized := *req
+		sanitized.Params = data
+		return next(&sanitized, ctx)
+	}
+}
+
+// mayContainControlChars быстро проверяет исходные параметры без разбора JSON.
+// Символы C0 внутри строк допустимы только в экранированном виде, а DEL (0x7F)
+// и символы C1 (U+0080-U+009F, в UTF-8 - 0xC2 0x80-0x9F) JSON разрешает и без экранирования
+func mayContainControlChars(params []byte) bool {
+	for i, b := range params {
+		switch {
+		case b == '\\' || b == 0x7F:
+			return true
+		case b == 0xC2 && i+1 < len(params) && params[i+1] >= 0x80 && params[i+1] <= 0x9F:
+			return true
+		}
+	}
+	return false
+}
+
+// stripControlChars рекурсивно удаляет управляющие символы из строк и ключей объектов.
+// Второе значение сообщает, были ли найдены такие символы
+func stripControlChars(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		if strings.IndexFunc(v, unicode.IsControl) < 0 {
+			return v, false
+		}
+		return strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, v), true
+	case []interface{}:
+		found := false
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			var itemFound bool
+			result[i], itemFound = stripControlChars(item)
+			found = found || itemFound
+		}
+		return result, found
+	case map[string]interface{}:
+		found := false
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			cleanKey, keyFound := stripControlChars(key)
+			cleanItem, itemFound := stripControlChars(item)
+			result[cleanKey.(string)] = cleanItem
+			found = found || keyFound || itemFound
+		}
+		return result, found
+	default:
+		return v, false
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlCharMiddleware(t *testing.T) {
+	nullParams := json.RawMessage(`{"message":"bad\u0000value","count":12345678901234567890}`)
+	newlineParams := json.RawMessage(`["line one\nline two", {"nested\r\n":"tab\there"}]`)
+	cleanParams := json.RawMessage(`{"message":"quote \" and slash \\ are fine"}`)
+
+	tests := []struct {
+		name           string
+		policy         ControlCharPolicy
+		params         json.RawMessage
+		expectError    bool
+		expectedParams string
+	}{
+		{
+			name:        "Null byte rejected",
+			policy:      ControlCharPolicyReject,
+			params:      nullParams,
+			expectError: true,
+		},
+		{
+			name:        "Newlines rejected",
+			policy:      ControlCharPolicyReject,
+			params:      newlineParams,
+			expectError: true,
+		},
+		{
+			name:           "Null byte stripped",
+			policy:         ControlCharPolicyStrip,
+			params:         nullParams,
+			expectedParams: `{"count":12345678901234567890,"message":"badvalue"}`,
+		},
+		{
+			name:           "Newlines stripped from values and keys",
+			policy:         ControlCharPolicyStrip,
+			params:         newlineParams,
+			expectedParams: `["line oneline two",{"nested":"tabhere"}]`,
+		},
+		{
+			name:        "Raw DEL rejected",
+			policy:      ControlCharPolicyReject,
+			params:      json.RawMessage("{\"message\":\"bad\x7fvalue\"}"),
+			expectError: true,
+		},
+		{
+			name:           "Raw C1 character stripped",
+			policy:         ControlCharPolicyStrip,
+			params:         json.RawMessage("{\"message\":\"bad\u0085value\"}"),
+			expectedParams: `{"message":"badvalue"}`,
+		},
+		{
+			name:           "Non-control multibyte characters pass unchanged",
+			policy:         ControlCharPolicyReject,
+			params:         json.RawMessage(`{"message":"café ©"}`),
+			expectedParams: `{"message":"café ©"}`,
+		},
+		{
+			name:           "Escapes without control characters pass unchanged",
+			policy:         ControlCharPolicyReject,
+			params:         cleanParams,
+			expectedParams: string(cleanParams),
+		},
+		{
+			name:           "Plain params pass unchanged",
+			policy:         ControlCharPolicyStrip,
+			params:         json.RawMessage(`{"a": 1}`),
+			expectedParams: `{"a": 1}`,
+		},
+		{
+			name:           "Missing params pass",
+			policy:         ControlCharPolicyReject,
+			params:         nil,
+			expectedParams: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := ControlCharMiddleware(tt.policy)
+			req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", Params: tt.params, ID: "test-1"}
+			ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+			var receivedParams json.RawMessage
+			handlerCalled := false
+			handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+				handlerCalled = true
+				receivedParams = req.Params
+				return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+			}
+
+			response, err := mw(req, ctx, handler)
+			require.NoError(t, err)
+			require.NotNil(t, response)
+
+			if tt.expectError {
+				assert.False(t, handlerCalled)
+				require.NotNil(t, response.Error)
+				assert.Equal(t, types.InvalidParams, response.Error.Code)
+				assert.Equal(t, "test-1", response.ID)
+				return
+			}
+
+			assert.True(t, handlerCalled)
+			assert.Nil(t, response.Error)
+			assert.Equal(t, tt.expectedParams, string(receivedParams))
+		})
+	}
+}
+
+func TestControlCharMiddleware_StripDoesNotMutateRequest(t *testing.T) {
+	mw := ControlCharMiddleware(ControlCharPolicyStrip)
+	original := `{"message":"a\u0000b"}`
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", Params: json.RawMessage(original), ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	_, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, original, string(req.Params))
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"streaming-server/pkg/types"
+)
+
+// GRPCTimeoutHeader - HTTP заголовок с таймаутом вызова в формате gRPC
+const GRPCTimeoutHeader = "Grpc-Timeout"
+
+// maxGRPCTimeoutDigits - максимальная длина числа в значении grpc-timeout
+const maxGRPCTimeoutDigits = 8
+
+// grpcTimeoutUnits сопоставляет единицы измерения grpc-timeout длительностям
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// ParseGRPCTimeout разбирает значение заголовка grpc-timeout: от 1 до 8 цифр и
+// единица измерения (H, M, S, m, u, n), например "100m" или "5S"
+func ParseGRPCTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 {
+		return 0, fmt.Errorf("некорректный grpc-timeout %q", value)
+	}
+
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("некорректная единица grpc-timeout %q", value)
+	}
+
+	digits := value[:len(value)-1]
+	if len(digits) > maxGRPCTimeoutDigits {
+		return 0, fmt.Errorf("слишком длинное значение grpc-timeout %q", value)
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, fmt.Errorf("некорректное значение grpc-timeout %q", value)
+		}
+	}
+
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("некорректное значение grpc-timeout %q: %w", value, err)
+	}
+	return time.Duration(amount) * unit, nil
+}
+
+// GRPCTimeoutMiddleware применяет таймаут из заголовка grpc-timeout к контексту
+// обработчика. Запросы без заголовка передаются дальше без изменений; запрос с
+// некорректным значением отклоняется ошибкой -32600. Таймаут только сокращает
+// время выполнения: более раннее ограничение контекста сохраняется
+func GRPCTimeoutMiddleware() types.Middleware {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		value := extractGRPCTimeout(ctx)
+		if value == "" {
+			return next(req, ctx)
+		}
+
+		timeout, err := ParseGRPCTimeout(value)
+		if err != nil {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidRequestError("Invalid grpc-timeout header: " + value),
+				ID:      req.ID,
+			}, nil
+		}
+
+		deadlineCtx, cancel := context.WithTimeout(ctx.Context(), timeout)
+		defer cancel()
+		return next(req, ctx.WithContext(deadlineCtx))
+	}
+}
+
+// extractGRPCTimeout возвращает значение заголовка grpc-timeout
+func extractGRPCTimeout(ctx *types.RequestContext) string {
+	if ctx.HTTPRequest != nil {
+		if value := ctx.HTTPRequest.Header.Get(GRPCTimeoutHeader); value != "" {
+			return value
+		}
+	}
+	return ctx.Headers[GRPCTimeoutHeader]
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"100m", 100 * time.Millisecond},
+		{"5S", 5 * time.Second},
+		{"2H", 2 * time.Hour},
+		{"3M", 3 * time.Minute},
+		{"250u", 250 * time.Microsecond},
+		{"99999999n", 99999999 * time.Nanosecond},
+		{"0m", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			timeout, err := ParseGRPCTimeout(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, timeout)
+		})
+	}
+}
+
+func TestParseGRPCTimeout_Invalid(t *testing.T) {
+	for _, value := range []string{"", "m", "100", "100ms", "10s", "-5S", "1.5S", " 5S", "123456789m", "5x"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := ParseGRPCTimeout(value)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGRPCTimeoutMiddleware(t *testing.T) {
+	mw := GRPCTimeoutMiddleware()
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: 1}
+
+	var deadline time.Time
+	var hasDeadline bool
+	next := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		deadline, hasDeadline = ctx.Context().Deadline()
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+
+	t.Run("HTTP header sets the deadline", func(t *testing.T) {
+		ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+		ctx.HTTPRequest = httptest.NewRequest("POST", "/rpc", nil)
+		ctx.HTTPRequest.Header.Set("grpc-timeout", "5S")
+
+		start := time.Now()
+		response, err := mw(req, ctx, next)
+		require.NoError(t, err)
+		assert.Nil(t, response.Error)
+		require.True(t, hasDeadline)
+		assert.WithinDuration(t, start.Add(5*time.Second), deadline, time.Second)
+	})
+
+	t.Run("Context headers are used without an HTTP request", func(t *testing.T) {
+		ctx := types.NewRequestContext(context.Background(), "WebSocket", "127.0.0.1")
+		ctx.Headers[GRPCTimeoutHeader] = "100m"
+
+		start := time.Now()
+		_, err := mw(req, ctx, next)
+		require.NoError(t, err)
+		require.True(t, hasDeadline)
+		assert.WithinDuration(t, start.Add(100*time.Millisecond), deadline, 50*time.Millisecond)
+	})
+
+	t.Run("Earlier deadline is kept", func(t *testing.T) {
+		parent, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		parentDeadline, _ := parent.Deadline()
+
+		ctx := types.NewRequestContext(parent, "HTTP", "127.0.0.1")
+		ctx.Headers[GRPCTimeoutHeader] = "5S"
+
+		_, err := mw(req, ctx, next)
+		require.NoError(t, err)
+		assert.Equal(t, parentDeadline, deadline)
+	})
+
+	t.Run("No header", func(t *testing.T) {
+		ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+		_, err := mw(req, ctx, next)
+		require.NoError(t, err)
+		assert.False(t, hasDeadline)
+	})
+
+	t.Run("Invalid header is rejected", func(t *testing.T) {
+		called := false
+		ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+		ctx.Headers[GRPCTimeoutHeader] = "soon"
+
+		response, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			called = true
+			return nil, nil
+		})
+		require.NoError(t, err)
+		assert.False(t, called)
+		require.NotNil(t, response.Error)
+		assert.Equal(t, types.InvalidRequest, response.Error.Code)
+		assert.Equal(t, 1, response.ID)
+	})
+}
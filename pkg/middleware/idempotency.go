@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+
+	"streaming-server/pkg/types"
+)
+
+const (
+	// IdempotencyKeyHeader - HTTP заголовок с ключом идемпотентности
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyKeyParam - поле объекта params с ключом идемпотентности
+	// для транспортов без заголовков
+	IdempotencyKeyParam = "idempotency_key"
+	// IdempotencyContextKey - ключ, под которым ключ идемпотентности сохраняется в контексте запроса
+	IdempotencyContextKey = "idempotency_key"
+)
+
+// DefaultIdempotencyCacheSize - количество запоминаемых ответов по умолчанию
+const DefaultIdempotencyCacheSize = 1024
+
+// idempotencyEntry - ответ на вызов с ключом; done закрывается, когда ответ готов.
+// digest - хеш параметров вызова, сохранившего запись
+type idempotencyEntry struct {
+	done     chan struct{}
+	digest   [sha256.Size]byte
+	response *types.JSONRPCResponse
+	ok       bool
+}
+
+// idempotencyCache хранит ответы последних вызовов. Самые старые записи
+// вытесняются при превышении размера
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	order   []string
+	size    int
+}
+
+// begin возвращает запись для ключа и признак того, что вызов выполняет текущий запрос
+func (c *idempotencyCache) begin(key string, digest [sha256.Size]byte) (*idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, exists := c.entries[key]; exists {
+		return entry, false
+	}
+
+	entry := &idempotencyEntry{done: make(chan struct{}), digest: digest}
+	c.entries[key] = entry
+	c.order = append(c.order, key)
+	for len(c.order) > c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	return entry, true
+}
+
+// finish сохраняет ответ. Неуспешные вызовы забываются, чтобы клиент мог повторить их
+func (c *idempotencyCache) finish(key string, entry *idempotencyEntry, response *types.JSONRPCResponse, ok bool) {
+	if !ok {
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+			for i, k := range c.order {
+				if k == key {
+					c.order = append(c.order[:i], c.order[i+1:]...)
+					break
+				}
+			}
+		}
+		c.mu.Unlock()
+	}
+
+	entry.response, entry.ok = response, ok
+	close(entry.done)
+}
+
+// IdempotencyMiddleware требует ключ идемпотентности для методов, для которых
+// required возвращает true (например, Dispatcher.IsIdempotent). Ключ берется из
+// заголовка Idempotency-Key или поля params.idempotency_key; вызов без ключа
+// отклоняется ошибкой -32602. Повторный вызов метода с тем же ключом от того же
+// клиента (арендатора или, без него, IP адреса) получает сохраненный ответ без
+// повторного выполнения обработчика; тот же ключ с другими параметрами
+// отклоняется ошибкой -32600. Остальные методы передаются дальше без проверки
+func IdempotencyMiddleware(required func(method string) bool) types.Middleware {
+	cache := &idempotencyCache{
+		entries: make(map[string]*idempotencyEntry),
+		size:    DefaultIdempotencyCacheSize,
+	}
+
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		if required == nil || !required(req.Method) {
+			return next(req, ctx)
+		}
+
+		key := extractIdempotencyKey(req, ctx)
+		if key == "" {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidParamsError("Idempotency key required"),
+				ID:      req.ID,
+			}, nil
+		}
+		ctx.WithValue(IdempotencyContextKey, key)
+
+		cacheKey := IdempotencyScope(ctx) + "\x00" + req.Method + "\x00" + key
+		digest := sha256.Sum256(req.Params)
+		for {
+			entry, owner := cache.begin(cacheKey, digest)
+			if owner {
+				response, err := next(req, ctx)
+				ok := err == nil && response != nil && response.Error == nil
+				cache.finish(cacheKey, entry, response, ok)
+				return response, err
+			}
+
+			if entry.digest != digest {
+				return &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewInvalidRequestError("Idempotency key reused with different params"),
+					ID:      req.ID,
+				}, nil
+			}
+
+			// Тот же вызов уже выполняется или выполнен: ждем его ответ
+			select {
+			case <-entry.done:
+			case <-ctx.Context().Done():
+				return nil, ctx.Context().Err()
+			}
+			if !entry.ok {
+				// Первый вызов завершился ошибкой и был забыт, выполняем заново
+				continue
+			}
+
+			replay := *entry.response
+			replay.ID = req.ID
+			return &replay, nil
+		}
+	}
+}
+
+// IdempotencyScope возвращает клиента, в пределах которого действуют ключи:
+// арендатора из TenantMiddleware или IP адрес клиента
+func IdempotencyScope(ctx *types.RequestContext) string {
+	if tenant, ok := ctx.GetValue(TenantContextKey); ok {
+		if tenantID, ok := tenant.(string); ok && tenantID != "" {
+			return "tenant:" + tenantID
+		}
+	}
+	return "ip:" + remoteIPKey(nil, ctx)
+}
+
+// extractIdempotencyKey ищет ключ сначала в заголовках, затем в параметрах
+func extractIdempotencyKey(req *types.JSONRPCRequest, ctx *types.RequestContext) string {
+	if ctx.HTTPRequest != nil {
+		if value := ctx.HTTPRequest.Header.Get(IdempotencyKeyHeader); value != "" {
+			return value
+		}
+	}
+	if value := ctx.Headers[IdempotencyKeyHeader]; value != "" {
+		return value
+	}
+
+	if req.HasParams() {
+		var params map[string]interface{}
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			if value, ok := params[IdempotencyKeyParam].(string); ok {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
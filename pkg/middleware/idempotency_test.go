@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHandler returns a handler that counts its calls and answers with the call number
+func countingHandler(calls *int32) types.Handler {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		n := atomic.AddInt32(calls, 1)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: n, ID: req.ID}, nil
+	}
+}
+
+func onlyMethod(method string) func(string) bool {
+	return func(m string) bool { return m == method }
+}
+
+func newIdempotencyContext() *types.RequestContext {
+	return types.NewRequestContext(context.Background(), "TCP", "127.0.0.1:1")
+}
+
+func TestIdempotencyMiddleware_MarkedMethodRequiresKey(t *testing.T) {
+	mw := IdempotencyMiddleware(onlyMethod("transfer"))
+	var calls int32
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "transfer", Params: json.RawMessage(`{"amount":10}`), ID: 1}
+	response, err := mw(req, newIdempotencyContext(), countingHandler(&calls))
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidParams, response.Error.Code)
+	assert.Equal(t, "Idempotency key required", response.Error.Data)
+	assert.Equal(t, int32(0), calls, "the handler is not called")
+}
+
+func TestIdempotencyMiddleware_UnmarkedMethodIgnoresKey(t *testing.T) {
+	mw := IdempotencyMiddleware(onlyMethod("transfer"))
+	var calls int32
+
+	// No key required
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", ID: 1}
+	response, err := mw(req, newIdempotencyContext(), countingHandler(&calls))
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+
+	// A key is ignored: every call reaches the handler
+	req = &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", Params: json.RawMessage(`{"idempotency_key":"k1"}`), ID: 2}
+	for i := 0; i < 2; i++ {
+		response, err = mw(req, newIdempotencyContext(), countingHandler(&calls))
+		require.NoError(t, err)
+		assert.Nil(t, response.Error)
+	}
+	assert.Equal(t, int32(3), calls)
+}
+
+func TestIdempotencyMiddleware_ReplaysResponse(t *testing.T) {
+	mw := IdempotencyMiddleware(onlyMethod("transfer"))
+	var calls int32
+
+	call := func(key string, id interface{}) *types.JSONRPCResponse {
+		httpReq := httptest.NewRequest("POST", "/rpc", nil)
+		httpReq.Header.Set(IdempotencyKeyHeader, key)
+		ctx := newIdempotencyContext()
+		ctx.HTTPRequest = httpReq
+
+		response, err := mw(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "transfer", ID: id}, ctx, countingHandler(&calls))
+		require.NoError(t, err)
+		value, _ := ctx.GetValue(IdempotencyContextKey)
+		assert.Equal(t, key, value)
+		return response
+	}
+
+	first := call("key-1", 1)
+	second := call("key-1", 2)
+	assert.Equal(t, int32(1), first.Result)
+	assert.Equal(t, int32(1), second.Result, "the stored response is replayed")
+	assert.Equal(t, 2, second.ID, "replays carry the new request ID")
+	assert.Equal(t, 1, first.ID)
+
+	third := call("key-2", 3)
+	assert.Equal(t, int32(2), third.Result)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotencyMiddleware_ScopedByClient(t *testing.T) {
+	mw := IdempotencyMiddleware(onlyMethod("transfer"))
+	var calls int32
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "transfer", Params: json.RawMessage(`{"idempotency_key":"k"}`), ID: 1}
+
+	call := func(remoteAddr, tenant string) *types.JSONRPCResponse {
+		ctx := types.NewRequestContext(context.Background(), "TCP", remoteAddr)
+		if tenant != "" {
+			ctx.WithValue(TenantContextKey, tenant)
+		}
+		response, err := mw(req, ctx, countingHandler(&calls))
+		require.NoError(t, err)
+		return response
+	}
+
+	assert.Equal(t, int32(1), call("10.0.0.1:1000", "").Result)
+	assert.Equal(t, int32(1), call("10.0.0.1:2000", "").Result, "a new connection of the same client replays")
+	assert.Equal(t, int32(2), call("10.0.0.2:1000", "").Result, "another client gets its own call")
+
+	assert.Equal(t, int32(3), call("10.0.0.1:1000", "acme").Result)
+	assert.Equal(t, int32(3), call("10.0.0.3:1000", "acme").Result, "a tenant shares keys across addresses")
+	assert.Equal(t, int32(4), call("10.0.0.1:1000", "globex").Result)
+}
+
+func TestIdempotencyMiddleware_RejectsDifferentParams(t *testing.T) {
+	mw := IdempotencyMiddleware(onlyMethod("transfer"))
+	var calls int32
+
+	first := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "transfer", Params: json.RawMessage(`{"idempotency_key":"k","amount":10}`), ID: 1}
+	response, err := mw(first, newIdempotencyContext(), countingHandler(&calls))
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+
+	changed := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "transfer", Params: json.RawMessage(`{"idempotency_key":"k","amount":99}`), ID: 2}
+	response, err = mw(changed, newIdempotencyContext(), countingHandler(&calls))
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+	assert.Equal(t, 2, response.ID)
+	assert.Equal(t, int32(1), calls, "the changed call is not executed")
+
+	// The original call still replays
+	response, err = mw(first, newIdempotencyContext(), countingHandler(&calls))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), response.Result)
+}
+
+func TestIdempotencyMiddleware_FailedCallsAreRetried(t *testing.T) {
+	mw := IdempotencyMiddleware(onlyMethod("transfer"))
+	var calls int32
+	handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Error: types.NewInternalError("temporary"), ID: req.ID}, nil
+		}
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "transfer", Params: json.RawMessage(`{"idempotency_key":"k"}`), ID: 1}
+	response, err := mw(req, newIdempotencyContext(), handler)
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+
+	response, err = mw(req, newIdempotencyContext(), handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", response.Result)
+
+	response, err = mw(req, newIdempotencyContext(), handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", response.Result)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotencyMiddleware_ConcurrentDuplicates(t *testing.T) {
+	mw := IdempotencyMiddleware(onlyMethod("transfer"))
+	var calls int32
+	release := make(chan struct{})
+	handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "done", ID: req.ID}, nil
+	}
+
+	const clients = 5
+	var wg sync.WaitGroup
+	results := make([]*types.JSONRPCResponse, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "transfer", Params: json.RawMessage(`{"idempotency_key":"same"}`), ID: i}
+			results[i], _ = mw(req, newIdempotencyContext(), handler)
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls, "concurrent duplicates wait for the first call")
+	for i, response := range results {
+		require.NotNil(t, response)
+		assert.Equal(t, "done", response.Result)
+		assert.Equal(t, i, response.ID)
+	}
+}
+
+func TestIdempotencyCache_Eviction(t *testing.T) {
+	cache := &idempotencyCache{entries: make(map[string]*idempotencyEntry), size: 2}
+	for _, key := range []string{"a", "b", "c"} {
+		entry, owner := cache.begin(key, [sha256.Size]byte{})
+		require.True(t, owner)
+		cache.finish(key, entry, &types.JSONRPCResponse{Result: key}, true)
+	}
+
+	_, owner := cache.begin("a", [sha256.Size]byte{})
+	assert.True(t, owner, "the oldest key was evicted")
+	_, owner = cache.begin("c", [sha256.Size]byte{})
+	assert.False(t, owner)
+}
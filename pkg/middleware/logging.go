@@ -1,14 +1,21 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
 	"streaming-server/pkg/types"
 )
 
@@ -39,6 +46,19 @@ const (
 	LogDestinationFile   LogDestination = "file"
 )
 
+// LogVerbosity определяет, какие запросы метода попадают в журнал
+type LogVerbosity string
+
+const (
+	// LogVerbosityAll записывает все запросы метода, в том числе при
+	// LogSuccessOnly, фильтрах методов и выборке SampleRate
+	LogVerbosityAll LogVerbosity = "all"
+	// LogVerbosityErrors записывает только запросы метода, завершившиеся ошибкой
+	LogVerbosityErrors LogVerbosity = "errors"
+	// LogVerbosityNone отключает запись запросов метода
+	LogVerbosityNone LogVerbosity = "none"
+)
+
 // LoggingConfig содержит конфигурацию для промежуточного слоя логирования
 type LoggingConfig struct {
 	// Конфигурация Kafka
@@ -56,17 +76,46 @@ type LoggingConfig struct {
 	ExcludeMethods []string `json:"exclude_methods"`
 	IncludeMethods []string `json:"include_methods"`
 
+	// MethodVerbosity переопределяет общие фильтры для отдельных методов,
+	// например {"heartbeat": "errors"} оставляет в журнале только ошибки heartbeat
+	MethodVerbosity map[string]LogVerbosity `json:"method_verbosity"`
+
+	// SampleRate - доля записываемых успешных запросов от 0 до 1. Ошибки
+	// записываются всегда. 0 (не задано) и значения >= 1 означают запись всех запросов
+	SampleRate float64 `json:"sample_rate"`
+
 	// Опции производительности
 	BufferSize    int           `json:"buffer_size"`
 	FlushInterval time.Duration `json:"flush_interval"`
+	// ShutdownTimeout ограничивает ожидание асинхронных записей при Close.
+	// 0 - DefaultLoggerShutdownTimeout
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
 
 	// Логирование в файл (если destination - file)
 	FilePath string `json:"file_path"`
+	// Ротация файла журнала: размер, после которого файл переименовывается
+	// в FilePath.1 (0 отключает ротацию), и количество хранимых копий
+	MaxFileSizeBytes int64 `json:"max_file_size_bytes"`
+	MaxBackups       int   `json:"max_backups"`
 
 	// Дополнительные метаданные
 	ServiceName    string            `json:"service_name"`
 	ServiceVersion string            `json:"service_version"`
 	ExtraFields    map[string]string `json:"extra_fields"`
+
+	// Fields определяет заголовки и данные запроса, попадающие в запись журнала
+	Fields LogFieldAllowlist `json:"fields"`
+}
+
+// LogFieldAllowlist - списки заголовков и ключей данных запроса, которые
+// копируются в запись журнала. Остальные значения не записываются; пустой
+// список отключает копирование
+type LogFieldAllowlist struct {
+	// Headers - имена заголовков (например, "User-Agent", "X-Request-ID").
+	// Заголовок ищется в HTTP запросе, затем в RequestContext.Headers
+	Headers []string `json:"headers"`
+	// Data - ключи RequestContext.Data (например, "tenant")
+	Data []string `json:"data"`
 }
 
 // DefaultLoggingConfig возвращает конфигурацию логирования по умолчанию
@@ -103,10 +152,16 @@ type LogEntry struct {
 	Duration  int64     `json:"duration_ms"`
 	StartTime time.Time `json:"start_time"`
 
+	// Время отправки HTTP ответа (ResponseTimingMiddleware): первый байт (TTFB)
+	// и последний байт от начала обработки
+	FirstByte *int64 `json:"first_byte_ms,omitempty"`
+	LastByte  *int64 `json:"last_byte_ms,omitempty"`
+
 	// Информация об ответе
-	Success   bool    `json:"success"`
-	ErrorCode *int    `json:"error_code,omitempty"`
-	ErrorMsg  *string `json:"error_message,omitempty"`
+	Success    bool    `json:"success"`
+	StatusCode int     `json:"status_code,omitempty"`
+	ErrorCode  *int    `json:"error_code,omitempty"`
+	ErrorMsg   *string `json:"error_message,omitempty"`
 
 	// Информация об обработчике
 	Handler string `json:"handler,omitempty"`
@@ -180,7 +235,7 @@ func (k *KafkaLogWriter) Write(entry LogEntry) error {
 	case LogFormatJSON:
 		data, err = json.Marshal(entry)
 	case LogFormatText:
-		data = []byte(k.formatTextEntry(entry))
+		data = []byte(formatTextEntry(entry))
 	default:
 		data, err = json.Marshal(entry)
 	}
@@ -204,8 +259,8 @@ func (k *KafkaLogWriter) Write(entry LogEntry) error {
 	return k.writer.WriteMessages(context.Background(), message)
 }
 
-// formatTextEntry форматирует запись журнала как обычный текст
-func (k *KafkaLogWriter) formatTextEntry(entry LogEntry) string {
+// formatTextEntry форматирует запись журнала как обычный текст; общий для всех писателей
+func formatTextEntry(entry LogEntry) string {
 	status := "УСПЕХ"
 	if !entry.Success {
 		status = "ОШИБКА"
@@ -234,6 +289,25 @@ func (k *KafkaLogWriter) Close() error {
 	return nil
 }
 
+// Ping проверяет, что хотя бы один брокер Kafka принимает соединения
+func (k *KafkaLogWriter) Ping(ctx context.Context) error {
+	if len(k.config.KafkaBrokers) == 0 {
+		return fmt.Errorf("брокеры kafka не настроены")
+	}
+
+	var dialer net.Dialer
+	var lastErr error
+	for _, broker := range k.config.KafkaBrokers {
+		conn, err := dialer.DialContext(ctx, "tcp", broker)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("брокеры kafka недоступны: %w", lastErr)
+}
+
 // Flush сбрасывает все ожидающие сообщения
 func (k *KafkaLogWriter) Flush() error {
 	// Писатель Kafka автоматически обрабатывает пакетирование
@@ -262,7 +336,7 @@ func (s *StdoutLogWriter) Write(entry LogEntry) error {
 		}
 		output = string(data)
 	case LogFormatText:
-		output = s.formatTextEntry(entry)
+		output = formatTextEntry(entry)
 	default:
 		data, jsonErr := json.Marshal(entry)
 		if jsonErr != nil {
@@ -275,25 +349,6 @@ func (s *StdoutLogWriter) Write(entry LogEntry) error {
 	return nil
 }
 
-// formatTextEntry форматирует запись журнала как обычный текст
-func (s *StdoutLogWriter) formatTextEntry(entry LogEntry) string {
-	status := "УСПЕХ"
-	if !entry.Success {
-		status = "ОШИБКА"
-	}
-
-	return fmt.Sprintf("[%s] %s %s %s %s %dмс - %s (ID: %s)",
-		entry.Timestamp.Format(time.RFC3339),
-		entry.Level,
-		entry.Transport,
-		entry.Method,
-		status,
-		entry.Duration,
-		entry.Handler,
-		entry.RequestID,
-	)
-}
-
 // Close является пустой операцией для писателя stdout
 func (s *StdoutLogWriter) Close() error {
 	return nil
@@ -304,6 +359,212 @@ func (s *StdoutLogWriter) Flush() error {
 	return nil
 }
 
+// FileLogWriter реализует LogWriter для файла
+type FileLogWriter struct {
+	config LoggingConfig
+	file   *os.File
+	writer *bufio.Writer
+	size   int64 // байты в текущем файле, включая буферизованные
+	mu     sync.Mutex
+}
+
+// NewFileLogWriter создает новый писатель журнала в файл, создавая недостающие каталоги
+func NewFileLogWriter(config LoggingConfig) (*FileLogWriter, error) {
+	if config.FilePath == "" {
+		return nil, fmt.Errorf("не настроен путь к файлу журнала")
+	}
+
+	if dir := filepath.Dir(config.FilePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("не удалось создать каталог журнала: %w", err)
+		}
+	}
+
+	f := &FileLogWriter{config: config}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open открывает файл журнала для дозаписи и определяет его текущий размер
+func (f *FileLogWriter) open() error {
+	file, err := os.OpenFile(f.config.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл журнала: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("не удалось получить размер файла журнала: %w", err)
+	}
+
+	f.file = file
+	f.writer = bufio.NewWriter(file)
+	f.size = info.Size()
+	return nil
+}
+
+// rotate закрывает текущий файл, сдвигает резервные копии (name.1 -> name.2 ...),
+// удаляет копии сверх MaxBackups и открывает новый файл. Вызывается под f.mu
+func (f *FileLogWriter) rotate() error {
+	if err := f.writer.Flush(); err != nil {
+		return fmt.Errorf("не удалось сбросить файл журнала: %w", err)
+	}
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("не удалось закрыть файл журнала: %w", err)
+	}
+	f.file = nil
+
+	path := f.config.FilePath
+	backup := func(n int) string { return fmt.Sprintf("%s.%d", path, n) }
+
+	if f.config.MaxBackups > 0 {
+		if err := os.Remove(backup(f.config.MaxBackups)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("не удалось удалить старую копию журнала: %w", err)
+		}
+		for n := f.config.MaxBackups - 1; n >= 1; n-- {
+			if err := os.Rename(backup(n), backup(n+1)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("не удалось сдвинуть копию журнала: %w", err)
+			}
+		}
+		if err := os.Rename(path, backup(1)); err != nil {
+			return fmt.Errorf("не удалось переименовать файл журнала: %w", err)
+		}
+	} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("не удалось удалить файл журнала: %w", err)
+	}
+
+	return f.open()
+}
+
+// Write записывает запись журнала в файл, по одной записи на строку
+func (f *FileLogWriter) Write(entry LogEntry) error {
+	var data []byte
+	var err error
+
+	switch f.config.Format {
+	case LogFormatText:
+		data = []byte(formatTextEntry(entry))
+	default:
+		data, err = json.Marshal(entry)
+	}
+
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать запись журнала: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return fmt.Errorf("файл журнала закрыт")
+	}
+
+	line := append(data, '\n')
+	limit := f.config.MaxFileSizeBytes
+	if limit > 0 && f.size > 0 && f.size+int64(len(line)) > limit {
+		if err := f.rotate(); err != nil {
+			return fmt.Errorf("не удалось выполнить ротацию журнала: %w", err)
+		}
+	}
+
+	n, err := f.writer.Write(line)
+	f.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("не удалось записать в файл журнала: %w", err)
+	}
+	return nil
+}
+
+// Close сбрасывает буфер и закрывает файл журнала
+func (f *FileLogWriter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	flushErr := f.writer.Flush()
+	closeErr := f.file.Close()
+	f.file = nil
+
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// Flush записывает буферизованные записи в файл
+func (f *FileLogWriter) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.writer.Flush()
+}
+
+// DefaultLoggerShutdownTimeout - время ожидания асинхронных записей при Close по умолчанию
+const DefaultLoggerShutdownTimeout = 10 * time.Second
+
+// LoggerStats содержит счетчики записей журнала
+type LoggerStats struct {
+	Written uint64 `json:"written"`
+	Failed  uint64 `json:"failed"`
+	// Dropped - записи, не дождавшиеся записи при закрытии логгера
+	Dropped uint64 `json:"dropped"`
+}
+
+// LoggerHealth описывает состояние логгера для проверки здоровья
+type LoggerHealth struct {
+	Enabled     bool           `json:"enabled"`
+	Destination LogDestination `json:"destination,omitempty"`
+	// Reachable сообщает, что назначение журнала доступно. Проверяется для
+	// писателей, реализующих Ping (Kafka); остальные считаются доступными
+	Reachable bool        `json:"reachable"`
+	Error     string      `json:"error,omitempty"`
+	Stats     LoggerStats `json:"stats"`
+}
+
+// pinger реализуется писателями, доступность которых можно проверить
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Health проверяет доступность назначения журнала
+func (l *Logger) Health(ctx context.Context) LoggerHealth {
+	if l == nil {
+		return LoggerHealth{}
+	}
+
+	health := LoggerHealth{
+		Enabled:     l.config.Enabled,
+		Destination: l.config.Destination,
+		Reachable:   true,
+		Stats:       l.Stats(),
+	}
+	if !l.config.Enabled {
+		health.Destination = ""
+		return health
+	}
+
+	l.mu.RLock()
+	writer := l.writer
+	l.mu.RUnlock()
+
+	if p, ok := writer.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			health.Reachable = false
+			health.Error = err.Error()
+		}
+	}
+	return health
+}
+
 // Logger обрабатывает операции логирования с асинхронной обработкой
 type Logger struct {
 	config         LoggingConfig
@@ -311,6 +572,25 @@ type Logger struct {
 	asyncProcessor AsyncProcessor
 	clock          types.Clock
 	mu             sync.RWMutex
+
+	// random возвращает число из [0, 1) для выборки; nil означает rand.Float64
+	random func() float64
+
+	written uint64
+	failed  uint64
+	dropped uint64
+}
+
+// Stats возвращает количество записанных и не записанных основным писателем записей
+func (l *Logger) Stats() LoggerStats {
+	if l == nil {
+		return LoggerStats{}
+	}
+	return LoggerStats{
+		Written: atomic.LoadUint64(&l.written),
+		Failed:  atomic.LoadUint64(&l.failed),
+		Dropped: atomic.LoadUint64(&l.dropped),
+	}
 }
 
 // NewLogger создает новый логгер с указанной конфигурацией
@@ -328,6 +608,14 @@ func NewLoggerWithDependencies(config LoggingConfig, asyncProcessor AsyncProcess
 		}, nil
 	}
 
+	for method, verbosity := range config.MethodVerbosity {
+		switch verbosity {
+		case LogVerbosityAll, LogVerbosityErrors, LogVerbosityNone:
+		default:
+			return nil, fmt.Errorf("неизвестная детализация журнала для метода %s: %s", method, verbosity)
+		}
+	}
+
 	var writer LogWriter
 	var err error
 
@@ -336,6 +624,8 @@ func NewLoggerWithDependencies(config LoggingConfig, asyncProcessor AsyncProcess
 		writer, err = NewKafkaLogWriter(config)
 	case LogDestinationStdout:
 		writer = NewStdoutLogWriter(config)
+	case LogDestinationFile:
+		writer, err = NewFileLogWriter(config)
 	default:
 		return nil, fmt.Errorf("неподдерживаемое назначение журнала: %s", config.Destination)
 	}
@@ -352,12 +642,49 @@ func NewLoggerWithDependencies(config LoggingConfig, asyncProcessor AsyncProcess
 	}, nil
 }
 
+// SetRandom заменяет источник случайных чисел для выборки записей (SampleRate).
+// Используется в тестах для детерминированной выборки; fn должна быть безопасна
+// для одновременного вызова, если логгер используется из нескольких горутин
+func (l *Logger) SetRandom(fn func() float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.random = fn
+}
+
+// sampled сообщает, попадает ли успешный запрос в выборку
+func (l *Logger) sampled() bool {
+	rate := l.config.SampleRate
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+
+	l.mu.RLock()
+	random := l.random
+	l.mu.RUnlock()
+	if random == nil {
+		random = rand.Float64
+	}
+	return random() < rate
+}
+
 // shouldLog определяет, должен ли запрос быть залогирован на основе конфигурации
 func (l *Logger) shouldLog(req *types.JSONRPCRequest, success bool, hasError bool) bool {
 	if !l.config.Enabled {
 		return false
 	}
 
+	// Детализация метода заменяет общие фильтры
+	if verbosity, ok := l.config.MethodVerbosity[req.Method]; ok {
+		switch verbosity {
+		case LogVerbosityAll:
+			return true
+		case LogVerbosityErrors:
+			return !success || hasError
+		case LogVerbosityNone:
+			return false
+		}
+	}
+
 	// Проверка фильтра только успешных
 	if l.config.LogSuccessOnly && (!success || hasError) {
 		return false
@@ -383,7 +710,11 @@ func (l *Logger) shouldLog(req *types.JSONRPCRequest, success bool, hasError boo
 		}
 	}
 
-	return true
+	// Ошибки не выбрасываются выборкой
+	if !success || hasError {
+		return true
+	}
+	return l.sampled()
 }
 
 // createLogEntry создает структурированную запись журнала из данных запроса
@@ -408,6 +739,12 @@ func (l *Logger) createLogEntry(req *types.JSONRPCRequest, ctx *types.RequestCon
 		ExtraFields:    make(map[string]string),
 	}
 
+	// Идентификаторы трассировки берутся из span, открытого промежуточным слоем трассировки
+	if spanCtx := trace.SpanContextFromContext(ctx.Context()); spanCtx.IsValid() {
+		entry.TraceID = spanCtx.TraceID().String()
+		entry.SpanID = spanCtx.SpanID().String()
+	}
+
 	// Определение успеха и информации об ошибке
 	entry.Success = err == nil && (response == nil || response.Error == nil)
 
@@ -422,24 +759,16 @@ func (l *Logger) createLogEntry(req *types.JSONRPCRequest, ctx *types.RequestCon
 		entry.ErrorMsg = &response.Error.Message
 	}
 
-	// Копирование заголовков (ограничение для предотвращения больших нагрузок)
-	headerCount := 0
-	for key, value := range ctx.Headers {
-		if headerCount >= 10 { // Ограничение заголовков для предотвращения больших записей журнала
-			break
+	// Копирование разрешенных заголовков и данных запроса
+	for _, name := range l.config.Fields.Headers {
+		if value := requestHeader(ctx, name); value != "" {
+			entry.Headers[name] = value
 		}
-		entry.Headers[key] = value
-		headerCount++
 	}
-
-	// Копирование данных запроса (ограничение для предотвращения больших нагрузок)
-	dataCount := 0
-	for key, value := range ctx.Data {
-		if dataCount >= 10 { // Ограничение полей данных
-			break
+	for _, key := range l.config.Fields.Data {
+		if value, exists := ctx.GetValue(key); exists {
+			entry.RequestData[key] = value
 		}
-		entry.RequestData[key] = value
-		dataCount++
 	}
 
 	// Копирование дополнительных полей
@@ -450,6 +779,16 @@ func (l *Logger) createLogEntry(req *types.JSONRPCRequest, ctx *types.RequestCon
 	return entry
 }
 
+// requestHeader возвращает значение заголовка из HTTP запроса или контекста запроса
+func requestHeader(ctx *types.RequestContext, name string) string {
+	if ctx.HTTPRequest != nil {
+		if value := ctx.HTTPRequest.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ctx.Headers[name]
+}
+
 // logEntry записывает запись журнала с использованием настроенного писателя
 func (l *Logger) logEntry(entry LogEntry) {
 	if l.writer == nil {
@@ -457,6 +796,7 @@ func (l *Logger) logEntry(entry LogEntry) {
 	}
 
 	if err := l.writer.Write(entry); err != nil {
+		atomic.AddUint64(&l.failed, 1)
 		log.Printf("Не удалось записать запись журнала: %v", err)
 
 		// Запасной вариант для stdout, если основной писатель не работает
@@ -466,7 +806,9 @@ func (l *Logger) logEntry(entry LogEntry) {
 				log.Printf("Запасное логирование также не удалось: %v", fallbackErr)
 			}
 		}
+		return
 	}
+	atomic.AddUint64(&l.written, 1)
 }
 
 // Close закрывает логгер и его писатель
@@ -476,9 +818,18 @@ func (l *Logger) Close() error {
 
 	// Сначала завершаем работу асинхронного процессора
 	if l.asyncProcessor != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		timeout := l.config.ShutdownTimeout
+		if timeout <= 0 {
+			timeout = DefaultLoggerShutdownTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
-		l.asyncProcessor.Shutdown(ctx)
+
+		// Записи, не успевшие завершиться за отведенное время, считаются потерянными
+		if dropped, err := l.asyncProcessor.Shutdown(ctx); dropped > 0 {
+			atomic.AddUint64(&l.dropped, uint64(dropped))
+			log.Printf("ВНИМАНИЕ: логгер закрыт с незаписанными записями журнала: %d (%v)", dropped, err)
+		}
 	}
 
 	if l.writer != nil {
@@ -1,15 +1,29 @@
 package middleware
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"streaming-server/pkg/observability"
 	"streaming-server/pkg/types"
 )
 
@@ -144,6 +158,190 @@ func TestStdoutLogWriter(t *testing.T) {
 	assert.NoError(t, writer.Flush())
 }
 
+// readLogLines читает непустые строки файла журнала
+func readLogLines(t *testing.T, path string) []string {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func TestFileLogWriter_JSONFormat(t *testing.T) {
+	// Недостающие каталоги создаются автоматически
+	path := filepath.Join(t.TempDir(), "nested", "logs", "server.log")
+
+	writer, err := NewFileLogWriter(LoggingConfig{Format: LogFormatJSON, FilePath: path})
+	require.NoError(t, err)
+
+	for _, method := range []string{"echo", "time", "status"} {
+		require.NoError(t, writer.Write(LogEntry{
+			RequestID: "req-" + method,
+			Method:    method,
+			Transport: "HTTP",
+			Timestamp: time.Now(),
+			Success:   true,
+			Level:     LogLevelInfo,
+		}))
+	}
+
+	// До сброса записи остаются в буфере
+	assert.Empty(t, readLogLines(t, path))
+
+	require.NoError(t, writer.Flush())
+	lines := readLogLines(t, path)
+	require.Len(t, lines, 3)
+
+	for i, method := range []string{"echo", "time", "status"} {
+		var entry LogEntry
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &entry))
+		assert.Equal(t, method, entry.Method)
+		assert.Equal(t, "req-"+method, entry.RequestID)
+	}
+
+	require.NoError(t, writer.Close())
+	assert.Error(t, writer.Write(LogEntry{Method: "late"}), "запись после закрытия должна завершаться ошибкой")
+	assert.NoError(t, writer.Close(), "повторное закрытие безопасно")
+}
+
+func TestFileLogWriter_TextFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+
+	writer, err := NewFileLogWriter(LoggingConfig{Format: LogFormatText, FilePath: path})
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Write(LogEntry{RequestID: "1", Method: "echo", Transport: "TCP", Success: true, Level: LogLevelInfo}))
+	require.NoError(t, writer.Write(LogEntry{RequestID: "2", Method: "calculate", Transport: "TCP", Success: false, Level: LogLevelError}))
+
+	// Close сбрасывает буфер
+	require.NoError(t, writer.Close())
+
+	lines := readLogLines(t, path)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "echo")
+	assert.Contains(t, lines[0], "УСПЕХ")
+	assert.Contains(t, lines[1], "calculate")
+	assert.Contains(t, lines[1], "ОШИБКА")
+}
+
+func TestFileLogWriter_AppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	require.NoError(t, os.WriteFile(path, []byte("existing\n"), 0644))
+
+	writer, err := NewFileLogWriter(LoggingConfig{FilePath: path})
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(LogEntry{RequestID: "1", Method: "echo"}))
+	require.NoError(t, writer.Close())
+
+	lines := readLogLines(t, path)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "existing", lines[0])
+}
+
+func TestFileLogWriter_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+
+	// Лимит меньше одной записи вызывает ротацию перед каждой следующей записью
+	writer, err := NewFileLogWriter(LoggingConfig{FilePath: path, MaxFileSizeBytes: 1, MaxBackups: 2})
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, writer.Write(LogEntry{RequestID: fmt.Sprintf("req-%d", i), Method: "echo"}))
+	}
+	require.NoError(t, writer.Close())
+
+	expected := map[string]string{
+		path:        "req-5",
+		path + ".1": "req-4",
+		path + ".2": "req-3",
+	}
+	for file, requestID := range expected {
+		lines := readLogLines(t, file)
+		require.Len(t, lines, 1, file)
+
+		var entry LogEntry
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		assert.Equal(t, requestID, entry.RequestID, file)
+	}
+
+	// Копии сверх MaxBackups удаляются
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileLogWriter_RotationKeepsWholeEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+
+	entry := LogEntry{RequestID: "req", Method: "echo"}
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+	entrySize := int64(len(data) + 1)
+
+	// В файл помещаются ровно две записи
+	writer, err := NewFileLogWriter(LoggingConfig{FilePath: path, MaxFileSizeBytes: 2 * entrySize, MaxBackups: 1})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, writer.Write(entry))
+	}
+	require.NoError(t, writer.Close())
+
+	assert.Len(t, readLogLines(t, path+".1"), 2)
+	assert.Len(t, readLogLines(t, path), 1)
+}
+
+func TestFileLogWriter_ConcurrentRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+
+	const goroutines = 8
+	const perGoroutine = 25
+
+	writer, err := NewFileLogWriter(LoggingConfig{FilePath: path, MaxFileSizeBytes: 512, MaxBackups: goroutines * perGoroutine})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				assert.NoError(t, writer.Write(LogEntry{RequestID: fmt.Sprintf("%d-%d", g, i), Method: "echo"}))
+			}
+		}(g)
+	}
+	wg.Wait()
+	require.NoError(t, writer.Close())
+
+	// Ни одна запись не потеряна и не разорвана между файлами
+	files, err := filepath.Glob(path + "*")
+	require.NoError(t, err)
+	assert.Greater(t, len(files), 1)
+
+	seen := make(map[string]bool)
+	for _, file := range files {
+		for _, line := range readLogLines(t, file) {
+			var entry LogEntry
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			seen[entry.RequestID] = true
+		}
+	}
+	assert.Len(t, seen, goroutines*perGoroutine)
+}
+
+func TestNewFileLogWriter_MissingPath(t *testing.T) {
+	writer, err := NewFileLogWriter(LoggingConfig{})
+	assert.Error(t, err)
+	assert.Nil(t, writer)
+}
+
 func TestLogger_shouldLog(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -240,6 +438,109 @@ func TestLogger_shouldLog(t *testing.T) {
 	}
 }
 
+func TestLogger_shouldLog_Sampling(t *testing.T) {
+	const iterations = 10000
+	req := &types.JSONRPCRequest{Method: "test"}
+
+	logger := &Logger{config: LoggingConfig{Enabled: true, SampleRate: 0.25}}
+	logger.SetRandom(rand.New(rand.NewSource(42)).Float64)
+
+	logged := 0
+	for i := 0; i < iterations; i++ {
+		if logger.shouldLog(req, true, false) {
+			logged++
+		}
+	}
+	assert.InDelta(t, 0.25, float64(logged)/iterations, 0.02)
+
+	// Errors are never dropped by sampling
+	for i := 0; i < iterations; i++ {
+		require.True(t, logger.shouldLog(req, false, true))
+		require.True(t, logger.shouldLog(req, true, true))
+	}
+
+	// The same seed gives the same decisions
+	first := &Logger{config: LoggingConfig{Enabled: true, SampleRate: 0.5}}
+	first.SetRandom(rand.New(rand.NewSource(7)).Float64)
+	second := &Logger{config: LoggingConfig{Enabled: true, SampleRate: 0.5}}
+	second.SetRandom(rand.New(rand.NewSource(7)).Float64)
+	for i := 0; i < 100; i++ {
+		require.Equal(t, first.shouldLog(req, true, false), second.shouldLog(req, true, false))
+	}
+
+	// Unset and full rates log everything without consulting the source
+	for _, rate := range []float64{0, 1, 1.5} {
+		logger := &Logger{config: LoggingConfig{Enabled: true, SampleRate: rate}}
+		logger.SetRandom(func() float64 {
+			t.Fatal("random source must not be used")
+			return 0
+		})
+		assert.True(t, logger.shouldLog(req, true, false), "rate %v", rate)
+	}
+
+	// Sampling runs after the method filters
+	excluded := &Logger{config: LoggingConfig{Enabled: true, SampleRate: 0.5, ExcludeMethods: []string{"test"}}}
+	excluded.SetRandom(func() float64 { return 0 })
+	assert.False(t, excluded.shouldLog(req, false, true))
+}
+
+func TestLogger_shouldLog_MethodVerbosity(t *testing.T) {
+	logger := &Logger{config: LoggingConfig{
+		Enabled:        true,
+		SampleRate:     0.5,
+		ExcludeMethods: []string{"audit"},
+		MethodVerbosity: map[string]LogVerbosity{
+			"heartbeat": LogVerbosityErrors,
+			"audit":     LogVerbosityAll,
+			"ping":      LogVerbosityNone,
+		},
+	}}
+	// Sampling would drop every success of methods without an override
+	logger.SetRandom(func() float64 { return 0.99 })
+
+	tests := []struct {
+		method    string
+		success   bool
+		hasError  bool
+		shouldLog bool
+	}{
+		{"heartbeat", true, false, false},
+		{"heartbeat", false, true, true},
+		{"heartbeat", true, true, true},
+		{"audit", true, false, true},
+		{"audit", false, true, true},
+		{"ping", true, false, false},
+		{"ping", false, true, false},
+		{"echo", true, false, false},
+		{"echo", false, true, true},
+	}
+
+	for _, tt := range tests {
+		req := &types.JSONRPCRequest{Method: tt.method}
+		assert.Equal(t, tt.shouldLog, logger.shouldLog(req, tt.success, tt.hasError),
+			"method %s, success %v, error %v", tt.method, tt.success, tt.hasError)
+	}
+
+	// The override applies even where LogSuccessOnly drops errors of other methods
+	successOnly := &Logger{config: LoggingConfig{
+		Enabled:         true,
+		LogSuccessOnly:  true,
+		MethodVerbosity: map[string]LogVerbosity{"heartbeat": LogVerbosityErrors},
+	}}
+	assert.True(t, successOnly.shouldLog(&types.JSONRPCRequest{Method: "heartbeat"}, false, true))
+	assert.False(t, successOnly.shouldLog(&types.JSONRPCRequest{Method: "echo"}, false, true))
+}
+
+func TestNewLogger_InvalidMethodVerbosity(t *testing.T) {
+	_, err := NewLogger(LoggingConfig{
+		Enabled:         true,
+		Destination:     LogDestinationStdout,
+		MethodVerbosity: map[string]LogVerbosity{"heartbeat": "loud"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "heartbeat")
+}
+
 func TestLogger_createLogEntry_WithMockClock(t *testing.T) {
 	// Используем мок-часы для детерминированного тестирования
 	fixedTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
@@ -249,6 +550,10 @@ func TestLogger_createLogEntry_WithMockClock(t *testing.T) {
 		ServiceName:    "test-service",
 		ServiceVersion: "1.0.0",
 		ExtraFields:    map[string]string{"env": "test"},
+		Fields: LogFieldAllowlist{
+			Headers: []string{"Content-Type"},
+			Data:    []string{"test_key"},
+		},
 	}
 
 	logger := &Logger{
@@ -353,6 +658,99 @@ func TestLogger_createLogEntry_WithMockClock(t *testing.T) {
 	}
 }
 
+func TestLogger_createLogEntry_FieldAllowlist(t *testing.T) {
+	logger := &Logger{
+		config: LoggingConfig{Fields: LogFieldAllowlist{
+			Headers: []string{"X-Request-ID", "User-Agent", "X-Missing"},
+			Data:    []string{"tenant", "key_5"},
+		}},
+		clock: types.GlobalClock,
+	}
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: 1}
+
+	ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1:8080")
+	ctx.HTTPRequest = httptest.NewRequest("POST", "/rpc", nil)
+	ctx.HTTPRequest.Header.Set("User-Agent", "curl/8.0")
+	ctx.HTTPRequest.Header.Set("Authorization", "Bearer secret")
+	ctx.Headers["X-Request-ID"] = "abc"
+	ctx.Headers["Cookie"] = "session=secret"
+	ctx.WithValue("tenant", "acme")
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		ctx.WithValue(key, i)
+		ctx.Headers["X-Extra-"+key] = key
+	}
+
+	// Map iteration order must not affect which fields are logged
+	for i := 0; i < 20; i++ {
+		entry := logger.createLogEntry(req, ctx, nil, nil)
+		assert.Equal(t, map[string]string{"X-Request-ID": "abc", "User-Agent": "curl/8.0"}, entry.Headers)
+		assert.Equal(t, map[string]interface{}{"tenant": "acme", "key_5": 5}, entry.RequestData)
+	}
+
+	// Without an allowlist nothing is copied
+	logger.config.Fields = LogFieldAllowlist{}
+	entry := logger.createLogEntry(req, ctx, nil, nil)
+	assert.Empty(t, entry.Headers)
+	assert.Empty(t, entry.RequestData)
+}
+
+func TestLoggingMiddleware_TraceIDsFromTracing(t *testing.T) {
+	mockWriter := &MockLogWriter{}
+	var entry LogEntry
+	mockWriter.On("Write", mock.AnythingOfType("LogEntry")).Run(func(args mock.Arguments) {
+		entry = args.Get(0).(LogEntry)
+	}).Return(nil)
+
+	logger := &Logger{
+		config: LoggingConfig{Enabled: true},
+		writer: mockWriter,
+		clock:  types.GlobalClock,
+	}
+
+	// The logging middleware runs inside the tracing middleware, so it sees the request span
+	chain := NewChain(observability.TracingMiddleware(), LoggingMiddleware(logger))
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: 1}
+	handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+
+	// Incoming request carries a remote parent span, as after trace context propagation
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	parent := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+
+	_, err = chain.Execute(req, types.NewRequestContext(parent, "HTTP", "127.0.0.1:8080"), handler)
+	require.NoError(t, err)
+	mockWriter.AssertNumberOfCalls(t, "Write", 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry.TraceID)
+	assert.NotEmpty(t, entry.SpanID)
+
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+
+	// Without a span the fields stay empty and hidden
+	_, err = chain.Execute(req, types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1:8080"), handler)
+	require.NoError(t, err)
+	assert.Empty(t, entry.TraceID)
+	assert.Empty(t, entry.SpanID)
+
+	data, err = json.Marshal(entry)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.NotContains(t, fields, "trace_id")
+	assert.NotContains(t, fields, "span_id")
+}
+
 func TestLoggingMiddleware_WithMockAsyncProcessor(t *testing.T) {
 	mockWriter := &MockLogWriter{}
 	mockWriter.On("Write", mock.AnythingOfType("LogEntry")).Return(nil)
@@ -524,6 +922,72 @@ func TestLogger_Close(t *testing.T) {
 	mockWriter.AssertCalled(t, "Close")
 }
 
+// slowLogWriter blocks every Write until release is closed
+type slowLogWriter struct {
+	release chan struct{}
+	written int32
+}
+
+func (w *slowLogWriter) Write(entry LogEntry) error {
+	<-w.release
+	atomic.AddInt32(&w.written, 1)
+	return nil
+}
+
+func (w *slowLogWriter) Close() error { return nil }
+func (w *slowLogWriter) Flush() error { return nil }
+
+func TestLogger_Close_ReportsDroppedEntries(t *testing.T) {
+	writer := &slowLogWriter{release: make(chan struct{})}
+	defer close(writer.release)
+
+	logger := &Logger{
+		config:         LoggingConfig{Enabled: true, ShutdownTimeout: 50 * time.Millisecond},
+		writer:         writer,
+		asyncProcessor: NewDefaultAsyncProcessor(),
+		clock:          types.GlobalClock,
+	}
+	middleware := LoggingMiddleware(logger)
+	next := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+	for i := 1; i <= 3; i++ {
+		_, err := middleware(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: i}, types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1"), next)
+		require.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	start := time.Now()
+	require.NoError(t, logger.Close())
+	assert.Less(t, time.Since(start), time.Second, "Close gives up after ShutdownTimeout")
+
+	assert.Equal(t, uint64(3), logger.Stats().Dropped)
+	assert.Contains(t, buf.String(), "незаписанными записями журнала: 3")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&writer.written))
+}
+
+func TestLogger_Close_NoDroppedEntries(t *testing.T) {
+	mockAsyncProcessor := NewMockAsyncProcessor()
+	logger := &Logger{asyncProcessor: mockAsyncProcessor}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	require.NoError(t, logger.Close())
+	assert.Zero(t, logger.Stats().Dropped)
+	assert.Empty(t, buf.String())
+
+	mockAsyncProcessor.SetShutdownPending(2)
+	mockAsyncProcessor.SetShutdownError(context.DeadlineExceeded)
+	require.NoError(t, logger.Close())
+	assert.Equal(t, uint64(2), logger.Stats().Dropped)
+	assert.Contains(t, buf.String(), "context deadline exceeded")
+}
+
 func TestLogger_Flush(t *testing.T) {
 	mockWriter := &MockLogWriter{}
 	mockWriter.On("Flush").Return(nil)
@@ -577,6 +1041,23 @@ func TestNewLoggerWithDependencies(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "file logger - valid config",
+			config: LoggingConfig{
+				Enabled:     true,
+				Destination: LogDestinationFile,
+				FilePath:    filepath.Join(t.TempDir(), "server.log"),
+			},
+			expectError: false,
+		},
+		{
+			name: "file logger - missing path",
+			config: LoggingConfig{
+				Enabled:     true,
+				Destination: LogDestinationFile,
+			},
+			expectError: true,
+		},
 		{
 			name: "unsupported destination",
 			config: LoggingConfig{
@@ -736,3 +1217,65 @@ func BenchmarkLoggingMiddleware_WithMockAsyncProcessor(b *testing.B) {
 		middleware(req, ctx, nextHandler)
 	}
 }
+
+func TestLogger_Stats(t *testing.T) {
+	mockWriter := &MockLogWriter{}
+	mockWriter.On("Write", mock.MatchedBy(func(entry LogEntry) bool { return entry.Method == "ok" })).Return(nil)
+	mockWriter.On("Write", mock.MatchedBy(func(entry LogEntry) bool { return entry.Method == "fail" })).Return(errors.New("write failed"))
+
+	logger := &Logger{
+		config: LoggingConfig{Enabled: true, Destination: LogDestinationStdout},
+		writer: mockWriter,
+		clock:  types.GlobalClock,
+	}
+
+	logger.logEntry(LogEntry{Method: "ok"})
+	logger.logEntry(LogEntry{Method: "ok"})
+	logger.logEntry(LogEntry{Method: "fail"})
+
+	assert.Equal(t, LoggerStats{Written: 2, Failed: 1}, logger.Stats())
+
+	var nilLogger *Logger
+	assert.Equal(t, LoggerStats{}, nilLogger.Stats())
+}
+
+func TestLogger_Health(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// A closed listener gives an address nobody accepts connections on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := closed.Addr().String()
+	closed.Close()
+
+	newKafkaLogger := func(brokers ...string) *Logger {
+		config := LoggingConfig{Enabled: true, Destination: LogDestinationKafka, KafkaBrokers: brokers}
+		return &Logger{config: config, writer: &KafkaLogWriter{config: config}, clock: types.GlobalClock}
+	}
+
+	health := newKafkaLogger(unreachable, listener.Addr().String()).Health(context.Background())
+	assert.True(t, health.Enabled)
+	assert.Equal(t, LogDestinationKafka, health.Destination)
+	assert.True(t, health.Reachable)
+	assert.Empty(t, health.Error)
+
+	health = newKafkaLogger(unreachable).Health(context.Background())
+	assert.False(t, health.Reachable)
+	assert.Contains(t, health.Error, "брокеры kafka недоступны")
+
+	health = newKafkaLogger().Health(context.Background())
+	assert.False(t, health.Reachable)
+	assert.Contains(t, health.Error, "не настроены")
+
+	// Writers without Ping are considered reachable
+	stdout := &Logger{config: LoggingConfig{Enabled: true, Destination: LogDestinationStdout}, writer: &MockLogWriter{}}
+	assert.Equal(t, LoggerHealth{Enabled: true, Destination: LogDestinationStdout, Reachable: true}, stdout.Health(context.Background()))
+
+	disabled := &Logger{config: LoggingConfig{Enabled: false, Destination: LogDestinationStdout}}
+	assert.Equal(t, LoggerHealth{Reachable: true}, disabled.Health(context.Background()))
+
+	var nilLogger *Logger
+	assert.Equal(t, LoggerHealth{}, nilLogger.Health(context.Background()))
+}
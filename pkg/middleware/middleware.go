@@ -22,6 +22,13 @@ func (c *Chain) Add(middleware types.Middleware) *Chain {
 	return c
 }
 
+// Prepend inserts middleware at the start of the chain, so it runs before
+// all middleware already added
+func (c *Chain) Prepend(middleware types.Middleware) *Chain {
+	c.middlewares = append([]types.Middleware{middleware}, c.middlewares...)
+	return c
+}
+
 // Execute executes the middleware chain with the final handler
 func (c *Chain) Execute(req *types.JSONRPCRequest, ctx *types.RequestContext, finalHandler types.Handler) (*types.JSONRPCResponse, error) {
 	if len(c.middlewares) == 0 {
@@ -270,6 +270,26 @@ func TestLoggingMiddleware_WithError(t *testing.T) {
 }
 
 // Benchmark tests
+func TestChain_Prepend(t *testing.T) {
+	var executionOrder []string
+	named := func(name string) types.Middleware {
+		return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+			executionOrder = append(executionOrder, name)
+			return next(req, ctx)
+		}
+	}
+
+	chain := NewChain(named("m2")).Prepend(named("m1")).Add(named("m3"))
+
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+	_, err := chain.Execute(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: 1}, ctx,
+		func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"m1", "m2", "m3"}, executionOrder)
+}
+
 func BenchmarkChain_Execute_EmptyChain(b *testing.B) {
 	chain := NewChain()
 
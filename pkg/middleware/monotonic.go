@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"encoding/json"
+
+	"streaming-server/pkg/types"
+)
+
+// monotonicIDKey ключ последнего принятого ID в состоянии соединения
+const monotonicIDKey = "monotonic_last_id"
+
+// MonotonicIDMiddleware отклоняет запросы, ID которых не больше ID предыдущего
+// запроса на том же соединении. Работает только для потоковых транспортов,
+// для запросов без состояния соединения и для уведомлений ничего не проверяет
+func MonotonicIDMiddleware() types.Middleware {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		if ctx.Connection == nil || req.IsNotification() {
+			return next(req, ctx)
+		}
+
+		accepted := ctx.Connection.Update(monotonicIDKey, func(last interface{}, exists bool) (interface{}, bool) {
+			if !exists {
+				return req.ID, true
+			}
+			return req.ID, idGreater(req.ID, last)
+		})
+
+		if !accepted {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidRequestError("Request ID must increase monotonically on this connection"),
+				ID:      req.ID,
+			}, nil
+		}
+
+		return next(req, ctx)
+	}
+}
+
+// idGreater сообщает, что ID a строго больше ID b. Числа сравниваются численно,
+// строки - лексикографически, ID разных типов считаются неупорядоченными
+func idGreater(a, b interface{}) bool {
+	if af, ok := idToFloat64(a); ok {
+		bf, ok := idToFloat64(b)
+		return ok && af > bf
+	}
+
+	as, ok := a.(string)
+	if !ok {
+		return false
+	}
+	bs, ok := b.(string)
+	return ok && as > bs
+}
+
+// idToFloat64 приводит числовой ID к float64
+func idToFloat64(id interface{}) (float64, bool) {
+	switch v := id.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
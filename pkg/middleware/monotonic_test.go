@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func monotonicTestHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+	return &types.JSONRPCResponse{
+		JSONRPC: "2.0",
+		Result:  "ok",
+		ID:      req.ID,
+	}, nil
+}
+
+func sendWithID(t *testing.T, mw types.Middleware, conn *types.ConnectionState, id interface{}) *types.JSONRPCResponse {
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "test", ID: id}
+	ctx := types.NewRequestContext(context.Background(), "TCP", "127.0.0.1")
+	ctx.Connection = conn
+
+	response, err := mw(req, ctx, monotonicTestHandler)
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	return response
+}
+
+func TestMonotonicIDMiddleware_IncreasingIDs(t *testing.T) {
+	mw := MonotonicIDMiddleware()
+	conn := types.NewConnectionState()
+
+	for _, id := range []interface{}{float64(1), float64(2), float64(10)} {
+		response := sendWithID(t, mw, conn, id)
+		assert.Nil(t, response.Error)
+		assert.Equal(t, "ok", response.Result)
+	}
+}
+
+func TestMonotonicIDMiddleware_RepeatedID(t *testing.T) {
+	mw := MonotonicIDMiddleware()
+	conn := types.NewConnectionState()
+
+	assert.Nil(t, sendWithID(t, mw, conn, float64(5)).Error)
+
+	response := sendWithID(t, mw, conn, float64(5))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+	assert.Equal(t, float64(5), response.ID)
+}
+
+func TestMonotonicIDMiddleware_DecreasingID(t *testing.T) {
+	mw := MonotonicIDMiddleware()
+	conn := types.NewConnectionState()
+
+	assert.Nil(t, sendWithID(t, mw, conn, float64(5)).Error)
+
+	response := sendWithID(t, mw, conn, float64(3))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+
+	// Rejected ID must not reset the high-water mark
+	assert.NotNil(t, sendWithID(t, mw, conn, float64(4)).Error)
+	assert.Nil(t, sendWithID(t, mw, conn, float64(6)).Error)
+}
+
+func TestMonotonicIDMiddleware_StringIDs(t *testing.T) {
+	mw := MonotonicIDMiddleware()
+	conn := types.NewConnectionState()
+
+	assert.Nil(t, sendWithID(t, mw, conn, "a").Error)
+	assert.Nil(t, sendWithID(t, mw, conn, "b").Error)
+	assert.NotNil(t, sendWithID(t, mw, conn, "a").Error)
+	assert.NotNil(t, sendWithID(t, mw, conn, float64(100)).Error) // mixed types are unordered
+}
+
+func TestMonotonicIDMiddleware_ConnectionsAreIndependent(t *testing.T) {
+	mw := MonotonicIDMiddleware()
+	conn1 := types.NewConnectionState()
+	conn2 := types.NewConnectionState()
+
+	assert.Nil(t, sendWithID(t, mw, conn1, float64(10)).Error)
+	assert.Nil(t, sendWithID(t, mw, conn2, float64(1)).Error)
+}
+
+func TestMonotonicIDMiddleware_WithoutConnection(t *testing.T) {
+	mw := MonotonicIDMiddleware()
+
+	// HTTP requests carry no connection state and are never rejected
+	assert.Nil(t, sendWithID(t, mw, nil, float64(2)).Error)
+	assert.Nil(t, sendWithID(t, mw, nil, float64(1)).Error)
+}
+
+func TestMonotonicIDMiddleware_Notification(t *testing.T) {
+	mw := MonotonicIDMiddleware()
+	conn := types.NewConnectionState()
+
+	assert.Nil(t, sendWithID(t, mw, conn, float64(5)).Error)
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "test"}
+	ctx := types.NewRequestContext(context.Background(), "TCP", "127.0.0.1")
+	ctx.Connection = conn
+	response, err := mw(req, ctx, monotonicTestHandler)
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+
+	assert.Nil(t, sendWithID(t, mw, conn, float64(6)).Error)
+}
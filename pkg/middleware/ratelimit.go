@@ -0,0 +1,261 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"streaming-server/pkg/types"
+)
+
+// RateLimitedCode код ошибки для запроса, превысившего лимит
+const RateLimitedCode = -32003
+
+// RateLimiter решает, пропустить ли очередной запрос клиента с ключом key.
+// Ошибка означает, что хранилище счетчиков недоступно и решение не принято
+type RateLimiter interface {
+	Allow(key string) (bool, error)
+}
+
+// RateLimitBackend определяет хранилище счетчиков ограничителя
+type RateLimitBackend string
+
+const (
+	// RateLimitBackendMemory хранит счетчики в памяти процесса (по умолчанию)
+	RateLimitBackendMemory RateLimitBackend = "memory"
+	// RateLimitBackendRedis хранит счетчики в Redis, общем для всех экземпляров
+	// сервера за балансировщиком
+	RateLimitBackendRedis RateLimitBackend = "redis"
+)
+
+// Значения по умолчанию для RateLimitConfig
+const (
+	DefaultRateLimitWindow = time.Second
+	DefaultRedisKeyPrefix  = "ratelimit:"
+	DefaultRedisTimeout    = 100 * time.Millisecond
+	// DefaultRedisPoolSize - число соединений с Redis, сохраняемых между обращениями
+	DefaultRedisPoolSize = 8
+	// DefaultRedisRetryInterval - пауза в обращениях к Redis после ошибки
+	DefaultRedisRetryInterval = time.Second
+)
+
+// RateLimitConfig содержит конфигурацию ограничения частоты запросов
+type RateLimitConfig struct {
+	// Backend - хранилище счетчиков; пустое значение означает "memory"
+	Backend RateLimitBackend
+	// Limit - число запросов одного клиента за Window
+	Limit int
+	// Window - окно ограничения; по умолчанию одна секунда
+	Window time.Duration
+	// MaxClients ограничивает число клиентов, корзины которых хранятся в памяти
+	// (Backend "memory"). Сверх него вытесняется клиент, дольше всех не
+	// присылавший запросов; 0 - DefaultMaxClients
+	MaxClients int
+
+	// RedisAddr - адрес Redis (host:port) для Backend "redis"
+	RedisAddr string
+	// RedisPassword - пароль для команды AUTH; пустой пароль отключает AUTH
+	RedisPassword string
+	// RedisKeyPrefix - префикс ключей счетчиков в Redis
+	RedisKeyPrefix string
+	// RedisTimeout ограничивает время одного обращения к Redis
+	RedisTimeout time.Duration
+	// RedisPoolSize - число свободных соединений с Redis, сохраняемых между
+	// обращениями; 0 - DefaultRedisPoolSize
+	RedisPoolSize int
+	// RedisRetryInterval - время после ошибки Redis, в течение которого
+	// ограничитель сразу возвращает ErrRedisUnavailable; 0 - DefaultRedisRetryInterval
+	RedisRetryInterval time.Duration
+
+	// FailClosed отклоняет запросы, если хранилище счетчиков недоступно.
+	// По умолчанию такие запросы пропускаются, чтобы сбой Redis не остановил сервис
+	FailClosed bool
+	// KeyFunc возвращает ключ клиента; по умолчанию - IP адрес из RemoteAddr
+	KeyFunc func(*types.JSONRPCRequest, *types.RequestContext) string
+}
+
+// NewRateLimiter создает ограничитель для хранилища из конфигурации
+func NewRateLimiter(config RateLimitConfig) (RateLimiter, error) {
+	if config.Limit <= 0 {
+		return nil, fmt.Errorf("rate limit must be positive, got %d", config.Limit)
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultRateLimitWindow
+	}
+
+	switch config.Backend {
+	case "", RateLimitBackendMemory:
+		limiter := NewTokenBucketLimiter(config.Limit, config.Window)
+		limiter.SetMaxClients(config.MaxClients)
+		return limiter, nil
+	case RateLimitBackendRedis:
+		if config.RedisAddr == "" {
+			return nil, fmt.Errorf("redis rate limiter requires an address")
+		}
+		return NewRedisRateLimiter(config), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", config.Backend)
+	}
+}
+
+// limiterErrorLogInterval - не чаще одной записи в журнал об ошибках
+// ограничителя за этот интервал, чтобы сбой Redis не заполнил журнал
+const limiterErrorLogInterval = 10 * time.Second
+
+// logThrottle пропускает не больше одной записи за interval и считает остальные
+type logThrottle struct {
+	mu         sync.Mutex
+	interval   time.Duration
+	last       time.Time
+	suppressed int
+}
+
+// allow сообщает, можно ли писать в журнал, и сколько записей пропущено с прошлой
+func (t *logThrottle) allow() (bool, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if !t.last.IsZero() && now.Sub(t.last) < t.interval {
+		t.suppressed++
+		return false, 0
+	}
+	suppressed := t.suppressed
+	t.last, t.suppressed = now, 0
+	return true, suppressed
+}
+
+// RateLimitMiddleware отклоняет запросы клиентов, превысивших лимит, ошибкой
+// -32003. Если ограничитель вернул ошибку, запрос пропускается или, при
+// FailClosed, отклоняется; такие ошибки попадают в журнал не чаще раза в
+// limiterErrorLogInterval
+func RateLimitMiddleware(limiter RateLimiter, config RateLimitConfig) types.Middleware {
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = remoteIPKey
+	}
+	throttle := &logThrottle{interval: limiterErrorLogInterval}
+
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		allowed, err := limiter.Allow(keyFunc(req, ctx))
+		if err != nil {
+			action := "rejecting"
+			if !config.FailClosed {
+				action = "allowing"
+			}
+			if ok, suppressed := throttle.allow(); ok {
+				log.Printf("Rate limiter unavailable, %s requests: %v (%d similar messages suppressed)", action, err, suppressed)
+			}
+			if !config.FailClosed {
+				return next(req, ctx)
+			}
+			return rateLimitErrorResponse(req, "Rate limiter unavailable"), nil
+		}
+
+		if !allowed {
+			return rateLimitErrorResponse(req, "Rate limit exceeded"), nil
+		}
+		return next(req, ctx)
+	}
+}
+
+// remoteIPKey возвращает IP адрес клиента без порта
+func remoteIPKey(_ *types.JSONRPCRequest, ctx *types.RequestContext) string {
+	if host, _, err := net.SplitHostPort(ctx.RemoteAddr); err == nil {
+		return host
+	}
+	return ctx.RemoteAddr
+}
+
+// rateLimitErrorResponse создает ответ с ошибкой ограничения частоты
+func rateLimitErrorResponse(req *types.JSONRPCRequest, message string) *types.JSONRPCResponse {
+	return &types.JSONRPCResponse{
+		JSONRPC: "2.0",
+		Error:   types.NewServerError(RateLimitedCode, message),
+		ID:      req.ID,
+	}
+}
+
+// tokenBucket - корзина токенов одного клиента
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// TokenBucketLimiter ограничивает частоту запросов корзиной токенов в памяти.
+// Корзина вмещает limit токенов и полностью наполняется за window. Число
+// корзин ограничено (SetMaxClients); корзина вытесненного клиента создается
+// заново полной
+type TokenBucketLimiter struct {
+	capacity float64
+	rate     float64 // токенов в секунду
+	buckets  *clientTable
+	clock    types.Clock
+	mu       sync.Mutex
+}
+
+// NewTokenBucketLimiter создает ограничитель на limit запросов за window
+func NewTokenBucketLimiter(limit int, window time.Duration) *TokenBucketLimiter {
+	return NewTokenBucketLimiterWithClock(limit, window, types.GlobalClock)
+}
+
+// NewTokenBucketLimiterWithClock создает ограничитель с внедряемыми часами
+func NewTokenBucketLimiterWithClock(limit int, window time.Duration, clock types.Clock) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		capacity: float64(limit),
+		rate:     float64(limit) / window.Seconds(),
+		buckets:  newClientTable(DefaultMaxClients),
+		clock:    clock,
+	}
+}
+
+// SetMaxClients ограничивает число хранимых корзин; max <= 0 означает
+// DefaultMaxClients. Лишние корзины давно не обращавшихся клиентов вытесняются
+func (l *TokenBucketLimiter) SetMaxClients(max int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	table := newClientTable(max)
+	// Переносим корзины от давно использованных к недавним, сохраняя порядок
+	for element := l.buckets.order.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*clientTableEntry)
+		table.add(entry.key, entry.value)
+	}
+	l.buckets = table
+}
+
+// Clients возвращает число клиентов, корзины которых хранятся в памяти
+func (l *TokenBucketLimiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buckets.len()
+}
+
+// Allow забирает токен из корзины клиента. Ошибок не возвращает
+func (l *TokenBucketLimiter) Allow(key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	var bucket *tokenBucket
+	if value, exists := l.buckets.get(key); exists {
+		bucket = value.(*tokenBucket)
+	} else {
+		bucket = &tokenBucket{tokens: l.capacity, last: now}
+		l.buckets.add(key, bucket)
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.capacity {
+		bucket.tokens = l.capacity
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, nil
+	}
+	bucket.tokens--
+	return true, nil
+}
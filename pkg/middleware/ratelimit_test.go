@@ -0,0 +1,393 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"streaming-server/pkg/types"
+)
+
+// mockRateLimiter answers Allow with a fixed decision and records the keys
+type mockRateLimiter struct {
+	allowed bool
+	err     error
+	keys    []string
+}
+
+func (m *mockRateLimiter) Allow(key string) (bool, error) {
+	m.keys = append(m.keys, key)
+	return m.allowed, m.err
+}
+
+func runRateLimited(t *testing.T, limiter RateLimiter, config RateLimitConfig) (*types.JSONRPCResponse, bool) {
+	t.Helper()
+	called := false
+	next := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		called = true
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "http", "10.0.0.1:5000")
+	response, err := RateLimitMiddleware(limiter, config)(req, ctx, next)
+	require.NoError(t, err)
+	return response, called
+}
+
+func TestRateLimitMiddleware_AllowAndDeny(t *testing.T) {
+	limiter := &mockRateLimiter{allowed: true}
+	response, called := runRateLimited(t, limiter, RateLimitConfig{})
+	assert.True(t, called)
+	assert.Equal(t, "ok", response.Result)
+	assert.Equal(t, []string{"10.0.0.1"}, limiter.keys, "clients are keyed by IP without the port")
+
+	limiter = &mockRateLimiter{allowed: false}
+	response, called = runRateLimited(t, limiter, RateLimitConfig{})
+	assert.False(t, called)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, RateLimitedCode, response.Error.Code)
+	assert.Equal(t, "Rate limit exceeded", response.Error.Message)
+	assert.Equal(t, 1, response.ID)
+}
+
+func TestRateLimitMiddleware_LimiterUnavailable(t *testing.T) {
+	limiter := &mockRateLimiter{err: errors.New("connection refused")}
+
+	// Fail-open by default: the request goes through
+	response, called := runRateLimited(t, limiter, RateLimitConfig{})
+	assert.True(t, called)
+	assert.Nil(t, response.Error)
+
+	response, called = runRateLimited(t, limiter, RateLimitConfig{FailClosed: true})
+	assert.False(t, called)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, RateLimitedCode, response.Error.Code)
+	assert.Equal(t, "Rate limiter unavailable", response.Error.Message)
+}
+
+func TestRateLimitMiddleware_KeyFunc(t *testing.T) {
+	limiter := &mockRateLimiter{allowed: true}
+	runRateLimited(t, limiter, RateLimitConfig{
+		KeyFunc: func(req *types.JSONRPCRequest, ctx *types.RequestContext) string { return "tenant-a" },
+	})
+	assert.Equal(t, []string{"tenant-a"}, limiter.keys)
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	clock := types.NewMockClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	limiter := NewTokenBucketLimiterWithClock(2, time.Second, clock)
+
+	allow := func(key string) bool {
+		allowed, err := limiter.Allow(key)
+		require.NoError(t, err)
+		return allowed
+	}
+
+	assert.True(t, allow("a"))
+	assert.True(t, allow("a"))
+	assert.False(t, allow("a"), "burst is limited to the limit")
+	assert.True(t, allow("b"), "clients have separate buckets")
+
+	clock.Advance(500 * time.Millisecond)
+	assert.True(t, allow("a"), "one token refills in half a window")
+	assert.False(t, allow("a"))
+
+	clock.Advance(time.Hour)
+	assert.True(t, allow("a"))
+	assert.True(t, allow("a"))
+	assert.False(t, allow("a"), "refill is capped at the limit")
+}
+
+func TestTokenBucketLimiter_MaxClients(t *testing.T) {
+	clock := types.NewMockClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	limiter := NewTokenBucketLimiterWithClock(1, time.Hour, clock)
+	limiter.SetMaxClients(2)
+
+	allow := func(key string) bool {
+		allowed, err := limiter.Allow(key)
+		require.NoError(t, err)
+		return allowed
+	}
+
+	// "active" exhausts its bucket and keeps sending requests between new clients
+	assert.True(t, allow("active"))
+	for i := 0; i < 10; i++ {
+		assert.True(t, allow(fmt.Sprintf("client-%d", i)))
+		assert.False(t, allow("active"), "an active client is never evicted and stays limited")
+	}
+	assert.Equal(t, 2, limiter.Clients())
+
+	// Idle clients were evicted, so their buckets start full again
+	assert.True(t, allow("client-0"))
+}
+
+func TestTokenBucketLimiter_SetMaxClientsShrinks(t *testing.T) {
+	limiter := NewTokenBucketLimiter(1, time.Hour)
+	for _, key := range []string{"a", "b", "c"} {
+		limiter.Allow(key)
+	}
+	limiter.Allow("a")
+
+	limiter.SetMaxClients(2)
+	assert.Equal(t, 2, limiter.Clients())
+
+	allowed, _ := limiter.Allow("a")
+	assert.False(t, allowed, "the most recently seen clients survive shrinking")
+	allowed, _ = limiter.Allow("b")
+	assert.True(t, allowed, "the oldest client was dropped")
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{Limit: 10})
+	require.NoError(t, err)
+	assert.IsType(t, &TokenBucketLimiter{}, limiter)
+
+	limiter, err = NewRateLimiter(RateLimitConfig{Limit: 10, MaxClients: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 5, limiter.(*TokenBucketLimiter).buckets.maxEntries)
+
+	limiter, err = NewRateLimiter(RateLimitConfig{Backend: RateLimitBackendRedis, Limit: 10, RedisAddr: "localhost:6379"})
+	require.NoError(t, err)
+	assert.IsType(t, &RedisRateLimiter{}, limiter)
+
+	_, err = NewRateLimiter(RateLimitConfig{Backend: RateLimitBackendRedis, Limit: 10})
+	assert.Error(t, err)
+	_, err = NewRateLimiter(RateLimitConfig{Backend: "memcached", Limit: 10})
+	assert.Error(t, err)
+	_, err = NewRateLimiter(RateLimitConfig{})
+	assert.Error(t, err)
+}
+
+// fakeRedis serves the commands used by RedisRateLimiter from an in-memory map
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	values   map[string]int64
+	ttls     map[string]string
+	accepted int32 // connections accepted so far
+	refuse   int32 // when set, connections are closed without a reply
+}
+
+func startFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeRedis{listener: listener, values: make(map[string]int64), ttls: make(map[string]string)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&server.accepted, 1)
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	if atomic.LoadInt32(&f.refuse) == 1 {
+		return
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		var reply string
+		switch args[0] {
+		case "INCR":
+			f.values[args[1]]++
+			reply = ":" + strconv.FormatInt(f.values[args[1]], 10) + "\r\n"
+		case "PEXPIRE":
+			f.ttls[args[1]] = args[2]
+			reply = ":1\r\n"
+		case "GET":
+			if value, ok := f.values[args[1]]; ok {
+				s := strconv.FormatInt(value, 10)
+				reply = "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+			} else {
+				reply = "$-1\r\n"
+			}
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// readRESPCommand reads a command encoded as an array of bulk strings
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(line[1 : len(line)-2])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		reply, err := readRESPReply(r)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = reply.(string)
+	}
+	return args, nil
+}
+
+func TestRedisRateLimiter_SlidingWindow(t *testing.T) {
+	redis := startFakeRedis(t)
+	// Start on a window boundary so the previous window weight is easy to follow
+	clock := types.NewMockClock(time.Unix(1700000000, 0))
+	limiter := NewRedisRateLimiterWithClock(RateLimitConfig{
+		Limit:          2,
+		Window:         time.Second,
+		RedisAddr:      redis.listener.Addr().String(),
+		RedisKeyPrefix: "rl:",
+	}, clock)
+	defer limiter.Close()
+
+	allow := func() bool {
+		allowed, err := limiter.Allow("10.0.0.1")
+		require.NoError(t, err)
+		return allowed
+	}
+
+	assert.True(t, allow())
+	assert.True(t, allow())
+	assert.False(t, allow())
+
+	redis.mu.Lock()
+	assert.Equal(t, int64(3), redis.values["rl:10.0.0.1:1700000000"])
+	assert.Equal(t, "2000", redis.ttls["rl:10.0.0.1:1700000000"], "keys outlive the next window")
+	redis.mu.Unlock()
+
+	// Half way into the next window the previous one still counts 3*0.5
+	clock.Advance(1500 * time.Millisecond)
+	assert.False(t, allow())
+
+	// Once the previous window has slid out the client is allowed again
+	clock.Advance(time.Second)
+	assert.True(t, allow())
+}
+
+func TestRedisRateLimiter_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	limiter := NewRedisRateLimiter(RateLimitConfig{Limit: 1, RedisAddr: addr})
+	_, err = limiter.Allow("10.0.0.1")
+	require.Error(t, err)
+
+	// The middleware lets the request through unless configured fail-closed
+	response, called := runRateLimited(t, limiter, RateLimitConfig{})
+	assert.True(t, called)
+	assert.Nil(t, response.Error)
+}
+
+func TestRedisRateLimiter_ReusesConnections(t *testing.T) {
+	redis := startFakeRedis(t)
+	limiter := NewRedisRateLimiter(RateLimitConfig{Limit: 100, RedisAddr: redis.listener.Addr().String(), RedisPoolSize: 2})
+
+	for i := 0; i < 5; i++ {
+		_, err := limiter.Allow("10.0.0.1")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&redis.accepted), "sequential calls share one connection")
+
+	// Concurrent calls open more connections; the pool keeps at most RedisPoolSize
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := limiter.Allow("10.0.0.1")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, len(limiter.idle), 2)
+
+	require.NoError(t, limiter.Close())
+	assert.Empty(t, limiter.idle)
+}
+
+func TestRedisRateLimiter_FailsFastAfterError(t *testing.T) {
+	redis := startFakeRedis(t)
+	atomic.StoreInt32(&redis.refuse, 1)
+	limiter := NewRedisRateLimiter(RateLimitConfig{
+		Limit:              100,
+		RedisAddr:          redis.listener.Addr().String(),
+		RedisRetryInterval: 200 * time.Millisecond,
+	})
+	defer limiter.Close()
+
+	_, err := limiter.Allow("10.0.0.1")
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrRedisUnavailable))
+	accepted := atomic.LoadInt32(&redis.accepted)
+
+	// Until the retry interval passes Redis is not contacted at all
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		_, err = limiter.Allow("10.0.0.1")
+		assert.ErrorIs(t, err, ErrRedisUnavailable)
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, accepted, atomic.LoadInt32(&redis.accepted))
+
+	// Once it has passed the next call tries again
+	atomic.StoreInt32(&redis.refuse, 0)
+	require.Eventually(t, func() bool {
+		_, err := limiter.Allow("10.0.0.1")
+		return err == nil
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestRateLimitMiddleware_ThrottlesErrorLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	limiter := &mockRateLimiter{err: errors.New("connection refused")}
+	mw := RateLimitMiddleware(limiter, RateLimitConfig{})
+	next := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+	for i := 0; i < 50; i++ {
+		ctx := types.NewRequestContext(context.Background(), "http", "10.0.0.1:5000")
+		_, err := mw(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", ID: i}, ctx, next)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, strings.Count(buf.String(), "Rate limiter unavailable"))
+}
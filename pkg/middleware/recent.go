@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"streaming-server/pkg/types"
+)
+
+// RequestSummary содержит краткие сведения об обработанном запросе
+type RequestSummary struct {
+	Method     string    `json:"method"`
+	Status     string    `json:"status"`
+	DurationMs float64   `json:"duration_ms"`
+	RemoteAddr string    `json:"remote_addr"`
+	Time       time.Time `json:"time"`
+}
+
+// RecentRequests хранит сводки последних запросов в кольцевом буфере фиксированного размера
+type RecentRequests struct {
+	entries []RequestSummary
+	next    int
+	full    bool
+	clock   types.Clock
+	mu      sync.Mutex
+}
+
+// NewRecentRequests создает буфер на size последних запросов
+func NewRecentRequests(size int) *RecentRequests {
+	return NewRecentRequestsWithClock(size, types.GlobalClock)
+}
+
+// NewRecentRequestsWithClock создает буфер с внедряемыми часами
+func NewRecentRequestsWithClock(size int, clock types.Clock) *RecentRequests {
+	if size <= 0 {
+		size = 1
+	}
+	return &RecentRequests{
+		entries: make([]RequestSummary, size),
+		clock:   clock,
+	}
+}
+
+// Add добавляет сводку, вытесняя самую старую при заполнении буфера
+func (r *RecentRequests) Add(summary RequestSummary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = summary
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Snapshot возвращает копию сводок от самой старой к самой новой
+func (r *RecentRequests) Snapshot() []RequestSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		result := make([]RequestSummary, r.next)
+		copy(result, r.entries[:r.next])
+		return result
+	}
+
+	result := make([]RequestSummary, 0, len(r.entries))
+	result = append(result, r.entries[r.next:]...)
+	result = append(result, r.entries[:r.next]...)
+	return result
+}
+
+// Middleware возвращает промежуточный слой, записывающий результат каждого запроса
+func (r *RecentRequests) Middleware() types.Middleware {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		response, err := next(req, ctx)
+
+		status := "success"
+		if err != nil {
+			status = "error"
+		} else if response != nil && response.Error != nil {
+			status = "rpc_error"
+		}
+
+		r.Add(RequestSummary{
+			Method:     req.Method,
+			Status:     status,
+			DurationMs: float64(ctx.Duration().Microseconds()) / 1000,
+			RemoteAddr: ctx.RemoteAddr,
+			Time:       r.clock.Now(),
+		})
+
+		return response, err
+	}
+}
+
+// HTTPHandler возвращает HTTP обработчик, отдающий сводки последних запросов
+func (r *RecentRequests) HTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(r.Snapshot())
+	}
+}
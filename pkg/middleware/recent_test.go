@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentRequests_Snapshot_NotFull(t *testing.T) {
+	recent := NewRecentRequests(5)
+	assert.Empty(t, recent.Snapshot())
+
+	recent.Add(RequestSummary{Method: "a"})
+	recent.Add(RequestSummary{Method: "b"})
+
+	snapshot := recent.Snapshot()
+	require.Len(t, snapshot, 2)
+	assert.Equal(t, "a", snapshot[0].Method)
+	assert.Equal(t, "b", snapshot[1].Method)
+}
+
+func TestRecentRequests_Snapshot_Overflow(t *testing.T) {
+	recent := NewRecentRequests(3)
+
+	for i := 0; i < 10; i++ {
+		recent.Add(RequestSummary{Method: fmt.Sprintf("m%d", i)})
+	}
+
+	snapshot := recent.Snapshot()
+	require.Len(t, snapshot, 3)
+	assert.Equal(t, "m7", snapshot[0].Method)
+	assert.Equal(t, "m8", snapshot[1].Method)
+	assert.Equal(t, "m9", snapshot[2].Method)
+}
+
+func TestRecentRequests_Middleware(t *testing.T) {
+	clock := types.NewMockClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	recent := NewRecentRequestsWithClock(10, clock)
+	mw := recent.Middleware()
+
+	handlers := map[string]types.Handler{
+		"ok": func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+		},
+		"rpc_fail": func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Error: types.NewInternalError(nil), ID: req.ID}, nil
+		},
+		"fail": func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return nil, errors.New("boom")
+		},
+	}
+
+	for _, method := range []string{"ok", "rpc_fail", "fail"} {
+		req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: method, ID: 1}
+		ctx := types.NewRequestContext(context.Background(), "HTTP", "10.0.0.1:1234")
+		mw(req, ctx, handlers[method])
+	}
+
+	snapshot := recent.Snapshot()
+	require.Len(t, snapshot, 3)
+	assert.Equal(t, "success", snapshot[0].Status)
+	assert.Equal(t, "rpc_error", snapshot[1].Status)
+	assert.Equal(t, "error", snapshot[2].Status)
+	assert.Equal(t, "10.0.0.1:1234", snapshot[0].RemoteAddr)
+	assert.Equal(t, clock.Now(), snapshot[0].Time)
+}
+
+func TestRecentRequests_HTTPHandler(t *testing.T) {
+	recent := NewRecentRequests(2)
+	for _, method := range []string{"first", "second", "third"} {
+		recent.Add(RequestSummary{Method: method, Status: "success"})
+	}
+
+	w := httptest.NewRecorder()
+	recent.HTTPHandler()(w, httptest.NewRequest("GET", "/debug/recent", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var summaries []RequestSummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summaries))
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "second", summaries[0].Method)
+	assert.Equal(t, "third", summaries[1].Method)
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"unicode"
+
+	"streaming-server/pkg/types"
+)
+
+// maxPanicMessageLength ограничивает длину сообщения о панике, возвращаемого клиенту
+const maxPanicMessageLength = 200
+
+// RecoveryMiddleware перехватывает панику в обработчике и следующих middleware,
+// записывает стек в журнал и возвращает клиенту внутреннюю ошибку (-32603)
+// вместо обрыва соединения
+func RecoveryMiddleware() types.Middleware {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (response *types.JSONRPCResponse, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Panic in handler for method %q: %v\n%s", req.Method, r, debug.Stack())
+
+				response = &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewInternalError("panic: " + sanitizePanicMessage(r)),
+					ID:      req.ID,
+				}
+				err = nil
+			}
+		}()
+
+		return next(req, ctx)
+	}
+}
+
+// sanitizePanicMessage оставляет первую строку сообщения о панике без управляющих
+// символов и обрезает ее, чтобы не передавать клиенту многострочные дампы
+func sanitizePanicMessage(r interface{}) string {
+	message := fmt.Sprint(r)
+	if i := strings.IndexAny(message, "\r\n"); i >= 0 {
+		message = message[:i]
+	}
+
+	message = strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) || c == unicode.ReplacementChar {
+			return -1
+		}
+		return c
+	}, message)
+
+	if runes := []rune(message); len(runes) > maxPanicMessageLength {
+		message = string(runes[:maxPanicMessageLength]) + "..."
+	}
+	return message
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	mw := RecoveryMiddleware()
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "boom", ID: 7}
+
+	response, err := mw(req, types.NewRequestContext(context.Background(), "TCP", "127.0.0.1:1"), func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		panic("nil map write")
+	})
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InternalError, response.Error.Code)
+	assert.Equal(t, "panic: nil map write", response.Error.Data)
+	assert.Equal(t, 7, response.ID)
+}
+
+func TestRecoveryMiddleware_PassesThrough(t *testing.T) {
+	mw := RecoveryMiddleware()
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "ok", ID: 1}
+	handlerErr := errors.New("handler failed")
+
+	response, err := mw(req, types.NewRequestContext(context.Background(), "TCP", "127.0.0.1:1"), func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, handlerErr
+	})
+	assert.Nil(t, response)
+	assert.ErrorIs(t, err, handlerErr)
+}
+
+func TestSanitizePanicMessage(t *testing.T) {
+	assert.Equal(t, "first line", sanitizePanicMessage("first line\ngoroutine 1 [running]:"))
+	assert.Equal(t, "tabbedbell", sanitizePanicMessage("tab\tbed\abell"))
+	assert.Equal(t, "runtime error: index out of range", sanitizePanicMessage(errors.New("runtime error: index out of range")))
+
+	long := sanitizePanicMessage(strings.Repeat("x", 500))
+	assert.Equal(t, maxPanicMessageLength+len("..."), len(long))
+}
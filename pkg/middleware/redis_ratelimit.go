@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"streaming-server/pkg/types"
+)
+
+// RedisRateLimiter ограничивает частоту запросов скользящим окном со счетчиками
+// в Redis, поэтому лимит общий для всех экземпляров сервера. Запросы считаются
+// командой INCR в ключе текущего окна, который живет два окна (EXPIRE); число
+// запросов за скользящее окно - счетчик текущего окна плюс счетчик предыдущего,
+// взвешенный долей, на которую они перекрываются
+type RedisRateLimiter struct {
+	addr     string
+	password string
+	prefix   string
+	timeout  time.Duration
+	limit    int
+	window   time.Duration
+	clock    types.Clock
+	retry    time.Duration
+
+	// Пул соединений: обращение берет свободное соединение или открывает новое,
+	// после ошибки соединение закрывается, лишние при возврате - тоже
+	idle chan *redisConn
+
+	// После ошибки обращения Allow до brokenUntil сразу возвращает
+	// ErrRedisUnavailable, не открывая соединений и не дожидаясь таймаутов
+	mu          sync.Mutex
+	brokenUntil time.Time
+	lastErr     error
+	closed      bool
+}
+
+// redisConn - соединение пула с буфером чтения ответов
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// ErrRedisUnavailable возвращается без обращения к Redis, пока после
+// предыдущей ошибки не истек RedisRetryInterval
+var ErrRedisUnavailable = errors.New("redis: unavailable")
+
+// NewRedisRateLimiter создает ограничитель по конфигурации. Соединения с Redis
+// открываются по мере необходимости
+func NewRedisRateLimiter(config RateLimitConfig) *RedisRateLimiter {
+	return NewRedisRateLimiterWithClock(config, types.GlobalClock)
+}
+
+// NewRedisRateLimiterWithClock создает ограничитель с внедряемыми часами
+func NewRedisRateLimiterWithClock(config RateLimitConfig, clock types.Clock) *RedisRateLimiter {
+	if config.Window <= 0 {
+		config.Window = DefaultRateLimitWindow
+	}
+	if config.RedisKeyPrefix == "" {
+		config.RedisKeyPrefix = DefaultRedisKeyPrefix
+	}
+	if config.RedisTimeout <= 0 {
+		config.RedisTimeout = DefaultRedisTimeout
+	}
+	if config.RedisPoolSize <= 0 {
+		config.RedisPoolSize = DefaultRedisPoolSize
+	}
+	if config.RedisRetryInterval <= 0 {
+		config.RedisRetryInterval = DefaultRedisRetryInterval
+	}
+
+	return &RedisRateLimiter{
+		addr:     config.RedisAddr,
+		password: config.RedisPassword,
+		prefix:   config.RedisKeyPrefix,
+		timeout:  config.RedisTimeout,
+		limit:    config.Limit,
+		window:   config.Window,
+		clock:    clock,
+		retry:    config.RedisRetryInterval,
+		idle:     make(chan *redisConn, config.RedisPoolSize),
+	}
+}
+
+// Allow учитывает запрос клиента и сообщает, укладывается ли он в лимит.
+// Отклоненные запросы тоже учитываются
+func (l *RedisRateLimiter) Allow(key string) (bool, error) {
+	now := l.clock.Now()
+	window := now.UnixNano() / int64(l.window)
+	currentKey := fmt.Sprintf("%s%s:%d", l.prefix, key, window)
+	previousKey := fmt.Sprintf("%s%s:%d", l.prefix, key, window-1)
+
+	replies, err := l.pipeline(
+		[]string{"INCR", currentKey},
+		[]string{"PEXPIRE", currentKey, strconv.FormatInt((2 * l.window).Milliseconds(), 10)},
+		[]string{"GET", previousKey},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	current, ok := replies[0].(int64)
+	if !ok {
+		return false, fmt.Errorf("redis: unexpected INCR reply %v", replies[0])
+	}
+	var previous int64
+	if value, ok := replies[2].(string); ok {
+		if previous, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return false, fmt.Errorf("redis: invalid counter %q", value)
+		}
+	}
+
+	elapsed := float64(now.UnixNano()-window*int64(l.window)) / float64(l.window)
+	count := float64(current) + float64(previous)*(1-elapsed)
+	return count <= float64(l.limit), nil
+}
+
+// Close закрывает свободные соединения с Redis; соединения, занятые
+// обращениями, закрываются при возврате
+func (l *RedisRateLimiter) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	var firstErr error
+	for {
+		select {
+		case c := <-l.idle:
+			if err := c.conn.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		default:
+			return firstErr
+		}
+	}
+}
+
+// pipeline отправляет команды одним пакетом и читает ответы на них.
+// Ошибка Redis в ответе возвращается как ошибка
+func (l *RedisRateLimiter) pipeline(commands ...[]string) ([]interface{}, error) {
+	if err := l.available(); err != nil {
+		return nil, err
+	}
+
+	c, err := l.get()
+	if err != nil {
+		l.fail(err)
+		return nil, err
+	}
+
+	replies, err := c.roundTrip(commands, l.timeout)
+	if err != nil {
+		c.conn.Close()
+		l.fail(err)
+		return nil, err
+	}
+	l.put(c)
+	return replies, nil
+}
+
+// available возвращает ErrRedisUnavailable, пока не истек интервал после ошибки
+func (l *RedisRateLimiter) available() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if time.Now().Before(l.brokenUntil) {
+		return fmt.Errorf("%w: %v", ErrRedisUnavailable, l.lastErr)
+	}
+	return nil
+}
+
+// fail запоминает ошибку и откладывает обращения на интервал повтора.
+// Интервал отсчитывается по реальному времени, как и сетевые таймауты
+func (l *RedisRateLimiter) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.brokenUntil = time.Now().Add(l.retry)
+	l.lastErr = err
+}
+
+// get берет свободное соединение из пула или открывает новое
+func (l *RedisRateLimiter) get() (*redisConn, error) {
+	select {
+	case c := <-l.idle:
+		return c, nil
+	default:
+		return l.dial()
+	}
+}
+
+// put возвращает соединение в пул или закрывает его, если пул полон или закрыт
+func (l *RedisRateLimiter) put(c *redisConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.closed {
+		select {
+		case l.idle <- c:
+			return
+		default:
+		}
+	}
+	c.conn.Close()
+}
+
+// dial открывает соединение и, если задан пароль, выполняет AUTH
+func (l *RedisRateLimiter) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", l.addr, l.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	if l.password != "" {
+		if _, err := c.roundTrip([][]string{{"AUTH", l.password}}, l.timeout); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// roundTrip отправляет команды и читает ответы. Сетевой дедлайн
+// отсчитывается по реальному времени, а не по часам ограничителя
+func (c *redisConn) roundTrip(commands [][]string, timeout time.Duration) ([]interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+
+	var buf []byte
+	for _, command := range commands {
+		buf = appendRESPCommand(buf, command)
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+
+	replies := make([]interface{}, len(commands))
+	for i := range commands {
+		reply, err := readRESPReply(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// appendRESPCommand кодирует команду массивом bulk строк протокола RESP
+func appendRESPCommand(buf []byte, args []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// readRESPReply читает ответ RESP: простую строку или bulk строку (string, nil
+// для отсутствующего значения), целое (int64) или ошибку Redis (error).
+// Массивы командам ограничителя не нужны и не поддерживаются
+func readRESPReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	payload := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", payload)
+	case ':':
+		value, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed integer %q", payload)
+		}
+		return value, nil
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", payload)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(data[:size]), nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply %q", line)
+	}
+}
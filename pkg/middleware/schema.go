@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"streaming-server/pkg/types"
+)
+
+// SchemaLookup возвращает JSON Schema метода (параметров или результата);
+// false означает, что схема не задана
+type SchemaLookup func(method string) (json.RawMessage, bool)
+
+// SchemaViolation описывает одно нарушение схемы параметров или результата
+type SchemaViolation struct {
+	// Field - JSON Pointer на поле ("" для params или result целиком)
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// compiledSchema кэшированная скомпилированная схема вместе с исходным текстом
+type compiledSchema struct {
+	source string
+	schema *jsonschema.Schema
+}
+
+// schemaCache компилирует схемы при первом использовании и перекомпилирует их,
+// если схема метода изменилась
+type schemaCache struct {
+	// kind - назначение схем ("params" или "result") для адресов и сообщений об ошибках
+	kind    string
+	mu      sync.Mutex
+	schemas map[string]compiledSchema
+}
+
+func (c *schemaCache) get(method string, source json.RawMessage) (*jsonschema.Schema, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.schemas[method]; ok && cached.source == string(source) {
+		return cached.schema, nil
+	}
+
+	url := c.kind + "/" + method + ".json"
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(source)); err != nil {
+		return nil, fmt.Errorf("invalid %s schema for %s: %w", c.kind, method, err)
+	}
+	schema, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s schema for %s: %w", c.kind, method, err)
+	}
+
+	c.schemas[method] = compiledSchema{source: string(source), schema: schema}
+	return schema, nil
+}
+
+// SchemaValidationMiddleware проверяет параметры запроса по JSON Schema, зарегистрированной
+// для метода. При нарушениях запрос отклоняется ошибкой -32602, в Data которой
+// перечислены поля и причины. Методы без схемы передаются дальше без проверки
+func SchemaValidationMiddleware(lookup SchemaLookup) types.Middleware {
+	cache := &schemaCache{kind: "params", schemas: make(map[string]compiledSchema)}
+
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		source, ok := lookup(req.Method)
+		if !ok || len(source) == 0 {
+			return next(req, ctx)
+		}
+
+		schema, err := cache.get(req.Method, source)
+		if err != nil {
+			return nil, err
+		}
+
+		var params interface{}
+		if len(req.Params) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(req.Params))
+			decoder.UseNumber()
+			if err := decoder.Decode(&params); err != nil {
+				return &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewInvalidParamsError("Invalid params JSON: " + err.Error()),
+					ID:      req.ID,
+				}, nil
+			}
+		}
+
+		if err := schema.Validate(params); err != nil {
+			var validationErr *jsonschema.ValidationError
+			if !errors.As(err, &validationErr) {
+				return nil, err
+			}
+
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidParamsError(schemaViolations(validationErr)),
+				ID:      req.ID,
+			}, nil
+		}
+
+		return next(req, ctx)
+	}
+}
+
+// ResponseSchemaCheckMiddleware проверяет результат успешного ответа по JSON Schema,
+// зарегистрированной для метода, на время перевода обработчиков на схемы.
+// Несоответствие записывается в журнал; при enforce ответ дополнительно
+// заменяется внутренней ошибкой (-32603), в Data которой перечислены нарушения.
+// Ответы с ошибкой, уведомления и методы без схемы не проверяются
+func ResponseSchemaCheckMiddleware(lookup SchemaLookup, enforce bool) types.Middleware {
+	cache := &schemaCache{kind: "result", schemas: make(map[string]compiledSchema)}
+
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		response, err := next(req, ctx)
+		if err != nil || response == nil || response.Error != nil {
+			return response, err
+		}
+
+		source, ok := lookup(req.Method)
+		if !ok || len(source) == 0 {
+			return response, nil
+		}
+
+		violations, checkErr := checkResultSchema(cache, req.Method, source, response.Result)
+		if checkErr != nil {
+			log.Printf("WARNING: result schema check for method %q failed: %v", req.Method, checkErr)
+			return response, nil
+		}
+		if len(violations) == 0 {
+			return response, nil
+		}
+
+		log.Printf("WARNING: result of method %q does not match its schema: %+v", req.Method, violations)
+		if !enforce {
+			return response, nil
+		}
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInternalError(violations),
+			ID:      req.ID,
+		}, nil
+	}
+}
+
+// checkResultSchema проверяет результат по схеме. Результат приводится к JSON,
+// чтобы проверка видела то же, что получит клиент
+func checkResultSchema(cache *schemaCache, method string, source json.RawMessage, result interface{}) ([]SchemaViolation, error) {
+	schema, err := cache.get(method, source)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+
+	if err := schema.Validate(value); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if !errors.As(err, &validationErr) {
+			return nil, err
+		}
+		return schemaViolations(validationErr), nil
+	}
+	return nil, nil
+}
+
+// schemaViolations собирает конечные причины ошибки валидации
+func schemaViolations(err *jsonschema.ValidationError) []SchemaViolation {
+	if len(err.Causes) == 0 {
+		return []SchemaViolation{{Field: err.InstanceLocation, Message: err.Message}}
+	}
+
+	var violations []SchemaViolation
+	for _, cause := range err.Causes {
+		violations = append(violations, schemaViolations(cause)...)
+	}
+	return violations
+}
@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const calculateSchema = `{
+	"type": "object",
+	"properties": {
+		"operation": {"type": "string"},
+		"a": {"type": "number"},
+		"b": {"type": "number"}
+	},
+	"required": ["operation", "a", "b"]
+}`
+
+func schemaLookup(schemas map[string]string) SchemaLookup {
+	return func(method string) (json.RawMessage, bool) {
+		schema, ok := schemas[method]
+		return json.RawMessage(schema), ok
+	}
+}
+
+func TestSchemaValidationMiddleware(t *testing.T) {
+	mw := SchemaValidationMiddleware(schemaLookup(map[string]string{"calculate": calculateSchema}))
+
+	tests := []struct {
+		name           string
+		method         string
+		params         string
+		expectError    bool
+		expectedFields map[string]string
+	}{
+		{
+			name:   "Valid params pass",
+			method: "calculate",
+			params: `{"operation":"add","a":1,"b":2.5}`,
+		},
+		{
+			name:           "Missing a and b rejected",
+			method:         "calculate",
+			params:         `{"operation":"add"}`,
+			expectError:    true,
+			expectedFields: map[string]string{"": "'a', 'b'"},
+		},
+		{
+			name:           "Wrong types rejected per field",
+			method:         "calculate",
+			params:         `{"operation":"add","a":"one","b":true}`,
+			expectError:    true,
+			expectedFields: map[string]string{"/a": "string", "/b": "boolean"},
+		},
+		{
+			name:           "Missing params rejected",
+			method:         "calculate",
+			expectError:    true,
+			expectedFields: map[string]string{"": "null"},
+		},
+		{
+			name:   "Methods without schema pass through",
+			method: "echo",
+			params: `"anything"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: tt.method, ID: 1}
+			if tt.params != "" {
+				req.Params = json.RawMessage(tt.params)
+			}
+			ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+			handlerCalled := false
+			response, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+				handlerCalled = true
+				return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+			})
+			require.NoError(t, err)
+			require.NotNil(t, response)
+
+			if !tt.expectError {
+				assert.True(t, handlerCalled)
+				assert.Nil(t, response.Error)
+				return
+			}
+
+			assert.False(t, handlerCalled)
+			require.NotNil(t, response.Error)
+			assert.Equal(t, types.InvalidParams, response.Error.Code)
+			assert.Equal(t, 1, response.ID)
+
+			violations, ok := response.Error.Data.([]SchemaViolation)
+			require.True(t, ok, "Data lists the schema violations")
+
+			messages := make(map[string]string)
+			for _, violation := range violations {
+				messages[violation.Field] += violation.Message
+			}
+			for field, fragment := range tt.expectedFields {
+				assert.Contains(t, messages[field], fragment, "field %q", field)
+			}
+		})
+	}
+}
+
+func TestSchemaValidationMiddleware_SchemaChangesAreRecompiled(t *testing.T) {
+	schemas := map[string]string{"calculate": calculateSchema}
+	mw := SchemaValidationMiddleware(schemaLookup(schemas))
+	handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "calculate", Params: json.RawMessage(`{"operation":"add"}`), ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	response, err := mw(req, ctx, handler)
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+
+	schemas["calculate"] = `{"type":"object"}`
+	response, err = mw(req, ctx, handler)
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+}
+
+func TestSchemaValidationMiddleware_InvalidSchema(t *testing.T) {
+	mw := SchemaValidationMiddleware(schemaLookup(map[string]string{"calculate": `{"type": 5}`}))
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "calculate", Params: json.RawMessage(`{}`), ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	_, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		t.Fatal("handler must not run with a broken schema")
+		return nil, nil
+	})
+	assert.ErrorContains(t, err, "invalid params schema")
+}
+
+const calculateResultSchema = `{
+	"type": "object",
+	"properties": {"result": {"type": "number"}},
+	"required": ["result"]
+}`
+
+// captureLog returns everything written to the standard logger while fn runs
+func captureLog(t *testing.T, fn func()) string {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	fn()
+	return buf.String()
+}
+
+func TestResponseSchemaCheckMiddleware(t *testing.T) {
+	lookup := schemaLookup(map[string]string{"calculate": calculateResultSchema})
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "calculate", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+
+	respond := func(result interface{}) types.Handler {
+		return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: result, ID: req.ID}, nil
+		}
+	}
+	nonConforming := respond(map[string]interface{}{"result": "15"})
+
+	t.Run("conforming response", func(t *testing.T) {
+		for _, enforce := range []bool{false, true} {
+			var response *types.JSONRPCResponse
+			output := captureLog(t, func() {
+				var err error
+				response, err = ResponseSchemaCheckMiddleware(lookup, enforce)(req, ctx, respond(map[string]interface{}{"result": 15}))
+				require.NoError(t, err)
+			})
+			assert.Nil(t, response.Error)
+			assert.Empty(t, output)
+		}
+	})
+
+	t.Run("non-conforming response is logged", func(t *testing.T) {
+		var response *types.JSONRPCResponse
+		output := captureLog(t, func() {
+			var err error
+			response, err = ResponseSchemaCheckMiddleware(lookup, false)(req, ctx, nonConforming)
+			require.NoError(t, err)
+		})
+
+		assert.Nil(t, response.Error)
+		assert.Equal(t, map[string]interface{}{"result": "15"}, response.Result)
+		assert.Contains(t, output, "WARNING")
+		assert.Contains(t, output, `method "calculate" does not match its schema`)
+		assert.Contains(t, output, "/result")
+	})
+
+	t.Run("non-conforming response is rejected when enforced", func(t *testing.T) {
+		var response *types.JSONRPCResponse
+		output := captureLog(t, func() {
+			var err error
+			response, err = ResponseSchemaCheckMiddleware(lookup, true)(req, ctx, nonConforming)
+			require.NoError(t, err)
+		})
+
+		require.NotNil(t, response.Error)
+		assert.Equal(t, types.InternalError, response.Error.Code)
+		assert.Nil(t, response.Result)
+		assert.Equal(t, 1, response.ID)
+		violations, ok := response.Error.Data.([]SchemaViolation)
+		require.True(t, ok)
+		require.Len(t, violations, 1)
+		assert.Equal(t, "/result", violations[0].Field)
+		assert.Contains(t, output, "WARNING")
+	})
+
+	t.Run("errors and methods without schema are not checked", func(t *testing.T) {
+		mw := ResponseSchemaCheckMiddleware(lookup, true)
+
+		rpcErr := &types.JSONRPCResponse{JSONRPC: "2.0", Error: types.NewInvalidParamsError("bad"), ID: 1}
+		response, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return rpcErr, nil
+		})
+		require.NoError(t, err)
+		assert.Same(t, rpcErr, response)
+
+		other := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", ID: 2}
+		response, err = mw(other, ctx, respond("anything"))
+		require.NoError(t, err)
+		assert.Nil(t, response.Error)
+	})
+
+	t.Run("broken schema only logs", func(t *testing.T) {
+		mw := ResponseSchemaCheckMiddleware(schemaLookup(map[string]string{"calculate": `{"type": 5}`}), true)
+		var response *types.JSONRPCResponse
+		output := captureLog(t, func() {
+			var err error
+			response, err = mw(req, ctx, nonConforming)
+			require.NoError(t, err)
+		})
+		assert.Nil(t, response.Error)
+		assert.Contains(t, output, "invalid result schema")
+	})
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"container/heap"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"streaming-server/pkg/types"
+)
+
+// SlowRequest содержит сведения об одном из самых медленных запросов
+type SlowRequest struct {
+	Method     string    `json:"method"`
+	DurationMs float64   `json:"duration_ms"`
+	Time       time.Time `json:"time"`
+}
+
+// slowHeap - куча запросов с самым быстрым в корне: новый запрос сравнивается
+// с корнем и вытесняет его, если выполнялся дольше
+type slowHeap []SlowRequest
+
+func (h slowHeap) Len() int            { return len(h) }
+func (h slowHeap) Less(i, j int) bool  { return h[i].DurationMs < h[j].DurationMs }
+func (h slowHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x interface{}) { *h = append(*h, x.(SlowRequest)) }
+func (h *slowHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// SlowestRequests хранит size самых медленных запросов за время работы сервера
+type SlowestRequests struct {
+	entries slowHeap
+	size    int
+	clock   types.Clock
+	mu      sync.Mutex
+}
+
+// NewSlowestRequests создает хранилище на size самых медленных запросов
+func NewSlowestRequests(size int) *SlowestRequests {
+	return NewSlowestRequestsWithClock(size, types.GlobalClock)
+}
+
+// NewSlowestRequestsWithClock создает хранилище с внедряемыми часами
+func NewSlowestRequestsWithClock(size int, clock types.Clock) *SlowestRequests {
+	if size <= 0 {
+		size = 1
+	}
+	return &SlowestRequests{
+		entries: make(slowHeap, 0, size),
+		size:    size,
+		clock:   clock,
+	}
+}
+
+// Add учитывает запрос. При заполнении хранилища запрос заменяет самый быстрый
+// из сохраненных, если выполнялся дольше него
+func (s *SlowestRequests) Add(request SlowRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) < s.size {
+		heap.Push(&s.entries, request)
+		return
+	}
+	if request.DurationMs > s.entries[0].DurationMs {
+		s.entries[0] = request
+		heap.Fix(&s.entries, 0)
+	}
+}
+
+// Snapshot возвращает копию сохраненных запросов от самого медленного
+func (s *SlowestRequests) Snapshot() []SlowRequest {
+	s.mu.Lock()
+	result := make([]SlowRequest, len(s.entries))
+	copy(result, s.entries)
+	s.mu.Unlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DurationMs > result[j].DurationMs
+	})
+	return result
+}
+
+// Middleware возвращает промежуточный слой, учитывающий длительность каждого запроса
+func (s *SlowestRequests) Middleware() types.Middleware {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		response, err := next(req, ctx)
+
+		s.Add(SlowRequest{
+			Method:     req.Method,
+			DurationMs: float64(ctx.Duration().Microseconds()) / 1000,
+			Time:       s.clock.Now(),
+		})
+
+		return response, err
+	}
+}
+
+// HTTPHandler возвращает HTTP обработчик, отдающий самые медленные запросы
+func (s *SlowestRequests) HTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(s.Snapshot())
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowestRequests_KeepsSlowest(t *testing.T) {
+	slowest := NewSlowestRequests(3)
+	assert.Empty(t, slowest.Snapshot())
+
+	for i, ms := range []float64{5, 50, 1, 20, 100, 3, 50.5, 7} {
+		slowest.Add(SlowRequest{Method: string(rune('a' + i)), DurationMs: ms})
+	}
+
+	snapshot := slowest.Snapshot()
+	require.Len(t, snapshot, 3)
+	assert.Equal(t, []float64{100, 50.5, 50}, []float64{snapshot[0].DurationMs, snapshot[1].DurationMs, snapshot[2].DurationMs})
+	assert.Equal(t, "e", snapshot[0].Method)
+}
+
+func TestSlowestRequests_Middleware(t *testing.T) {
+	clock := types.NewMockClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	slowest := NewSlowestRequestsWithClock(2, clock)
+	mw := slowest.Middleware()
+
+	durations := map[string]time.Duration{
+		"fast":    time.Millisecond,
+		"slow":    300 * time.Millisecond,
+		"medium":  40 * time.Millisecond,
+		"slowest": 2 * time.Second,
+	}
+	for _, method := range []string{"fast", "slow", "medium", "slowest"} {
+		req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: method, ID: 1}
+		ctx := types.NewRequestContextWithClock(context.Background(), "HTTP", "10.0.0.1:1234", clock)
+		mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			clock.Advance(durations[req.Method])
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+		})
+	}
+
+	snapshot := slowest.Snapshot()
+	require.Len(t, snapshot, 2)
+	assert.Equal(t, "slowest", snapshot[0].Method)
+	assert.Equal(t, float64(2000), snapshot[0].DurationMs)
+	assert.Equal(t, clock.Now(), snapshot[0].Time)
+	assert.Equal(t, "slow", snapshot[1].Method)
+	assert.Equal(t, float64(300), snapshot[1].DurationMs)
+}
+
+func TestSlowestRequests_HTTPHandler(t *testing.T) {
+	slowest := NewSlowestRequests(2)
+	slowest.Add(SlowRequest{Method: "a", DurationMs: 1})
+	slowest.Add(SlowRequest{Method: "b", DurationMs: 3})
+	slowest.Add(SlowRequest{Method: "c", DurationMs: 2})
+
+	w := httptest.NewRecorder()
+	slowest.HTTPHandler()(w, httptest.NewRequest("GET", "/debug/slowest", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var requests []SlowRequest
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &requests))
+	require.Len(t, requests, 2)
+	assert.Equal(t, "b", requests[0].Method)
+	assert.Equal(t, "c", requests[1].Method)
+}
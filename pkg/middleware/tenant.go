@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"encoding/json"
+
+	"streaming-server/pkg/types"
+)
+
+// TenantErrorCode код ошибки для отсутствующего или неизвестного арендатора
+const TenantErrorCode = -32001
+
+// TenantContextKey ключ, под которым ID арендатора сохраняется в контексте запроса
+const TenantContextKey = "tenant"
+
+// TenantConfig содержит конфигурацию промежуточного слоя арендаторов
+type TenantConfig struct {
+	// HeaderName - HTTP заголовок с ID арендатора (например, "X-Tenant-ID")
+	HeaderName string
+	// ParamName - поле объекта params с ID арендатора; используется,
+	// если заголовок не задан или отсутствует
+	ParamName string
+	// Lookup проверяет, что арендатор существует
+	Lookup func(tenantID string) bool
+}
+
+// NewTenantAllowlist создает функцию проверки по фиксированному списку арендаторов
+func NewTenantAllowlist(tenantIDs ...string) func(string) bool {
+	allowed := make(map[string]bool, len(tenantIDs))
+	for _, id := range tenantIDs {
+		allowed[id] = true
+	}
+	return func(tenantID string) bool {
+		return allowed[tenantID]
+	}
+}
+
+// TenantMiddleware извлекает ID арендатора из заголовка или параметров,
+// проверяет его и сохраняет в контексте запроса под ключом TenantContextKey.
+// Запросы без арендатора или с неизвестным арендатором отклоняются ошибкой -32001
+func TenantMiddleware(config TenantConfig) types.Middleware {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		tenantID := extractTenantID(config, req, ctx)
+
+		if tenantID == "" {
+			return tenantErrorResponse(req, "Tenant required", nil), nil
+		}
+
+		if config.Lookup == nil || !config.Lookup(tenantID) {
+			return tenantErrorResponse(req, "Unknown tenant", tenantID), nil
+		}
+
+		ctx.WithValue(TenantContextKey, tenantID)
+		return next(req, ctx)
+	}
+}
+
+// extractTenantID ищет ID арендатора сначала в заголовках, затем в параметрах
+func extractTenantID(config TenantConfig, req *types.JSONRPCRequest, ctx *types.RequestContext) string {
+	if config.HeaderName != "" {
+		if ctx.HTTPRequest != nil {
+			if value := ctx.HTTPRequest.Header.Get(config.HeaderName); value != "" {
+				return value
+			}
+		}
+		if value := ctx.Headers[config.HeaderName]; value != "" {
+			return value
+		}
+	}
+
+	if config.ParamName != "" && req.HasParams() {
+		var params map[string]interface{}
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			if value, ok := params[config.ParamName].(string); ok {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+// tenantErrorResponse создает ответ с ошибкой арендатора
+func tenantErrorResponse(req *types.JSONRPCRequest, message string, data interface{}) *types.JSONRPCResponse {
+	rpcErr := types.NewServerError(TenantErrorCode, message)
+	rpcErr.Data = data
+
+	return &types.JSONRPCResponse{
+		JSONRPC: "2.0",
+		Error:   rpcErr,
+		ID:      req.ID,
+	}
+}
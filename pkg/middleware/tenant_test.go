@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	mw := TenantMiddleware(TenantConfig{
+		HeaderName: "X-Tenant-ID",
+		ParamName:  "tenant",
+		Lookup:     NewTenantAllowlist("acme", "globex"),
+	})
+
+	tests := []struct {
+		name           string
+		header         string
+		params         json.RawMessage
+		expectedTenant string
+		expectedError  string
+	}{
+		{
+			name:           "Valid tenant from header",
+			header:         "acme",
+			expectedTenant: "acme",
+		},
+		{
+			name:           "Valid tenant from params",
+			params:         json.RawMessage(`{"tenant":"globex","message":"hi"}`),
+			expectedTenant: "globex",
+		},
+		{
+			name:           "Header takes precedence over params",
+			header:         "acme",
+			params:         json.RawMessage(`{"tenant":"globex"}`),
+			expectedTenant: "acme",
+		},
+		{
+			name:          "Missing tenant",
+			params:        json.RawMessage(`{"message":"hi"}`),
+			expectedError: "Tenant required",
+		},
+		{
+			name:          "Missing tenant with positional params",
+			params:        json.RawMessage(`["acme"]`),
+			expectedError: "Tenant required",
+		},
+		{
+			name:          "Unknown tenant",
+			header:        "initech",
+			expectedError: "Unknown tenant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", Params: tt.params, ID: "test-1"}
+			ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+
+			httpReq := httptest.NewRequest("POST", "/rpc", nil)
+			if tt.header != "" {
+				httpReq.Header.Set("X-Tenant-ID", tt.header)
+			}
+			ctx.HTTPRequest = httpReq
+
+			handlerCalled := false
+			handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+				handlerCalled = true
+				tenant, exists := ctx.GetValue(TenantContextKey)
+				assert.True(t, exists)
+				assert.Equal(t, tt.expectedTenant, tenant)
+				return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+			}
+
+			response, err := mw(req, ctx, handler)
+			require.NoError(t, err)
+			require.NotNil(t, response)
+
+			if tt.expectedError != "" {
+				assert.False(t, handlerCalled)
+				require.NotNil(t, response.Error)
+				assert.Equal(t, TenantErrorCode, response.Error.Code)
+				assert.Equal(t, tt.expectedError, response.Error.Message)
+				assert.Equal(t, "test-1", response.ID)
+				return
+			}
+
+			assert.True(t, handlerCalled)
+			assert.Nil(t, response.Error)
+		})
+	}
+}
+
+func TestTenantMiddleware_StreamHeaders(t *testing.T) {
+	mw := TenantMiddleware(TenantConfig{
+		HeaderName: "X-Tenant-ID",
+		Lookup:     NewTenantAllowlist("acme"),
+	})
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "TCP", "127.0.0.1")
+	ctx.Headers["X-Tenant-ID"] = "acme"
+
+	response, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	})
+	require.NoError(t, err)
+	assert.Nil(t, response.Error)
+}
+
+func TestTenantMiddleware_NoLookup(t *testing.T) {
+	mw := TenantMiddleware(TenantConfig{ParamName: "tenant"})
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", Params: json.RawMessage(`{"tenant":"acme"}`), ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+
+	response, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		t.Fatal("handler must not be called without a lookup")
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, "Unknown tenant", response.Error.Message)
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"streaming-server/pkg/types"
+)
+
+// timingResponseWriter фиксирует моменты отправки первого и последнего байта ответа
+type timingResponseWriter struct {
+	http.ResponseWriter
+	clock     types.Clock
+	status    int
+	firstByte time.Time
+	lastByte  time.Time
+}
+
+// WriteHeader сохраняет код статуса ответа
+func (w *timingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write фиксирует время первой и каждой последующей записи тела ответа
+func (w *timingResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	now := w.clock.Now()
+	if w.firstByte.IsZero() {
+		w.firstByte = now
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.lastByte = w.clock.Now()
+	return n, err
+}
+
+// Flush передает сброс буфера исходному писателю, чтобы потоковые ответы
+// отправлялись клиенту частями
+func (w *timingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap позволяет http.ResponseController получить исходный писатель
+func (w *timingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ResponseTimingMiddleware создает HTTP промежуточный слой, записывающий в журнал
+// время до первого байта ответа (TTFB) и полное время отправки ответа.
+// Полезно для анализа задержек потоковых и больших ответов
+func ResponseTimingMiddleware(logger *Logger, next http.Handler) http.Handler {
+	if logger == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := logger.clock.Now()
+		tw := &timingResponseWriter{ResponseWriter: w, clock: logger.clock}
+
+		next.ServeHTTP(tw, r)
+
+		// Ответ без тела: последний байт совпадает с завершением обработчика
+		if tw.lastByte.IsZero() {
+			tw.lastByte = logger.clock.Now()
+		}
+		if tw.status == 0 {
+			tw.status = http.StatusOK
+		}
+
+		req := &types.JSONRPCRequest{Method: r.URL.Path}
+		success := tw.status < http.StatusBadRequest
+		if !logger.shouldLog(req, success, !success) {
+			return
+		}
+
+		entry := logger.createResponseTimingEntry(r, tw, start)
+		if logger.asyncProcessor != nil {
+			logger.asyncProcessor.Process(context.Background(), func() {
+				defer func() {
+					if rec := recover(); rec != nil {
+						log.Printf("Паника в промежуточном слое измерения ответа: %v", rec)
+					}
+				}()
+				logger.logEntry(entry)
+			})
+		} else {
+			logger.logEntry(entry)
+		}
+	})
+}
+
+// createResponseTimingEntry создает запись журнала с временем отправки HTTP ответа
+func (l *Logger) createResponseTimingEntry(r *http.Request, tw *timingResponseWriter, start time.Time) LogEntry {
+	entry := LogEntry{
+		RequestID:      r.Header.Get("X-Request-ID"),
+		Method:         r.URL.Path,
+		Transport:      "HTTP",
+		RemoteAddr:     r.RemoteAddr,
+		UserAgent:      r.UserAgent(),
+		Timestamp:      l.clock.Now(),
+		Duration:       tw.lastByte.Sub(start).Milliseconds(),
+		StartTime:      start,
+		StatusCode:     tw.status,
+		ServiceName:    l.config.ServiceName,
+		ServiceVersion: l.config.ServiceVersion,
+		Level:          LogLevelInfo,
+		ExtraFields:    make(map[string]string),
+	}
+
+	lastByte := tw.lastByte.Sub(start).Milliseconds()
+	entry.LastByte = &lastByte
+	if !tw.firstByte.IsZero() {
+		ttfb := tw.firstByte.Sub(start).Milliseconds()
+		entry.FirstByte = &ttfb
+	}
+
+	if tw.status >= http.StatusBadRequest {
+		entry.Success = false
+		entry.Level = LogLevelWarn
+	} else {
+		entry.Success = true
+	}
+
+	for key, value := range l.config.ExtraFields {
+		entry.ExtraFields[key] = value
+	}
+
+	return entry
+}
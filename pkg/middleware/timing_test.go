@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTimingLogger(clock types.Clock) (*Logger, *MockLogWriter) {
+	writer := &MockLogWriter{}
+	writer.On("Write", mock.AnythingOfType("LogEntry")).Return(nil)
+
+	return &Logger{
+		config: LoggingConfig{
+			Enabled:        true,
+			ServiceName:    "test-service",
+			ServiceVersion: "1.0.0",
+		},
+		writer: writer,
+		clock:  clock,
+	}, writer
+}
+
+func TestResponseTimingMiddleware_StreamedResponse(t *testing.T) {
+	clock := types.NewMockClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	logger, writer := newTimingLogger(clock)
+
+	// Первый фрагмент готов через 10мс, последний - еще через 40мс
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(10 * time.Millisecond)
+		w.Write([]byte(`{"jsonrpc":"2.0","result":[`))
+		w.(http.Flusher).Flush()
+
+		clock.Advance(40 * time.Millisecond)
+		w.Write([]byte(`1,2,3],"id":1}`))
+	})
+
+	recorder := httptest.NewRecorder()
+	ResponseTimingMiddleware(logger, handler).ServeHTTP(recorder, httptest.NewRequest("POST", "/rpc", nil))
+
+	assert.True(t, recorder.Flushed, "flush must reach the underlying writer")
+	assert.Equal(t, `{"jsonrpc":"2.0","result":[1,2,3],"id":1}`, recorder.Body.String())
+
+	entries := writer.GetEntries()
+	require.Len(t, entries, 1)
+	entry := entries[0]
+
+	require.NotNil(t, entry.FirstByte)
+	require.NotNil(t, entry.LastByte)
+	assert.Equal(t, int64(10), *entry.FirstByte)
+	assert.Equal(t, int64(50), *entry.LastByte)
+	assert.Less(t, *entry.FirstByte, *entry.LastByte, "TTFB must be recorded before total completion")
+	assert.Equal(t, "/rpc", entry.Method)
+	assert.Equal(t, "HTTP", entry.Transport)
+	assert.Equal(t, http.StatusOK, entry.StatusCode)
+	assert.True(t, entry.Success)
+}
+
+func TestResponseTimingMiddleware_NoBody(t *testing.T) {
+	clock := types.NewMockClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	logger, writer := newTimingLogger(clock)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(5 * time.Millisecond)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+
+	ResponseTimingMiddleware(logger, handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/rpc", nil))
+
+	entries := writer.GetEntries()
+	require.Len(t, entries, 1)
+	entry := entries[0]
+
+	assert.Nil(t, entry.FirstByte, "no body means no first byte")
+	require.NotNil(t, entry.LastByte)
+	assert.Equal(t, int64(5), *entry.LastByte)
+	assert.Equal(t, http.StatusMethodNotAllowed, entry.StatusCode)
+	assert.False(t, entry.Success)
+	assert.Equal(t, LogLevelWarn, entry.Level)
+}
+
+func TestResponseTimingMiddleware_NilLogger(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	wrapped := ResponseTimingMiddleware(nil, handler)
+
+	recorder := httptest.NewRecorder()
+	wrapped.ServeHTTP(recorder, httptest.NewRequest("GET", "/rpc", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"streaming-server/pkg/types"
+)
+
+// UTF8Policy определяет, как обрабатывать некорректный UTF-8 в параметрах
+type UTF8Policy string
+
+const (
+	// UTF8PolicyReject отклоняет запрос ошибкой разбора (-32700)
+	UTF8PolicyReject UTF8Policy = "reject"
+	// UTF8PolicySanitize заменяет некорректные последовательности символом U+FFFD
+	UTF8PolicySanitize UTF8Policy = "sanitize"
+)
+
+// utf8Replacement символ замены некорректных последовательностей
+var utf8Replacement = []byte("\uFFFD")
+
+// UTF8ValidationMiddleware проверяет, что параметры запроса являются корректным UTF-8.
+// В зависимости от политики запрос отклоняется или параметры очищаются
+// перед передачей обработчику
+func UTF8ValidationMiddleware(policy UTF8Policy) types.Middleware {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
+		if utf8.Valid(req.Params) {
+			return next(req, ctx)
+		}
+
+		if policy == UTF8PolicySanitize {
+			sanitized := *req
+			sanitized.Params = bytes.ToValidUTF8(req.Params, utf8Replacement)
+			return next(&sanitized, ctx)
+		}
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewParseError("Params contain invalid UTF-8"),
+			ID:      req.ID,
+		}, nil
+	}
+}
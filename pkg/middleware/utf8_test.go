@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUTF8ValidationMiddleware(t *testing.T) {
+	validParams := json.RawMessage(`{"message":"привет, 世界"}`)
+	invalidParams := json.RawMessage("{\"message\":\"bad \xff\xfe bytes\"}")
+	truncatedParams := json.RawMessage("{\"message\":\"cut \xe4\xb8\"}")
+
+	tests := []struct {
+		name           string
+		policy         UTF8Policy
+		params         json.RawMessage
+		expectError    bool
+		expectedParams string
+	}{
+		{
+			name:           "Valid UTF-8 passes with reject policy",
+			policy:         UTF8PolicyReject,
+			params:         validParams,
+			expectedParams: string(validParams),
+		},
+		{
+			name:           "Valid UTF-8 passes with sanitize policy",
+			policy:         UTF8PolicySanitize,
+			params:         validParams,
+			expectedParams: string(validParams),
+		},
+		{
+			name:        "Invalid bytes rejected",
+			policy:      UTF8PolicyReject,
+			params:      invalidParams,
+			expectError: true,
+		},
+		{
+			name:        "Truncated sequence rejected",
+			policy:      UTF8PolicyReject,
+			params:      truncatedParams,
+			expectError: true,
+		},
+		{
+			name:           "Invalid bytes sanitized",
+			policy:         UTF8PolicySanitize,
+			params:         invalidParams,
+			expectedParams: `{"message":"bad ` + "�" + ` bytes"}`,
+		},
+		{
+			name:           "Missing params pass",
+			policy:         UTF8PolicyReject,
+			params:         nil,
+			expectedParams: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := UTF8ValidationMiddleware(tt.policy)
+			req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", Params: tt.params, ID: "test-1"}
+			ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+			var receivedParams json.RawMessage
+			handlerCalled := false
+			handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+				handlerCalled = true
+				receivedParams = req.Params
+				return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+			}
+
+			response, err := mw(req, ctx, handler)
+			require.NoError(t, err)
+			require.NotNil(t, response)
+
+			if tt.expectError {
+				assert.False(t, handlerCalled)
+				require.NotNil(t, response.Error)
+				assert.Equal(t, types.ParseError, response.Error.Code)
+				assert.Equal(t, "test-1", response.ID)
+				return
+			}
+
+			assert.True(t, handlerCalled)
+			assert.Nil(t, response.Error)
+			assert.Equal(t, tt.expectedParams, string(receivedParams))
+
+			if len(receivedParams) > 0 {
+				assert.True(t, json.Valid(receivedParams))
+			}
+		})
+	}
+}
+
+func TestUTF8ValidationMiddleware_SanitizeDoesNotMutateRequest(t *testing.T) {
+	mw := UTF8ValidationMiddleware(UTF8PolicySanitize)
+	original := json.RawMessage("{\"message\":\"\xff\"}")
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", Params: original, ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "test-service", "127.0.0.1")
+
+	_, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "{\"message\":\"\xff\"}", string(req.Params))
+}
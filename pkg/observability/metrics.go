@@ -1,42 +1,116 @@
 package observability
 
 import (
-	// Удалим неиспользуемый импорт
-	// "context"
+	"errors"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"streaming-server/pkg/types"
-	"time"
 )
 
-var (
-	requestsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "jsonrpc_requests_total",
-			Help: "Total number of JSON-RPC requests",
-		},
-		[]string{"method", "transport", "status"},
-	)
-
-	requestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name: "jsonrpc_request_duration_seconds",
-			Help: "Duration of JSON-RPC requests",
-		},
-		[]string{"method", "transport"},
-	)
-
-	activeConnections = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "jsonrpc_active_connections",
-			Help: "Number of active connections",
-		},
-		[]string{"transport"},
-	)
-)
+// sizeBuckets границы гистограмм размеров: от 64 байт до 4 МБ
+var sizeBuckets = prometheus.ExponentialBuckets(64, 4, 9)
+
+// Metrics - набор метрик JSON-RPC, зарегистрированных в одном реестре
+type Metrics struct {
+	requestsTotal      *prometheus.CounterVec
+	requestDuration    *prometheus.HistogramVec
+	requestSize        *prometheus.HistogramVec
+	responseSize       *prometheus.HistogramVec
+	callsTotal         *prometheus.CounterVec
+	notificationErrors *prometheus.CounterVec
+	activeConnections  *prometheus.GaugeVec
+}
+
+// defaultMetrics регистрируются в реестре Prometheus по умолчанию
+var defaultMetrics = NewMetrics(prometheus.DefaultRegisterer)
+
+// NewMetrics создает метрики и регистрирует их в reg. Повторный вызов с тем же
+// реестром использует уже зарегистрированные метрики
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	return &Metrics{
+		requestsTotal: registerCollector(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "jsonrpc_requests_total",
+				Help: "Total number of JSON-RPC requests",
+			},
+			[]string{"method", "transport", "status"},
+		)).(*prometheus.CounterVec),
+
+		requestDuration: registerCollector(reg, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name: "jsonrpc_request_duration_seconds",
+				Help: "Duration of JSON-RPC requests",
+			},
+			[]string{"method", "transport"},
+		)).(*prometheus.HistogramVec),
+
+		requestSize: registerCollector(reg, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "jsonrpc_request_size_bytes",
+				Help:    "Size of JSON-RPC requests in bytes",
+				Buckets: sizeBuckets,
+			},
+			[]string{"method"},
+		)).(*prometheus.HistogramVec),
+
+		responseSize: registerCollector(reg, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "jsonrpc_response_size_bytes",
+				Help:    "Size of JSON-RPC responses in bytes",
+				Buckets: sizeBuckets,
+			},
+			[]string{"method"},
+		)).(*prometheus.HistogramVec),
 
-// MetricsMiddleware добавляет сбор метрик
+		callsTotal: registerCollector(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "jsonrpc_calls_total",
+				Help: "Total number of JSON-RPC calls by method and kind (request or notification)",
+			},
+			[]string{"method", "kind"},
+		)).(*prometheus.CounterVec),
+
+		notificationErrors: registerCollector(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "jsonrpc_notification_errors_total",
+				Help: "Total number of failed JSON-RPC notifications",
+			},
+			[]string{"method", "transport"},
+		)).(*prometheus.CounterVec),
+
+		activeConnections: registerCollector(reg, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "jsonrpc_active_connections",
+				Help: "Number of active connections",
+			},
+			[]string{"transport"},
+		)).(*prometheus.GaugeVec),
+	}
+}
+
+// registerCollector регистрирует метрику или возвращает ранее зарегистрированную
+func registerCollector(reg prometheus.Registerer, collector prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			return already.ExistingCollector
+		}
+		panic(err)
+	}
+	return collector
+}
+
+// MetricsMiddleware добавляет сбор метрик в реестр Prometheus по умолчанию
 func MetricsMiddleware() types.Middleware {
+	return defaultMetrics.Middleware()
+}
+
+// Middleware собирает метрики запросов: количество по исходу, длительность,
+// размер исходного запроса и количество вызовов по виду (запрос или уведомление).
+// Размер ответа известен только после сериализации и учитывается транспортом
+// через ObserveResponseSize
+func (m *Metrics) Middleware() types.Middleware {
 	return func(req *types.JSONRPCRequest, ctx *types.RequestContext, next types.Handler) (*types.JSONRPCResponse, error) {
 		start := time.Now()
 
@@ -51,26 +125,79 @@ func MetricsMiddleware() types.Middleware {
 			status = "rpc_error"
 		}
 
-		requestsTotal.WithLabelValues(req.Method, ctx.Transport, status).Inc()
-		requestDuration.WithLabelValues(req.Method, ctx.Transport).Observe(duration.Seconds())
+		transport := requestTransport(ctx)
+		m.requestsTotal.WithLabelValues(req.Method, transport, status).Inc()
+		m.requestDuration.WithLabelValues(req.Method, transport).Observe(duration.Seconds())
+		m.callsTotal.WithLabelValues(req.Method, callKind(req)).Inc()
+
+		// Запрос не сериализуется повторно: без исходных байтов размер не учитывается
+		if len(ctx.RawRequest) > 0 {
+			m.requestSize.WithLabelValues(req.Method).Observe(float64(len(ctx.RawRequest)))
+		}
 
 		return response, err
 	}
 }
 
+// callKind возвращает значение метки kind: "notification" или "request"
+func callKind(req *types.JSONRPCRequest) string {
+	if req.IsNotification() {
+		return "notification"
+	}
+	return "request"
+}
+
+// requestTransport возвращает транспорт, записанный процессором в данные контекста,
+// или поле Transport для контекстов, созданных вне процессора
+func requestTransport(ctx *types.RequestContext) string {
+	if value, ok := ctx.GetValue("transport"); ok {
+		if transport, ok := value.(string); ok && transport != "" {
+			return transport
+		}
+	}
+	return ctx.Transport
+}
+
+// ObserveResponseSize учитывает размер сериализованного ответа на вызов метода
+func (m *Metrics) ObserveResponseSize(method string, size int) {
+	m.responseSize.WithLabelValues(method).Observe(float64(size))
+}
+
+// NotificationErrorHook возвращает обработчик ошибок уведомлений, считающий их в метриках.
+// Совместим с server.NotificationErrorHook
+func NotificationErrorHook() func(*types.JSONRPCRequest, *types.RequestContext, error) {
+	return defaultMetrics.NotificationErrorHook()
+}
+
+// NotificationErrorHook возвращает обработчик ошибок уведомлений для этого набора метрик
+func (m *Metrics) NotificationErrorHook() func(*types.JSONRPCRequest, *types.RequestContext, error) {
+	return m.NotificationError
+}
+
+// NotificationError учитывает ошибку обработки уведомления
+func (m *Metrics) NotificationError(req *types.JSONRPCRequest, ctx *types.RequestContext, err error) {
+	m.notificationErrors.WithLabelValues(req.Method, requestTransport(ctx)).Inc()
+}
+
 // ConnectionTracker отслеживает активные соединения
 type ConnectionTracker struct {
 	transport string
+	gauge     *prometheus.GaugeVec
 }
 
 func NewConnectionTracker(transport string) *ConnectionTracker {
-	return &ConnectionTracker{transport: transport}
+	return defaultMetrics.ConnectionTracker(transport)
+}
+
+// ConnectionTracker создает счетчик активных соединений транспорта
+func (m *Metrics) ConnectionTracker(transport string) *ConnectionTracker {
+	return &ConnectionTracker{transport: transport, gauge: m.activeConnections}
 }
 
 func (ct *ConnectionTracker) OnConnect() {
-	activeConnections.WithLabelValues(ct.transport).Inc()
+	ct.gauge.WithLabelValues(ct.transport).Inc()
 }
 
 func (ct *ConnectionTracker) OnDisconnect() {
-	activeConnections.WithLabelValues(ct.transport).Dec()
+	ct.gauge.WithLabelValues(ct.transport).Dec()
 }
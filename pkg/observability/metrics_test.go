@@ -0,0 +1,179 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"streaming-server/pkg/types"
+)
+
+// scrapeMetrics returns the text exposition of the default registry
+func scrapeMetrics(t *testing.T) string {
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, w.Code)
+	return w.Body.String()
+}
+
+func TestMetricsMiddleware_SizeHistograms(t *testing.T) {
+	mw := MetricsMiddleware()
+
+	raw := []byte(`{"jsonrpc":"2.0","method":"size_test","params":{"message":"` + strings.Repeat("x", 100) + `"},"id":1}`)
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "size_test", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+	ctx.RawRequest = raw
+
+	largeResult := strings.Repeat("y", 2000)
+	_, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: largeResult, ID: req.ID}, nil
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, scrapeMetrics(t), `jsonrpc_response_size_bytes_count{method="size_test"}`,
+		"the middleware does not serialize responses")
+
+	// The transport reports the size of the bytes it wrote
+	response, err := json.Marshal(&types.JSONRPCResponse{JSONRPC: "2.0", Result: largeResult, ID: 1})
+	require.NoError(t, err)
+	defaultMetrics.ObserveResponseSize("size_test", len(response))
+
+	metrics := scrapeMetrics(t)
+
+	// The raw request is ~150 bytes: outside the 64 bucket, inside 256
+	assert.Contains(t, metrics, `jsonrpc_request_size_bytes_bucket{method="size_test",le="64"} 0`)
+	assert.Contains(t, metrics, `jsonrpc_request_size_bytes_bucket{method="size_test",le="256"} 1`)
+	assert.Contains(t, metrics, `jsonrpc_request_size_bytes_count{method="size_test"} 1`)
+	assert.Contains(t, metrics, `jsonrpc_request_size_bytes_sum{method="size_test"} `+strconv.Itoa(len(raw)))
+
+	// The ~2KB response lands in the 4096 bucket
+	assert.Contains(t, metrics, `jsonrpc_response_size_bytes_bucket{method="size_test",le="1024"} 0`)
+	assert.Contains(t, metrics, `jsonrpc_response_size_bytes_bucket{method="size_test",le="4096"} 1`)
+	assert.Contains(t, metrics, `jsonrpc_response_size_bytes_sum{method="size_test"} `+strconv.Itoa(len(response)))
+}
+
+func TestMetricsMiddleware_SizeWithoutRawRequest(t *testing.T) {
+	mw := MetricsMiddleware()
+
+	req := &types.JSONRPCRequest{JSONRPC: "2.0", Method: "size_test_serialized", ID: 1}
+	ctx := types.NewRequestContext(context.Background(), "TCP", "127.0.0.1")
+
+	// Without the raw bytes the request is not serialized again just to be measured
+	_, err := mw(req, ctx, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	metrics := scrapeMetrics(t)
+	assert.Contains(t, metrics, `jsonrpc_requests_total{method="size_test_serialized",status="success",transport="TCP"} 1`)
+	assert.NotContains(t, metrics, `jsonrpc_request_size_bytes_count{method="size_test_serialized"}`)
+	assert.NotContains(t, metrics, `jsonrpc_response_size_bytes_count{method="size_test_serialized"}`)
+}
+
+func TestMetrics_CountsByOutcome(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mw := NewMetrics(reg).Middleware()
+
+	handlers := map[string]types.Handler{
+		"ok": func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+		},
+		"rpc_error": func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Error: types.NewInvalidParamsError("bad"), ID: req.ID}, nil
+		},
+		"go_error": func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return nil, errors.New("failed")
+		},
+	}
+
+	call := func(method, transport string) {
+		ctx := types.NewRequestContext(context.Background(), "service", "127.0.0.1")
+		ctx.WithValue("transport", transport)
+		mw(&types.JSONRPCRequest{JSONRPC: "2.0", Method: method, ID: 1}, ctx, handlers[method])
+	}
+
+	call("ok", "HTTP")
+	call("ok", "HTTP")
+	call("ok", "TCP")
+	call("rpc_error", "HTTP")
+	call("go_error", "WebSocket")
+
+	expected := `
+# HELP jsonrpc_requests_total Total number of JSON-RPC requests
+# TYPE jsonrpc_requests_total counter
+jsonrpc_requests_total{method="go_error",status="error",transport="WebSocket"} 1
+jsonrpc_requests_total{method="ok",status="success",transport="HTTP"} 2
+jsonrpc_requests_total{method="ok",status="success",transport="TCP"} 1
+jsonrpc_requests_total{method="rpc_error",status="rpc_error",transport="HTTP"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "jsonrpc_requests_total"))
+
+	durations, err := testutil.GatherAndCount(reg, "jsonrpc_request_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 4, durations)
+}
+
+func TestMetrics_TransportFallsBackToContextField(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mw := NewMetrics(reg).Middleware()
+
+	ctx := types.NewRequestContext(context.Background(), "Unix", "local")
+	mw(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "echo", ID: 1}, ctx,
+		func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+		})
+
+	count, err := testutil.GatherAndCount(reg, "jsonrpc_requests_total")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	assert.Equal(t, 1.0, testutil.ToFloat64(NewMetrics(reg).requestsTotal.WithLabelValues("echo", "Unix", "success")))
+}
+
+func TestMetrics_SameRegistryTwice(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	assert.NotPanics(t, func() {
+		NewMetrics(reg)
+		NewMetrics(reg)
+	})
+}
+
+func TestMetrics_CountsNotificationsSeparately(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics := NewMetrics(reg)
+	mw := metrics.Middleware()
+
+	next := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, nil
+	}
+	call := func(id interface{}) {
+		ctx := types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1")
+		mw(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "event", ID: id}, ctx, next)
+	}
+
+	call(nil)
+	call(nil)
+	call(1)
+	metrics.NotificationError(&types.JSONRPCRequest{JSONRPC: "2.0", Method: "event"},
+		types.NewRequestContext(context.Background(), "HTTP", "127.0.0.1"), errors.New("failed"))
+
+	expected := `
+# HELP jsonrpc_calls_total Total number of JSON-RPC calls by method and kind (request or notification)
+# TYPE jsonrpc_calls_total counter
+jsonrpc_calls_total{kind="notification",method="event"} 2
+jsonrpc_calls_total{kind="request",method="event"} 1
+# HELP jsonrpc_notification_errors_total Total number of failed JSON-RPC notifications
+# TYPE jsonrpc_notification_errors_total counter
+jsonrpc_notification_errors_total{method="event",transport="HTTP"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "jsonrpc_calls_total", "jsonrpc_notification_errors_total"))
+}
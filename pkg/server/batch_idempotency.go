@@ -0,0 +1,133 @@
+package server
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"streaming-server/pkg/middleware"
+	"streaming-server/pkg/types"
+)
+
+// BatchIdempotencyKeyHeader - HTTP заголовок с ключом идемпотентности пакета.
+// Отличается от Idempotency-Key, который относится к отдельным вызовам
+const BatchIdempotencyKeyHeader = "Batch-Idempotency-Key"
+
+// batchIdempotencyEntry - результат пакета с ключом; done закрывается, когда
+// результат готов
+type batchIdempotencyEntry struct {
+	done   chan struct{}
+	digest [sha256.Size]byte
+	result interface{}
+	ok     bool
+}
+
+// batchIdempotencyCache хранит результаты последних пакетов с ключом
+// идемпотентности. Самые старые записи вытесняются при превышении размера
+type batchIdempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*batchIdempotencyEntry
+	order   []string
+	size    int
+}
+
+func newBatchIdempotencyCache(size int) *batchIdempotencyCache {
+	return &batchIdempotencyCache{
+		entries: make(map[string]*batchIdempotencyEntry),
+		size:    size,
+	}
+}
+
+// do выполняет process один раз для ключа. Повтор пакета с тем же ключом, в
+// том числе одновременный, получает сохраненный результат; тот же ключ с другим
+// пакетом отклоняется ошибкой -32600. done прерывает ожидание чужого результата
+func (c *batchIdempotencyCache) do(key string, data []byte, done <-chan struct{}, process func() interface{}) interface{} {
+	digest := sha256.Sum256(data)
+
+	for {
+		entry, owner := c.begin(key, digest)
+		if owner {
+			result := process()
+			c.finish(key, entry, result)
+			return result
+		}
+
+		if entry.digest != digest {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidRequestError("Batch idempotency key reused for a different batch"),
+				ID:      nil,
+			}
+		}
+
+		// Тот же пакет уже выполняется или выполнен: ждем его результат
+		select {
+		case <-entry.done:
+		case <-done:
+			return nil
+		}
+		if !entry.ok {
+			// Первое выполнение не дошло до элементов пакета и было забыто
+			continue
+		}
+		return entry.result
+	}
+}
+
+// begin возвращает запись для ключа и признак того, что пакет выполняет текущий запрос
+func (c *batchIdempotencyCache) begin(key string, digest [sha256.Size]byte) (*batchIdempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, exists := c.entries[key]; exists {
+		return entry, false
+	}
+
+	entry := &batchIdempotencyEntry{done: make(chan struct{}), digest: digest}
+	c.entries[key] = entry
+	c.order = append(c.order, key)
+	for len(c.order) > c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	return entry, true
+}
+
+// finish сохраняет результат. Ошибка пакета целиком (разбор, перегрузка) не
+// сохраняется, чтобы клиент мог повторить пакет; ответы элементов сохраняются
+// вместе с ошибками отдельных вызовов
+func (c *batchIdempotencyCache) finish(key string, entry *batchIdempotencyEntry, result interface{}) {
+	_, failed := result.(*types.JSONRPCResponse)
+	if failed {
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+			for i, k := range c.order {
+				if k == key {
+					c.order = append(c.order[:i], c.order[i+1:]...)
+					break
+				}
+			}
+		}
+		c.mu.Unlock()
+	}
+
+	entry.result, entry.ok = result, !failed
+	close(entry.done)
+}
+
+// batchIdempotencyKey возвращает ключ идемпотентности пакета. Ключ берется
+// только из заголовков HTTP запроса: у потоковых соединений заголовки запроса
+// на подключение общие для всех пакетов. Как и у отдельных вызовов, ключи
+// действуют в пределах клиента, чтобы клиенты с совпавшими ключами не получали
+// чужие ответы
+func (p *JSONRPCProcessor) batchIdempotencyKey(ctx ProcessingContext) string {
+	if p.batchIdempotency == nil || ctx.Connection != nil || ctx.HTTPRequest == nil {
+		return ""
+	}
+	key := ctx.HTTPRequest.Header.Get(BatchIdempotencyKeyHeader)
+	if key == "" {
+		return ""
+	}
+	scope := middleware.IdempotencyScope(&types.RequestContext{RemoteAddr: ctx.RemoteAddr})
+	return scope + "\x00" + key
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"streaming-server/pkg/types"
+)
+
+// setupBatchIdempotencyServer returns a server that counts calls of "count"
+func setupBatchIdempotencyServer(t *testing.T, delay time.Duration) (*httptest.Server, *int32) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", BatchIdempotencyCacheSize: 16}, logger)
+
+	var calls int32
+	server.RegisterHandler("count", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		n := atomic.AddInt32(&calls, 1)
+		time.Sleep(delay)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: n, ID: req.ID}, nil
+	})
+
+	httpServer := httptest.NewServer(server.newHTTPMux())
+	t.Cleanup(httpServer.Close)
+	return httpServer, &calls
+}
+
+// postBatch sends body to /rpc with an optional batch idempotency key
+func postBatch(t *testing.T, url, key, body string) string {
+	req, err := http.NewRequest("POST", url+"/rpc", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(BatchIdempotencyKeyHeader, key)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&raw))
+	return string(raw)
+}
+
+const idempotentBatch = `[{"jsonrpc":"2.0","method":"count","id":1},{"jsonrpc":"2.0","method":"count","id":2}]`
+
+func TestServer_BatchIdempotency_RetryProcessedOnce(t *testing.T) {
+	httpServer, calls := setupBatchIdempotencyServer(t, 0)
+
+	first := postBatch(t, httpServer.URL, "batch-1", idempotentBatch)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	retry := postBatch(t, httpServer.URL, "batch-1", idempotentBatch)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls), "retried batch must not run again")
+	assert.JSONEq(t, first, retry)
+
+	// A new key and a batch without a key both run
+	postBatch(t, httpServer.URL, "batch-2", idempotentBatch)
+	assert.Equal(t, int32(4), atomic.LoadInt32(calls))
+	postBatch(t, httpServer.URL, "", idempotentBatch)
+	postBatch(t, httpServer.URL, "", idempotentBatch)
+	assert.Equal(t, int32(8), atomic.LoadInt32(calls))
+}
+
+func TestServer_BatchIdempotency_ConcurrentRetries(t *testing.T) {
+	httpServer, calls := setupBatchIdempotencyServer(t, 50*time.Millisecond)
+
+	const clients = 5
+	responses := make([]string, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = postBatch(t, httpServer.URL, "concurrent", idempotentBatch)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls), "identical concurrent batches run once")
+	for _, response := range responses[1:] {
+		assert.JSONEq(t, responses[0], response)
+	}
+}
+
+func TestServer_BatchIdempotency_KeyReusedForDifferentBatch(t *testing.T) {
+	httpServer, calls := setupBatchIdempotencyServer(t, 0)
+
+	postBatch(t, httpServer.URL, "batch-1", idempotentBatch)
+	other := postBatch(t, httpServer.URL, "batch-1", `[{"jsonrpc":"2.0","method":"count","id":3}]`)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal([]byte(other), &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+}
+
+func TestServer_BatchIdempotency_BatchErrorsNotCached(t *testing.T) {
+	httpServer, calls := setupBatchIdempotencyServer(t, 0)
+
+	// An empty batch is rejected as a whole, so the key stays free
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal([]byte(postBatch(t, httpServer.URL, "batch-1", `[]`)), &response))
+	require.NotNil(t, response.Error)
+
+	var responses []types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal([]byte(postBatch(t, httpServer.URL, "batch-1", idempotentBatch)), &responses))
+	assert.Len(t, responses, 2)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestServer_BatchIdempotency_ScopedByClient(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", BatchIdempotencyCacheSize: 16}, logger)
+
+	var calls int32
+	server.RegisterHandler("count", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: atomic.AddInt32(&calls, 1), ID: req.ID}, nil
+	})
+
+	send := func(remoteAddr, body string) string {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(body))
+		req.Header.Set(BatchIdempotencyKeyHeader, "shared")
+		result := server.processor.ProcessBatchRequest([]byte(body), ProcessingContext{
+			Transport:   "HTTP",
+			RemoteAddr:  remoteAddr,
+			HTTPRequest: req,
+		})
+		data, err := json.Marshal(result)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	first := send("10.0.0.1:1234", idempotentBatch)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Another client with the same key and batch runs its own batch
+	second := send("10.0.0.2:1234", idempotentBatch)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	assert.NotEqual(t, first, second)
+
+	// Another client with a different batch is not told the key was reused
+	third := send("10.0.0.3:1234", `[{"jsonrpc":"2.0","method":"count","id":3}]`)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+	assert.NotContains(t, third, "reused")
+
+	// Retries from the first client still replay its own responses
+	assert.Equal(t, first, send("10.0.0.1:5678", idempotentBatch))
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+}
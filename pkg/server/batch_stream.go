@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"streaming-server/pkg/types"
+)
+
+// BatchSink получает ответы пакетного запроса по мере их готовности
+type BatchSink interface {
+	WriteResponse(response *types.JSONRPCResponse) error
+	Close() error
+}
+
+// streamBatchSink записывает ответы пакета в виде JSON-массива по одному элементу,
+// не удерживая в памяти уже отправленные ответы
+type streamBatchSink struct {
+	w      io.Writer
+	start  func()
+	flush  func()
+	suffix []byte
+	count  int
+
+	// observe получает каждый ответ и размер его сериализации; nil - не учитывать
+	observe func(response *types.JSONRPCResponse, size int)
+}
+
+// newStreamBatchSink создает sink для потоковых транспортов. Массив завершается
+// переводом строки, как и ответы json.Encoder
+func newStreamBatchSink(w io.Writer, flush func()) *streamBatchSink {
+	return &streamBatchSink{
+		w:      w,
+		flush:  flush,
+		suffix: []byte("\n"),
+	}
+}
+
+// newHTTPBatchSink создает sink для HTTP. Заголовки отправляются перед первым
+// элементом, после каждого элемента ответ сбрасывается клиенту
+func newHTTPBatchSink(w http.ResponseWriter) *streamBatchSink {
+	sink := &streamBatchSink{
+		w: w,
+		start: func() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+		},
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		sink.flush = flusher.Flush
+	}
+	return sink
+}
+
+// WriteResponse сериализует и отправляет очередной элемент массива
+func (s *streamBatchSink) WriteResponse(response *types.JSONRPCResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	if s.observe != nil {
+		s.observe(response, len(data))
+	}
+
+	prefix := byte(',')
+	if s.count == 0 {
+		if s.start != nil {
+			s.start()
+		}
+		prefix = '['
+	}
+
+	if _, err := s.w.Write(append([]byte{prefix}, data...)); err != nil {
+		return err
+	}
+	s.count++
+
+	if s.flush != nil {
+		s.flush()
+	}
+	return nil
+}
+
+// Close закрывает массив. Если не было отправлено ни одного ответа, ничего не пишет
+func (s *streamBatchSink) Close() error {
+	if s.count == 0 {
+		return nil
+	}
+
+	if _, err := s.w.Write(append([]byte{']'}, s.suffix...)); err != nil {
+		return err
+	}
+
+	if s.flush != nil {
+		s.flush()
+	}
+	return nil
+}
+
+// Started сообщает, был ли отправлен хотя бы один элемент
+func (s *streamBatchSink) Started() bool {
+	return s.count > 0
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter records the size of every write made by a sink
+type recordingWriter struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (r *recordingWriter) Write(p []byte) (int, error) {
+	r.writes = append(r.writes, len(p))
+	return r.Buffer.Write(p)
+}
+
+func buildEchoBatch(requests, notifications int) string {
+	elements := make([]string, 0, requests+notifications)
+	for i := 0; i < requests; i++ {
+		elements = append(elements, fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","params":{"n":%d},"id":%d}`, i, i))
+	}
+	for i := 0; i < notifications; i++ {
+		elements = append(elements, `{"jsonrpc":"2.0","method":"echo","params":{"n":-1}}`)
+	}
+	return "[" + strings.Join(elements, ",") + "]"
+}
+
+func TestJSONRPCProcessor_StreamBatchRequest_LargeBatch(t *testing.T) {
+	server, _ := setupTestServer(t)
+	ctx := ProcessingContext{Transport: "TCP", RemoteAddr: "127.0.0.1"}
+
+	const size = 500
+	writer := &recordingWriter{}
+	sink := newStreamBatchSink(writer, nil)
+
+	result, err := server.processor.StreamBatchRequest([]byte(buildEchoBatch(size, 20)), ctx, 10, sink)
+	require.NoError(t, err)
+	assert.Nil(t, result, "streamed batches are written to the sink only")
+
+	// One write per response plus the closing bracket
+	assert.Len(t, writer.writes, size+1)
+	for _, n := range writer.writes {
+		assert.Less(t, n, 1024, "each write must hold a single response")
+	}
+
+	output := writer.Bytes()
+	assert.Equal(t, byte('\n'), output[len(output)-1])
+
+	var responses []*types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(output, &responses))
+	require.Len(t, responses, size)
+	for i, response := range responses {
+		assert.Equal(t, float64(i), response.ID)
+		assert.Nil(t, response.Error)
+	}
+}
+
+func TestJSONRPCProcessor_StreamBatchRequest_StrictBatchDuplicateID(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.processor.SetStrictBatch(true)
+	ctx := ProcessingContext{Transport: "TCP", RemoteAddr: "127.0.0.1"}
+
+	batch := `[` +
+		`{"jsonrpc":"2.0","method":"echo","params":{"n":0},"id":1},` +
+		`{"jsonrpc":"2.0","method":"echo","params":{"n":1},"id":1},` +
+		`{"jsonrpc":"2.0","method":"echo","params":{"n":2},"id":2}]`
+
+	writer := &recordingWriter{}
+	result, err := server.processor.StreamBatchRequest([]byte(batch), ctx, 1, newStreamBatchSink(writer, nil))
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	var responses []*types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(writer.Bytes(), &responses))
+	require.Len(t, responses, 3)
+	assert.Nil(t, responses[0].Error)
+	require.NotNil(t, responses[1].Error)
+	assert.Equal(t, types.InvalidRequest, responses[1].Error.Code)
+	assert.Equal(t, float64(1), responses[1].ID)
+	assert.Nil(t, responses[2].Error)
+}
+
+func TestJSONRPCProcessor_StreamBatchRequest_BelowThreshold(t *testing.T) {
+	server, _ := setupTestServer(t)
+	ctx := ProcessingContext{Transport: "TCP", RemoteAddr: "127.0.0.1"}
+
+	writer := &recordingWriter{}
+	result, err := server.processor.StreamBatchRequest([]byte(buildEchoBatch(3, 0)), ctx, 10, newStreamBatchSink(writer, nil))
+	require.NoError(t, err)
+
+	responses, ok := result.([]*types.JSONRPCResponse)
+	require.True(t, ok)
+	assert.Len(t, responses, 3)
+	assert.Empty(t, writer.writes)
+}
+
+func TestJSONRPCProcessor_StreamBatchRequest_AllNotifications(t *testing.T) {
+	server, _ := setupTestServer(t)
+	ctx := ProcessingContext{Transport: "TCP", RemoteAddr: "127.0.0.1"}
+
+	writer := &recordingWriter{}
+	result, err := server.processor.StreamBatchRequest([]byte(buildEchoBatch(0, 20)), ctx, 10, newStreamBatchSink(writer, nil))
+	require.NoError(t, err)
+	assert.Nil(t, result)
+	assert.Empty(t, writer.writes)
+}
+
+func TestJSONRPCProcessor_StreamBatchRequest_InvalidBatch(t *testing.T) {
+	server, _ := setupTestServer(t)
+	ctx := ProcessingContext{Transport: "TCP", RemoteAddr: "127.0.0.1"}
+
+	result, err := server.processor.StreamBatchRequest([]byte(`[]`), ctx, 1, newStreamBatchSink(&recordingWriter{}, nil))
+	require.NoError(t, err)
+
+	response, ok := result.(*types.JSONRPCResponse)
+	require.True(t, ok)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+}
+
+func TestServer_handleHTTPRequest_StreamedBatch(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.config.BatchStreamThreshold = 5
+
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(buildEchoBatch(50, 5)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	server.handleHTTPRequest(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.True(t, w.Flushed)
+
+	var responses []*types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responses))
+	assert.Len(t, responses, 50)
+}
+
+func TestServer_handleHTTPRequest_StreamedBatchAllNotifications(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.config.BatchStreamThreshold = 5
+
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(buildEchoBatch(0, 10)))
+	w := httptest.NewRecorder()
+
+	server.handleHTTPRequest(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultGzipMinBytes - размер ответа, начиная с которого он сжимается
+const DefaultGzipMinBytes = 1024
+
+// DefaultMaxDecompressedBytes ограничивает распакованное тело gzip запроса,
+// когда MaxRequestBytes не задан
+const DefaultMaxDecompressedBytes = 10 << 20
+
+// errUnsupportedEncoding возвращается для тел запросов в неподдерживаемой кодировке
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// requestBodyReader возвращает reader тела запроса с учетом Content-Encoding.
+// limit ограничивает размер распакованных данных; при 0 для сжатых тел
+// действует DefaultMaxDecompressedBytes
+func requestBodyReader(r *http.Request, limit int64) (io.Reader, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return r.Body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err == io.EOF {
+			// Пустое тело обрабатывается как обычный пустой запрос
+			return bytes.NewReader(nil), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		if limit <= 0 {
+			limit = DefaultMaxDecompressedBytes
+		}
+		return &decompressedLimitReader{r: zr, limit: limit, remaining: limit}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedEncoding, encoding)
+	}
+}
+
+// decompressedLimitReader возвращает http.MaxBytesError, если распакованное тело
+// превышает лимит, защищая от "zip-бомб"
+type decompressedLimitReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func (l *decompressedLimitReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Проверяем, остались ли данные сверх лимита
+		var probe [1]byte
+		if n, _ := l.r.Read(probe[:]); n > 0 {
+			return 0, &http.MaxBytesError{Limit: l.limit}
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// acceptsGzip проверяет, что клиент принимает ответы в gzip (Accept-Encoding)
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "gzip" && coding != "*" {
+				continue
+			}
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// gzipMinBytes возвращает порог сжатия ответов; отрицательное значение отключает сжатие
+func (s *Server) gzipMinBytes() int {
+	if s.config.GzipMinBytes == 0 {
+		return DefaultGzipMinBytes
+	}
+	return s.config.GzipMinBytes
+}
+
+// writeJSONResponse отправляет сериализованный ответ, сжимая его в gzip,
+// если клиент это допускает и размер не меньше порога
+func (s *Server) writeJSONResponse(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+
+	minBytes := s.gzipMinBytes()
+	if minBytes >= 0 {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	if minBytes < 0 || len(body) < minBytes || !acceptsGzip(r) {
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(body)
+	if err := zw.Close(); err != nil {
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(status)
+	w.Write(compressed.Bytes())
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func gunzipBytes(t *testing.T, data []byte) []byte {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return decoded
+}
+
+func TestServer_handleHTTPRequest_GzipRequestBody(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	body := gzipBytes(t, []byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"compressed"},"id":1}`))
+	req := httptest.NewRequest("POST", "/rpc", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+
+	server.handleHTTPRequest(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "client did not ask for a compressed response")
+
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(t, response.Error)
+	assert.Equal(t, map[string]interface{}{"message": "compressed"}, response.Result.(map[string]interface{})["echo"])
+}
+
+func TestServer_handleHTTPRequest_GzipResponse(t *testing.T) {
+	server, _ := setupTestServer(t)
+	message := strings.Repeat("x", 2*DefaultGzipMinBytes)
+
+	send := func(body []byte, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+		return w
+	}
+
+	large := []byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"` + message + `"},"id":1}`)
+
+	w := send(large, "br, gzip;q=0.8")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+	assert.Less(t, w.Body.Len(), len(message), "response must actually be compressed")
+
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(gunzipBytes(t, w.Body.Bytes()), &response))
+	assert.Nil(t, response.Error)
+	assert.Equal(t, map[string]interface{}{"message": message}, response.Result.(map[string]interface{})["echo"])
+
+	// Batches are compressed as well
+	w = send([]byte("["+string(large)+","+string(large)+"]"), "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	var responses []types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(gunzipBytes(t, w.Body.Bytes()), &responses))
+	assert.Len(t, responses, 2)
+
+	// Small responses and refused gzip stay uncompressed
+	w = send([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":2}`), "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.True(t, json.Valid(w.Body.Bytes()))
+
+	w = send(large, "gzip;q=0")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+
+	// Notifications still produce an empty body
+	w = send([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"`+message+`"}}`), "gzip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Zero(t, w.Body.Len())
+
+	// Compression can be disabled
+	server.config.GzipMinBytes = -1
+	w = send(large, "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestServer_handleHTTPRequest_GzipEdgeCases(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	send := func(body []byte, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", encoding)
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+		return w
+	}
+
+	// Empty gzip body behaves like an empty body
+	w := send(nil, "gzip")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+
+	// Compressed empty payload as well
+	w = send(gzipBytes(t, nil), "gzip")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+
+	// Not actually gzip
+	w = send([]byte(`{"jsonrpc":"2.0","method":"echo","id":1}`), "gzip")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, types.ParseError, response.Error.Code)
+
+	w = send([]byte(`{}`), "br")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	// The size limit applies to the decompressed body
+	server.config.MaxRequestBytes = 256
+	padded := `{"jsonrpc":"2.0","method":"echo","params":{"message":"` + strings.Repeat("a", 4096) + `"},"id":1}`
+	w = send(gzipBytes(t, []byte(padded)), "gzip")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Without MaxRequestBytes a compressed body is still capped
+	server.config.MaxRequestBytes = 0
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	chunk := make([]byte, 64<<10)
+	for written := 0; written <= DefaultMaxDecompressedBytes; written += len(chunk) {
+		_, err := zw.Write(chunk)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	w = send(bomb.Bytes(), "gzip")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"streaming-server/pkg/types"
+)
+
+// ErrConnectionNotFound возвращается Notify, если соединение закрыто или не существует
+var ErrConnectionNotFound = errors.New("connection not found")
+
+// pushConnection - соединение, способное принимать уведомления сервера
+// (WebSocket или SSE поток)
+type pushConnection interface {
+	// writePush записывает готовое уведомление с ограничением времени
+	writePush(data []byte) error
+	// close закрывает соединение; цикл обслуживания соединения завершится сам
+	close() error
+}
+
+// wsConnection сериализует запись в WebSocket соединение: gorilla/websocket
+// не допускает одновременных вызовов WriteJSON
+type wsConnection struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// pushWriteTimeout ограничивает запись уведомления одному клиенту, чтобы
+// зависшее соединение не блокировало Notify и Broadcast
+const pushWriteTimeout = 5 * time.Second
+
+// WriteJSON записывает значение в соединение под мьютексом соединения
+func (c *wsConnection) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// WriteMessage записывает готовое сообщение под мьютексом соединения
+func (c *wsConnection) WriteMessage(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// writePush записывает готовое уведомление с ограничением времени
+func (c *wsConnection) writePush(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(pushWriteTimeout))
+	defer c.conn.SetWriteDeadline(time.Time{})
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// ping отправляет ping-кадр под мьютексом соединения
+func (c *wsConnection) ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pushWriteTimeout))
+}
+
+// close закрывает WebSocket соединение
+func (c *wsConnection) close() error {
+	return c.conn.Close()
+}
+
+// ConnectionRegistry хранит открытые WebSocket и SSE соединения по ID соединения
+// (ConnectionState.ID), чтобы сервер мог отправлять им уведомления
+type ConnectionRegistry struct {
+	conns map[string]pushConnection
+	mu    sync.RWMutex
+}
+
+// NewConnectionRegistry создает пустой реестр соединений
+func NewConnectionRegistry() *ConnectionRegistry {
+	return &ConnectionRegistry{
+		conns: make(map[string]pushConnection),
+	}
+}
+
+// Register добавляет соединение в реестр
+func (r *ConnectionRegistry) Register(id string, conn pushConnection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conns[id] = conn
+}
+
+// Unregister удаляет соединение из реестра
+func (r *ConnectionRegistry) Unregister(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, id)
+}
+
+// Get возвращает соединение по ID
+func (r *ConnectionRegistry) Get(id string) (pushConnection, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	conn, exists := r.conns[id]
+	return conn, exists
+}
+
+// snapshot возвращает копию реестра для обхода без удержания блокировки
+func (r *ConnectionRegistry) snapshot() map[string]pushConnection {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	conns := make(map[string]pushConnection, len(r.conns))
+	for id, conn := range r.conns {
+		conns[id] = conn
+	}
+	return conns
+}
+
+// IDs возвращает отсортированный список ID открытых соединений
+func (r *ConnectionRegistry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.conns))
+	for id := range r.conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Count возвращает количество открытых соединений
+func (r *ConnectionRegistry) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.conns)
+}
+
+// Connections возвращает реестр WebSocket и SSE соединений сервера
+func (s *Server) Connections() *ConnectionRegistry {
+	return s.connections
+}
+
+// Notify отправляет JSON-RPC уведомление (запрос без ID) WebSocket или SSE клиенту.
+// Обработчики получают ID своего соединения из ctx.Connection.ID.
+// Соединение, запись в которое не удалась, закрывается и удаляется из реестра
+func (s *Server) Notify(connID string, method string, params interface{}) error {
+	conn, exists := s.connections.Get(connID)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrConnectionNotFound, connID)
+	}
+
+	data, err := marshalNotification(method, params)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.writePush(data); err != nil {
+		s.dropConnection(connID, conn, err)
+		return err
+	}
+	return nil
+}
+
+// Broadcast отправляет JSON-RPC уведомление всем открытым WebSocket и SSE соединениям
+// параллельно и возвращает количество клиентов, получивших его. Закрытые и
+// зависшие соединения удаляются из реестра и не мешают доставке остальным
+func (s *Server) Broadcast(method string, params interface{}) (int, error) {
+	data, err := marshalNotification(method, params)
+	if err != nil {
+		return 0, err
+	}
+
+	var delivered int64
+	var wg sync.WaitGroup
+	for id, conn := range s.connections.snapshot() {
+		wg.Add(1)
+		go func(id string, conn pushConnection) {
+			defer wg.Done()
+			if err := conn.writePush(data); err != nil {
+				s.dropConnection(id, conn, err)
+				return
+			}
+			atomic.AddInt64(&delivered, 1)
+		}(id, conn)
+	}
+	wg.Wait()
+
+	return int(delivered), nil
+}
+
+// dropConnection закрывает соединение после ошибки записи. Цикл чтения соединения
+// завершится и освободит остальные ресурсы
+func (s *Server) dropConnection(id string, conn pushConnection, err error) {
+	log.Printf("Push to %s failed, dropping connection: %v", id, err)
+	s.connections.Unregister(id)
+	conn.close()
+}
+
+// marshalNotification сериализует JSON-RPC уведомление один раз для всех получателей
+func marshalNotification(method string, params interface{}) ([]byte, error) {
+	notification := &types.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+	}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal notification params: %w", err)
+		}
+		notification.Params = data
+	}
+
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadConnection returns a server-side WebSocket connection whose socket is already closed
+func deadConnection(t *testing.T) *wsConnection {
+	upgrader := websocket.Upgrader{}
+	conns := make(chan *websocket.Conn, 1)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		conns <- conn
+	}))
+	defer httpServer.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	client.Close()
+
+	conn := <-conns
+	conn.Close()
+	return &wsConnection{conn: conn}
+}
+
+func TestServer_Broadcast_PrunesDeadConnections(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+	defer httpServer.Close()
+
+	const live = 3
+	clients := make([]*websocket.Conn, live)
+	for i := range clients {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		clients[i] = conn
+	}
+	require.Eventually(t, func() bool { return server.Connections().Count() == live }, 5*time.Second, 10*time.Millisecond)
+
+	server.connections.Register("dead-1", deadConnection(t))
+	server.connections.Register("dead-2", deadConnection(t))
+	require.Equal(t, live+2, server.Connections().Count())
+
+	delivered, err := server.Broadcast("news.update", map[string]string{"headline": "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, live, delivered)
+
+	for _, client := range clients {
+		var notification types.JSONRPCRequest
+		require.NoError(t, client.ReadJSON(&notification))
+		assert.Equal(t, "news.update", notification.Method)
+		assert.JSONEq(t, `{"headline":"hello"}`, string(notification.Params))
+		assert.Nil(t, notification.ID)
+	}
+
+	ids := server.Connections().IDs()
+	assert.Len(t, ids, live)
+	assert.NotContains(t, ids, "dead-1")
+	assert.NotContains(t, ids, "dead-2")
+
+	// Later broadcasts only reach the remaining clients
+	delivered, err = server.Broadcast("news.update", nil)
+	require.NoError(t, err)
+	assert.Equal(t, live, delivered)
+}
+
+func TestServer_Notify_DropsDeadConnection(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.connections.Register("dead", deadConnection(t))
+
+	assert.Error(t, server.Notify("dead", "ping", nil))
+	assert.Zero(t, server.Connections().Count())
+	assert.ErrorIs(t, server.Notify("dead", "ping", nil), ErrConnectionNotFound)
+}
+
+func TestServer_Broadcast_NoConnections(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	delivered, err := server.Broadcast("news.update", nil)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+
+	_, err = server.Broadcast("news.update", func() {})
+	assert.Error(t, err, "unmarshalable params are rejected before any write")
+}
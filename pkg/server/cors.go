@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig задает политику CORS для HTTP/HTTPS эндпоинта /rpc
+type CORSConfig struct {
+	// AllowedOrigins - разрешенные значения заголовка Origin (без учета
+	// регистра). "*" разрешает любой источник
+	AllowedOrigins []string
+	// AllowedMethods - методы для Access-Control-Allow-Methods.
+	// По умолчанию POST и OPTIONS
+	AllowedMethods []string
+	// AllowedHeaders - заголовки для Access-Control-Allow-Headers.
+	// По умолчанию Content-Type
+	AllowedHeaders []string
+	// AllowCredentials разрешает запросы с cookie и авторизацией. Вместе с "*"
+	// в ответ подставляется конкретный Origin, так как браузер не принимает
+	// "*" для запросов с учетными данными
+	AllowCredentials bool
+	// MaxAge - время кэширования ответа на preflight запрос. 0 не передает заголовок
+	MaxAge time.Duration
+}
+
+// Значения CORS по умолчанию, совпадающие с поведением без CORSConfig
+var (
+	defaultCORSMethods = []string{"POST", "OPTIONS"}
+	defaultCORSHeaders = []string{"Content-Type"}
+)
+
+// applyCORS устанавливает заголовки CORS для ответа. Без CORSConfig разрешен
+// любой источник. Если Origin запроса не разрешен, заголовки не устанавливаются
+// и браузер отклонит ответ
+func (s *Server) applyCORS(w http.ResponseWriter, r *http.Request) {
+	cors := s.config.CORS
+	if cors == nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(defaultCORSMethods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(defaultCORSHeaders, ", "))
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	allowOrigin, ok := cors.allowOrigin(origin)
+	if allowOrigin != "*" {
+		// Ответ зависит от Origin запроса, кэши должны это учитывать
+		w.Header().Add("Vary", "Origin")
+	}
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	if cors.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	methods := cors.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := cors.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+
+	if r.Method == http.MethodOptions && cors.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge/time.Second)))
+	}
+}
+
+// allowOrigin возвращает значение Access-Control-Allow-Origin для Origin запроса
+// и признак того, что источник разрешен
+func (c *CORSConfig) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			if c.AllowCredentials {
+				// С учетными данными "*" недопустим; без Origin заголовок не нужен
+				return origin, origin != ""
+			}
+			return "*", true
+		}
+		if origin != "" && strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCORSServer(t *testing.T, cors *CORSConfig) *Server {
+	_, logger := setupTestServer(t)
+	return NewServer(Config{ServiceName: "test", CORS: cors}, logger)
+}
+
+func TestCORS_AllowedOrigin(t *testing.T) {
+	server := newCORSServer(t, &CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+	})
+
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"time","id":1}`))
+	req.Header.Set("Origin", "https://APP.example.com")
+	w := httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://APP.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "POST, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+}
+
+func TestCORS_DisallowedOrigin(t *testing.T) {
+	server := newCORSServer(t, &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"time","id":1}`))
+	req.Header.Set("Origin", "https://evil.example.com")
+	w := httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+
+	// The request is still served; the browser blocks the response
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+
+	// Requests without Origin get no CORS headers either
+	req = httptest.NewRequest("OPTIONS", "/rpc", nil)
+	w = httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORS_PreflightWithCredentials(t *testing.T) {
+	server := newCORSServer(t, &CORSConfig{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"POST"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/rpc", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"), "credentials require the exact origin")
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	// Max-Age is only sent on preflight responses
+	req = httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"time","id":1}`))
+	req.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestCORS_WildcardWithoutCredentials(t *testing.T) {
+	server := newCORSServer(t, &CORSConfig{AllowedOrigins: []string{"*"}})
+
+	req := httptest.NewRequest("OPTIONS", "/rpc", nil)
+	req.Header.Set("Origin", "https://any.example.com")
+	w := httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Values("Vary"))
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Framings negotiated through rpc.hello
+const (
+	// FramingJSON reads a stream of concatenated JSON values. It is the default
+	// for TCP, TLS and Unix connections. A syntax error is answered with an error
+	// and reading resumes from the next line that starts a message; an oversized
+	// message closes the connection
+	FramingJSON = "json"
+	// FramingNDJSON reads one message per line. A malformed or oversized line is
+	// answered with an error and the connection keeps going from the next line
+	FramingNDJSON = "ndjson"
+	// FramingWebSocket is the only framing of WebSocket connections: one message per frame
+	FramingWebSocket = "websocket"
+)
+
+// streamFramings lists the framings supported by TCP, TLS and Unix connections
+var streamFramings = []string{FramingJSON, FramingNDJSON}
+
+// streamReader reads JSON-RPC messages from a TCP, TLS or Unix connection using
+// the current framing. Switching the framing keeps bytes that were already
+// buffered, so a client may pipeline messages right after rpc.hello.
+type streamReader struct {
+	framing  string
+	src      io.Reader
+	maxBytes int64
+
+	decoder *json.Decoder
+	limiter *messageLimitReader
+	lines   *bufio.Reader
+}
+
+func newStreamReader(src io.Reader, maxBytes int64) *streamReader {
+	r := &streamReader{maxBytes: maxBytes}
+	r.reset(FramingJSON, src)
+	return r
+}
+
+// reset starts reading src with the given framing
+func (r *streamReader) reset(framing string, src io.Reader) {
+	r.framing = framing
+	r.src = src
+	r.decoder, r.limiter, r.lines = nil, nil, nil
+
+	if framing == FramingNDJSON {
+		r.lines = bufio.NewReader(src)
+		return
+	}
+
+	var reader io.Reader = src
+	if r.maxBytes > 0 {
+		r.limiter = &messageLimitReader{r: src, end: r.maxBytes}
+		reader = r.limiter
+	}
+	r.decoder = json.NewDecoder(reader)
+}
+
+// switchTo changes the framing for the following messages
+func (r *streamReader) switchTo(framing string) {
+	if framing == r.framing || (framing != FramingJSON && framing != FramingNDJSON) {
+		return
+	}
+
+	var rest io.Reader
+	if r.decoder != nil {
+		rest = io.MultiReader(r.decoder.Buffered(), r.src)
+	} else {
+		rest = r.lines
+	}
+	r.reset(framing, rest)
+}
+
+// recoverable reports whether the stream can continue after a malformed or
+// oversized message
+func (r *streamReader) recoverable() bool {
+	return r.framing == FramingNDJSON
+}
+
+// skipMalformed drops input after a syntax error so the JSON framing resumes
+// with the next message. Input is discarded from the offending byte up to the
+// next line that starts, after indentation, with '{' or '[': the remaining
+// lines of a malformed multi-line value are dropped instead of being decoded
+// as messages of their own. The decoder cannot continue after a syntax error
+// and is replaced. Line framing never needs this: malformed lines are returned whole
+func (r *streamReader) skipMalformed(syntaxErr *json.SyntaxError) error {
+	if r.decoder == nil {
+		return nil
+	}
+
+	// Buffered starts where decoding of the malformed value began, which may
+	// be whitespace left after the previous message. Offset counts bytes from
+	// the start of the decoder input up to and including the offending byte
+	buffered, _ := io.ReadAll(r.decoder.Buffered())
+	start := int(syntaxErr.Offset - 1 - r.decoder.InputOffset())
+	if start < 0 {
+		start = 0
+	}
+	if start > len(buffered) {
+		start = len(buffered)
+	}
+	src := io.MultiReader(bytes.NewReader(buffered[start:]), r.src)
+
+	var b [1]byte
+	lineStart := false
+	for {
+		if _, err := io.ReadFull(src, b[:]); err != nil {
+			return err
+		}
+		switch c := b[0]; {
+		case c == '\n':
+			lineStart = true
+		case lineStart && (c == ' ' || c == '\t' || c == '\r'):
+		case lineStart && (c == '{' || c == '['):
+			// The byte already read opens the next message
+			r.reset(r.framing, io.MultiReader(bytes.NewReader([]byte{c}), src))
+			return nil
+		default:
+			lineStart = false
+		}
+	}
+}
+
+// read returns the next message. Oversized messages fail with errMessageTooLarge
+func (r *streamReader) read() (json.RawMessage, error) {
+	if r.lines != nil {
+		return r.readLine()
+	}
+
+	var rawMessage json.RawMessage
+	if err := r.decoder.Decode(&rawMessage); err != nil {
+		return nil, err
+	}
+	if r.limiter != nil {
+		r.limiter.end = r.decoder.InputOffset() + r.maxBytes
+	}
+	return rawMessage, nil
+}
+
+// readLine returns the next non-empty line. The line is not validated: malformed
+// JSON reaches the processor and is answered with a parse error
+func (r *streamReader) readLine() (json.RawMessage, error) {
+	for {
+		line, err := r.readLimitedLine()
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			continue
+		}
+		return json.RawMessage(line), nil
+	}
+}
+
+// readLimitedLine reads up to the next newline. A line longer than maxBytes is
+// consumed to its end without being kept, so the stream stays in sync
+func (r *streamReader) readLimitedLine() ([]byte, error) {
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := r.lines.ReadSlice('\n')
+		if !tooLarge {
+			line = append(line, chunk...)
+			if r.maxBytes > 0 && int64(len(bytes.TrimRight(line, "\r\n"))) > r.maxBytes {
+				tooLarge, line = true, nil
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if tooLarge {
+			return nil, errMessageTooLarge
+		}
+		return line, err
+	}
+}
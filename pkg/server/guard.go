@@ -0,0 +1,65 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"streaming-server/pkg/types"
+)
+
+// ServerBusyCode возвращается, когда достигнут предел горутин MaxGoroutines
+const ServerBusyCode = -32002
+
+// goroutineGuard ограничивает общее количество горутин, запускаемых сервером
+// для соединений и параллельной обработки пакетов. Нулевой предел - без ограничения
+type goroutineGuard struct {
+	max   int64
+	count int64
+}
+
+// newGoroutineGuard создает ограничитель на max горутин
+func newGoroutineGuard(max int) *goroutineGuard {
+	return &goroutineGuard{max: int64(max)}
+}
+
+// tryAcquire резервирует место под горутину; false, если предел достигнут
+func (g *goroutineGuard) tryAcquire() bool {
+	if g == nil {
+		return true
+	}
+	for {
+		current := atomic.LoadInt64(&g.count)
+		if g.max > 0 && current >= g.max {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&g.count, current, current+1) {
+			return true
+		}
+	}
+}
+
+// release освобождает место, зарезервированное tryAcquire
+func (g *goroutineGuard) release() {
+	if g == nil {
+		return
+	}
+	atomic.AddInt64(&g.count, -1)
+}
+
+// Count возвращает количество работающих отслеживаемых горутин
+func (g *goroutineGuard) Count() int {
+	if g == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&g.count))
+}
+
+// newServerBusyError создает ошибку отказа из-за предела горутин
+func newServerBusyError() *types.RPCError {
+	return types.NewServerError(ServerBusyCode, "Server busy")
+}
+
+// GoroutineCount возвращает количество горутин соединений и пакетов,
+// учитываемых ограничением MaxGoroutines
+func (s *Server) GoroutineCount() int {
+	return s.guard.Count()
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutineGuard(t *testing.T) {
+	guard := newGoroutineGuard(2)
+
+	assert.True(t, guard.tryAcquire())
+	assert.True(t, guard.tryAcquire())
+	assert.False(t, guard.tryAcquire(), "ceiling reached")
+	assert.Equal(t, 2, guard.Count())
+
+	guard.release()
+	assert.True(t, guard.tryAcquire(), "released slot is reusable")
+
+	unlimited := newGoroutineGuard(0)
+	for i := 0; i < 100; i++ {
+		require.True(t, unlimited.tryAcquire())
+	}
+	assert.Equal(t, 100, unlimited.Count())
+}
+
+func TestServer_MaxGoroutines_Connections(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", MaxGoroutines: 1}, logger)
+
+	connect := func() (net.Conn, *bufio.Reader) {
+		serverConn, clientConn := net.Pipe()
+		t.Cleanup(func() { clientConn.Close() })
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+		go server.serveConnection(serverConn, "TCP")
+		return clientConn, bufio.NewReader(clientConn)
+	}
+	echo := func(conn net.Conn, reader *bufio.Reader) {
+		go conn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"m"},"id":1}` + "\n"))
+		line, err := reader.ReadBytes('\n')
+		require.NoError(t, err)
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(line, &response))
+		assert.Nil(t, response.Error)
+	}
+
+	first, firstReader := connect()
+	echo(first, firstReader)
+	assert.Equal(t, 1, server.GoroutineCount())
+
+	// At the ceiling the next connection gets a busy error and is closed
+	_, busyReader := connect()
+	line, err := busyReader.ReadBytes('\n')
+	require.NoError(t, err)
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(line, &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, ServerBusyCode, response.Error.Code)
+	_, err = busyReader.ReadBytes('\n')
+	assert.ErrorIs(t, err, io.EOF)
+
+	// The existing connection is unaffected
+	echo(first, firstReader)
+
+	// Recovery once the connection goroutine exits
+	first.Close()
+	require.Eventually(t, func() bool { return server.GoroutineCount() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	next, nextReader := connect()
+	echo(next, nextReader)
+}
+
+func TestServer_MaxGoroutines_SilentTLSClient(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", MaxGoroutines: 1}, logger)
+	require.True(t, server.guard.tryAcquire())
+	defer server.guard.release()
+
+	// A client that never sends its ClientHello would hold the busy-error
+	// write in the TLS handshake; the accept path must not wait for it
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	returned := make(chan struct{})
+	go func() {
+		server.serveConnection(tls.Server(serverConn, &tls.Config{}), "TLS")
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("serveConnection blocked on a silent client")
+	}
+
+	// The rejection gives up after rejectWriteTimeout and closes the connection
+	clientConn.SetReadDeadline(time.Now().Add(rejectWriteTimeout + 2*time.Second))
+	_, err := io.ReadAll(clientConn)
+	assert.NoError(t, err)
+}
+
+func TestServer_MaxGoroutines_Batch(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", MaxGoroutines: 2, BatchConcurrency: 4}, logger)
+	ctx := ProcessingContext{Transport: "HTTP"}
+
+	elements := make([]string, 6)
+	for i := range elements {
+		elements[i] = fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","params":{"message":"m"},"id":%d}`, i)
+	}
+	batch := []byte("[" + strings.Join(elements, ",") + "]")
+
+	// Simulate two busy connections occupying the whole budget
+	require.True(t, server.guard.tryAcquire())
+	require.True(t, server.guard.tryAcquire())
+
+	result := server.processor.ProcessBatchRequest(batch, ctx)
+	busy, ok := result.(*types.JSONRPCResponse)
+	require.True(t, ok, "batch is refused as a whole")
+	require.NotNil(t, busy.Error)
+	assert.Equal(t, ServerBusyCode, busy.Error.Code)
+
+	// With one slot free the batch runs on the workers that fit
+	server.guard.release()
+	result = server.processor.ProcessBatchRequest(batch, ctx)
+	responses, ok := result.([]*types.JSONRPCResponse)
+	require.True(t, ok)
+	require.Len(t, responses, 6)
+	for i, response := range responses {
+		assert.Nil(t, response.Error)
+		assert.Equal(t, float64(i), response.ID)
+	}
+	assert.Equal(t, 1, server.GoroutineCount(), "batch workers release their slots")
+
+	server.guard.release()
+	assert.Zero(t, server.GoroutineCount())
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+
+	"streaming-server/pkg/dispatcher"
+	"streaming-server/pkg/types"
+)
+
+// HelloMethod - встроенный метод согласования возможностей клиента и сервера
+// для потоковых транспортов (TCP, TLS, Unix, WebSocket)
+const HelloMethod = "rpc.hello"
+
+// EncodingJSON - кодирование сообщений, поддерживаемое сервером
+const EncodingJSON = "json"
+
+// Ключи ConnectionState, используемые рукопожатием
+const (
+	// connectionFramingsKey - поддерживаемые соединением способы разбиения потока
+	connectionFramingsKey = "framings"
+	// connectionHelloKey - согласованные настройки соединения (HelloSettings)
+	connectionHelloKey = "hello"
+)
+
+// HelloParams - возможности клиента, передаваемые в rpc.hello.
+// Списки перечисляются в порядке предпочтения клиента
+type HelloParams struct {
+	Encodings []string `json:"encodings,omitempty"`
+	Framing   []string `json:"framing,omitempty"`
+	MaxBatch  int      `json:"max_batch,omitempty"`
+}
+
+// ServerCapabilities - возможности сервера для текущего соединения
+type ServerCapabilities struct {
+	Service   string   `json:"service"`
+	Version   string   `json:"version"`
+	Encodings []string `json:"encodings"`
+	Framing   []string `json:"framing"`
+	// MaxBatch - ограничение размера пакета на сервере; 0 означает отсутствие ограничения
+	MaxBatch int `json:"max_batch"`
+}
+
+// HelloSettings - настройки, согласованные для соединения
+type HelloSettings struct {
+	Encoding string `json:"encoding"`
+	Framing  string `json:"framing"`
+	MaxBatch int    `json:"max_batch"`
+}
+
+// HelloResult - ответ rpc.hello
+type HelloResult struct {
+	Server     ServerCapabilities `json:"server"`
+	Negotiated HelloSettings      `json:"negotiated"`
+}
+
+// helloHandlerInfo описывает rpc.hello для rpc.discover
+var helloHandlerInfo = dispatcher.HandlerInfo{
+	Description: "Negotiates encoding, framing and batch size for a stream connection",
+	Params:      `{"encodings": ["json"], "framing": ["ndjson"], "max_batch": 100}`,
+}
+
+// helloHandler возвращает обработчик rpc.hello. Обработчик сохраняет согласованные
+// настройки в состоянии соединения; транспорт применяет их к следующим сообщениям.
+// Повторный вызов согласует настройки заново
+func helloHandler(service, version string, maxBatch int) types.Handler {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		framings := connectionFramings(ctx.Connection)
+		if len(framings) == 0 {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidRequestError("rpc.hello requires a stream transport"),
+				ID:      req.ID,
+			}, nil
+		}
+
+		var params HelloParams
+		if req.HasParams() && !req.HasNullParams() {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewInvalidParamsError("Invalid hello parameters: " + err.Error()),
+					ID:      req.ID,
+				}, nil
+			}
+		}
+
+		capabilities := ServerCapabilities{
+			Service:   service,
+			Version:   version,
+			Encodings: []string{EncodingJSON},
+			Framing:   framings,
+			MaxBatch:  maxBatch,
+		}
+
+		encoding, ok := negotiate(params.Encodings, capabilities.Encodings)
+		if !ok {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidParamsError("No common encoding"),
+				ID:      req.ID,
+			}, nil
+		}
+		framing, ok := negotiate(params.Framing, capabilities.Framing)
+		if !ok {
+			return &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidParamsError("No common framing"),
+				ID:      req.ID,
+			}, nil
+		}
+
+		settings := HelloSettings{
+			Encoding: encoding,
+			Framing:  framing,
+			MaxBatch: minLimit(maxBatch, params.MaxBatch),
+		}
+		ctx.Connection.Set(connectionHelloKey, settings)
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Result:  HelloResult{Server: capabilities, Negotiated: settings},
+			ID:      req.ID,
+		}, nil
+	}
+}
+
+// negotiate выбирает первый вариант клиента, поддерживаемый сервером. Если клиент
+// не передал вариантов, выбирается первый (текущий) вариант сервера
+func negotiate(client, server []string) (string, bool) {
+	if len(client) == 0 {
+		return server[0], true
+	}
+	for _, want := range client {
+		for _, have := range server {
+			if want == have {
+				return have, true
+			}
+		}
+	}
+	return "", false
+}
+
+// minLimit возвращает меньшее из ограничений; 0 означает отсутствие ограничения
+func minLimit(a, b int) int {
+	switch {
+	case a <= 0:
+		return b
+	case b <= 0:
+		return a
+	case a < b:
+		return a
+	default:
+		return b
+	}
+}
+
+// connectionFramings возвращает способы разбиения потока, которые поддерживает
+// соединение. Пустой результат означает транспорт без постоянного соединения
+func connectionFramings(conn *types.ConnectionState) []string {
+	if conn == nil {
+		return nil
+	}
+	value, ok := conn.Get(connectionFramingsKey)
+	if !ok {
+		return nil
+	}
+	framings, _ := value.([]string)
+	return framings
+}
+
+// connectionHello возвращает настройки, согласованные через rpc.hello
+func connectionHello(conn *types.ConnectionState) (HelloSettings, bool) {
+	if conn == nil {
+		return HelloSettings{}, false
+	}
+	value, ok := conn.Get(connectionHelloKey)
+	if !ok {
+		return HelloSettings{}, false
+	}
+	settings, ok := value.(HelloSettings)
+	return settings, ok
+}
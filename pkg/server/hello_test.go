@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"streaming-server/pkg/types"
+)
+
+// helloResponse is a rpc.hello response with a typed result
+type helloResponse struct {
+	Result HelloResult     `json:"result"`
+	Error  *types.RPCError `json:"error"`
+	ID     interface{}     `json:"id"`
+}
+
+// startHelloTCP opens a TCP connection to the server over an in-memory pipe
+func startHelloTCP(t *testing.T, server *Server) (net.Conn, *bufio.Reader) {
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() { clientConn.Close() })
+	go server.handleTCPConnection(serverConn, "TCP")
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	return clientConn, bufio.NewReader(clientConn)
+}
+
+// readLine reads one newline-delimited response
+func readLine(t *testing.T, reader *bufio.Reader, v interface{}) {
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(line, v), string(line))
+}
+
+func TestHello_TCP_NegotiatesCapabilities(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "hello-test", Version: "1.2.3", MaxBatchSize: 10}, logger)
+	conn, reader := startHelloTCP(t, server)
+
+	_, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"rpc.hello","params":{"encodings":["msgpack","json"],"framing":["ndjson","json"],"max_batch":2},"id":1}` + "\n"))
+	require.NoError(t, err)
+
+	var hello helloResponse
+	readLine(t, reader, &hello)
+	require.Nil(t, hello.Error)
+	assert.Equal(t, ServerCapabilities{
+		Service:   "hello-test",
+		Version:   "1.2.3",
+		Encodings: []string{EncodingJSON},
+		Framing:   []string{FramingJSON, FramingNDJSON},
+		MaxBatch:  10,
+	}, hello.Result.Server)
+	assert.Equal(t, HelloSettings{Encoding: EncodingJSON, Framing: FramingNDJSON, MaxBatch: 2}, hello.Result.Negotiated)
+
+	// The negotiated batch size is stricter than the server limit
+	_, err = conn.Write([]byte(`[{"jsonrpc":"2.0","method":"echo","params":{"message":"a"},"id":1},{"jsonrpc":"2.0","method":"echo","params":{"message":"b"},"id":2},{"jsonrpc":"2.0","method":"echo","params":{"message":"c"},"id":3}]` + "\n"))
+	require.NoError(t, err)
+
+	var tooLarge types.JSONRPCResponse
+	readLine(t, reader, &tooLarge)
+	require.NotNil(t, tooLarge.Error)
+	assert.Equal(t, types.InvalidRequest, tooLarge.Error.Code)
+	assert.Equal(t, "batch too large", tooLarge.Error.Data)
+}
+
+func TestHello_TCP_SwitchesFraming(t *testing.T) {
+	server, _ := setupTestServer(t)
+	conn, reader := startHelloTCP(t, server)
+
+	// The hello and the first ndjson message arrive in one write: bytes buffered
+	// by the JSON decoder must not be lost when the framing changes
+	go conn.Write([]byte(`{"jsonrpc":"2.0","method":"rpc.hello","params":{"framing":["ndjson"]},"id":1}` + "\n" +
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"first"},"id":2}` + "\n"))
+
+	var hello helloResponse
+	readLine(t, reader, &hello)
+	require.Nil(t, hello.Error)
+	assert.Equal(t, FramingNDJSON, hello.Result.Negotiated.Framing)
+
+	var first types.JSONRPCResponse
+	readLine(t, reader, &first)
+	assert.Equal(t, float64(2), first.ID)
+	assert.Nil(t, first.Error)
+
+	// With ndjson a malformed line is answered and the connection stays open
+	_, err := conn.Write([]byte("{not json\n\n"))
+	require.NoError(t, err)
+
+	var parseErr types.JSONRPCResponse
+	readLine(t, reader, &parseErr)
+	require.NotNil(t, parseErr.Error)
+	assert.Equal(t, types.ParseError, parseErr.Error.Code)
+
+	_, err = conn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"after"},"id":3}` + "\n"))
+	require.NoError(t, err)
+
+	var after types.JSONRPCResponse
+	readLine(t, reader, &after)
+	assert.Equal(t, float64(3), after.ID)
+	assert.Nil(t, after.Error)
+
+	// Switching back to the default framing
+	_, err = conn.Write([]byte(`{"jsonrpc":"2.0","method":"rpc.hello","params":{"framing":["json"]},"id":4}` + "\n"))
+	require.NoError(t, err)
+	readLine(t, reader, &hello)
+	assert.Equal(t, FramingJSON, hello.Result.Negotiated.Framing)
+
+	// The json framing answers a malformed message too and resumes on the next line
+	_, err = conn.Write([]byte("{not json\n"))
+	require.NoError(t, err)
+	parseErr = types.JSONRPCResponse{}
+	readLine(t, reader, &parseErr)
+	require.NotNil(t, parseErr.Error)
+	assert.Equal(t, types.ParseError, parseErr.Error.Code)
+
+	_, err = conn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"json"},"id":5}` + "\n"))
+	require.NoError(t, err)
+	after = types.JSONRPCResponse{}
+	readLine(t, reader, &after)
+	assert.Equal(t, float64(5), after.ID)
+}
+
+func TestHello_TCP_NDJSONOversizedLine(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", MaxRequestBytes: 128}, logger)
+	conn, reader := startHelloTCP(t, server)
+
+	_, err := conn.Write([]byte(`{"jsonrpc":"2.0","method":"rpc.hello","params":{"framing":["ndjson"]},"id":1}` + "\n"))
+	require.NoError(t, err)
+	var hello helloResponse
+	readLine(t, reader, &hello)
+	require.Nil(t, hello.Error)
+
+	go conn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"` + strings.Repeat("x", 8192) + `"},"id":2}` + "\n" +
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"ok"},"id":3}` + "\n"))
+
+	var tooLarge types.JSONRPCResponse
+	readLine(t, reader, &tooLarge)
+	require.NotNil(t, tooLarge.Error)
+	assert.Equal(t, types.ParseError, tooLarge.Error.Code)
+
+	var next types.JSONRPCResponse
+	readLine(t, reader, &next)
+	assert.Equal(t, float64(3), next.ID)
+	assert.Nil(t, next.Error)
+}
+
+func TestHello_Errors(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	// rpc.hello has no meaning without a connection
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"rpc.hello","id":1}`), ProcessingContext{Transport: "HTTP"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+
+	ctx := ProcessingContext{Transport: "TCP", Connection: types.NewConnectionState()}
+	ctx.Connection.Set(connectionFramingsKey, streamFramings)
+
+	response = server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"rpc.hello","params":{"encodings":["msgpack"]},"id":1}`), ctx)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidParams, response.Error.Code)
+
+	response = server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"rpc.hello","params":{"framing":["length-prefixed"]},"id":1}`), ctx)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidParams, response.Error.Code)
+
+	_, negotiated := connectionHello(ctx.Connection)
+	assert.False(t, negotiated, "failed handshakes leave the connection unchanged")
+}
+
+func TestHello_WebSocket(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"rpc.hello","params":{"max_batch":1},"id":1}`)))
+	var hello helloResponse
+	require.NoError(t, conn.ReadJSON(&hello))
+	require.Nil(t, hello.Error)
+	assert.Equal(t, []string{FramingWebSocket}, hello.Result.Server.Framing)
+	assert.Equal(t, HelloSettings{Encoding: EncodingJSON, Framing: FramingWebSocket, MaxBatch: 1}, hello.Result.Negotiated)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`[{"jsonrpc":"2.0","method":"time","id":1},{"jsonrpc":"2.0","method":"time","id":2}]`)))
+	var tooLarge types.JSONRPCResponse
+	require.NoError(t, conn.ReadJSON(&tooLarge))
+	require.NotNil(t, tooLarge.Error)
+	assert.Equal(t, "batch too large", tooLarge.Error.Data)
+}
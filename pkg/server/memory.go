@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+)
+
+// MemoryTransport - имя транспорта соединений MemoryListener
+const MemoryTransport = "Memory"
+
+// memoryAddr - адрес соединений в памяти
+type memoryAddr struct{}
+
+func (memoryAddr) Network() string { return "memory" }
+func (memoryAddr) String() string  { return "memory" }
+
+// MemoryListener - net.Listener без сети: каждый Dial создает пару соединений
+// net.Pipe и передает серверную сторону в Accept. Позволяет тестам проходить
+// полный цикл запроса через обработку потокового соединения без портов и
+// сокетов. net.Pipe не буферизует запись: ответ сервера ждет чтения клиентом
+type MemoryListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewMemoryListener создает открытый MemoryListener
+func NewMemoryListener() *MemoryListener {
+	return &MemoryListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// Accept ожидает следующего Dial. После Close возвращает net.ErrClosed
+func (l *MemoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close закрывает слушатель; уже принятые соединения продолжают работать
+func (l *MemoryListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr возвращает адрес слушателя
+func (l *MemoryListener) Addr() net.Addr {
+	return memoryAddr{}
+}
+
+// Dial открывает соединение с сервером, обслуживающим слушатель
+func (l *MemoryListener) Dial() (net.Conn, error) {
+	return l.DialContext(context.Background())
+}
+
+// DialContext открывает соединение, ожидая Accept не дольше ctx
+func (l *MemoryListener) DialContext(ctx context.Context) (net.Conn, error) {
+	serverConn, clientConn := net.Pipe()
+	select {
+	case l.conns <- serverConn:
+		return clientConn, nil
+	case <-l.closed:
+	case <-ctx.Done():
+		serverConn.Close()
+		clientConn.Close()
+		return nil, ctx.Err()
+	}
+	serverConn.Close()
+	clientConn.Close()
+	return nil, net.ErrClosed
+}
+
+// Serve принимает соединения слушателя и обслуживает их как потоковые
+// соединения TCP: с ограничениями MaxConnections и MaxGoroutines и выбором
+// framing через rpc.hello. Возвращает nil после закрытия слушателя или
+// ошибку конфигурации сервера
+func (s *Server) Serve(listener net.Listener, transport string) error {
+	if s.configErr != nil {
+		return s.configErr
+	}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("%s accept error: %v", transport, err)
+			continue
+		}
+
+		s.serveConnection(conn, transport)
+	}
+}
+
+// ServeConn обслуживает одно потоковое соединение в текущей горутине до его
+// закрытия клиентом и закрывает conn. Подходит для соединений, созданных вне
+// сервера, например net.Pipe в тестах
+func (s *Server) ServeConn(conn net.Conn, transport string) {
+	s.handleTCPConnection(conn, transport)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"streaming-server/pkg/types"
+)
+
+// memoryRoundTrip writes one newline-framed message and decodes the reply line into v
+func memoryRoundTrip(t *testing.T, conn net.Conn, reader *bufio.Reader, message string, v interface{}) {
+	t.Helper()
+	go conn.Write([]byte(message + "\n"))
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(line, v))
+}
+
+func TestServer_ServeConn(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		server.ServeConn(serverConn, MemoryTransport)
+		close(done)
+	}()
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(clientConn)
+
+	var response types.JSONRPCResponse
+	memoryRoundTrip(t, clientConn, reader, `{"jsonrpc":"2.0","method":"echo","params":{"message":"in memory"},"id":1}`, &response)
+	require.Nil(t, response.Error)
+	assert.Equal(t, float64(1), response.ID)
+	result, ok := response.Result.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"message": "in memory"}, result["echo"])
+	assert.Equal(t, MemoryTransport, result["transport"])
+
+	var batch []types.JSONRPCResponse
+	memoryRoundTrip(t, clientConn, reader, `[{"jsonrpc":"2.0","method":"echo","id":2},{"jsonrpc":"2.0","method":"missing","id":3}]`, &batch)
+	require.Len(t, batch, 2)
+	assert.Nil(t, batch[0].Error)
+	require.NotNil(t, batch[1].Error)
+	assert.Equal(t, types.MethodNotFound, batch[1].Error.Code)
+
+	clientConn.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeConn did not return after the client closed")
+	}
+}
+
+func TestServer_Serve_MemoryListener(t *testing.T) {
+	server, _ := setupTestServer(t)
+	listener := NewMemoryListener()
+
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener, MemoryTransport) }()
+
+	const clients = 5
+	var wg sync.WaitGroup
+	for i := 1; i <= clients; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			conn, err := listener.Dial()
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			var response types.JSONRPCResponse
+			memoryRoundTrip(t, conn, bufio.NewReader(conn), fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","id":%d}`, id), &response)
+			assert.Nil(t, response.Error)
+			assert.Equal(t, float64(id), response.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	require.NoError(t, listener.Close())
+	select {
+	case err := <-served:
+		assert.NoError(t, err, "Serve returns cleanly once the listener is closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after the listener was closed")
+	}
+
+	_, err := listener.Dial()
+	assert.ErrorIs(t, err, net.ErrClosed)
+	assert.Equal(t, "memory", listener.Addr().Network())
+}
+
+func TestMemoryListener_DialContextWithoutServer(t *testing.T) {
+	listener := NewMemoryListener()
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := listener.DialContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "nothing accepts the connection")
+}
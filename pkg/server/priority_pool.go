@@ -0,0 +1,117 @@
+package server
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// priorityTask задача пула с приоритетом; seq сохраняет порядок FIFO
+// среди задач с одинаковым приоритетом
+type priorityTask struct {
+	priority int
+	seq      uint64
+	run      func()
+}
+
+// taskHeap очередь задач: больший приоритет извлекается первым
+type taskHeap []*priorityTask
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *taskHeap) Push(x interface{}) { *h = append(*h, x.(*priorityTask)) }
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return task
+}
+
+// priorityPool ограничивает число одновременно выполняемых обработчиков.
+// Когда все исполнители заняты, ожидающие задачи выполняются в порядке приоритета
+type priorityPool struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  taskHeap
+	seq    uint64
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// newPriorityPool запускает пул с указанным числом исполнителей
+func newPriorityPool(workers int) *priorityPool {
+	p := &priorityPool{}
+	p.cond = sync.NewCond(&p.mu)
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *priorityPool) worker() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		for len(p.queue) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if len(p.queue) == 0 {
+			p.mu.Unlock()
+			return
+		}
+		task := heap.Pop(&p.queue).(*priorityTask)
+		p.mu.Unlock()
+
+		task.run()
+	}
+}
+
+// Submit ставит задачу в очередь. После Close задача выполняется сразу
+// в вызывающей горутине
+func (p *priorityPool) Submit(priority int, run func()) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		run()
+		return
+	}
+	p.seq++
+	heap.Push(&p.queue, &priorityTask{priority: priority, seq: p.seq, run: run})
+	p.mu.Unlock()
+
+	p.cond.Signal()
+}
+
+// Run выполняет задачу через пул и ждет ее завершения
+func (p *priorityPool) Run(priority int, run func()) {
+	done := make(chan struct{})
+	p.Submit(priority, func() {
+		defer close(done)
+		run()
+	})
+	<-done
+}
+
+// Close останавливает исполнителей после выполнения уже поставленных задач
+func (p *priorityPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.cond.Broadcast()
+	p.wg.Wait()
+}
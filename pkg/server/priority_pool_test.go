@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityPool_HighPriorityFirst(t *testing.T) {
+	pool := newPriorityPool(1)
+	defer pool.Close()
+
+	// Occupy the only worker so everything else queues up
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool.Submit(0, func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func() {
+		return func() {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}
+	}
+
+	var wg sync.WaitGroup
+	submit := func(priority int, name string) {
+		wg.Add(1)
+		pool.Submit(priority, func() {
+			defer wg.Done()
+			record(name)()
+		})
+	}
+
+	submit(0, "bulk-1")
+	submit(0, "bulk-2")
+	submit(10, "health")
+	submit(5, "control")
+	submit(0, "bulk-3")
+	submit(10, "health-2")
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, []string{"health", "health-2", "control", "bulk-1", "bulk-2", "bulk-3"}, order)
+}
+
+func TestPriorityPool_SubmitAfterClose(t *testing.T) {
+	pool := newPriorityPool(2)
+	pool.Close()
+
+	ran := false
+	pool.Run(0, func() { ran = true })
+	assert.True(t, ran, "tasks run inline once the pool is closed")
+}
+
+func TestProcessor_WorkerPoolPriorities(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.processor.SetWorkerPool(1, map[string]int{"health": 10})
+	defer server.processor.SetWorkerPool(0, nil)
+
+	release := make(chan struct{})
+	blocking := make(chan struct{})
+
+	var mu sync.Mutex
+	var order []string
+	handler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		mu.Lock()
+		order = append(order, req.Method)
+		mu.Unlock()
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+	server.RegisterHandler("health", handler)
+	server.RegisterHandler("bulk", handler)
+	server.RegisterHandler("block", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		close(blocking)
+		<-release
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	})
+
+	ctx := ProcessingContext{Transport: "TCP"}
+	var wg sync.WaitGroup
+	send := func(method string, id int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response := server.processor.ProcessSingleRequest([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"id":%d}`, method, id)), ctx)
+			assert.Nil(t, response.Error)
+		}()
+	}
+
+	send("block", 0)
+	<-blocking
+
+	// Queue bulk requests first, then a high-priority one
+	for i := 1; i <= 3; i++ {
+		send("bulk", i)
+	}
+	require.Eventually(t, func() bool { return queueLen(server.processor.pool) == 3 }, time.Second, time.Millisecond)
+	send("health", 4)
+	require.Eventually(t, func() bool { return queueLen(server.processor.pool) == 4 }, time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	require.Len(t, order, 4)
+	assert.Equal(t, "health", order[0], "high-priority requests are served ahead of queued bulk traffic")
+}
+
+func TestServer_Stop_ClosesWorkerPool(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.processor.SetWorkerPool(2, nil)
+	pool := server.processor.pool
+
+	require.NoError(t, server.Stop())
+
+	pool.mu.Lock()
+	closed := pool.closed
+	pool.mu.Unlock()
+	assert.True(t, closed)
+	// Workers drained the queue before exiting
+	assert.Equal(t, 0, queueLen(pool))
+
+	// Requests after shutdown still complete, inline
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":1}`), ProcessingContext{Transport: "TCP"})
+	assert.Nil(t, response.Error)
+}
+
+func queueLen(pool *priorityPool) int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return len(pool.queue)
+}
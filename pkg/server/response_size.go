@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"streaming-server/pkg/types"
+)
+
+// encodeResult сериализует ответ или массив ответов пакета и учитывает размер
+// каждого ответа в метриках. Массив собирается из отдельно сериализованных
+// элементов и совпадает с результатом json.Marshal для среза
+func (p *JSONRPCProcessor) encodeResult(result interface{}) ([]byte, error) {
+	switch v := result.(type) {
+	case *types.JSONRPCResponse:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		p.observeResponseSize(v, len(data))
+		return data, nil
+	case []*types.JSONRPCResponse:
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, response := range v {
+			data, err := json.Marshal(response)
+			if err != nil {
+				return nil, err
+			}
+			p.observeResponseSize(response, len(data))
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(data)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	default:
+		return json.Marshal(result)
+	}
+}
+
+// observeResponseSize учитывает размер ответа на вызов метода. Ответы, не
+// прошедшие через диспетчер (ошибки разбора и проверки запроса), метода не имеют
+func (p *JSONRPCProcessor) observeResponseSize(response *types.JSONRPCResponse, size int) {
+	if p.metrics == nil || response == nil || response.Method == "" {
+		return
+	}
+	p.metrics.ObserveResponseSize(response.Method, size)
+}
@@ -1,21 +1,32 @@
 package server
 
 import (
+	"bufio"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"streaming-server/pkg/dispatcher"
 	"streaming-server/pkg/handlers"
 	"streaming-server/pkg/middleware"
+	"streaming-server/pkg/observability"
 	"streaming-server/pkg/types"
 )
 
@@ -27,9 +38,35 @@ type Server struct {
 	logger     *middleware.Logger
 	httpServer *http.Server
 	upgrader   websocket.Upgrader
+	recent     *middleware.RecentRequests
+	slowest    *middleware.SlowestRequests
+
+	connections *ConnectionRegistry
+	metrics     *prometheus.Registry
+
+	// connSlots - счетный семафор потоковых соединений (nil без MaxConnections)
+	connSlots chan struct{}
+
+	guard *goroutineGuard
+
+	// configErr - ошибка конфигурации, обнаруженная NewServer; Start и Serve ее возвращают
+	configErr error
+
+	// startTime - время создания сервера для расчета uptime
+	startTime time.Time
+
+	shutdownHooks []ShutdownHook
+	shutdownMu    sync.Mutex
+
+	unixListener net.Listener
+	listenerMu   sync.Mutex
 	// Другие поля...
 }
 
+// ShutdownHook вызывается в начале Stop до закрытия слушателей.
+// Контекст ограничен временем ShutdownTimeout
+type ShutdownHook func(ctx context.Context) error
+
 // Config содержит конфигурацию сервера
 type Config struct {
 	HTTPAddr     string
@@ -44,8 +81,180 @@ type Config struct {
 	TLSConfig    *tls.Config
 	ServiceName  string
 	Version      string
+
+	// BatchStreamThreshold - количество элементов пакета, начиная с которого
+	// ответы HTTP и TCP отправляются по одному, без накопления в памяти.
+	// 0 отключает потоковую отправку
+	BatchStreamThreshold int
+
+	// DebugHeaders включает отладочные данные в ответах: заголовки X-Debug-*
+	// для одиночных HTTP запросов и объект _debug в остальных случаях.
+	// Нарушает строгое соответствие JSON-RPC 2.0, поэтому выключено по умолчанию
+	DebugHeaders bool
+
+	// RequireJSONContentType отклоняет HTTP запросы с Content-Type,
+	// отличным от JSON, статусом 415
+	RequireJSONContentType bool
+
+	// DisableBatchOnTransports перечисляет транспорты (например, "TCP"),
+	// на которых пакетные запросы отклоняются ошибкой -32600
+	DisableBatchOnTransports []string
+
+	// StrictBatch отклоняет ошибкой -32600 элементы пакета, ID которых
+	// (кроме null) уже встречался в том же пакете. Первый элемент с этим ID
+	// и остальные элементы обрабатываются как обычно
+	StrictBatch bool
+
+	// BatchIdempotencyCacheSize - количество пакетов с заголовком
+	// Batch-Idempotency-Key, результаты которых запоминаются. Повтор пакета
+	// с тем же ключом, в том числе одновременный, получает сохраненный ответ
+	// без повторного выполнения. Действует для HTTP. 0 отключает проверку
+	BatchIdempotencyCacheSize int
+
+	// ErrorCatalog содержит переводы сообщений об ошибках. Язык выбирается по
+	// заголовку Accept-Language (для WebSocket - запроса на подключение), а при
+	// его отсутствии - DefaultLocale. Пустой DefaultLocale означает английский
+	ErrorCatalog  types.ErrorCatalog
+	DefaultLocale string
+
+	// CheckBatchResponses после обработки пакета сверяет количество ответов
+	// с количеством элементов, требующих ответа, и записывает в лог
+	// расхождение. Проверка для тестов и строгого режима; ответ не меняется
+	CheckBatchResponses bool
+
+	// MaxBatchSize - максимальное количество элементов пакетного запроса.
+	// Больший пакет отклоняется целиком ошибкой -32600. 0 отключает ограничение
+	MaxBatchSize int
+
+	// BatchConcurrency - количество элементов пакета, обрабатываемых
+	// одновременно. Порядок ответов сохраняется. 0 или 1 - последовательная
+	// обработка. Потоковая отправка (BatchStreamThreshold) остается последовательной,
+	// как и пакеты потоковых соединений при включенном MonotonicIDs
+	BatchConcurrency int
+
+	// ConnectionBatchConcurrency ограничивает количество элементов пакетов,
+	// одновременно выполняемых для одного соединения (для HTTP - для одного
+	// запроса), чтобы один клиент не занимал все обработчики. Действует
+	// вместе с BatchConcurrency. 0 отключает ограничение
+	ConnectionBatchConcurrency int
+
+	// SendConnectBanner включает отправку уведомления server.banner с версией
+	// сервера и списком методов сразу после подключения по TCP/TLS/WebSocket
+	SendConnectBanner bool
+
+	// RecentRequestsSize - количество последних запросов, доступных через
+	// /debug/recent. 0 отключает сбор
+	RecentRequestsSize int
+
+	// SlowestRequestsSize - количество самых медленных запросов, доступных
+	// через /debug/slowest. 0 отключает сбор
+	SlowestRequestsSize int
+
+	// RateLimit включает ограничение частоты запросов клиентов. Счетчики
+	// хранятся в памяти или, для нескольких экземпляров за балансировщиком,
+	// в Redis (RateLimit.Backend). nil отключает ограничение
+	RateLimit *middleware.RateLimitConfig
+
+	// MonotonicIDs включает проверку возрастания ID запросов в пределах
+	// одного соединения для потоковых транспортов. Элементы пакетов таких
+	// соединений выполняются по порядку независимо от BatchConcurrency
+	MonotonicIDs bool
+
+	// MaxRequestBytes - максимальный размер тела HTTP запроса и одного
+	// сообщения TCP/TLS/Unix в байтах. Незавершенное сообщение, превысившее
+	// лимит, получает ошибку разбора, и соединение закрывается, поэтому
+	// клиент не может занять память бесконечным сообщением. 0 отключает ограничение;
+	// распакованное gzip тело при этом все равно ограничено DefaultMaxDecompressedBytes
+	MaxRequestBytes int64
+
+	// TCPReadBufferSize - размер буфера чтения TCP/TLS/Unix соединения перед
+	// разбором JSON. Больший буфер сокращает число системных вызовов при
+	// больших сообщениях. 0 означает DefaultTCPReadBufferSize
+	TCPReadBufferSize int
+
+	// HandlerTimeout ограничивает время выполнения обработчика на всех
+	// транспортах. 0 означает DefaultHandlerTimeout, отрицательное значение
+	// отключает ограничение
+	HandlerTimeout time.Duration
+
+	// MethodTimeouts переопределяет HandlerTimeout для отдельных методов; нулевое
+	// значение снимает ограничение для метода
+	MethodTimeouts map[string]time.Duration
+
+	// ValidateParamsSchema включает проверку параметров по JSON Schema,
+	// заданной в HandlerInfo.ParamsSchema
+	ValidateParamsSchema bool
+
+	// CheckResultSchema включает проверку результатов по JSON Schema, заданной
+	// в HandlerInfo.ResultSchema. Несоответствия записываются в журнал; при
+	// EnforceResultSchema такой ответ заменяется внутренней ошибкой
+	CheckResultSchema   bool
+	EnforceResultSchema bool
+
+	// UnixSocketAddr - путь к Unix сокету для локальных клиентов.
+	// Пустая строка отключает транспорт
+	UnixSocketAddr string
+
+	// WorkerPoolSize ограничивает число одновременно выполняемых обработчиков.
+	// 0 отключает пул: каждый запрос выполняется в горутине соединения
+	WorkerPoolSize int
+
+	// MethodPriorities задает приоритеты методов в пуле обработчиков
+	// (например, health и управляющие методы выше массовых). По умолчанию 0
+	MethodPriorities map[string]int
+
+	// ResponseTiming включает запись в журнал времени до первого байта и
+	// полного времени отправки HTTP/HTTPS ответов
+	ResponseTiming bool
+
+	// GzipMinBytes - размер HTTP ответа, начиная с которого он сжимается
+	// в gzip для клиентов с Accept-Encoding: gzip. 0 означает
+	// DefaultGzipMinBytes, отрицательное значение отключает сжатие.
+	// Запросы с Content-Encoding: gzip принимаются всегда
+	GzipMinBytes int
+
+	// PrometheusMetrics включает сбор RED-метрик по методам и транспортам
+	// и эндпоинт /metrics на HTTP/HTTPS серверах
+	PrometheusMetrics bool
+
+	// ConnIdleTimeout закрывает соединение TCP, TLS или Unix сокета,
+	// если клиент не прислал ни одного сообщения за это время. 0 отключает
+	ConnIdleTimeout time.Duration
+
+	// WSPingInterval - период ping-кадров WebSocket соединений. Клиент,
+	// не ответивший pong и не приславший сообщений за два интервала,
+	// считается отключившимся, и соединение закрывается. 0 отключает
+	WSPingInterval time.Duration
+
+	// MaxGoroutines ограничивает общее количество горутин, запускаемых
+	// сервером для соединений и параллельной обработки пакетов. При
+	// достижении предела новые соединения и пакеты отклоняются ошибкой
+	// "Server busy". 0 отключает ограничение
+	MaxGoroutines int
+
+	// MaxConnections ограничивает количество одновременных соединений
+	// TCP, TLS и Unix сокета. Сверх лимита соединение получает ошибку
+	// и закрывается. 0 отключает ограничение
+	MaxConnections int
+
+	// CORS задает разрешенные источники, методы и заголовки для HTTP/HTTPS.
+	// nil сохраняет разрешающее поведение: Access-Control-Allow-Origin: *
+	CORS *CORSConfig
+
+	// ShutdownTimeout ограничивает время корректного завершения, включая
+	// хуки OnShutdown. 0 означает DefaultShutdownTimeout
+	ShutdownTimeout time.Duration
 }
 
+// DefaultShutdownTimeout - время корректного завершения по умолчанию
+const DefaultShutdownTimeout = 30 * time.Second
+
+// DefaultTCPReadBufferSize - размер буфера чтения потоковых соединений по умолчанию
+const DefaultTCPReadBufferSize = 64 * 1024
+
+// DefaultHandlerTimeout - ограничение времени выполнения обработчика по умолчанию
+const DefaultHandlerTimeout = 30 * time.Second
+
 // ProcessingContext содержит контекст обработки запроса
 type ProcessingContext struct {
 	Transport      string
@@ -55,28 +264,119 @@ type ProcessingContext struct {
 	ServiceVersion string
 	Headers        http.Header
 	UserAgent      string
+	Connection     *types.ConnectionState
+	// Context - базовый контекст запросов потокового соединения, отменяемый
+	// при закрытии соединения. nil означает контекст HTTP запроса или
+	// context.Background()
+	Context context.Context
+	// Notify отправляет уведомление по соединению до ответа на запрос
+	// (RequestContext.Notify). nil - транспорт не поддерживает уведомления
+	Notify types.Notifier
 }
 
 // NewServer создает новый экземпляр сервера
 func NewServer(config Config, logger *middleware.Logger) *Server {
 	dispatcher := dispatcher.NewDispatcher()
 
-	// Set up middleware chain
+	// Set up middleware chain; recovery comes first so a panicking handler
+	// or middleware is answered with an internal error
 	chain := middleware.NewChain(
+		middleware.RecoveryMiddleware(),
 		middleware.LoggingMiddleware(logger),
 	)
+	var configErr error
+	if config.RateLimit != nil {
+		rateLimit := *config.RateLimit
+		limiter, err := middleware.NewRateLimiter(rateLimit)
+		if err != nil {
+			// An invalid limit must not quietly lift it: requests are rejected
+			// and Start reports the error
+			configErr = fmt.Errorf("rate limit: %w", err)
+			log.Printf("ERROR: invalid rate limit configuration, rejecting requests: %v", err)
+			limiter = misconfiguredLimiter{err: configErr}
+			rateLimit.FailClosed = true
+		}
+		chain.Add(middleware.RateLimitMiddleware(limiter, rateLimit))
+	}
+	// Таймаут из заголовка grpc-timeout сокращает время выполнения обработчика
+	chain.Add(middleware.GRPCTimeoutMiddleware())
+	if config.MonotonicIDs {
+		chain.Add(middleware.MonotonicIDMiddleware())
+	}
+
+	var recent *middleware.RecentRequests
+	if config.RecentRequestsSize > 0 {
+		recent = middleware.NewRecentRequests(config.RecentRequestsSize)
+		chain.Add(recent.Middleware())
+	}
+
+	var slowest *middleware.SlowestRequests
+	if config.SlowestRequestsSize > 0 {
+		slowest = middleware.NewSlowestRequests(config.SlowestRequestsSize)
+		chain.Add(slowest.Middleware())
+	}
+	if config.ValidateParamsSchema {
+		chain.Add(middleware.SchemaValidationMiddleware(dispatcher.ParamsSchema))
+	}
+	if config.CheckResultSchema || config.EnforceResultSchema {
+		chain.Add(middleware.ResponseSchemaCheckMiddleware(dispatcher.ResultSchema, config.EnforceResultSchema))
+	}
+	// Ключ идемпотентности проверяется только для методов, отмеченных MarkIdempotent
+	chain.Add(middleware.IdempotencyMiddleware(dispatcher.IsIdempotent))
+
+	var metrics *prometheus.Registry
+	var rpcMetrics *observability.Metrics
+	if config.PrometheusMetrics {
+		metrics = prometheus.NewRegistry()
+		rpcMetrics = observability.NewMetrics(metrics)
+		chain.Add(rpcMetrics.Middleware())
+	}
 	dispatcher.SetMiddleware(chain)
 
+	handlerTimeout := config.HandlerTimeout
+	if handlerTimeout == 0 {
+		handlerTimeout = DefaultHandlerTimeout
+	}
+	dispatcher.SetDefaultTimeout(handlerTimeout)
+	for method, timeout := range config.MethodTimeouts {
+		dispatcher.SetMethodTimeout(method, timeout)
+	}
+
 	// Register default handlers
 	registerDefaultHandlers(dispatcher)
 
 	processor := NewJSONRPCProcessor(dispatcher, logger)
+	processor.SetDebugInfo(config.DebugHeaders)
+	processor.DisableBatchOnTransports(config.DisableBatchOnTransports...)
+	processor.SetStrictBatch(config.StrictBatch)
+	processor.SetBatchResponseCheck(config.CheckBatchResponses)
+	processor.SetBatchIdempotency(config.BatchIdempotencyCacheSize)
+	processor.SetErrorCatalog(config.ErrorCatalog, config.DefaultLocale)
+	processor.SetMaxBatchSize(config.MaxBatchSize)
+	processor.SetBatchConcurrency(config.BatchConcurrency)
+	processor.SetConnectionBatchConcurrency(config.ConnectionBatchConcurrency)
+	processor.SetMonotonicIDs(config.MonotonicIDs)
+	guard := newGoroutineGuard(config.MaxGoroutines)
+	processor.guard = guard
+	processor.SetWorkerPool(config.WorkerPoolSize, config.MethodPriorities)
+	processor.metrics = rpcMetrics
+	dispatcher.RegisterHandlerWithInfo(MetricsMethod, metricsHandler(processor.stats, logger), metricsHandlerInfo)
+	dispatcher.RegisterHandlerWithInfo(StatsMethod, statsHandler(processor.stats, dispatcher), statsHandlerInfo)
+	dispatcher.RegisterHandlerWithInfo(HelloMethod, helloHandler(config.ServiceName, config.Version, config.MaxBatchSize), helloHandlerInfo)
 
 	return &Server{
-		config:     config,
-		dispatcher: dispatcher,
-		processor:  processor,
-		logger:     logger,
+		config:      config,
+		dispatcher:  dispatcher,
+		processor:   processor,
+		logger:      logger,
+		recent:      recent,
+		slowest:     slowest,
+		connections: NewConnectionRegistry(),
+		metrics:     metrics,
+		connSlots:   newConnectionSlots(config.MaxConnections),
+		guard:       guard,
+		configErr:   configErr,
+		startTime:   types.GlobalClock.Now(),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins for testing
@@ -85,8 +385,25 @@ func NewServer(config Config, logger *middleware.Logger) *Server {
 	}
 }
 
+// misconfiguredLimiter stands in for a limiter that could not be built from
+// the configuration and fails every check
+type misconfiguredLimiter struct {
+	err error
+}
+
+func (l misconfiguredLimiter) Allow(string) (bool, error) {
+	return false, l.err
+}
+
 // registerDefaultHandlers registers the default JSON-RPC handlers
 func registerDefaultHandlers(d *dispatcher.Dispatcher) {
+	d.RegisterHandlerWithInfo(DiscoverMethod, discoverHandler(d), dispatcher.HandlerInfo{
+		Description: "Lists registered methods and their descriptions",
+	})
+	d.RegisterHandlerWithInfo(ExamplesMethod, examplesHandler(d), dispatcher.HandlerInfo{
+		Description: "Returns example requests and responses of all methods or of one method",
+		Params:      `{"method": string} (optional)`,
+	})
 	d.RegisterHandler("echo", handlers.EchoHandler)
 	d.RegisterHandler("calculate", handlers.CalculateHandler)
 	d.RegisterHandler("status", handlers.StatusHandler)
@@ -99,13 +416,121 @@ func registerDefaultHandlers(d *dispatcher.Dispatcher) {
 	})
 }
 
+// DiscoverMethod - встроенный метод, возвращающий список поддерживаемых методов
+const DiscoverMethod = "rpc.discover"
+
+// ExamplesMethod - встроенный метод, возвращающий примеры запросов и ответов
+const ExamplesMethod = "rpc.examples"
+
+// reservedMethods перечисляет методы с зарезервированным префиксом "rpc.",
+// которые реализует сам сервер
+var reservedMethods = map[string]bool{
+	DiscoverMethod: true,
+	ExamplesMethod: true,
+	MetricsMethod:  true,
+	HelloMethod:    true,
+}
+
+// discoverHandler возвращает отсортированный список методов, зарегистрированных в диспетчере,
+// и описания тех методов, для которых они заданы
+func discoverHandler(d *dispatcher.Dispatcher) types.Handler {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		methods := d.GetRegisteredMethods()
+		sort.Strings(methods)
+
+		info := make(map[string]dispatcher.HandlerInfo)
+		for _, method := range methods {
+			if methodInfo, ok := d.GetHandlerInfo(method); ok && !methodInfo.IsZero() {
+				info[method] = methodInfo
+			}
+		}
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Result: map[string]interface{}{
+				"methods": methods,
+				"info":    info,
+			},
+			ID: req.ID,
+		}, nil
+	}
+}
+
+// examplesHandler возвращает примеры, зарегистрированные через RegisterExample.
+// С параметром method возвращаются только примеры этого метода
+func examplesHandler(d *dispatcher.Dispatcher) types.Handler {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		var params struct {
+			Method string `json:"method"`
+		}
+		if req.HasParams() && !req.HasNullParams() {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewInvalidParamsError("Invalid parameters: " + err.Error()),
+					ID:      req.ID,
+				}, nil
+			}
+		}
+
+		examples := d.AllExamples()
+		if params.Method != "" {
+			if _, exists := d.GetHandlerInfo(params.Method); !exists {
+				return &types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewInvalidParamsError("Unknown method: " + params.Method),
+					ID:      req.ID,
+				}, nil
+			}
+			examples = map[string][]dispatcher.Example{}
+			if methodExamples := d.Examples(params.Method); len(methodExamples) > 0 {
+				examples[params.Method] = methodExamples
+			}
+		}
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Result: map[string]interface{}{
+				"examples": examples,
+			},
+			ID: req.ID,
+		}, nil
+	}
+}
+
 // RegisterHandler регистрирует обработчик для указанного метода
 func (s *Server) RegisterHandler(method string, handler types.Handler) {
 	s.dispatcher.RegisterHandler(method, handler)
 }
 
+// RegisterHandlerWithInfo регистрирует обработчик вместе с описанием метода для rpc.discover
+func (s *Server) RegisterHandlerWithInfo(method string, handler types.Handler, info dispatcher.HandlerInfo) {
+	s.dispatcher.RegisterHandlerWithInfo(method, handler, info)
+}
+
+// MarkIdempotent требует ключ идемпотентности (заголовок Idempotency-Key или
+// params.idempotency_key) для вызовов указанных методов
+func (s *Server) MarkIdempotent(methods ...string) {
+	s.dispatcher.MarkIdempotent(methods...)
+}
+
+// RegisterExample добавляет пример запроса и ответа метода для rpc.examples
+func (s *Server) RegisterExample(method string, reqExample, respExample interface{}) error {
+	return s.dispatcher.RegisterExample(method, reqExample, respExample)
+}
+
+// SetNotificationErrorHook устанавливает обработчик ошибок уведомлений.
+// Должен вызываться до Start
+func (s *Server) SetNotificationErrorHook(hook NotificationErrorHook) {
+	s.processor.SetNotificationErrorHook(hook)
+}
+
 // Start starts all configured server protocols
 func (s *Server) Start() error {
+	if s.configErr != nil {
+		return s.configErr
+	}
+
 	// Start HTTP server
 	go func() {
 		if err := s.startHTTP(); err != nil && err != http.ErrServerClosed {
@@ -148,13 +573,60 @@ func (s *Server) Start() error {
 		}
 	}()
 
+	// Start Unix socket server
+	if s.config.UnixSocketAddr != "" {
+		go func() {
+			if err := s.startUnix(); err != nil {
+				log.Printf("Unix socket server error: %v", err)
+			}
+		}()
+	}
+
 	return nil
 }
 
+// OnShutdown регистрирует хук, выполняемый в начале Stop (например, для снятия
+// регистрации в service discovery). Хуки выполняются в порядке регистрации
+func (s *Server) OnShutdown(hook ShutdownHook) {
+	s.shutdownMu.Lock()
+	defer s.shutdownMu.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
+// runShutdownHooks выполняет все хуки и объединяет их ошибки
+func (s *Server) runShutdownHooks(ctx context.Context) error {
+	s.shutdownMu.Lock()
+	hooks := append([]ShutdownHook(nil), s.shutdownHooks...)
+	s.shutdownMu.Unlock()
+
+	var errs []error
+	for i, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Stop gracefully stops the server
 func (s *Server) Stop() error {
+	timeout := s.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Pre-stop hooks run before listeners are closed
+	hookErr := s.runShutdownHooks(ctx)
+
+	unixErr := s.stopUnix()
+
+	// Workers finish the queued requests; later requests run without the pool
+	poolErr := s.processor.closeWorkerPool(ctx)
+
 	// Implementation for graceful shutdown would go here
-	return nil
+	return errors.Join(hookErr, unixErr, poolErr)
 }
 
 // GetDispatcher возвращает диспетчер сервера
@@ -162,12 +634,16 @@ func (s *Server) GetDispatcher() *dispatcher.Dispatcher {
 	return s.dispatcher
 }
 
+// MetricsRegistry возвращает реестр Prometheus, отдаваемый на /metrics,
+// или nil, если PrometheusMetrics выключен
+func (s *Server) MetricsRegistry() *prometheus.Registry {
+	return s.metrics
+}
+
 // handleHTTPRequest обрабатывает HTTP запрос
 func (s *Server) handleHTTPRequest(w http.ResponseWriter, r *http.Request) {
 	// Обработка CORS
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	s.applyCORS(w, r)
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
@@ -179,9 +655,52 @@ func (s *Server) handleHTTPRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.config.RequireJSONContentType && !isJSONContentType(r.Header.Get("Content-Type")) {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if s.config.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxRequestBytes)
+	}
+
+	// Тело может быть сжато; лимит MaxRequestBytes действует и на распакованные данные
+	bodyReader, err := requestBodyReader(r, s.config.MaxRequestBytes)
+	if err != nil {
+		if errors.Is(err, errUnsupportedEncoding) {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		parseError := &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewParseError(err.Error()),
+			ID:      nil,
+		}
+
+		responseJSON, _ := json.Marshal(parseError)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(responseJSON)
+		return
+	}
+
 	// Чтение тела запроса
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(bodyReader)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			tooLargeError := &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInvalidRequestError(fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit)),
+				ID:      nil,
+			}
+
+			responseJSON, _ := json.Marshal(tooLargeError)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write(responseJSON)
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -218,7 +737,16 @@ func (s *Server) handleHTTPRequest(w http.ResponseWriter, r *http.Request) {
 
 	// Определяем, является ли запрос пакетным
 	if len(body) > 0 && body[0] == '[' {
-		result = s.processor.ProcessBatchRequest(body, ctx)
+		sink := newHTTPBatchSink(w)
+		sink.observe = s.processor.observeResponseSize
+		result, err = s.processor.StreamBatchRequest(body, ctx, s.config.BatchStreamThreshold, sink)
+		if err != nil {
+			log.Printf("HTTP batch write error: %v", err)
+			return
+		}
+		if result == nil && sink.Started() {
+			return
+		}
 	} else {
 		result = s.processor.ProcessSingleRequest(body, ctx)
 	}
@@ -237,6 +765,12 @@ func (s *Server) handleHTTPRequest(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+
+		// Для одиночных HTTP запросов отладочные данные передаются в заголовках
+		if v.Debug != nil {
+			setDebugHeaders(w.Header(), v.Debug)
+			v.Debug = nil
+		}
 	case []*types.JSONRPCResponse:
 		if len(v) == 0 {
 			w.WriteHeader(http.StatusOK)
@@ -245,16 +779,39 @@ func (s *Server) handleHTTPRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Сериализация ответа только для валидных результатов
-	responseJSON, err := json.Marshal(result)
+	responseJSON, err := s.processor.encodeResult(result)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	// Отправка ответа
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(responseJSON)
+	s.writeJSONResponse(w, r, http.StatusOK, responseJSON)
+}
+
+// setDebugHeaders записывает отладочные данные в заголовки HTTP ответа
+func setDebugHeaders(header http.Header, debug *types.DebugInfo) {
+	header.Set("X-Debug-Transport", debug.Transport)
+	header.Set("X-Debug-Handler", debug.Handler)
+	header.Set("X-Debug-Duration", debug.Duration)
+	header.Set("X-Debug-Request-ID", debug.RequestID)
+}
+
+// jsonContentTypes содержит допустимые типы содержимого JSON-RPC запросов
+var jsonContentTypes = map[string]bool{
+	"application/json":        true,
+	"application/json-rpc":    true,
+	"application/jsonrequest": true,
+}
+
+// isJSONContentType проверяет, что Content-Type указывает на JSON.
+// Параметры вроде charset допускаются
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return jsonContentTypes[mediaType]
 }
 
 // handleHealth обрабатывает запрос проверки здоровья
@@ -266,6 +823,24 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"version":   s.config.Version,
 	}
 
+	// ?verbose=true добавляет состояние подсистем
+	if verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose")); verbose {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		loggerHealth := s.logger.Health(ctx)
+		if loggerHealth.Enabled && !loggerHealth.Reachable {
+			response["status"] = "degraded"
+		}
+
+		response["uptime_seconds"] = types.GlobalClock.Since(s.startTime).Seconds()
+		response["handlers"] = s.dispatcher.HandlerCount()
+		response["connections"] = map[string]interface{}{
+			"active": s.processor.stats.snapshot().ActiveConnections,
+		}
+		response["logger"] = loggerHealth
+	}
+
 	responseJSON, err := json.Marshal(response)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -277,10 +852,34 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Write(responseJSON)
 }
 
+// healthCheckTimeout ограничивает время проверки подсистем в подробном ответе /health
+const healthCheckTimeout = 2 * time.Second
+
+// NotificationErrorHook вызывается, когда обработка уведомления завершилась ошибкой.
+// err - либо ошибка диспетчера, либо *types.RPCError из ответа обработчика
+type NotificationErrorHook func(req *types.JSONRPCRequest, ctx *types.RequestContext, err error)
+
 // JSONRPCProcessor обрабатывает JSON-RPC запросы
 type JSONRPCProcessor struct {
-	dispatcher *dispatcher.Dispatcher
-	logger     *middleware.Logger
+	dispatcher          *dispatcher.Dispatcher
+	logger              *middleware.Logger
+	onNotificationError NotificationErrorHook
+	debugInfo           bool
+	batchDisabled       map[string]bool
+	strictBatch         bool
+	checkBatchResponses bool
+	batchIdempotency    *batchIdempotencyCache
+	errorCatalog        types.ErrorCatalog
+	defaultLocale       string
+	stats               *serverStats
+	maxBatchSize        int
+	batchConcurrency    int
+	connBatchLimit      int
+	monotonicIDs        bool
+	guard               *goroutineGuard
+	metrics             *observability.Metrics // nil, если PrometheusMetrics выключен
+	pool                *priorityPool
+	priorities          map[string]int
 }
 
 // NewJSONRPCProcessor создает новый процессор JSON-RPC
@@ -288,23 +887,185 @@ func NewJSONRPCProcessor(dispatcher *dispatcher.Dispatcher, logger *middleware.L
 	return &JSONRPCProcessor{
 		dispatcher: dispatcher,
 		logger:     logger,
+		stats:      newServerStats(),
+	}
+}
+
+// SetStrictBatch включает проверку повторяющихся ID внутри пакета
+func (p *JSONRPCProcessor) SetStrictBatch(enabled bool) {
+	p.strictBatch = enabled
+}
+
+// SetBatchResponseCheck включает сверку количества ответов пакета
+func (p *JSONRPCProcessor) SetBatchResponseCheck(enabled bool) {
+	p.checkBatchResponses = enabled
+}
+
+// SetErrorCatalog задает переводы сообщений об ошибках и язык по умолчанию
+func (p *JSONRPCProcessor) SetErrorCatalog(catalog types.ErrorCatalog, defaultLocale string) {
+	if defaultLocale == "" {
+		defaultLocale = types.DefaultLocale
+	}
+	p.errorCatalog = catalog
+	p.defaultLocale = strings.ToLower(defaultLocale)
+}
+
+// responseLocale выбирает язык сообщений об ошибках для запроса
+func (p *JSONRPCProcessor) responseLocale(ctx ProcessingContext) string {
+	if p.errorCatalog == nil {
+		return p.defaultLocale
+	}
+	header := ctx.Headers.Get("Accept-Language")
+	if header == "" && ctx.HTTPRequest != nil {
+		header = ctx.HTTPRequest.Header.Get("Accept-Language")
+	}
+	return p.errorCatalog.MatchLanguage(header, p.defaultLocale)
+}
+
+// localize возвращает копию ответа с сообщением об ошибке на языке locale.
+// Исходный ответ не изменяется: он может храниться в кэше идемпотентности
+func (p *JSONRPCProcessor) localize(response *types.JSONRPCResponse, locale string) *types.JSONRPCResponse {
+	if response == nil || response.Error == nil || p.errorCatalog == nil {
+		return response
+	}
+	localized := p.errorCatalog.Localize(response.Error, locale)
+	if localized == nil {
+		return response
+	}
+	copied := *response
+	copied.Error = localized
+	return &copied
+}
+
+// SetBatchIdempotency включает запоминание результатов size последних пакетов
+// с ключом идемпотентности; 0 отключает
+func (p *JSONRPCProcessor) SetBatchIdempotency(size int) {
+	if size <= 0 {
+		p.batchIdempotency = nil
+		return
+	}
+	p.batchIdempotency = newBatchIdempotencyCache(size)
+}
+
+// SetMaxBatchSize ограничивает количество элементов пакета; 0 снимает ограничение
+func (p *JSONRPCProcessor) SetMaxBatchSize(size int) {
+	p.maxBatchSize = size
+}
+
+// SetBatchConcurrency задает количество элементов пакета, обрабатываемых одновременно
+func (p *JSONRPCProcessor) SetBatchConcurrency(workers int) {
+	p.batchConcurrency = workers
+}
+
+// SetConnectionBatchConcurrency ограничивает количество элементов пакетов,
+// одновременно выполняемых для одного соединения
+func (p *JSONRPCProcessor) SetConnectionBatchConcurrency(limit int) {
+	p.connBatchLimit = limit
+}
+
+// SetMonotonicIDs сообщает процессору о проверке возрастания ID на соединении:
+// элементы пакета потокового соединения тогда выполняются по порядку, иначе
+// одновременное выполнение нарушило бы порядок проверки ID
+func (p *JSONRPCProcessor) SetMonotonicIDs(enabled bool) {
+	p.monotonicIDs = enabled
+}
+
+// SetWorkerPool ограничивает число одновременно выполняемых обработчиков.
+// При занятом пуле запросы методов с большим приоритетом выполняются первыми;
+// методы без приоритета имеют приоритет 0. workers <= 0 отключает пул
+func (p *JSONRPCProcessor) SetWorkerPool(workers int, priorities map[string]int) {
+	if p.pool != nil {
+		p.pool.Close()
+		p.pool = nil
+	}
+	if workers <= 0 {
+		return
+	}
+	p.pool = newPriorityPool(workers)
+	p.priorities = priorities
+}
+
+// closeWorkerPool останавливает исполнителей пула, дожидаясь поставленных в очередь
+// задач не дольше ctx. После этого запросы выполняются в вызывающей горутине
+func (p *JSONRPCProcessor) closeWorkerPool(ctx context.Context) error {
+	if p.pool == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.pool.Close()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("worker pool: %w", ctx.Err())
+	}
+}
+
+// dispatch передает запрос диспетчеру, через пул исполнителей, если он настроен
+func (p *JSONRPCProcessor) dispatch(req *types.JSONRPCRequest, requestCtx *types.RequestContext) (*types.JSONRPCResponse, error) {
+	if p.pool == nil {
+		return p.dispatcher.Dispatch(req, requestCtx)
+	}
+
+	var response *types.JSONRPCResponse
+	var err error
+	p.pool.Run(p.priorities[req.Method], func() {
+		response, err = p.dispatcher.Dispatch(req, requestCtx)
+	})
+	return response, err
+}
+
+// SetNotificationErrorHook устанавливает обработчик ошибок уведомлений
+func (p *JSONRPCProcessor) SetNotificationErrorHook(hook NotificationErrorHook) {
+	p.onNotificationError = hook
+}
+
+// RequestsServed возвращает количество запросов и уведомлений, обработанных
+// процессором, включая завершившиеся ошибкой
+func (p *JSONRPCProcessor) RequestsServed() uint64 {
+	return p.stats.requestsServed()
+}
+
+// SetDebugInfo включает добавление отладочных данных в ответы
+func (p *JSONRPCProcessor) SetDebugInfo(enabled bool) {
+	p.debugInfo = enabled
+}
+
+// DisableBatchOnTransports запрещает пакетные запросы на указанных транспортах.
+// Имена транспортов сравниваются без учета регистра
+func (p *JSONRPCProcessor) DisableBatchOnTransports(transports ...string) {
+	p.batchDisabled = make(map[string]bool, len(transports))
+	for _, transport := range transports {
+		p.batchDisabled[strings.ToLower(transport)] = true
 	}
 }
 
 // ProcessSingleRequest обрабатывает одиночный JSON-RPC запрос
 func (p *JSONRPCProcessor) ProcessSingleRequest(data []byte, ctx ProcessingContext) *types.JSONRPCResponse {
+	return p.localize(p.processSingleRequest(data, ctx), p.responseLocale(ctx))
+}
+
+func (p *JSONRPCProcessor) processSingleRequest(data []byte, ctx ProcessingContext) *types.JSONRPCResponse {
 	// Step 1: Parse JSON
 	var request types.JSONRPCRequest
 	if err := json.Unmarshal(data, &request); err != nil {
+		rpcErr := types.NewParseError("Invalid JSON: " + err.Error())
+		p.stats.record("", false, rpcErr)
 		return &types.JSONRPCResponse{
 			JSONRPC: "2.0",
-			Error:   types.NewParseError("Invalid JSON: " + err.Error()),
+			Error:   rpcErr,
 			ID:      nil, // ID is null when request cannot be parsed
 		}
 	}
 
 	// Step 2: Validate JSON-RPC 2.0 structure
 	if err := p.validateRequest(&request); err != nil {
+		p.stats.record("", false, err)
 		return &types.JSONRPCResponse{
 			JSONRPC: "2.0",
 			Error:   err,
@@ -315,44 +1076,86 @@ func (p *JSONRPCProcessor) ProcessSingleRequest(data []byte, ctx ProcessingConte
 	// Step 3: Handle notifications (requests without ID)
 	if request.IsNotification() {
 		// Process notification but don't return response
-		p.processNotification(&request, ctx)
+		p.processNotification(&request, data, ctx)
 		return nil // No response for notifications per JSON-RPC 2.0 spec
 	}
 
 	// Step 4: Process regular request
-	return p.processRegularRequest(&request, ctx)
+	response := p.processRegularRequest(&request, data, ctx)
+	var rpcErr *types.RPCError
+	if response != nil {
+		rpcErr = response.Error
+	}
+	p.stats.record(request.Method, false, rpcErr)
+	return response
 }
 
 // ProcessBatchRequest обрабатывает пакетный JSON-RPC запрос
 func (p *JSONRPCProcessor) ProcessBatchRequest(data []byte, ctx ProcessingContext) interface{} {
-	// Parse as array of raw messages
-	var rawRequests []json.RawMessage
-	if err := json.Unmarshal(data, &rawRequests); err != nil {
-		return &types.JSONRPCResponse{
-			JSONRPC: "2.0",
-			Error:   types.NewParseError("Invalid JSON in batch request: " + err.Error()),
-			ID:      nil,
-		}
+	var result interface{}
+	if key := p.batchIdempotencyKey(ctx); key != "" {
+		result = p.batchIdempotency.do(key, data, ctx.HTTPRequest.Context().Done(), func() interface{} {
+			return p.processBatch(data, ctx)
+		})
+	} else {
+		result = p.processBatch(data, ctx)
 	}
 
-	// Validate batch is not empty
-	if len(rawRequests) == 0 {
-		return &types.JSONRPCResponse{
-			JSONRPC: "2.0",
-			Error:   types.NewInvalidRequestError("Batch request cannot be empty"),
-			ID:      nil,
+	// Elements are localized one by one; this covers errors for the whole batch
+	if response, ok := result.(*types.JSONRPCResponse); ok {
+		return p.localize(response, p.responseLocale(ctx))
+	}
+	return result
+}
+
+// processBatch выполняет элементы пакета и собирает ответы
+func (p *JSONRPCProcessor) processBatch(data []byte, ctx ProcessingContext) interface{} {
+	rawRequests, errResponse := p.parseBatch(data, ctx)
+	if errResponse != nil {
+		p.stats.record("", false, errResponse.Error)
+		return errResponse
+	}
+
+	// Duplicate IDs are resolved in batch order before anything runs
+	results := make([]*types.JSONRPCResponse, len(rawRequests))
+	pending := make([]int, 0, len(rawRequests))
+	seen := p.newBatchIDSet()
+	for i, rawReq := range rawRequests {
+		if results[i] = seen.check(rawReq); results[i] == nil {
+			pending = append(pending, i)
 		}
 	}
 
 	// Process each request in the batch
+	// ID increase in batch order only when elements run one by one
+	ordered := p.monotonicIDs && ctx.Connection != nil
+	if p.batchConcurrency > 1 && len(pending) > 1 && !ordered {
+		if !p.processBatchConcurrently(rawRequests, pending, results, ctx) {
+			busy := &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   newServerBusyError(),
+				ID:      nil,
+			}
+			p.stats.record("", false, busy.Error)
+			return busy
+		}
+	} else {
+		for _, i := range pending {
+			results[i] = p.ProcessSingleRequest(rawRequests[i], ctx)
+		}
+	}
+
 	var responses []*types.JSONRPCResponse
-	for _, rawReq := range rawRequests {
-		response := p.ProcessSingleRequest(rawReq, ctx)
+	for _, response := range results {
 		if response != nil { // Only add non-notification responses
 			responses = append(responses, response)
 		}
 	}
 
+	if p.checkBatchResponses {
+		p.checkBatchResponseCount(rawRequests, responses)
+	}
+
 	// If all requests were notifications, return nothing
 	if len(responses) == 0 {
 		return nil
@@ -362,44 +1165,370 @@ func (p *JSONRPCProcessor) ProcessBatchRequest(data []byte, ctx ProcessingContex
 	return responses
 }
 
-// validateRequest validates a JSON-RPC 2.0 request structure
-func (p *JSONRPCProcessor) validateRequest(req *types.JSONRPCRequest) *types.RPCError {
-	// Validate JSON-RPC version
-	if req.JSONRPC != "2.0" {
-		return types.NewInvalidRequestError("JSON-RPC version must be '2.0'")
+// checkBatchResponseCount сверяет количество ответов пакета с количеством
+// элементов, требующих ответа, и записывает расхождение в лог. Возвращает false
+// при расхождении
+func (p *JSONRPCProcessor) checkBatchResponseCount(rawRequests []json.RawMessage, responses []*types.JSONRPCResponse) bool {
+	expected := 0
+	for _, raw := range rawRequests {
+		if p.expectsResponse(raw) {
+			expected++
+		}
 	}
-
-	// Validate method is present and non-empty
-	if req.Method == "" {
-		return types.NewInvalidRequestError("Method is required and cannot be empty")
+	if expected == len(responses) {
+		return true
 	}
+	log.Printf("WARNING: batch of %d requests expected %d responses, got %d", len(rawRequests), expected, len(responses))
+	return false
+}
 
-	// Validate method name format (should not start with "rpc." unless it's a reserved method)
-	if strings.HasPrefix(req.Method, "rpc.") {
-		return types.NewMethodNotFoundError(req.Method + " (reserved method prefix)")
+// expectsResponse сообщает, должен ли элемент пакета получить ответ: ответа
+// не получают только корректные уведомления
+func (p *JSONRPCProcessor) expectsResponse(raw json.RawMessage) bool {
+	var request types.JSONRPCRequest
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return true
 	}
-
-	return nil
+	if p.validateRequest(&request) != nil {
+		return true
+	}
+	return !request.IsNotification()
 }
 
-// processNotification processes a notification request (no response expected)
-func (p *JSONRPCProcessor) processNotification(req *types.JSONRPCRequest, ctx ProcessingContext) {
-	// Create request context
-	requestCtx := p.createRequestContext(req, ctx)
+// processBatchConcurrently выполняет элементы пакета с индексами pending не более чем
+// в batchConcurrency горутинах. Ответ каждого элемента сохраняется по его индексу,
+// поэтому порядок ответов совпадает с порядком запросов. Возвращает false, не
+// выполнив ни одного элемента, если предел MaxGoroutines не оставил ни одной горутины
+func (p *JSONRPCProcessor) processBatchConcurrently(rawRequests []json.RawMessage, pending []int, results []*types.JSONRPCResponse, ctx ProcessingContext) bool {
+	workers := p.batchConcurrency
+	if p.connBatchLimit > 0 && workers > p.connBatchLimit {
+		workers = p.connBatchLimit
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
 
-	// Process through dispatcher (ignore response and errors for notifications)
-	if p.dispatcher != nil {
-		_, _ = p.dispatcher.Dispatch(req, requestCtx)
+	// Workers beyond the goroutine ceiling are not started
+	acquired := 0
+	for acquired < workers && p.guard.tryAcquire() {
+		acquired++
+	}
+	if acquired == 0 {
+		return false
+	}
+	workers = acquired
+
+	// Slots are shared by every batch of the connection
+	slots := p.connectionBatchSlots(ctx.Connection)
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.guard.release()
+			for i := range indexes {
+				if slots != nil {
+					slots <- struct{}{}
+				}
+				results[i] = p.processBatchElement(rawRequests[i], ctx)
+				if slots != nil {
+					<-slots
+				}
+			}
+		}()
 	}
-}
+
+	for _, i := range pending {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return true
+}
+
+// connectionBatchSlotsKey - ключ семафора пакетных элементов в ConnectionState
+const connectionBatchSlotsKey = "batch_slots"
+
+// connectionBatchSlots возвращает семафор соединения, ограничивающий количество
+// одновременно выполняемых элементов пакетов. nil, если ограничение выключено
+// или у транспорта нет постоянного соединения
+func (p *JSONRPCProcessor) connectionBatchSlots(conn *types.ConnectionState) chan struct{} {
+	if p.connBatchLimit <= 0 || conn == nil {
+		return nil
+	}
+
+	conn.Update(connectionBatchSlotsKey, func(current interface{}, exists bool) (interface{}, bool) {
+		if exists {
+			return nil, false
+		}
+		return make(chan struct{}, p.connBatchLimit), true
+	})
+	slots, _ := conn.Get(connectionBatchSlotsKey)
+	return slots.(chan struct{})
+}
+
+// processBatchElement обрабатывает элемент пакета; паника в обработчике превращается
+// во внутреннюю ошибку этого элемента и не прерывает остальные
+func (p *JSONRPCProcessor) processBatchElement(raw json.RawMessage, ctx ProcessingContext) (response *types.JSONRPCResponse) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in batch element: %v", r)
+
+			var item struct {
+				ID interface{} `json:"id"`
+			}
+			if json.Unmarshal(raw, &item) != nil || item.ID == nil {
+				// Notifications still get no response
+				response = nil
+				return
+			}
+			response = &types.JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error:   types.NewInternalError(fmt.Sprintf("panic: %v", r)),
+				ID:      item.ID,
+			}
+		}
+	}()
+
+	return p.ProcessSingleRequest(raw, ctx)
+}
+
+// StreamBatchRequest обрабатывает пакетный JSON-RPC запрос, передавая ответы в sink.
+// Если пакет содержит больше threshold элементов, каждый ответ отправляется сразу
+// после обработки и не накапливается в памяти; в этом случае возвращается nil.
+// Меньшие пакеты и ошибки разбора обрабатываются как в ProcessBatchRequest.
+// Ошибка возвращается, только если sink не смог записать ответ
+func (p *JSONRPCProcessor) StreamBatchRequest(data []byte, ctx ProcessingContext, threshold int, sink BatchSink) (interface{}, error) {
+	rawRequests, errResponse := p.parseBatch(data, ctx)
+	if errResponse != nil {
+		p.stats.record("", false, errResponse.Error)
+		return p.localize(errResponse, p.responseLocale(ctx)), nil
+	}
+
+	// A batch with an idempotency key is collected so that it can be replayed
+	if threshold <= 0 || len(rawRequests) <= threshold || p.batchIdempotencyKey(ctx) != "" {
+		return p.ProcessBatchRequest(data, ctx), nil
+	}
+
+	var writeErr error
+	seen := p.newBatchIDSet()
+	for _, rawReq := range rawRequests {
+		response := seen.check(rawReq)
+		if response == nil {
+			response = p.ProcessSingleRequest(rawReq, ctx)
+		}
+		if response == nil || writeErr != nil {
+			// Remaining requests are still executed after a write failure
+			continue
+		}
+		writeErr = sink.WriteResponse(response)
+	}
+
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	return nil, sink.Close()
+}
+
+// batchIDSet запоминает ID элементов пакета для проверки StrictBatch
+type batchIDSet map[string]bool
+
+// newBatchIDSet возвращает nil, если проверка повторяющихся ID выключена
+func (p *JSONRPCProcessor) newBatchIDSet() batchIDSet {
+	if !p.strictBatch {
+		return nil
+	}
+	return make(batchIDSet)
+}
+
+// check возвращает ответ с ошибкой, если ID элемента уже встречался в пакете.
+// Элементы без ID, с ID null или неразборчивые пропускаются: их обрабатывает
+// ProcessSingleRequest
+func (s batchIDSet) check(raw json.RawMessage) *types.JSONRPCResponse {
+	if s == nil {
+		return nil
+	}
+
+	var item struct {
+		ID interface{} `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &item); err != nil || item.ID == nil {
+		return nil
+	}
+
+	// Тип входит в ключ, чтобы 1 и "1" считались разными ID
+	key := fmt.Sprintf("%T:%v", item.ID, item.ID)
+	if !s[key] {
+		s[key] = true
+		return nil
+	}
+
+	return &types.JSONRPCResponse{
+		JSONRPC: "2.0",
+		Error:   types.NewInvalidRequestError("duplicate id in batch"),
+		ID:      item.ID,
+	}
+}
+
+// parseBatch разбирает пакетный запрос на отдельные элементы
+func (p *JSONRPCProcessor) parseBatch(data []byte, ctx ProcessingContext) ([]json.RawMessage, *types.JSONRPCResponse) {
+	if p.batchDisabled[strings.ToLower(ctx.Transport)] {
+		return nil, &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInvalidRequestError("Batch not supported on this transport"),
+			ID:      nil,
+		}
+	}
+
+	// Parse as array of raw messages
+	var rawRequests []json.RawMessage
+	if err := json.Unmarshal(data, &rawRequests); err != nil {
+		return nil, &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewParseError("Invalid JSON in batch request: " + err.Error()),
+			ID:      nil,
+		}
+	}
+
+	// Validate batch is not empty
+	if len(rawRequests) == 0 {
+		return nil, &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInvalidRequestError("Batch request cannot be empty"),
+			ID:      nil,
+		}
+	}
+
+	// Oversized batches are rejected before any element is executed
+	maxBatchSize := p.maxBatchSize
+	if settings, ok := connectionHello(ctx.Connection); ok {
+		// Размер, согласованный через rpc.hello, не превышает серверного
+		maxBatchSize = minLimit(maxBatchSize, settings.MaxBatch)
+	}
+	if maxBatchSize > 0 && len(rawRequests) > maxBatchSize {
+		return nil, &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInvalidRequestError("batch too large"),
+			ID:      nil,
+		}
+	}
+
+	return rawRequests, nil
+}
+
+// validateRequest validates a JSON-RPC 2.0 request structure
+func (p *JSONRPCProcessor) validateRequest(req *types.JSONRPCRequest) *types.RPCError {
+	// Validate JSON-RPC version
+	if req.JSONRPC != "2.0" {
+		return types.NewInvalidRequestError("JSON-RPC version must be '2.0'")
+	}
+
+	// Validate method is present and non-empty
+	if req.Method == "" {
+		return types.NewInvalidRequestError("Method is required and cannot be empty")
+	}
+
+	// Validate method name format (should not start with "rpc." unless it's a reserved method)
+	if strings.HasPrefix(req.Method, "rpc.") && !reservedMethods[req.Method] {
+		return types.NewMethodNotFoundError(req.Method + " (reserved method prefix)")
+	}
+
+	return nil
+}
+
+// processNotification processes a notification request (no response expected)
+func (p *JSONRPCProcessor) processNotification(req *types.JSONRPCRequest, raw []byte, ctx ProcessingContext) {
+	// Create request context
+	requestCtx := p.createRequestContext(req, raw, ctx)
+
+	// Process through dispatcher; the response is discarded, errors only reach the hook
+	if p.dispatcher != nil {
+		response, err := p.dispatch(req, requestCtx)
+		if err == nil && response != nil && response.Error != nil {
+			err = response.Error
+		}
+
+		p.stats.record(req.Method, true, notificationRPCError(err))
+
+		if err != nil && p.metrics != nil {
+			p.metrics.NotificationError(req, requestCtx, err)
+		}
+		if err != nil && p.onNotificationError != nil {
+			p.onNotificationError(req, requestCtx, err)
+		}
+		return
+	}
+
+	p.stats.record(req.Method, true, nil)
+}
+
+// contextRPCError maps handler errors caused by an expired or cancelled request
+// context to a server error, keeping them apart from generic internal errors.
+// Returns nil for other errors
+func contextRPCError(err error) *types.RPCError {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return types.NewServerError(dispatcher.HandlerTimeoutCode, "Timeout")
+	case errors.Is(err, context.Canceled):
+		return types.NewServerError(dispatcher.HandlerTimeoutCode, "Cancelled")
+	default:
+		return nil
+	}
+}
+
+// notificationRPCError converts a notification failure into the JSON-RPC error
+// a regular request would have received, for error class accounting
+func notificationRPCError(err error) *types.RPCError {
+	if ctxErr := contextRPCError(err); ctxErr != nil {
+		return ctxErr
+	}
+
+	var rpcErr *types.RPCError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &rpcErr):
+		return rpcErr
+	case errors.Is(err, dispatcher.ErrMethodNotFound):
+		return types.NewMethodNotFoundError(err.Error())
+	default:
+		return types.NewInternalError(err.Error())
+	}
+}
 
 // processRegularRequest processes a regular request (response expected)
-func (p *JSONRPCProcessor) processRegularRequest(req *types.JSONRPCRequest, ctx ProcessingContext) *types.JSONRPCResponse {
+func (p *JSONRPCProcessor) processRegularRequest(req *types.JSONRPCRequest, raw []byte, ctx ProcessingContext) *types.JSONRPCResponse {
+	// A processor built without a dispatcher cannot serve requests
+	if p.dispatcher == nil {
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewInternalError("Dispatcher is not configured"),
+			ID:      req.ID,
+		}
+	}
+
 	// Create request context
-	requestCtx := p.createRequestContext(req, ctx)
+	requestCtx := p.createRequestContext(req, raw, ctx)
 
 	// Process through dispatcher
-	response, err := p.dispatcher.Dispatch(req, requestCtx)
+	response, err := p.dispatch(req, requestCtx)
+	if errors.Is(err, dispatcher.ErrMethodNotFound) {
+		// Middleware did not handle the error: answer as the dispatcher does by default
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   types.NewMethodNotFoundError(fmt.Sprintf("Method not found: %s", req.Method)),
+			ID:      req.ID,
+		}
+	}
+	if rpcErr := contextRPCError(err); rpcErr != nil {
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error:   rpcErr,
+			ID:      req.ID,
+		}
+	}
 	if err != nil {
 		return &types.JSONRPCResponse{
 			JSONRPC: "2.0",
@@ -412,24 +1541,53 @@ func (p *JSONRPCProcessor) processRegularRequest(req *types.JSONRPCRequest, ctx
 	if response != nil {
 		response.JSONRPC = "2.0"
 		response.ID = req.ID
+		response.Method = req.Method
+
+		if p.debugInfo {
+			response.Debug = newDebugInfo(req, requestCtx, ctx)
+		}
 	}
 
 	return response
 }
 
-// createRequestContext creates a request context from processing context
-func (p *JSONRPCProcessor) createRequestContext(req *types.JSONRPCRequest, ctx ProcessingContext) *types.RequestContext {
+// newDebugInfo собирает отладочные данные обработанного запроса
+func newDebugInfo(req *types.JSONRPCRequest, requestCtx *types.RequestContext, ctx ProcessingContext) *types.DebugInfo {
+	handler := requestCtx.SelectedHandler
+	if handler == "" {
+		handler = req.Method
+	}
+
+	return &types.DebugInfo{
+		Transport: ctx.Transport,
+		Handler:   handler,
+		Duration:  requestCtx.Duration().String(),
+		RequestID: requestCtx.RequestID,
+	}
+}
+
+// createRequestContext creates a request context from processing context and the raw request bytes
+func (p *JSONRPCProcessor) createRequestContext(req *types.JSONRPCRequest, raw []byte, ctx ProcessingContext) *types.RequestContext {
 	var requestCtx *types.RequestContext
 
-	if ctx.HTTPRequest != nil {
-		requestCtx = types.NewRequestContext(ctx.HTTPRequest.Context(), ctx.ServiceName, ctx.RemoteAddr)
-	} else {
-		requestCtx = types.NewRequestContext(context.Background(), ctx.ServiceName, ctx.RemoteAddr)
+	// Handlers observe client disconnects through the request context
+	switch {
+	case ctx.Context != nil:
+		requestCtx = types.NewRequestContext(ctx.Context, ctx.Transport, ctx.RemoteAddr)
+	case ctx.HTTPRequest != nil:
+		requestCtx = types.NewRequestContext(ctx.HTTPRequest.Context(), ctx.Transport, ctx.RemoteAddr)
+	default:
+		requestCtx = types.NewRequestContext(context.Background(), ctx.Transport, ctx.RemoteAddr)
 	}
 
 	requestCtx.WithValue("transport", ctx.Transport)
 	requestCtx.WithValue("service_version", ctx.ServiceVersion)
 	requestCtx.WithValue("method", req.Method)
+	requestCtx.Connection = ctx.Connection
+	requestCtx.RawRequest = raw
+	if ctx.Notify != nil {
+		requestCtx.SetNotifier(ctx.Notify)
+	}
 
 	if ctx.HTTPRequest != nil {
 		requestCtx.WithValue("headers", ctx.HTTPRequest.Header)
@@ -442,15 +1600,40 @@ func (p *JSONRPCProcessor) createRequestContext(req *types.JSONRPCRequest, ctx P
 
 // HTTP Server Implementation
 
-// startHTTP starts the HTTP server
-func (s *Server) startHTTP() error {
+// newHTTPMux creates the request multiplexer shared by HTTP and HTTPS servers
+func (s *Server) newHTTPMux() *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/rpc", s.handleHTTPRequest)
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/sse", s.handleSSE)
+	if s.recent != nil {
+		mux.HandleFunc("/debug/recent", s.recent.HTTPHandler())
+	}
+	if s.slowest != nil {
+		mux.HandleFunc("/debug/slowest", s.slowest.HTTPHandler())
+	}
+	if s.metrics != nil {
+		mux.Handle("/metrics", promhttp.HandlerFor(s.metrics, promhttp.HandlerOpts{}))
+	}
+	return mux
+}
+
+// newHTTPHandler wraps the HTTP multiplexer with optional response timing
+func (s *Server) newHTTPHandler() http.Handler {
+	mux := s.newHTTPMux()
+	if !s.config.ResponseTiming {
+		return mux
+	}
+	return middleware.ResponseTimingMiddleware(s.logger, mux)
+}
+
+// startHTTP starts the HTTP server
+func (s *Server) startHTTP() error {
+	handler := s.newHTTPHandler()
 
 	server := &http.Server{
 		Addr:         s.config.HTTPAddr,
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  s.config.ReadTimeout,
 		WriteTimeout: s.config.WriteTimeout,
 		IdleTimeout:  s.config.IdleTimeout,
@@ -462,13 +1645,11 @@ func (s *Server) startHTTP() error {
 
 // startHTTPS starts the HTTPS server
 func (s *Server) startHTTPS() error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/rpc", s.handleHTTPRequest)
-	mux.HandleFunc("/health", s.handleHealth)
+	handler := s.newHTTPHandler()
 
 	server := &http.Server{
 		Addr:         s.config.HTTPSAddr,
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  s.config.ReadTimeout,
 		WriteTimeout: s.config.WriteTimeout,
 		IdleTimeout:  s.config.IdleTimeout,
@@ -518,6 +1699,12 @@ func (s *Server) startSecureWebSocket() error {
 
 // handleWebSocket handles WebSocket connections
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.guard.tryAcquire() {
+		http.Error(w, "Server busy", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.guard.release()
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
@@ -530,6 +1717,12 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 // handleSecureWebSocket handles secure WebSocket connections
 func (s *Server) handleSecureWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.guard.tryAcquire() {
+		http.Error(w, "Server busy", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.guard.release()
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Secure WebSocket upgrade error: %v", err)
@@ -540,6 +1733,24 @@ func (s *Server) handleSecureWebSocket(w http.ResponseWriter, r *http.Request) {
 	s.handleWebSocketConnection(conn, r, "Secure WebSocket")
 }
 
+// connectBanner builds the server.banner notification sent to stream clients on connect
+func (s *Server) connectBanner() *types.JSONRPCRequest {
+	methods := s.dispatcher.GetRegisteredMethods()
+	sort.Strings(methods)
+
+	params, _ := json.Marshal(map[string]interface{}{
+		"service": s.config.ServiceName,
+		"version": s.config.Version,
+		"methods": methods,
+	})
+
+	return &types.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "server.banner",
+		Params:  params,
+	}
+}
+
 // handleWebSocketConnection handles WebSocket message processing with JSON-RPC 2.0 compliance
 func (s *Server) handleWebSocketConnection(conn *websocket.Conn, r *http.Request, transport string) {
 	ctx := ProcessingContext{
@@ -548,18 +1759,89 @@ func (s *Server) handleWebSocketConnection(conn *websocket.Conn, r *http.Request
 		HTTPRequest:    r,
 		ServiceName:    s.config.ServiceName,
 		ServiceVersion: s.config.Version,
+		Connection:     types.NewConnectionState(),
 	}
-
-	for {
-		// Read message
-		_, message, err := conn.ReadMessage()
+	ctx.Connection.Set(connectionFramingsKey, []string{FramingWebSocket})
+
+	// The upgrade request context is not cancelled when a hijacked connection
+	// closes, so requests get their own context cancelled on disconnect: the
+	// reader below keeps reading while a request is processed and cancels it
+	// once the connection fails
+	connCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx.Context = connCtx
+
+	// Responses and server-initiated notifications share the connection,
+	// so every write goes through the registered wrapper
+	wsConn := &wsConnection{conn: conn}
+	s.connections.Register(ctx.Connection.ID, wsConn)
+	defer s.connections.Unregister(ctx.Connection.ID)
+
+	// Handlers may report progress before their response (RequestContext.Notify)
+	ctx.Notify = func(method string, params interface{}) error {
+		data, err := marshalNotification(method, params)
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+			return err
+		}
+		return wsConn.writePush(data)
+	}
+
+	s.processor.stats.connectionOpened()
+	defer s.processor.stats.connectionClosed()
+
+	if s.config.SendConnectBanner {
+		if err := wsConn.WriteJSON(s.connectBanner()); err != nil {
+			log.Printf("WebSocket banner write error: %v", err)
+			return
+		}
+	}
+
+	// Pings keep intermediaries from dropping idle connections; a peer that
+	// answers neither pings nor with messages hits the read deadline
+	pongWait := 2 * s.config.WSPingInterval
+	if s.config.WSPingInterval > 0 {
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
+		stopPings := make(chan struct{})
+		defer close(stopPings)
+		go s.pingWebSocket(wsConn, stopPings, cancel)
+	}
+
+	messages := make(chan []byte)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(messages)
+		defer cancel()
+		for {
+			// Pongs are only handled inside ReadMessage, so the deadline starts
+			// over when reading resumes rather than counting processing time
+			if s.config.WSPingInterval > 0 {
+				conn.SetReadDeadline(time.Now().Add(pongWait))
+			}
+
+			// Read message
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				if isTimeout(err) {
+					log.Printf("WebSocket peer %s stopped answering pings, closing", r.RemoteAddr)
+				} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					log.Printf("WebSocket error: %v", err)
+				}
+				return
+			}
+
+			select {
+			case messages <- message:
+			case <-done:
+				return
 			}
-			break
 		}
+	}()
 
+	for message := range messages {
 		// Process JSON-RPC request
 		var result interface{}
 		trimmed := strings.TrimSpace(string(message))
@@ -568,13 +1850,21 @@ func (s *Server) handleWebSocketConnection(conn *websocket.Conn, r *http.Request
 			// Batch request
 			result = s.processor.ProcessBatchRequest(message, ctx)
 		} else {
-			// Single request
-			result = s.processor.ProcessSingleRequest(message, ctx)
+			// Single request. A notification yields a nil *JSONRPCResponse, which
+			// must not end up in result: a non-nil interface would be sent as "null"
+			if response := s.processor.ProcessSingleRequest(message, ctx); response != nil {
+				result = response
+			}
 		}
 
 		// Send response (skip if notification)
 		if result != nil {
-			if err := conn.WriteJSON(result); err != nil {
+			data, err := s.processor.encodeResult(result)
+			if err != nil {
+				log.Printf("WebSocket encode error: %v", err)
+				break
+			}
+			if err := wsConn.WriteMessage(append(data, '\n')); err != nil {
 				log.Printf("WebSocket write error: %v", err)
 				break
 			}
@@ -582,6 +1872,27 @@ func (s *Server) handleWebSocketConnection(conn *websocket.Conn, r *http.Request
 	}
 }
 
+// pingWebSocket sends a ping every WSPingInterval until stop is closed. A failed
+// ping closes the connection so the read loop exits, and cancels the context of
+// a request still running on it
+func (s *Server) pingWebSocket(wsConn *wsConnection, stop <-chan struct{}, cancel context.CancelFunc) {
+	ticker := time.NewTicker(s.config.WSPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := wsConn.ping(); err != nil {
+				cancel()
+				wsConn.close()
+				return
+			}
+		}
+	}
+}
+
 // TCP Server Implementation
 
 // startTCP starts the TCP server
@@ -601,7 +1912,7 @@ func (s *Server) startTCP() error {
 			continue
 		}
 
-		go s.handleTCPConnection(conn, "TCP")
+		s.serveConnection(conn, "TCP")
 	}
 }
 
@@ -622,7 +1933,205 @@ func (s *Server) startTLS() error {
 			continue
 		}
 
-		go s.handleTCPConnection(conn, "TLS")
+		s.serveConnection(conn, "TLS")
+	}
+}
+
+// isTimeout reports whether err is a network deadline expiry
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// TooManyConnectionsCode is sent to connections rejected by MaxConnections.
+// It stays clear of the tenant (-32001), busy (-32002) and rate limit (-32003) codes
+const TooManyConnectionsCode = -32004
+
+// rejectWriteTimeout bounds the whole exchange with a rejected connection,
+// including a TLS handshake the error write has to complete first
+const rejectWriteTimeout = time.Second
+
+// newConnectionSlots creates the connection semaphore; nil means no limit
+func newConnectionSlots(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+// acquireConnection takes a connection slot without waiting
+func (s *Server) acquireConnection() bool {
+	if s.connSlots != nil {
+		select {
+		case s.connSlots <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// releaseConnection frees the slot taken by acquireConnection
+func (s *Server) releaseConnection() {
+	if s.connSlots != nil {
+		<-s.connSlots
+	}
+}
+
+// ConnectionCount returns the number of open streaming connections: TCP, TLS,
+// Unix socket, WebSocket and SSE. It is the counter reported by the metrics snapshot
+func (s *Server) ConnectionCount() int {
+	return int(atomic.LoadInt64(&s.processor.stats.activeConnections))
+}
+
+// rejectConnection tells a client why its connection is being closed
+func rejectConnection(conn net.Conn, rpcErr *types.RPCError) {
+	conn.SetDeadline(time.Now().Add(rejectWriteTimeout))
+	json.NewEncoder(conn).Encode(&types.JSONRPCResponse{
+		JSONRPC: "2.0",
+		Error:   rpcErr,
+		ID:      nil,
+	})
+}
+
+// serveConnection handles an accepted stream connection in its own goroutine,
+// unless the MaxGoroutines ceiling is reached
+func (s *Server) serveConnection(conn net.Conn, transport string) {
+	if !s.guard.tryAcquire() {
+		log.Printf("%s connection from %s rejected: goroutine limit reached", transport, conn.RemoteAddr())
+		// Rejecting is bounded by rejectWriteTimeout but must not stall the
+		// accept loop, so it runs outside the goroutine budget
+		go func() {
+			rejectConnection(conn, newServerBusyError())
+			conn.Close()
+		}()
+		return
+	}
+
+	go func() {
+		defer s.guard.release()
+		s.handleTCPConnection(conn, transport)
+	}()
+}
+
+// unixSocketMode restricts the socket to the owner and group
+const unixSocketMode = 0660
+
+// startUnix starts the Unix domain socket server
+func (s *Server) startUnix() error {
+	addr := s.config.UnixSocketAddr
+
+	// A socket left behind by a crashed process blocks Listen; anything
+	// other than a socket at that path is left alone
+	if info, err := os.Lstat(addr); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("unix socket path %s exists and is not a socket", addr)
+		}
+		if err := os.Remove(addr); err != nil {
+			return fmt.Errorf("failed to remove stale unix socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	s.listenerMu.Lock()
+	s.unixListener = listener
+	s.listenerMu.Unlock()
+
+	if err := os.Chmod(addr, unixSocketMode); err != nil {
+		return fmt.Errorf("failed to set unix socket permissions: %w", err)
+	}
+
+	log.Printf("Starting Unix socket server on %s", addr)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("Unix accept error: %v", err)
+			continue
+		}
+
+		s.serveConnection(conn, "Unix")
+	}
+}
+
+// stopUnix closes the Unix socket listener and unlinks the socket file
+func (s *Server) stopUnix() error {
+	s.listenerMu.Lock()
+	listener := s.unixListener
+	s.unixListener = nil
+	s.listenerMu.Unlock()
+
+	if listener == nil {
+		return nil
+	}
+
+	listener.Close()
+	if err := os.Remove(s.config.UnixSocketAddr); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove unix socket: %w", err)
+	}
+	return nil
+}
+
+// errMessageTooLarge is returned by messageLimitReader once a message exceeds the limit
+var errMessageTooLarge = errors.New("message too large")
+
+// messageLimitReader limits the size of each message read from a stream.
+// Unlike io.LimitReader, which caps the whole connection, the limit is moved
+// forward after every decoded message; end is the absolute stream offset
+// past which reads fail with errMessageTooLarge.
+type messageLimitReader struct {
+	r    io.Reader
+	read int64
+	end  int64
+}
+
+func (l *messageLimitReader) Read(p []byte) (int, error) {
+	if l.read >= l.end {
+		return 0, errMessageTooLarge
+	}
+	if remaining := l.end - l.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
+}
+
+// tcpReadBufferSize returns the configured read buffer size of stream connections
+func (s *Server) tcpReadBufferSize() int {
+	if s.config.TCPReadBufferSize <= 0 {
+		return DefaultTCPReadBufferSize
+	}
+	return s.config.TCPReadBufferSize
+}
+
+// watchPeerClose cancels the connection context if the peer closes the
+// connection while a request is processed. It peeks at the read buffer, so
+// nothing is consumed; once more data arrives the peer is still there and the
+// watch ends. A client that half-closes after its request is treated as gone.
+// The returned function ends the watch and must be called before the next read
+func watchPeerClose(conn net.Conn, buffered *bufio.Reader, cancel context.CancelFunc) func() {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := buffered.Peek(1); err != nil && !isTimeout(err) {
+			cancel()
+		}
+	}()
+
+	return func() {
+		// An expired deadline wakes the pending peek without closing the connection
+		conn.SetReadDeadline(time.Now())
+		<-done
+		conn.SetReadDeadline(time.Time{})
 	}
 }
 
@@ -630,46 +2139,137 @@ func (s *Server) startTLS() error {
 func (s *Server) handleTCPConnection(conn net.Conn, transport string) {
 	defer conn.Close()
 
+	if !s.acquireConnection() {
+		log.Printf("%s connection from %s rejected: connection limit reached", transport, conn.RemoteAddr())
+		rejectConnection(conn, types.NewServerError(TooManyConnectionsCode, "Too many connections"))
+		return
+	}
+	defer s.releaseConnection()
+
+	s.processor.stats.connectionOpened()
+	defer s.processor.stats.connectionClosed()
+
 	ctx := ProcessingContext{
 		Transport:      transport,
 		RemoteAddr:     conn.RemoteAddr().String(),
 		HTTPRequest:    nil,
 		ServiceName:    s.config.ServiceName,
 		ServiceVersion: s.config.Version,
+		Connection:     types.NewConnectionState(),
 	}
 
-	decoder := json.NewDecoder(conn)
+	ctx.Connection.Set(connectionFramingsKey, streamFramings)
+
+	// Requests get a context cancelled when the connection goes away; while a
+	// request is processed the connection is watched for the peer closing it
+	connCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx.Context = connCtx
+
+	// Buffered reads cut syscalls for large messages; framing switches keep the buffer
+	buffered := bufio.NewReaderSize(conn, s.tcpReadBufferSize())
+	reader := newStreamReader(buffered, s.config.MaxRequestBytes)
 	encoder := json.NewEncoder(conn)
 
+	if s.config.SendConnectBanner {
+		if err := encoder.Encode(s.connectBanner()); err != nil {
+			log.Printf("TCP banner write error: %v", err)
+			return
+		}
+	}
+
 	for {
+		// A silent client is disconnected after ConnIdleTimeout; the deadline
+		// is pushed forward before every message
+		if s.config.ConnIdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.config.ConnIdleTimeout))
+		}
+
 		// Read raw JSON message
-		var rawMessage json.RawMessage
-		if err := decoder.Decode(&rawMessage); err != nil {
+		rawMessage, err := reader.read()
+		if err != nil {
 			if err == io.EOF {
 				break
 			}
+			if isTimeout(err) {
+				// Idle connections are closed quietly: this is expected, not a failure
+				break
+			}
+			if errors.Is(err, errMessageTooLarge) {
+				// Поток нельзя синхронизировать после обрезанного сообщения,
+				// поэтому отвечаем ошибкой парсинга и закрываем соединение.
+				// Построчное разбиение (ndjson) продолжает со следующей строки
+				encoder.Encode(&types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewParseError(fmt.Sprintf("Message exceeds %d bytes", s.config.MaxRequestBytes)),
+					ID:      nil,
+				})
+				if reader.recoverable() {
+					continue
+				}
+				break
+			}
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				// A malformed message is not a transport failure: answer it with
+				// a parse error and resume from the next message
+				if err := encoder.Encode(&types.JSONRPCResponse{
+					JSONRPC: "2.0",
+					Error:   types.NewParseError("Invalid JSON: " + syntaxErr.Error()),
+					ID:      nil,
+				}); err != nil {
+					log.Printf("TCP encode error: %v", err)
+					break
+				}
+				if err := reader.skipMalformed(syntaxErr); err != nil {
+					break
+				}
+				continue
+			}
 			log.Printf("TCP decode error: %v", err)
 			break
 		}
 
 		// Process JSON-RPC request
+		stopWatch := watchPeerClose(conn, buffered, cancel)
 		var result interface{}
 		trimmed := strings.TrimSpace(string(rawMessage))
 
 		if strings.HasPrefix(trimmed, "[") {
 			// Batch request
-			result = s.processor.ProcessBatchRequest(rawMessage, ctx)
+			var err error
+			sink := newStreamBatchSink(conn, nil)
+			sink.observe = s.processor.observeResponseSize
+			result, err = s.processor.StreamBatchRequest(rawMessage, ctx, s.config.BatchStreamThreshold, sink)
+			if err != nil {
+				log.Printf("TCP batch write error: %v", err)
+				break
+			}
 		} else {
-			// Single request
-			result = s.processor.ProcessSingleRequest(rawMessage, ctx)
+			// Single request. A notification yields a nil *JSONRPCResponse, which
+			// must not end up in result: a non-nil interface would be sent as "null"
+			if response := s.processor.ProcessSingleRequest(rawMessage, ctx); response != nil {
+				result = response
+			}
 		}
+		stopWatch()
 
 		// Send response (skip if notification)
 		if result != nil {
-			if err := encoder.Encode(result); err != nil {
+			data, err := s.processor.encodeResult(result)
+			if err != nil {
 				log.Printf("TCP encode error: %v", err)
 				break
 			}
+			if _, err := conn.Write(append(data, '\n')); err != nil {
+				log.Printf("TCP write error: %v", err)
+				break
+			}
+		}
+
+		// rpc.hello may have negotiated another framing for the following messages
+		if settings, ok := connectionHello(ctx.Connection); ok {
+			reader.switchTo(settings.Framing)
 		}
 	}
 }
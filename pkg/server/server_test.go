@@ -1,16 +1,32 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"streaming-server/pkg/dispatcher"
+	"streaming-server/pkg/handlers"
 	"streaming-server/pkg/middleware"
 	"streaming-server/pkg/types"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -252,6 +268,108 @@ func TestJSONRPCProcessor_ProcessBatchRequest_AllNotifications(t *testing.T) {
 	assert.Nil(t, result)
 }
 
+func TestJSONRPCProcessor_CheckBatchResponses(t *testing.T) {
+	requestData := `[
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"a"},"id":1},
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"notify"}},
+		{"jsonrpc":"2.0","method":"missing","id":2},
+		{"jsonrpc":"1.0","method":"echo"},
+		42
+	]`
+	ctx := ProcessingContext{Transport: "HTTP", RemoteAddr: "127.0.0.1"}
+
+	var logs bytes.Buffer
+	var logsMu sync.Mutex
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		logsMu.Lock()
+		defer logsMu.Unlock()
+		return logs.Write(p)
+	}))
+	defer log.SetOutput(os.Stderr)
+
+	server, _ := setupTestServer(t)
+	server.processor.SetBatchResponseCheck(true)
+
+	t.Run("matching batch", func(t *testing.T) {
+		result := server.processor.ProcessBatchRequest([]byte(requestData), ctx)
+		responses, ok := result.([]*types.JSONRPCResponse)
+		require.True(t, ok)
+		require.Len(t, responses, 4, "only the valid notification gets no response")
+
+		logsMu.Lock()
+		defer logsMu.Unlock()
+		assert.NotContains(t, logs.String(), "expected")
+	})
+
+	t.Run("mismatched batch", func(t *testing.T) {
+		var rawRequests []json.RawMessage
+		require.NoError(t, json.Unmarshal([]byte(requestData), &rawRequests))
+		responses := []*types.JSONRPCResponse{{JSONRPC: "2.0", Result: "a", ID: float64(1)}}
+
+		assert.False(t, server.processor.checkBatchResponseCount(rawRequests, responses))
+
+		logsMu.Lock()
+		defer logsMu.Unlock()
+		assert.Contains(t, logs.String(), "WARNING: batch of 5 requests expected 4 responses, got 1")
+	})
+}
+
+func TestJSONRPCProcessor_ProcessBatchRequest_StrictBatchDuplicateID(t *testing.T) {
+	requestData := `[
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"first"},"id":1},
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"second"},"id":1},
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"string id"},"id":"1"},
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"null a"},"id":null},
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"null b"},"id":null},
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"other"},"id":2}
+	]`
+
+	ctx := ProcessingContext{
+		Transport:   "HTTP",
+		RemoteAddr:  "127.0.0.1",
+		ServiceName: "test-service",
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		server, _ := setupTestServer(t)
+		server.processor.SetStrictBatch(true)
+
+		result := server.processor.ProcessBatchRequest([]byte(requestData), ctx)
+
+		responses, ok := result.([]*types.JSONRPCResponse)
+		require.True(t, ok)
+		require.Len(t, responses, 4, "null IDs are notifications and get no response")
+
+		assert.Nil(t, responses[0].Error)
+		assert.Equal(t, float64(1), responses[0].ID)
+
+		require.NotNil(t, responses[1].Error)
+		assert.Equal(t, types.InvalidRequest, responses[1].Error.Code)
+		assert.Equal(t, "duplicate id in batch", responses[1].Error.Data)
+		assert.Equal(t, float64(1), responses[1].ID)
+
+		// A string ID does not collide with the numeric one
+		assert.Nil(t, responses[2].Error)
+		assert.Equal(t, "1", responses[2].ID)
+
+		assert.Nil(t, responses[3].Error)
+		assert.Equal(t, float64(2), responses[3].ID)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		server, _ := setupTestServer(t)
+
+		result := server.processor.ProcessBatchRequest([]byte(requestData), ctx)
+
+		responses, ok := result.([]*types.JSONRPCResponse)
+		require.True(t, ok)
+		require.Len(t, responses, 4)
+		for _, response := range responses {
+			assert.Nil(t, response.Error)
+		}
+	})
+}
+
 func TestServer_handleHTTPRequest_ValidRequest(t *testing.T) {
 	server, _ := setupTestServer(t)
 
@@ -408,6 +526,54 @@ func TestServer_handleHealth(t *testing.T) {
 	assert.Contains(t, response, "timestamp")
 	assert.Equal(t, "integration-test-server", response["service"])
 	assert.Equal(t, "test-1.0.0", response["version"])
+
+	// The default body stays minimal
+	assert.Len(t, response, 4)
+}
+
+func TestServer_handleHealth_Verbose(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.processor.stats.connectionOpened()
+	defer server.processor.stats.connectionClosed()
+
+	req := httptest.NewRequest("GET", "/health?verbose=true", nil)
+	w := httptest.NewRecorder()
+
+	server.handleHealth(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Status        string                  `json:"status"`
+		Timestamp     string                  `json:"timestamp"`
+		Service       string                  `json:"service"`
+		Version       string                  `json:"version"`
+		UptimeSeconds *float64                `json:"uptime_seconds"`
+		Handlers      int                     `json:"handlers"`
+		Connections   map[string]int64        `json:"connections"`
+		Logger        middleware.LoggerHealth `json:"logger"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Equal(t, "healthy", response.Status)
+	assert.NotEmpty(t, response.Timestamp)
+	assert.Equal(t, "integration-test-server", response.Service)
+	require.NotNil(t, response.UptimeSeconds)
+	assert.GreaterOrEqual(t, *response.UptimeSeconds, 0.0)
+	assert.Equal(t, server.dispatcher.HandlerCount(), response.Handlers)
+	assert.Equal(t, int64(1), response.Connections["active"])
+	assert.True(t, response.Logger.Enabled)
+	assert.Equal(t, middleware.LogDestinationStdout, response.Logger.Destination)
+	assert.True(t, response.Logger.Reachable)
+
+	// Invalid or false values keep the minimal body
+	for _, query := range []string{"verbose=false", "verbose=yes"} {
+		w = httptest.NewRecorder()
+		server.handleHealth(w, httptest.NewRequest("GET", "/health?"+query, nil))
+		var minimal map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &minimal))
+		assert.NotContains(t, minimal, "logger", query)
+	}
 }
 
 func TestConfig_Validation(t *testing.T) {
@@ -507,3 +673,2186 @@ func BenchmarkServer_handleHTTPRequest(b *testing.B) {
 		server.handleHTTPRequest(w, req)
 	}
 }
+
+func TestJSONRPCProcessor_NotificationErrorHook(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	server.RegisterHandler("failing_notification", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, errors.New("notification failed")
+	})
+
+	var hookMethods []string
+	var hookErrors []error
+	server.SetNotificationErrorHook(func(req *types.JSONRPCRequest, ctx *types.RequestContext, err error) {
+		hookMethods = append(hookMethods, req.Method)
+		hookErrors = append(hookErrors, err)
+	})
+
+	ctx := ProcessingContext{
+		Transport:   "HTTP",
+		RemoteAddr:  "127.0.0.1",
+		ServiceName: "test-service",
+	}
+
+	// Handler returning a Go error
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"failing_notification"}`), ctx)
+	assert.Nil(t, response)
+
+	// Handler returning a JSON-RPC error response
+	response = server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"calculate","params":{"operation":"divide","a":1,"b":0}}`), ctx)
+	assert.Nil(t, response)
+
+	// Successful notifications do not reach the hook
+	response = server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"echo"}`), ctx)
+	assert.Nil(t, response)
+
+	require.Len(t, hookErrors, 2)
+	assert.Equal(t, []string{"failing_notification", "calculate"}, hookMethods)
+	assert.EqualError(t, hookErrors[0], "notification failed")
+
+	var rpcErr *types.RPCError
+	require.True(t, errors.As(hookErrors[1], &rpcErr))
+	assert.Equal(t, types.InvalidParams, rpcErr.Code)
+}
+
+func TestJSONRPCProcessor_NotificationErrorWithoutHook(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	ctx := ProcessingContext{Transport: "HTTP", RemoteAddr: "127.0.0.1"}
+
+	// Without a hook failing notifications are still silently discarded
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"test_error"}`), ctx)
+	assert.Nil(t, response)
+}
+
+func TestServer_handleHTTPRequest_RequireJSONContentType(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.config.RequireJSONContentType = true
+
+	tests := []struct {
+		name           string
+		contentType    string
+		expectedStatus int
+	}{
+		{"application/json", "application/json", http.StatusOK},
+		{"application/json with charset", "application/json; charset=utf-8", http.StatusOK},
+		{"mixed case", "Application/JSON", http.StatusOK},
+		{"application/json-rpc", "application/json-rpc", http.StatusOK},
+		{"text/plain", "text/plain", http.StatusUnsupportedMediaType},
+		{"form encoded", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"missing", "", http.StatusUnsupportedMediaType},
+		{"malformed", "application/json;;", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestBody := `{"jsonrpc":"2.0","method":"echo","params":{"message":"test"},"id":"test-1"}`
+			req := httptest.NewRequest("POST", "/rpc", strings.NewReader(requestBody))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			server.handleHTTPRequest(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assert.Equal(t, 0, w.Body.Len())
+			}
+		})
+	}
+}
+
+func TestServer_handleHTTPRequest_ContentTypeNotRequiredByDefault(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	requestBody := `{"jsonrpc":"2.0","method":"echo","params":{"message":"test"},"id":"test-1"}`
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(requestBody))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+
+	server.handleHTTPRequest(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestJSONRPCProcessor_DebugInfo(t *testing.T) {
+	server, _ := setupTestServer(t)
+	ctx := ProcessingContext{Transport: "TCP", RemoteAddr: "127.0.0.1"}
+	requestData := []byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"test"},"id":1}`)
+
+	// Disabled by default
+	response := server.processor.ProcessSingleRequest(requestData, ctx)
+	require.NotNil(t, response)
+	assert.Nil(t, response.Debug)
+
+	data, err := json.Marshal(response)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "_debug")
+
+	server.processor.SetDebugInfo(true)
+
+	response = server.processor.ProcessSingleRequest(requestData, ctx)
+	require.NotNil(t, response)
+	require.NotNil(t, response.Debug)
+	assert.Equal(t, "TCP", response.Debug.Transport)
+	assert.Equal(t, "echo", response.Debug.Handler)
+	assert.NotEmpty(t, response.Debug.Duration)
+	assert.NotEmpty(t, response.Debug.RequestID)
+
+	data, err = json.Marshal(response)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"_debug"`)
+}
+
+func TestServer_handleHTTPRequest_DebugHeaders(t *testing.T) {
+	requestBody := `{"jsonrpc":"2.0","method":"echo","params":{"message":"test"},"id":"test-1"}`
+
+	t.Run("disabled", func(t *testing.T) {
+		server, _ := setupTestServer(t)
+
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(requestBody))
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Debug-Request-ID"))
+		assert.NotContains(t, w.Body.String(), "_debug")
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		server, _ := setupTestServer(t)
+		server.processor.SetDebugInfo(true)
+
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(requestBody))
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "HTTP", w.Header().Get("X-Debug-Transport"))
+		assert.Equal(t, "echo", w.Header().Get("X-Debug-Handler"))
+		assert.NotEmpty(t, w.Header().Get("X-Debug-Duration"))
+		assert.NotEmpty(t, w.Header().Get("X-Debug-Request-ID"))
+
+		// HTTP responses carry debug info in headers only
+		assert.NotContains(t, w.Body.String(), "_debug")
+	})
+}
+
+func TestJSONRPCProcessor_DisableBatchOnTransports(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.processor.DisableBatchOnTransports("tcp")
+
+	batch := []byte(`[{"jsonrpc":"2.0","method":"echo","id":1},{"jsonrpc":"2.0","method":"echo","id":2}]`)
+	single := []byte(`{"jsonrpc":"2.0","method":"echo","id":1}`)
+
+	tcpCtx := ProcessingContext{Transport: "TCP", RemoteAddr: "127.0.0.1"}
+	httpCtx := ProcessingContext{Transport: "HTTP", RemoteAddr: "127.0.0.1"}
+
+	// Batch over a disabled transport is rejected as a whole
+	result := server.processor.ProcessBatchRequest(batch, tcpCtx)
+	response, ok := result.(*types.JSONRPCResponse)
+	require.True(t, ok)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+	assert.Equal(t, "Batch not supported on this transport", response.Error.Data)
+	assert.Nil(t, response.ID)
+
+	// Streaming path applies the same rule
+	result, err := server.processor.StreamBatchRequest(batch, tcpCtx, 1, newStreamBatchSink(&recordingWriter{}, nil))
+	require.NoError(t, err)
+	response, ok = result.(*types.JSONRPCResponse)
+	require.True(t, ok)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+
+	// Single requests on the same transport still work
+	response = server.processor.ProcessSingleRequest(single, tcpCtx)
+	require.NotNil(t, response)
+	assert.Nil(t, response.Error)
+
+	// Other transports keep batch support
+	result = server.processor.ProcessBatchRequest(batch, httpCtx)
+	responses, ok := result.([]*types.JSONRPCResponse)
+	require.True(t, ok)
+	assert.Len(t, responses, 2)
+}
+
+func TestServer_ConnectBanner_TCP(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			server, _ := setupTestServer(t)
+			server.config.SendConnectBanner = enabled
+
+			serverConn, clientConn := net.Pipe()
+			defer clientConn.Close()
+			go server.handleTCPConnection(serverConn, "TCP")
+
+			reader := bufio.NewReader(clientConn)
+			clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			if enabled {
+				line, err := reader.ReadBytes('\n')
+				require.NoError(t, err)
+
+				var banner types.JSONRPCRequest
+				require.NoError(t, json.Unmarshal(line, &banner))
+				assert.Equal(t, "server.banner", banner.Method)
+				assert.True(t, banner.IsNotification())
+
+				var params map[string]interface{}
+				require.NoError(t, json.Unmarshal(banner.Params, &params))
+				assert.Equal(t, "test-1.0.0", params["version"])
+				assert.Contains(t, params["methods"], "echo")
+			}
+
+			// The first message after the optional banner is the response
+			_, err := clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","id":7}` + "\n"))
+			require.NoError(t, err)
+
+			line, err := reader.ReadBytes('\n')
+			require.NoError(t, err)
+
+			var response types.JSONRPCResponse
+			require.NoError(t, json.Unmarshal(line, &response))
+			assert.Equal(t, float64(7), response.ID)
+		})
+	}
+}
+
+func TestServer_ConnectBanner_WebSocket(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			server, _ := setupTestServer(t)
+			server.config.SendConnectBanner = enabled
+
+			httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+			defer httpServer.Close()
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+			require.NoError(t, err)
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			if enabled {
+				var banner types.JSONRPCRequest
+				require.NoError(t, conn.ReadJSON(&banner))
+				assert.Equal(t, "server.banner", banner.Method)
+				assert.Nil(t, banner.ID)
+			}
+
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"echo","id":7}`)))
+
+			var response types.JSONRPCResponse
+			require.NoError(t, conn.ReadJSON(&response))
+			assert.Equal(t, float64(7), response.ID)
+		})
+	}
+}
+
+func TestServer_DebugRecentEndpoint(t *testing.T) {
+	server, logger := setupTestServer(t)
+	server = NewServer(Config{ServiceName: "test", RecentRequestsSize: 2}, logger)
+	mux := server.newHTTPMux()
+
+	for _, id := range []string{"1", "2", "3"} {
+		body := `{"jsonrpc":"2.0","method":"echo","id":"` + id + `"}`
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/debug/recent", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var summaries []middleware.RequestSummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summaries))
+	assert.Len(t, summaries, 2)
+	assert.Equal(t, "echo", summaries[0].Method)
+
+	// Endpoint is not registered unless enabled
+	server, _ = setupTestServer(t)
+	w = httptest.NewRecorder()
+	server.newHTTPMux().ServeHTTP(w, httptest.NewRequest("GET", "/debug/recent", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServer_DebugSlowestEndpoint(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", SlowestRequestsSize: 2}, logger)
+	server.RegisterHandler("nap", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		time.Sleep(20 * time.Millisecond)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "rested", ID: req.ID}, nil
+	})
+	mux := server.newHTTPMux()
+
+	for _, method := range []string{"echo", "nap", "echo", "echo"} {
+		body := `{"jsonrpc":"2.0","method":"` + method + `","id":1}`
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/debug/slowest", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var requests []middleware.SlowRequest
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &requests))
+	require.Len(t, requests, 2)
+	assert.Equal(t, "nap", requests[0].Method)
+	assert.GreaterOrEqual(t, requests[0].DurationMs, float64(20))
+
+	// Endpoint is not registered unless enabled
+	server, _ = setupTestServer(t)
+	w = httptest.NewRecorder()
+	server.newHTTPMux().ServeHTTP(w, httptest.NewRequest("GET", "/debug/slowest", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// paddedEchoRequest builds an echo request of exactly size bytes
+func paddedEchoRequest(t *testing.T, size int) []byte {
+	const prefix = `{"jsonrpc":"2.0","method":"echo","params":{"pad":"`
+	const suffix = `"},"id":1}`
+	padding := size - len(prefix) - len(suffix)
+	require.GreaterOrEqual(t, padding, 0)
+	return []byte(prefix + strings.Repeat("x", padding) + suffix)
+}
+
+func TestServer_MaxRequestBytes_HTTP(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.config.MaxRequestBytes = 256
+
+	// At the limit the request is processed normally
+	req := httptest.NewRequest("POST", "/rpc", bytes.NewReader(paddedEchoRequest(t, 256)))
+	w := httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(t, response.Error)
+
+	// One byte over the limit is rejected
+	req = httptest.NewRequest("POST", "/rpc", bytes.NewReader(paddedEchoRequest(t, 257)))
+	w = httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	response = types.JSONRPCResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+	assert.Nil(t, response.ID)
+}
+
+func TestServer_MaxRequestBytes_TCP(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.config.MaxRequestBytes = 256
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go server.handleTCPConnection(serverConn, "TCP")
+
+	reader := bufio.NewReader(clientConn)
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Messages at the limit keep working, including several on one connection
+	for i := 0; i < 3; i++ {
+		_, err := clientConn.Write(paddedEchoRequest(t, 256))
+		require.NoError(t, err)
+
+		line, err := reader.ReadBytes('\n')
+		require.NoError(t, err)
+
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(line, &response))
+		assert.Nil(t, response.Error)
+	}
+
+	// An oversized message yields a parse error and closes the connection
+	go clientConn.Write(paddedEchoRequest(t, 257))
+
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(line, &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.ParseError, response.Error.Code)
+
+	_, err = reader.ReadBytes('\n')
+	assert.Error(t, err)
+}
+
+func TestServer_MaxRequestBytes_TCP_Fragmented(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.config.MaxRequestBytes = 256
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go server.handleTCPConnection(serverConn, "TCP")
+
+	reader := bufio.NewReader(clientConn)
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// A request split into small fragments is reassembled
+	request := []byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"fragmented"},"id":1}` + "\n")
+	for start := 0; start < len(request); start += 7 {
+		end := start + 7
+		if end > len(request) {
+			end = len(request)
+		}
+		_, err := clientConn.Write(request[start:end])
+		require.NoError(t, err)
+	}
+
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(line, &response))
+	assert.Nil(t, response.Error)
+	assert.Equal(t, float64(1), response.ID)
+
+	// A message that never terminates is cut off at the limit instead of
+	// being buffered forever
+	go func() {
+		clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"`))
+		chunk := []byte(strings.Repeat("x", 32))
+		for i := 0; i < 64; i++ {
+			if _, err := clientConn.Write(chunk); err != nil {
+				return
+			}
+		}
+	}()
+
+	line, err = reader.ReadBytes('\n')
+	require.NoError(t, err)
+	response = types.JSONRPCResponse{}
+	require.NoError(t, json.Unmarshal(line, &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.ParseError, response.Error.Code)
+	assert.Nil(t, response.ID)
+
+	_, err = reader.ReadBytes('\n')
+	assert.Error(t, err, "the connection is closed")
+}
+
+func TestServer_RawHandler_ReceivesOriginalBytes(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	var received []string
+	server.GetDispatcher().RegisterRawHandler("verify", func(raw json.RawMessage, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		received = append(received, string(raw))
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: len(raw)}, nil
+	})
+
+	// Key order and whitespace must survive, as a signature would cover them
+	single := `{"params": {"z":1, "a":2}, "method":"verify","jsonrpc":"2.0","id":1}`
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(single))
+	w := httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, received, 1)
+	assert.Equal(t, single, received[0])
+
+	// Each batch element receives only its own bytes
+	first := `{"jsonrpc":"2.0","method":"verify","id":2}`
+	second := `{"id":3, "method":"verify", "jsonrpc":"2.0", "params":["x"]}`
+	req = httptest.NewRequest("POST", "/rpc", strings.NewReader("["+first+", "+second+"]"))
+	w = httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, received, 3)
+	assert.Equal(t, first, received[1])
+	assert.Equal(t, second, received[2])
+}
+
+func TestServer_HandlerTimeout(t *testing.T) {
+	logger, err := middleware.NewLogger(middleware.LoggingConfig{Enabled: false})
+	require.NoError(t, err)
+
+	deadlineHandler := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		deadline, ok := ctx.Context().Deadline()
+		var remaining time.Duration
+		if ok {
+			remaining = time.Until(deadline)
+		}
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: remaining.Seconds(), ID: req.ID}, nil
+	}
+	remaining := func(server *Server) float64 {
+		response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"deadline","id":1}`), ProcessingContext{Transport: "TCP"})
+		require.NotNil(t, response)
+		require.Nil(t, response.Error)
+		return response.Result.(float64)
+	}
+
+	t.Run("default applies", func(t *testing.T) {
+		server := NewServer(Config{ServiceName: "test"}, logger)
+		server.RegisterHandler("deadline", deadlineHandler)
+		assert.InDelta(t, DefaultHandlerTimeout.Seconds(), remaining(server), 1)
+	})
+
+	t.Run("negative disables", func(t *testing.T) {
+		server := NewServer(Config{ServiceName: "test", HandlerTimeout: -1}, logger)
+		server.RegisterHandler("deadline", deadlineHandler)
+		assert.Zero(t, remaining(server))
+	})
+
+	t.Run("per-method override", func(t *testing.T) {
+		server := NewServer(Config{
+			ServiceName:    "test",
+			HandlerTimeout: 20 * time.Millisecond,
+			MethodTimeouts: map[string]time.Duration{"patient": time.Second},
+		}, logger)
+
+		slow := func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			select {
+			case <-ctx.Context().Done():
+				return nil, ctx.Context().Err()
+			case <-time.After(100 * time.Millisecond):
+				return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "done", ID: req.ID}, nil
+			}
+		}
+		server.RegisterHandler("hasty", slow)
+		server.RegisterHandler("patient", slow)
+
+		response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"hasty","id":1}`), ProcessingContext{Transport: "HTTP"})
+		require.NotNil(t, response.Error)
+		assert.Equal(t, dispatcher.HandlerTimeoutCode, response.Error.Code)
+
+		response = server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"patient","id":2}`), ProcessingContext{Transport: "HTTP"})
+		assert.Nil(t, response.Error)
+		assert.Equal(t, "done", response.Result)
+	})
+}
+
+func TestServer_GRPCTimeoutHeader_HTTP(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.RegisterHandler("slow", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		select {
+		case <-ctx.Context().Done():
+			return nil, ctx.Context().Err()
+		case <-time.After(time.Second):
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "done", ID: req.ID}, nil
+		}
+	})
+
+	call := func(timeout string) *types.JSONRPCResponse {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"slow","id":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("grpc-timeout", timeout)
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+		return &response
+	}
+
+	start := time.Now()
+	response := call("50m")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, dispatcher.HandlerTimeoutCode, response.Error.Code)
+
+	response = call("100ms")
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+}
+
+func TestServer_RPCDiscover(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"rpc.discover","id":1}`))
+	w := httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Result struct {
+			Methods []string                          `json:"methods"`
+			Info    map[string]dispatcher.HandlerInfo `json:"info"`
+		} `json:"result"`
+		Error *types.RPCError `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Nil(t, response.Error)
+
+	for _, method := range []string{"echo", "calculate", "status", "time", "rpc.discover"} {
+		assert.Contains(t, response.Result.Methods, method)
+	}
+	assert.NotContains(t, response.Result.Methods, "generate", "generate is opt-in")
+	assert.True(t, sort.StringsAreSorted(response.Result.Methods))
+	assert.NotEmpty(t, response.Result.Info["rpc.discover"].Description)
+	assert.NotContains(t, response.Result.Info, "echo", "methods without info are omitted")
+
+	// Other reserved names are still rejected
+	req = httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"rpc.other","id":2}`))
+	w = httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+
+	var rejected types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rejected))
+	require.NotNil(t, rejected.Error)
+	assert.Equal(t, types.MethodNotFound, rejected.Error.Code)
+}
+
+func TestServer_NotificationOnlyMethod(t *testing.T) {
+	server, _ := setupTestServer(t)
+	received := make(chan string, 1)
+	server.RegisterHandlerWithInfo("audit", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		received <- string(req.Params)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "recorded", ID: req.ID}, nil
+	}, dispatcher.HandlerInfo{NotificationOnly: true})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+		return w
+	}
+
+	w := post(`{"jsonrpc":"2.0","method":"audit","params":{"event":"login"}}`)
+	assert.Empty(t, strings.TrimSpace(w.Body.String()), "notifications get no response")
+	select {
+	case params := <-received:
+		assert.JSONEq(t, `{"event":"login"}`, params)
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification was not processed")
+	}
+
+	w = post(`{"jsonrpc":"2.0","method":"audit","params":{"event":"login"},"id":1}`)
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidRequest, response.Error.Code)
+	assert.Equal(t, "method is notification-only", response.Error.Data)
+	assert.Equal(t, float64(1), response.ID)
+	assert.Empty(t, received)
+}
+
+func TestServer_RPCDiscover_HandlerInfo(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.RegisterHandlerWithInfo("audit", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, nil
+	}, dispatcher.HandlerInfo{Description: "Records an audit event", NotificationOnly: true})
+
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"rpc.discover","id":1}`), ProcessingContext{Transport: "HTTP"})
+	require.NotNil(t, response)
+	require.Nil(t, response.Error)
+
+	result := response.Result.(map[string]interface{})
+	info := result["info"].(map[string]dispatcher.HandlerInfo)
+	assert.Equal(t, dispatcher.HandlerInfo{Description: "Records an audit event", NotificationOnly: true}, info["audit"])
+}
+
+func TestServer_RPCExamples(t *testing.T) {
+	server, _ := setupTestServer(t)
+	require.NoError(t, server.RegisterExample("echo",
+		json.RawMessage(`{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":1}`),
+		json.RawMessage(`{"jsonrpc":"2.0","result":{"echo":{"message":"hi"}},"id":1}`),
+	))
+	require.NoError(t, server.RegisterExample("time",
+		json.RawMessage(`{"jsonrpc":"2.0","method":"time","id":1}`),
+		json.RawMessage(`{"jsonrpc":"2.0","result":{"unix":1700000000},"id":1}`),
+	))
+	assert.Error(t, server.RegisterExample("missing", json.RawMessage(`{}`), nil))
+
+	fetch := func(params string) (map[string][]dispatcher.Example, *types.RPCError) {
+		request := `{"jsonrpc":"2.0","method":"rpc.examples","id":1}`
+		if params != "" {
+			request = `{"jsonrpc":"2.0","method":"rpc.examples","params":` + params + `,"id":1}`
+		}
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(request))
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+
+		var response struct {
+			Result struct {
+				Examples map[string][]dispatcher.Example `json:"examples"`
+			} `json:"result"`
+			Error *types.RPCError `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Result.Examples, response.Error
+	}
+
+	all, rpcErr := fetch("")
+	require.Nil(t, rpcErr)
+	assert.Len(t, all, 2)
+	require.Len(t, all["echo"], 1)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":1}`, string(all["echo"][0].Request))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"echo":{"message":"hi"}},"id":1}`, string(all["echo"][0].Response))
+
+	single, rpcErr := fetch(`{"method":"time"}`)
+	require.Nil(t, rpcErr)
+	assert.Len(t, single, 1)
+	assert.Contains(t, single, "time")
+
+	none, rpcErr := fetch(`{"method":"status"}`)
+	require.Nil(t, rpcErr)
+	assert.Empty(t, none)
+
+	_, rpcErr = fetch(`{"method":"missing"}`)
+	require.NotNil(t, rpcErr)
+	assert.Equal(t, types.InvalidParams, rpcErr.Code)
+}
+
+func TestServer_MarkIdempotent(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	var calls int32
+	server.RegisterHandler("transfer", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: atomic.AddInt32(&calls, 1), ID: req.ID}, nil
+	})
+	server.MarkIdempotent("transfer")
+
+	post := func(key string, id int) types.JSONRPCResponse {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(fmt.Sprintf(`{"jsonrpc":"2.0","method":"transfer","id":%d}`, id)))
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	missing := post("", 1)
+	require.NotNil(t, missing.Error)
+	assert.Equal(t, types.InvalidParams, missing.Error.Code)
+
+	first := post("order-42", 2)
+	retry := post("order-42", 3)
+	assert.Nil(t, first.Error)
+	assert.Equal(t, first.Result, retry.Result)
+	assert.Equal(t, float64(3), retry.ID)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Unmarked methods do not need a key
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"time","id":4}`))
+	w := httptest.NewRecorder()
+	server.handleHTTPRequest(w, req)
+	assert.NotContains(t, w.Body.String(), "error")
+}
+
+func TestServer_ValidateParamsSchema(t *testing.T) {
+	logger, err := middleware.NewLogger(middleware.LoggingConfig{Enabled: false})
+	require.NoError(t, err)
+
+	server := NewServer(Config{ServiceName: "test", ValidateParamsSchema: true}, logger)
+	server.RegisterHandlerWithInfo("calculate", handlers.CalculateHandler, dispatcher.HandlerInfo{
+		Description:  "Performs arithmetic",
+		ParamsSchema: json.RawMessage(`{"type":"object","required":["operation","a","b"],"properties":{"a":{"type":"number"},"b":{"type":"number"}}}`),
+	})
+
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"calculate","params":{"operation":"add","a":"x"},"id":1}`), ProcessingContext{Transport: "HTTP"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InvalidParams, response.Error.Code)
+	assert.NotEmpty(t, response.Error.Data)
+
+	response = server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"calculate","params":{"operation":"add","a":1,"b":2},"id":2}`), ProcessingContext{Transport: "HTTP"})
+	assert.Nil(t, response.Error)
+}
+
+func TestServer_EnforceResultSchema(t *testing.T) {
+	logger, err := middleware.NewLogger(middleware.LoggingConfig{Enabled: false})
+	require.NoError(t, err)
+
+	server := NewServer(Config{ServiceName: "test", EnforceResultSchema: true}, logger)
+	server.RegisterHandlerWithInfo("calculate", handlers.CalculateHandler, dispatcher.HandlerInfo{
+		ResultSchema: json.RawMessage(`{"type":"object","required":["result"],"properties":{"result":{"type":"number"}}}`),
+	})
+	server.RegisterHandlerWithInfo("legacy", handlers.EchoHandler, dispatcher.HandlerInfo{
+		ResultSchema: json.RawMessage(`{"type":"object","required":["result"]}`),
+	})
+
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"calculate","params":{"operation":"add","a":1,"b":2},"id":1}`), ProcessingContext{Transport: "HTTP"})
+	assert.Nil(t, response.Error)
+
+	response = server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"legacy","params":{"message":"hi"},"id":2}`), ProcessingContext{Transport: "HTTP"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InternalError, response.Error.Code)
+}
+
+func TestServer_OnShutdown(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.config.ShutdownTimeout = time.Second
+
+	var order []string
+	errFirst := errors.New("deregister failed")
+	errThird := errors.New("flush failed")
+
+	server.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "deregister")
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok, "hooks receive the drain timeout as deadline")
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+		return errFirst
+	})
+	server.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "drain")
+		return nil
+	})
+	server.OnShutdown(func(ctx context.Context) error {
+		order = append(order, "flush")
+		return errThird
+	})
+
+	err := server.Stop()
+	assert.Equal(t, []string{"deregister", "drain", "flush"}, order, "hooks run in registration order even after failures")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errFirst)
+	assert.ErrorIs(t, err, errThird)
+}
+
+func TestServer_Stop_WithoutHooks(t *testing.T) {
+	server, _ := setupTestServer(t)
+	assert.NoError(t, server.Stop())
+}
+
+// waitForSocket waits until a Unix socket accepts connections
+func waitForSocket(t *testing.T, path string) {
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServer_UnixSocket(t *testing.T) {
+	server, _ := setupTestServer(t)
+	path := filepath.Join(t.TempDir(), "rpc.sock")
+	server.config.UnixSocketAddr = path
+
+	// A stale socket from a previous run is replaced
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- server.startUnix() }()
+	waitForSocket(t, path)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(unixSocketMode), info.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = conn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"local"},"id":1}` + "\n"))
+	require.NoError(t, err)
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	require.NoError(t, err)
+
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(line, &response))
+	assert.Nil(t, response.Error)
+	assert.Equal(t, float64(1), response.ID)
+
+	require.NoError(t, server.Stop())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("unix listener did not stop")
+	}
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Stop unlinks the socket")
+}
+
+func TestServer_UnixSocket_RefusesNonSocketPath(t *testing.T) {
+	server, _ := setupTestServer(t)
+	path := filepath.Join(t.TempDir(), "not-a-socket")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+	server.config.UnixSocketAddr = path
+
+	assert.ErrorContains(t, server.startUnix(), "not a socket")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestServer_Notify_WebSocket(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	const pushes = 5
+	server.RegisterHandler("subscribe", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		connID := ctx.Connection.ID
+		// Push concurrently with the response write to exercise the per-connection lock
+		go func() {
+			for i := 0; i < pushes; i++ {
+				if err := server.Notify(connID, "ticker.update", map[string]int{"seq": i}); err != nil {
+					t.Errorf("notify failed: %v", err)
+				}
+			}
+		}()
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: connID, ID: req.ID}, nil
+	})
+
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"subscribe","id":1}`)))
+
+	var connID string
+	var seqs []int
+	for connID == "" || len(seqs) < pushes {
+		var msg struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result string          `json:"result"`
+			ID     interface{}     `json:"id"`
+		}
+		require.NoError(t, conn.ReadJSON(&msg))
+
+		if msg.ID != nil {
+			connID = msg.Result
+			continue
+		}
+		assert.Equal(t, "ticker.update", msg.Method)
+		var params struct {
+			Seq int `json:"seq"`
+		}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		seqs = append(seqs, params.Seq)
+	}
+
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, seqs)
+	assert.Equal(t, []string{connID}, server.Connections().IDs())
+
+	// Direct push from outside any handler
+	require.NoError(t, server.Notify(connID, "server.message", "hello"))
+	var push types.JSONRPCRequest
+	require.NoError(t, conn.ReadJSON(&push))
+	assert.Equal(t, "server.message", push.Method)
+	assert.JSONEq(t, `"hello"`, string(push.Params))
+	assert.Nil(t, push.ID)
+
+	conn.Close()
+	require.Eventually(t, func() bool { return server.Connections().Count() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, server.Notify(connID, "server.message", nil), ErrConnectionNotFound)
+}
+
+func TestServer_ProgressNotifications_WebSocket(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.RegisterHandler("long", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		for _, percent := range []int{25, 50, 100} {
+			if err := ctx.Notify(types.ProgressMethod, types.ProgressParams{ID: req.ID, Value: percent}); err != nil {
+				return nil, err
+			}
+		}
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "done", ID: req.ID}, nil
+	})
+
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"long","id":"job-1"}`)))
+
+	// Progress arrives in order, before the response, tagged with the request ID
+	for _, percent := range []float64{25, 50, 100} {
+		var progress struct {
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+			ID     interface{}            `json:"id"`
+		}
+		require.NoError(t, conn.ReadJSON(&progress))
+		assert.Equal(t, types.ProgressMethod, progress.Method)
+		assert.Nil(t, progress.ID, "progress is sent as a notification")
+		assert.Equal(t, map[string]interface{}{"id": "job-1", "value": percent}, progress.Params)
+	}
+
+	var response types.JSONRPCResponse
+	require.NoError(t, conn.ReadJSON(&response))
+	assert.Nil(t, response.Error)
+	assert.Equal(t, "done", response.Result)
+	assert.Equal(t, "job-1", response.ID)
+}
+
+func TestServer_ProgressNotifications_Unsupported(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.RegisterHandler("long", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		err := ctx.Notify(types.ProgressMethod, types.ProgressParams{ID: req.ID, Value: 50})
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: errors.Is(err, types.ErrNotifyUnsupported), ID: req.ID}, nil
+	})
+
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"long","id":1}`), ProcessingContext{Transport: "HTTP"})
+	require.NotNil(t, response)
+	assert.Equal(t, true, response.Result, "HTTP requests cannot receive progress")
+}
+
+func TestProcessor_MethodNotFoundAsError(t *testing.T) {
+	server, _ := setupTestServer(t)
+	ctx := ProcessingContext{Transport: "HTTP"}
+	request := []byte(`{"jsonrpc":"2.0","method":"missing","id":3}`)
+
+	defaultResponse := server.processor.ProcessSingleRequest(request, ctx)
+
+	server.GetDispatcher().SetMethodNotFoundAsError(true)
+	errorModeResponse := server.processor.ProcessSingleRequest(request, ctx)
+
+	// Unintercepted errors produce the same response on the wire
+	require.NotNil(t, errorModeResponse.Error)
+	assert.Equal(t, types.MethodNotFound, errorModeResponse.Error.Code)
+	assert.Equal(t, defaultResponse.Error, errorModeResponse.Error)
+	assert.Equal(t, float64(3), errorModeResponse.ID)
+}
+
+func TestServer_MaxBatchSize(t *testing.T) {
+	const limit = 3
+
+	newLimitedServer := func(t *testing.T) (*Server, *int32) {
+		server, logger := setupTestServer(t)
+		server = NewServer(Config{ServiceName: "test", MaxBatchSize: limit}, logger)
+
+		var calls int32
+		server.RegisterHandler("count", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			atomic.AddInt32(&calls, 1)
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+		})
+		return server, &calls
+	}
+
+	buildBatch := func(size int) string {
+		elements := make([]string, size)
+		for i := range elements {
+			elements[i] = fmt.Sprintf(`{"jsonrpc":"2.0","method":"count","id":%d}`, i)
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	}
+
+	assertRejected := func(t *testing.T, data []byte, calls *int32) {
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(data, &response))
+		require.NotNil(t, response.Error)
+		assert.Equal(t, types.InvalidRequest, response.Error.Code)
+		assert.Equal(t, "batch too large", response.Error.Data)
+		assert.Nil(t, response.ID)
+		assert.Zero(t, atomic.LoadInt32(calls), "no element may run when the batch is rejected")
+	}
+
+	t.Run("HTTP", func(t *testing.T) {
+		server, calls := newLimitedServer(t)
+
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(buildBatch(limit)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+
+		var responses []types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responses))
+		assert.Len(t, responses, limit)
+		assert.Equal(t, int32(limit), atomic.SwapInt32(calls, 0))
+
+		req = httptest.NewRequest("POST", "/rpc", strings.NewReader(buildBatch(limit+1)))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+
+		assertRejected(t, w.Body.Bytes(), calls)
+	})
+
+	t.Run("TCP", func(t *testing.T) {
+		server, calls := newLimitedServer(t)
+
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+		go server.handleTCPConnection(serverConn, "TCP")
+
+		reader := bufio.NewReader(clientConn)
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		go clientConn.Write([]byte(buildBatch(limit) + "\n"))
+		line, err := reader.ReadBytes('\n')
+		require.NoError(t, err)
+
+		var responses []types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(line, &responses))
+		assert.Len(t, responses, limit)
+		assert.Equal(t, int32(limit), atomic.SwapInt32(calls, 0))
+
+		go clientConn.Write([]byte(buildBatch(limit+1) + "\n"))
+		line, err = reader.ReadBytes('\n')
+		require.NoError(t, err)
+
+		assertRejected(t, line, calls)
+	})
+}
+
+func TestProcessor_TransportFallbacks(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.GetDispatcher().SetTransportFallbackHandler("HTTP", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "proxied " + req.Method, ID: req.ID}, nil
+	})
+
+	request := []byte(`{"jsonrpc":"2.0","method":"upstream.call","id":1}`)
+
+	// The request context carries the transport the request arrived on
+	response := server.processor.ProcessSingleRequest(request, ProcessingContext{Transport: "HTTP", ServiceName: "test"})
+	require.NotNil(t, response)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, "proxied upstream.call", response.Result)
+
+	response = server.processor.ProcessSingleRequest(request, ProcessingContext{Transport: "TCP", ServiceName: "test"})
+	require.NotNil(t, response)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.MethodNotFound, response.Error.Code)
+}
+
+func TestJSONRPCProcessor_ProcessBatchRequest_Concurrent(t *testing.T) {
+	server, logger := setupTestServer(t)
+	// Without a handler timeout panics are not recovered by the dispatcher
+	server = NewServer(Config{ServiceName: "test", BatchConcurrency: 4, HandlerTimeout: -1}, logger)
+
+	var running, maxRunning int32
+	server.RegisterHandler("sleep", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			observed := atomic.LoadInt32(&maxRunning)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+				break
+			}
+		}
+
+		var params struct {
+			Ms int `json:"ms"`
+		}
+		json.Unmarshal(req.Params, &params)
+		time.Sleep(time.Duration(params.Ms) * time.Millisecond)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: params.Ms, ID: req.ID}, nil
+	})
+	server.RegisterHandler("boom", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		panic("boom")
+	})
+
+	// Earlier elements sleep longer so they finish last
+	elements := []string{}
+	for i := 0; i < 8; i++ {
+		elements = append(elements, fmt.Sprintf(`{"jsonrpc":"2.0","method":"sleep","params":{"ms":%d},"id":%d}`, (8-i)*10, i))
+	}
+	elements = append(elements,
+		`{"jsonrpc":"2.0","method":"sleep","params":{"ms":1}}`,
+		`{"jsonrpc":"2.0","method":"boom","id":"panic"}`,
+		`{"jsonrpc":"2.0","method":"boom"}`,
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"last"},"id":"last"}`,
+	)
+	batch := []byte("[" + strings.Join(elements, ",") + "]")
+
+	ctx := ProcessingContext{Transport: "HTTP", RemoteAddr: "127.0.0.1"}
+	for run := 0; run < 3; run++ {
+		result := server.processor.ProcessBatchRequest(batch, ctx)
+
+		responses, ok := result.([]*types.JSONRPCResponse)
+		require.True(t, ok)
+		require.Len(t, responses, 10, "notifications produce no response")
+
+		for i := 0; i < 8; i++ {
+			assert.Equal(t, float64(i), responses[i].ID)
+			assert.Nil(t, responses[i].Error)
+		}
+
+		require.NotNil(t, responses[8].Error)
+		assert.Equal(t, types.InternalError, responses[8].Error.Code)
+		assert.Equal(t, "panic", responses[8].ID)
+
+		assert.Nil(t, responses[9].Error)
+		assert.Equal(t, "last", responses[9].ID)
+	}
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(4), "concurrency is bounded")
+	assert.Greater(t, atomic.LoadInt32(&maxRunning), int32(1), "elements run in parallel")
+}
+
+func benchmarkSlowBatch(b *testing.B, concurrency int) {
+	logger, _ := middleware.NewLogger(middleware.LoggingConfig{Enabled: false})
+	server := NewServer(Config{ServiceName: "bench", BatchConcurrency: concurrency}, logger)
+
+	elements := make([]string, 4)
+	for i := range elements {
+		elements[i] = fmt.Sprintf(`{"jsonrpc":"2.0","method":"test_slow","id":%d}`, i)
+	}
+	batch := []byte("[" + strings.Join(elements, ",") + "]")
+	ctx := ProcessingContext{Transport: "HTTP", RemoteAddr: "127.0.0.1"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		server.processor.ProcessBatchRequest(batch, ctx)
+	}
+}
+
+func BenchmarkJSONRPCProcessor_ProcessBatchRequest_SlowSequential(b *testing.B) {
+	benchmarkSlowBatch(b, 0)
+}
+
+func BenchmarkJSONRPCProcessor_ProcessBatchRequest_SlowConcurrent(b *testing.B) {
+	benchmarkSlowBatch(b, 4)
+}
+
+func TestServer_PrometheusMetricsEndpoint(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", PrometheusMetrics: true}, logger)
+	mux := server.newHTTPMux()
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"echo","params":{"message":"m"},"id":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"calculate","params":{"operation":"divide","a":1,"b":0},"id":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	assert.Contains(t, body, `jsonrpc_requests_total{method="echo",status="success",transport="HTTP"} 3`)
+	assert.Contains(t, body, `jsonrpc_requests_total{method="calculate",status="rpc_error",transport="HTTP"} 1`)
+	assert.Contains(t, body, `jsonrpc_request_duration_seconds_count{method="echo",transport="HTTP"} 3`)
+	assert.Contains(t, body, `jsonrpc_calls_total{kind="request",method="echo"} 3`)
+	assert.Contains(t, body, `jsonrpc_request_size_bytes_count{method="echo"} 3`)
+	assert.Contains(t, body, `jsonrpc_response_size_bytes_count{method="echo"} 3`)
+
+	// Batch responses are measured element by element
+	req = httptest.NewRequest("POST", "/rpc", strings.NewReader(`[
+		{"jsonrpc":"2.0","method":"echo","params":{"message":"m"},"id":3},
+		{"jsonrpc":"2.0","method":"time","id":4}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `jsonrpc_response_size_bytes_count{method="echo"} 4`)
+	assert.Contains(t, w.Body.String(), `jsonrpc_response_size_bytes_count{method="time"} 1`)
+
+	// Failed notifications are counted on the same registry
+	server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"no_such_method"}`), ProcessingContext{Transport: "TCP"})
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `jsonrpc_notification_errors_total{method="no_such_method",transport="TCP"} 1`)
+
+	// Disabled by default
+	server, _ = setupTestServer(t)
+	w = httptest.NewRecorder()
+	server.newHTTPMux().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Nil(t, server.MetricsRegistry())
+}
+
+// concurrencyProbe records how many handler calls run at the same time
+type concurrencyProbe struct {
+	running, max int32
+}
+
+func (c *concurrencyProbe) handler(delay time.Duration) types.Handler {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		current := atomic.AddInt32(&c.running, 1)
+		defer atomic.AddInt32(&c.running, -1)
+		for {
+			observed := atomic.LoadInt32(&c.max)
+			if current <= observed || atomic.CompareAndSwapInt32(&c.max, observed, current) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}
+}
+
+func buildMethodBatch(method string, size int) string {
+	elements := make([]string, size)
+	for i := range elements {
+		elements[i] = fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"id":%d}`, method, i)
+	}
+	return "[" + strings.Join(elements, ",") + "]"
+}
+
+func TestServer_ConnectionBatchConcurrency_TCP(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", BatchConcurrency: 16, ConnectionBatchConcurrency: 3}, logger)
+
+	probe := &concurrencyProbe{}
+	server.RegisterHandler("work", probe.handler(10*time.Millisecond))
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go server.handleTCPConnection(serverConn, "TCP")
+
+	reader := bufio.NewReader(clientConn)
+	clientConn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	go clientConn.Write([]byte(buildMethodBatch("work", 40) + "\n"))
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var responses []types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(line, &responses))
+	require.Len(t, responses, 40)
+	for i, response := range responses {
+		assert.Equal(t, float64(i), response.ID)
+	}
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&probe.max), int32(3), "connection is capped below the global batch concurrency")
+	assert.Greater(t, atomic.LoadInt32(&probe.max), int32(1))
+}
+
+func TestServer_BatchConcurrency_MonotonicIDs_TCP(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", BatchConcurrency: 16, MonotonicIDs: true}, logger)
+
+	probe := &concurrencyProbe{}
+	server.RegisterHandler("work", probe.handler(time.Millisecond))
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go server.handleTCPConnection(serverConn, "TCP")
+
+	reader := bufio.NewReader(clientConn)
+	clientConn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// Every element of an increasing batch is accepted, batch after batch
+	for round := 0; round < 5; round++ {
+		batch := make([]map[string]interface{}, 20)
+		for i := range batch {
+			batch[i] = map[string]interface{}{"jsonrpc": "2.0", "method": "work", "id": round*len(batch) + i}
+		}
+		data, err := json.Marshal(batch)
+		require.NoError(t, err)
+
+		go clientConn.Write(append(data, '\n'))
+		line, err := reader.ReadBytes('\n')
+		require.NoError(t, err)
+
+		var responses []types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(line, &responses))
+		require.Len(t, responses, len(batch))
+		for _, response := range responses {
+			assert.Nil(t, response.Error, "request %v", response.ID)
+		}
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&probe.max), "batch elements run in order")
+}
+
+func TestJSONRPCProcessor_ConnectionBatchConcurrency_SharedAcrossBatches(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.processor.SetBatchConcurrency(8)
+	server.processor.SetConnectionBatchConcurrency(2)
+
+	probe := &concurrencyProbe{}
+	server.RegisterHandler("work", probe.handler(10*time.Millisecond))
+	batch := []byte(buildMethodBatch("work", 10))
+
+	run := func(conns ...*types.ConnectionState) int32 {
+		atomic.StoreInt32(&probe.max, 0)
+		var wg sync.WaitGroup
+		for _, conn := range conns {
+			wg.Add(1)
+			go func(conn *types.ConnectionState) {
+				defer wg.Done()
+				server.processor.ProcessBatchRequest(batch, ProcessingContext{Transport: "WebSocket", Connection: conn})
+			}(conn)
+		}
+		wg.Wait()
+		return atomic.LoadInt32(&probe.max)
+	}
+
+	// Two batches on one connection share its slots
+	shared := types.NewConnectionState()
+	assert.LessOrEqual(t, run(shared, shared), int32(2))
+
+	// Separate connections each get their own allowance
+	assert.Greater(t, run(types.NewConnectionState(), types.NewConnectionState()), int32(2))
+}
+
+func TestJSONRPCProcessor_ContextErrors(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	server.RegisterHandler("deadline", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, fmt.Errorf("upstream call: %w", context.DeadlineExceeded)
+	})
+	server.RegisterHandler("cancelled", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, context.Canceled
+	})
+	server.RegisterHandler("failure", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		return nil, errors.New("disk full")
+	})
+
+	tests := []struct {
+		method  string
+		code    int
+		message string
+	}{
+		{method: "deadline", code: -32000, message: "Timeout"},
+		{method: "cancelled", code: -32000, message: "Cancelled"},
+		{method: "failure", code: types.InternalError, message: "Internal error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			request := fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"id":"req-%s"}`, tt.method, tt.method)
+			response := server.processor.ProcessSingleRequest([]byte(request), ProcessingContext{Transport: "HTTP"})
+
+			require.NotNil(t, response)
+			require.NotNil(t, response.Error)
+			assert.Equal(t, tt.code, response.Error.Code)
+			assert.Equal(t, tt.message, response.Error.Message)
+			assert.Equal(t, "req-"+tt.method, response.ID)
+		})
+	}
+}
+
+func TestJSONRPCProcessor_NilDispatcher(t *testing.T) {
+	_, logger := setupTestServer(t)
+	processor := NewJSONRPCProcessor(nil, logger)
+	ctx := ProcessingContext{Transport: "HTTP"}
+
+	var response *types.JSONRPCResponse
+	require.NotPanics(t, func() {
+		response = processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"echo","id":1}`), ctx)
+	})
+	require.NotNil(t, response)
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InternalError, response.Error.Code)
+	assert.Equal(t, float64(1), response.ID)
+
+	// Notifications are accepted silently
+	require.NotPanics(t, func() {
+		response = processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"echo"}`), ctx)
+	})
+	assert.Nil(t, response)
+
+	var batch interface{}
+	require.NotPanics(t, func() {
+		batch = processor.ProcessBatchRequest([]byte(`[{"jsonrpc":"2.0","method":"echo","id":1},{"jsonrpc":"2.0","method":"log"}]`), ctx)
+	})
+	responses, ok := batch.([]*types.JSONRPCResponse)
+	require.True(t, ok)
+	require.Len(t, responses, 1)
+	assert.Equal(t, types.InternalError, responses[0].Error.Code)
+}
+
+func TestServer_HandlerPanicRecovery_TCP(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.RegisterHandler("boom", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		var m map[string]int
+		m["key"] = 1 // panics: assignment to entry in nil map
+		return nil, nil
+	})
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go server.handleTCPConnection(serverConn, "TCP")
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(clientConn)
+
+	_, err := clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"boom","id":1}` + "\n"))
+	require.NoError(t, err)
+
+	var response types.JSONRPCResponse
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(line, &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, types.InternalError, response.Error.Code)
+	assert.Equal(t, "panic: assignment to entry in nil map", response.Error.Data)
+	assert.Equal(t, float64(1), response.ID)
+
+	// The connection and the server keep serving requests
+	_, err = clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"still here"},"id":2}` + "\n"))
+	require.NoError(t, err)
+
+	line, err = reader.ReadBytes('\n')
+	require.NoError(t, err)
+	response = types.JSONRPCResponse{}
+	require.NoError(t, json.Unmarshal(line, &response))
+	assert.Nil(t, response.Error)
+	assert.Equal(t, float64(2), response.ID)
+}
+
+func TestServer_MalformedMessage_TCP(t *testing.T) {
+	const (
+		valid1 = `{"jsonrpc":"2.0","method":"echo","params":{"message":"ok"},"id":1}` + "\n"
+		valid2 = `{"jsonrpc":"2.0","method":"echo","params":{"message":"ok"},"id":2}` + "\n"
+		parse  = "parse error"
+	)
+
+	tests := []struct {
+		name   string
+		writes []string
+		// expected replies in order: a request ID or parse
+		expected []interface{}
+	}{
+		{"same write", []string{`{"jsonrpc":"2.0",bad}` + "\n" + valid2}, []interface{}{parse, float64(2)}},
+		{"separate writes", []string{`{"jsonrpc":"2.0",bad}` + "\n", valid2}, []interface{}{parse, float64(2)}},
+		{"line longer than the read buffer", []string{`{"jsonrpc":"2.0",` + strings.Repeat("x", 64*1024) + "\n", valid2}, []interface{}{parse, float64(2)}},
+		{"valid then malformed", []string{valid1 + `{bad}` + "\n" + valid2}, []interface{}{float64(1), parse, float64(2)}},
+		{"valid then malformed in separate writes", []string{valid1, "{bad}\n", valid2}, []interface{}{float64(1), parse, float64(2)}},
+		{"multi-line malformed object", []string{valid1 + "{\n \"x\": ,\n \"y\": 1\n}\n" + valid2}, []interface{}{float64(1), parse, float64(2)}},
+		{"multi-line malformed object in separate writes", []string{"{\n", " \"x\": ,\n", " \"y\": [1,\n", "  2]\n}\n", "  " + valid2}, []interface{}{parse, float64(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := setupTestServer(t)
+
+			serverConn, clientConn := net.Pipe()
+			defer clientConn.Close()
+			done := make(chan struct{})
+			go func() {
+				server.ServeConn(serverConn, "TCP")
+				close(done)
+			}()
+			clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+			reader := bufio.NewReader(clientConn)
+
+			// A final probe shows that no extra replies were produced
+			writes := append(append([]string{}, tt.writes...), `{"jsonrpc":"2.0","method":"echo","id":99}`+"\n")
+			expected := append(append([]interface{}{}, tt.expected...), float64(99))
+			go func() {
+				for _, data := range writes {
+					if _, err := clientConn.Write([]byte(data)); err != nil {
+						return
+					}
+				}
+			}()
+
+			for _, want := range expected {
+				var response types.JSONRPCResponse
+				line, err := reader.ReadBytes('\n')
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(line, &response), string(line))
+
+				if want == parse {
+					require.NotNil(t, response.Error, string(line))
+					assert.Equal(t, types.ParseError, response.Error.Code)
+					assert.Nil(t, response.ID)
+					continue
+				}
+				assert.Nil(t, response.Error, string(line))
+				assert.Equal(t, want, response.ID)
+			}
+
+			clientConn.Close()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("connection handler did not return after the client closed")
+			}
+		})
+	}
+}
+
+func TestServer_TruncatedMessage_TCP(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		server.handleTCPConnection(serverConn, "TCP")
+		close(done)
+	}()
+
+	// A message cut short by EOF is a transport failure: the connection closes without a reply
+	_, err := clientConn.Write([]byte(`{"jsonrpc":"2.0","method":`))
+	require.NoError(t, err)
+	clientConn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection handler did not return")
+	}
+}
+
+func TestServer_MaxConnections_TCP(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", MaxConnections: 2}, logger)
+
+	type client struct {
+		conn   net.Conn
+		reader *bufio.Reader
+	}
+	connect := func() client {
+		serverConn, clientConn := net.Pipe()
+		t.Cleanup(func() { clientConn.Close() })
+		go server.handleTCPConnection(serverConn, "TCP")
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+		return client{conn: clientConn, reader: bufio.NewReader(clientConn)}
+	}
+	echo := func(c client, id int) {
+		go c.conn.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","params":{"message":"m"},"id":%d}`+"\n", id)))
+		line, err := c.reader.ReadBytes('\n')
+		require.NoError(t, err)
+
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(line, &response))
+		assert.Nil(t, response.Error)
+		assert.Equal(t, float64(id), response.ID)
+	}
+
+	first, second := connect(), connect()
+	echo(first, 1)
+	echo(second, 2)
+	assert.Equal(t, 2, server.ConnectionCount())
+
+	// The third connection gets an error and is closed
+	excess := connect()
+	line, err := excess.reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var response types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(line, &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, TooManyConnectionsCode, response.Error.Code)
+	assert.Nil(t, response.ID)
+
+	_, err = excess.reader.ReadBytes('\n')
+	assert.Error(t, err, "rejected connection is closed")
+	assert.Equal(t, 2, server.ConnectionCount())
+
+	// Existing connections keep working
+	echo(first, 3)
+	echo(second, 4)
+
+	// Closing one frees its slot
+	first.conn.Close()
+	require.Eventually(t, func() bool { return server.ConnectionCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	echo(connect(), 5)
+}
+
+func TestServer_ConnIdleTimeout_TCP(t *testing.T) {
+	server, _ := setupTestServer(t)
+	server.config.ConnIdleTimeout = 100 * time.Millisecond
+
+	var logs bytes.Buffer
+	var logsMu sync.Mutex
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		logsMu.Lock()
+		defer logsMu.Unlock()
+		return logs.Write(p)
+	}))
+	defer log.SetOutput(os.Stderr)
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		server.handleTCPConnection(serverConn, "TCP")
+		close(done)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Regular traffic keeps the connection open well past a single timeout
+	for i := 0; i < 5; i++ {
+		go clientConn.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","params":{"message":"m"},"id":%d}`+"\n", i)))
+		_, err := reader.ReadBytes('\n')
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Going silent closes the connection
+	start := time.Now()
+	_, err := reader.ReadBytes('\n')
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection handler did not exit")
+	}
+	assert.Zero(t, server.ConnectionCount())
+
+	logsMu.Lock()
+	defer logsMu.Unlock()
+	assert.NotContains(t, logs.String(), "TCP decode error", "idle close is not an error")
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// echoLargeOverTCP sends echo requests with a payload of the given size over an
+// in-memory connection and checks every response
+func echoLargeOverTCP(tb testing.TB, server *Server, payload string, count int) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go server.handleTCPConnection(serverConn, "TCP")
+
+	reader := bufio.NewReaderSize(clientConn, len(payload)+1024)
+	for i := 0; i < count; i++ {
+		message := fmt.Sprintf(`{"jsonrpc":"2.0","method":"echo","params":{"message":%q},"id":%d}`, payload, i)
+		go clientConn.Write([]byte(message))
+
+		line, err := reader.ReadBytes('\n')
+		require.NoError(tb, err)
+		var response struct {
+			Result struct {
+				Echo struct {
+					Message string `json:"message"`
+				} `json:"echo"`
+			} `json:"result"`
+			Error *types.RPCError `json:"error"`
+			ID    int             `json:"id"`
+		}
+		require.NoError(tb, json.Unmarshal(line, &response))
+		require.Nil(tb, response.Error)
+		require.Equal(tb, i, response.ID)
+		require.Equal(tb, payload, response.Result.Echo.Message)
+	}
+}
+
+func TestServer_TCPReadBufferSize(t *testing.T) {
+	payload := strings.Repeat("x", 256*1024)
+
+	for _, size := range []int{0, 16, 4096, 1 << 20} {
+		t.Run(fmt.Sprintf("buffer %d", size), func(t *testing.T) {
+			server, _ := setupTestServer(t)
+			server.config.TCPReadBufferSize = size
+			echoLargeOverTCP(t, server, payload, 3)
+		})
+	}
+}
+
+func BenchmarkServer_TCPReadBufferSize_LargeMessages(b *testing.B) {
+	payload := strings.Repeat("x", 1<<20)
+
+	for _, size := range []int{512, 4096, DefaultTCPReadBufferSize, 1 << 20} {
+		b.Run(fmt.Sprintf("buffer %d", size), func(b *testing.B) {
+			logger, _ := middleware.NewLogger(middleware.LoggingConfig{Enabled: false})
+			server := NewServer(Config{ServiceName: "bench", TCPReadBufferSize: size}, logger)
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+			echoLargeOverTCP(b, server, payload, b.N)
+		})
+	}
+}
+
+func TestServer_WSPingInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	server, _ := setupTestServer(t)
+	server.config.WSPingInterval = interval
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+	defer httpServer.Close()
+	wsURL := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+
+	t.Run("answered pings keep the connection alive", func(t *testing.T) {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		var pings int32
+		conn.SetPingHandler(func(data string) error {
+			atomic.AddInt32(&pings, 1)
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		messages := make(chan []byte, 1)
+		go func() {
+			for {
+				_, message, err := conn.ReadMessage()
+				if err != nil {
+					close(messages)
+					return
+				}
+				messages <- message
+			}
+		}()
+
+		// Idle for several intervals, well past the read deadline
+		time.Sleep(6 * interval)
+		assert.GreaterOrEqual(t, atomic.LoadInt32(&pings), int32(3))
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"alive"},"id":1}`)))
+		select {
+		case message, ok := <-messages:
+			require.True(t, ok, "connection was closed")
+			var response types.JSONRPCResponse
+			require.NoError(t, json.Unmarshal(message, &response))
+			assert.Nil(t, response.Error)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no response")
+		}
+	})
+
+	t.Run("slow requests do not use up the read deadline", func(t *testing.T) {
+		server.RegisterHandler("slow", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			time.Sleep(3 * interval)
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "done", ID: req.ID}, nil
+		})
+
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetPingHandler(func(data string) error {
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		// The second request waits longer than the ping deadline while the
+		// first one runs; reading must resume with a fresh deadline
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"slow","id":1}`)))
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"slow","id":2}`)))
+		for id := 1; id <= 2; id++ {
+			_, message, err := conn.ReadMessage()
+			require.NoError(t, err)
+			var response types.JSONRPCResponse
+			require.NoError(t, json.Unmarshal(message, &response))
+			assert.Equal(t, "done", response.Result)
+		}
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"alive"},"id":3}`)))
+		_, message, err := conn.ReadMessage()
+		require.NoError(t, err, "connection was closed")
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(message, &response))
+		assert.Nil(t, response.Error)
+	})
+
+	t.Run("missing pongs close the connection", func(t *testing.T) {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Eventually(t, func() bool { return server.Connections().Count() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+		// The client reads but never answers pings
+		conn.SetPingHandler(func(string) error { return nil })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		start := time.Now()
+		_, _, err = conn.ReadMessage()
+		require.Error(t, err)
+		assert.False(t, isTimeout(err), "the server closed the connection: %v", err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+		require.Eventually(t, func() bool { return server.Connections().Count() == 0 }, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestServer_MixedBatchAndSingle_TCP(t *testing.T) {
+	// Pipelined in one write: a batch, a notification, a single request, a
+	// notification-only batch and another single request
+	stream := `[{"jsonrpc":"2.0","method":"echo","params":{"message":"b1"},"id":1},` +
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"note"}},` +
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"b2"},"id":2}]` + "\n" +
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"quiet"}}` + "\n" +
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"s1"},"id":3}` +
+		`[{"jsonrpc":"2.0","method":"echo","params":{"message":"n1"}}]` +
+		` {"jsonrpc":"2.0","method":"echo","params":{"message":"s2"},"id":4}` + "\n"
+
+	for _, threshold := range []int{0, 1} {
+		t.Run(fmt.Sprintf("stream threshold %d", threshold), func(t *testing.T) {
+			server, _ := setupTestServer(t)
+			server.config.BatchStreamThreshold = threshold
+
+			serverConn, clientConn := net.Pipe()
+			defer clientConn.Close()
+			go server.handleTCPConnection(serverConn, "TCP")
+
+			reader := bufio.NewReader(clientConn)
+			clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+			go clientConn.Write([]byte(stream))
+
+			// Every response is one line; notifications produce nothing
+			line, err := reader.ReadBytes('\n')
+			require.NoError(t, err)
+			var batch []types.JSONRPCResponse
+			require.NoError(t, json.Unmarshal(line, &batch), string(line))
+			require.Len(t, batch, 2)
+			assert.Equal(t, float64(1), batch[0].ID)
+			assert.Equal(t, float64(2), batch[1].ID)
+
+			for _, id := range []float64{3, 4} {
+				line, err := reader.ReadBytes('\n')
+				require.NoError(t, err)
+				var single types.JSONRPCResponse
+				require.NoError(t, json.Unmarshal(line, &single), string(line))
+				assert.Nil(t, single.Error)
+				assert.Equal(t, id, single.ID)
+			}
+
+			// Nothing else is pending on the connection
+			clientConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			extra, err := reader.ReadBytes('\n')
+			assert.True(t, isTimeout(err), "unexpected output %q", extra)
+		})
+	}
+}
+
+func TestServer_MixedBatchAndSingle_WebSocket(t *testing.T) {
+	server, _ := setupTestServer(t)
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for _, message := range []string{
+		`[{"jsonrpc":"2.0","method":"echo","params":{"message":"b1"},"id":1},{"jsonrpc":"2.0","method":"echo","params":{"message":"note"}}]`,
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"quiet"}}`,
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"s1"},"id":2}`,
+	} {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(message)))
+	}
+
+	var batch []types.JSONRPCResponse
+	require.NoError(t, conn.ReadJSON(&batch))
+	require.Len(t, batch, 1)
+	assert.Equal(t, float64(1), batch[0].ID)
+
+	// The notification in between produces no frame
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var single types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(data, &single), string(data))
+	assert.Equal(t, float64(2), single.ID)
+}
+
+// registerCancellationProbe registers a method that blocks until its request
+// context is cancelled and reports the cancellation on the returned channel
+func registerCancellationProbe(server *Server, method string) (started, cancelled chan struct{}) {
+	started = make(chan struct{}, 1)
+	cancelled = make(chan struct{}, 1)
+	server.RegisterHandler(method, func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		started <- struct{}{}
+		select {
+		case <-ctx.Context().Done():
+			cancelled <- struct{}{}
+			return nil, ctx.Context().Err()
+		case <-time.After(10 * time.Second):
+			return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "not cancelled", ID: req.ID}, nil
+		}
+	})
+	return started, cancelled
+}
+
+func TestServer_HandlerObservesClientCancellation(t *testing.T) {
+	t.Run("http", func(t *testing.T) {
+		server, _ := setupTestServer(t)
+		started, cancelled := registerCancellationProbe(server, "block")
+		httpServer := httptest.NewServer(server.newHTTPMux())
+		defer httpServer.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, "POST", httpServer.URL+"/rpc",
+			strings.NewReader(`{"jsonrpc":"2.0","method":"block","id":1}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		done := make(chan error, 1)
+		go func() {
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- err
+		}()
+
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler did not start")
+		}
+		cancel()
+
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler did not observe cancellation")
+		}
+		assert.ErrorIs(t, <-done, context.Canceled)
+	})
+
+	t.Run("websocket", func(t *testing.T) {
+		server, _ := setupTestServer(t)
+		// No pings: the connection reader notices the close on its own
+		started, cancelled := registerCancellationProbe(server, "block")
+		httpServer := httptest.NewServer(http.HandlerFunc(server.handleWebSocket))
+		defer httpServer.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http"), nil)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"block","id":1}`)))
+
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler did not start")
+		}
+		conn.Close()
+
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler did not observe cancellation")
+		}
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		server, _ := setupTestServer(t)
+		started, cancelled := registerCancellationProbe(server, "block")
+
+		serverConn, clientConn := net.Pipe()
+		go server.handleTCPConnection(serverConn, "TCP")
+		go clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"block","id":1}` + "\n"))
+
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler did not start")
+		}
+		clientConn.Close()
+
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler did not observe cancellation")
+		}
+	})
+
+	t.Run("tcp keeps pipelined requests", func(t *testing.T) {
+		server, _ := setupTestServer(t)
+		server.RegisterHandler("slow", func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+			select {
+			case <-ctx.Context().Done():
+				return nil, ctx.Context().Err()
+			case <-time.After(50 * time.Millisecond):
+				return &types.JSONRPCResponse{JSONRPC: "2.0", Result: "done", ID: req.ID}, nil
+			}
+		})
+
+		serverConn, clientConn := net.Pipe()
+		defer clientConn.Close()
+		go server.handleTCPConnection(serverConn, "TCP")
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		// The second request arrives while the first is running and is read after it
+		go clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"slow","id":1}` + "\n" + `{"jsonrpc":"2.0","method":"slow","id":2}` + "\n"))
+		reader := bufio.NewReader(clientConn)
+		for id := 1; id <= 2; id++ {
+			line, err := reader.ReadBytes('\n')
+			require.NoError(t, err)
+			var response types.JSONRPCResponse
+			require.NoError(t, json.Unmarshal(line, &response))
+			assert.Nil(t, response.Error)
+			assert.Equal(t, "done", response.Result)
+			assert.EqualValues(t, id, response.ID)
+		}
+	})
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", RateLimit: &middleware.RateLimitConfig{Limit: 1, Window: time.Minute}}, logger)
+
+	send := func(remoteAddr string) types.JSONRPCResponse {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":1}`))
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	assert.Nil(t, send("10.0.0.1:1000").Error)
+	limited := send("10.0.0.1:2000")
+	require.NotNil(t, limited.Error)
+	assert.Equal(t, middleware.RateLimitedCode, limited.Error.Code)
+	assert.Nil(t, send("10.0.0.2:1000").Error, "other clients keep their own limit")
+}
+
+func TestServer_RateLimit_InvalidConfig(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", RateLimit: &middleware.RateLimitConfig{Backend: "memcached", Limit: 1}}, logger)
+
+	// Start refuses to run without the requested limit
+	err := server.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "memcached")
+
+	// Requests served anyway are rejected rather than let through unlimited
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":1}`), ProcessingContext{Transport: "HTTP", RemoteAddr: "10.0.0.1:1000"})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, middleware.RateLimitedCode, response.Error.Code)
+}
+
+func TestServer_LocalizedErrorMessages(t *testing.T) {
+	_, logger := setupTestServer(t)
+	catalog := types.ErrorCatalog{
+		"ru": {
+			types.MethodNotFound: "Метод не найден",
+			types.InvalidRequest: "Неверный запрос",
+		},
+	}
+
+	send := func(server *Server, body, acceptLanguage string) []byte {
+		req := httptest.NewRequest("POST", "/rpc", strings.NewReader(body))
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		server.handleHTTPRequest(w, req)
+		return w.Body.Bytes()
+	}
+	errorMessage := func(data []byte) string {
+		var response types.JSONRPCResponse
+		require.NoError(t, json.Unmarshal(data, &response))
+		require.NotNil(t, response.Error)
+		return response.Error.Message
+	}
+
+	server := NewServer(Config{ServiceName: "test", ErrorCatalog: catalog}, logger)
+	missing := `{"jsonrpc":"2.0","method":"missing","id":1}`
+
+	assert.Equal(t, "Метод не найден", errorMessage(send(server, missing, "ru-RU,ru;q=0.9")))
+	assert.Equal(t, "Method not found", errorMessage(send(server, missing, "de")), "unknown locales fall back to English")
+	assert.Equal(t, "Method not found", errorMessage(send(server, missing, "")))
+
+	// Batch elements and errors for the whole batch are localized as well
+	var responses []types.JSONRPCResponse
+	require.NoError(t, json.Unmarshal(send(server, `[`+missing+`,{"jsonrpc":"2.0","method":"echo","params":{"message":"hi"},"id":2}]`, "ru"), &responses))
+	require.Len(t, responses, 2)
+	assert.Equal(t, "Метод не найден", responses[0].Error.Message)
+	assert.Nil(t, responses[1].Error)
+	assert.Equal(t, "Неверный запрос", errorMessage(send(server, `[]`, "ru")))
+
+	// The configured default applies when the client does not ask for a language
+	server = NewServer(Config{ServiceName: "test", ErrorCatalog: catalog, DefaultLocale: "ru"}, logger)
+	assert.Equal(t, "Метод не найден", errorMessage(send(server, missing, "")))
+	assert.Equal(t, "Method not found", errorMessage(send(server, missing, "en")))
+}
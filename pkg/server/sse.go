@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"streaming-server/pkg/types"
+)
+
+// SSEConnectionIDHeader - заголовок ответа /sse с ID соединения, по которому
+// сервер адресует уведомления через Notify
+const SSEConnectionIDHeader = "X-Connection-ID"
+
+// SSEConnectionEvent - первое событие потока /sse с ID соединения в поле
+// connection_id. EventSource в браузере не видит заголовков ответа, поэтому
+// ID передается и в самом потоке
+const SSEConnectionEvent = "connection"
+
+// sseKeepAliveInterval - период комментариев-пингов, не дающих прокси закрыть
+// простаивающий поток
+const sseKeepAliveInterval = 15 * time.Second
+
+// sseConnection - поток Server-Sent Events, в который сервер пишет JSON-RPC
+// уведомления событиями "data:". Запись сериализуется мьютексом: поток
+// одновременно используют Notify, Broadcast и цикл пингов
+type sseConnection struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	rc      *http.ResponseController
+
+	mu        sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newSSEConnection(w http.ResponseWriter, flusher http.Flusher) *sseConnection {
+	return &sseConnection{
+		w:       w,
+		flusher: flusher,
+		rc:      http.NewResponseController(w),
+		done:    make(chan struct{}),
+	}
+}
+
+// writePush записывает уведомление одним событием и сразу отправляет его клиенту
+func (c *sseConnection) writePush(data []byte) error {
+	return c.write("data: %s\n\n", data)
+}
+
+// writeConnectionID записывает событие SSEConnectionEvent с ID соединения
+func (c *sseConnection) writeConnectionID(id string) error {
+	data, err := json.Marshal(map[string]string{"connection_id": id})
+	if err != nil {
+		return err
+	}
+	return c.write("event: %s\ndata: %s\n\n", SSEConnectionEvent, data)
+}
+
+// ping записывает комментарий, который клиенты EventSource игнорируют
+func (c *sseConnection) ping() error {
+	return c.write(": ping\n\n")
+}
+
+func (c *sseConnection) write(format string, args ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return fmt.Errorf("sse connection closed")
+	default:
+	}
+
+	// Дедлайн поддерживается не всеми ResponseWriter; без него запись не ограничена
+	c.rc.SetWriteDeadline(time.Now().Add(pushWriteTimeout))
+	defer c.rc.SetWriteDeadline(time.Time{})
+
+	if _, err := fmt.Fprintf(c.w, format, args...); err != nil {
+		return err
+	}
+	c.flusher.Flush()
+	return nil
+}
+
+// close завершает обработчик потока; ответ закрывается после выхода из него.
+// Блокировка гарантирует, что после close в ResponseWriter никто не пишет
+func (c *sseConnection) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+// handleSSE открывает поток Server-Sent Events для получения уведомлений сервера
+// без WebSocket. Соединение регистрируется в реестре под ID, который клиент
+// получает в заголовке X-Connection-ID и первым событием потока, и удаляется
+// при отключении клиента
+func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	s.applyCORS(w, r)
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	if !s.guard.tryAcquire() {
+		http.Error(w, "Server busy", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.guard.release()
+
+	conn := newSSEConnection(w, flusher)
+	// WriteTimeout сервера ограничил бы время жизни потока
+	conn.rc.SetWriteDeadline(time.Time{})
+
+	id := types.NewConnectionState().ID
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set(SSEConnectionIDHeader, id)
+	w.WriteHeader(http.StatusOK)
+
+	// ID отправляется до регистрации, чтобы уведомления пришли после него
+	if err := conn.writeConnectionID(id); err != nil {
+		log.Printf("SSE connection ID to %s failed: %v", id, err)
+		return
+	}
+
+	s.connections.Register(id, conn)
+	defer s.connections.Unregister(id)
+	s.processor.stats.connectionOpened()
+	defer s.processor.stats.connectionClosed()
+	defer conn.close()
+
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-conn.done:
+			return
+		case <-ticker.C:
+			if err := conn.ping(); err != nil {
+				log.Printf("SSE keep-alive to %s failed: %v", id, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"streaming-server/pkg/types"
+)
+
+// openSSE connects to /sse and returns the connection ID and a reader of the stream
+// positioned after the connection event
+func openSSE(t *testing.T, ctx context.Context, url string) (string, *bufio.Reader) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url+"/sse", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	id := resp.Header.Get(SSEConnectionIDHeader)
+	require.NotEmpty(t, id)
+
+	// The first event repeats the ID for EventSource clients, which cannot read headers
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: "+SSEConnectionEvent+"\n", line)
+	var event struct {
+		ConnectionID string `json:"connection_id"`
+	}
+	require.NoError(t, json.Unmarshal(readSSEData(t, reader), &event))
+	assert.Equal(t, id, event.ConnectionID)
+	return id, reader
+}
+
+// readSSEData returns the payload of the next "data:" event
+func readSSEData(t *testing.T, reader *bufio.Reader) []byte {
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			return []byte(strings.TrimSpace(data))
+		}
+	}
+}
+
+func TestServer_SSE_ReceivesNotifications(t *testing.T) {
+	server, _ := setupTestServer(t)
+	httpServer := httptest.NewServer(server.newHTTPMux())
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	id, reader := openSSE(t, ctx, httpServer.URL)
+	require.Eventually(t, func() bool { return server.Connections().Count() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, server.Notify(id, "tick", map[string]int{"n": 1}))
+
+	var notification types.JSONRPCRequest
+	require.NoError(t, json.Unmarshal(readSSEData(t, reader), &notification))
+	assert.Equal(t, "2.0", notification.JSONRPC)
+	assert.Equal(t, "tick", notification.Method)
+	assert.True(t, notification.IsNotification())
+	assert.JSONEq(t, `{"n":1}`, string(notification.Params))
+
+	delivered, err := server.Broadcast("news", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	require.NoError(t, json.Unmarshal(readSSEData(t, reader), &notification))
+	assert.Equal(t, "news", notification.Method)
+
+	// Disconnecting removes the stream from the registry
+	cancel()
+	require.Eventually(t, func() bool { return server.Connections().Count() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, server.Notify(id, "tick", nil), ErrConnectionNotFound)
+}
+
+func TestServer_SSE_RejectsNonGET(t *testing.T) {
+	server, _ := setupTestServer(t)
+	httpServer := httptest.NewServer(server.newHTTPMux())
+	defer httpServer.Close()
+
+	resp, err := http.Post(httpServer.URL+"/sse", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, 0, server.Connections().Count())
+}
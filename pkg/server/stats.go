@@ -0,0 +1,225 @@
+package server
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"streaming-server/pkg/dispatcher"
+	"streaming-server/pkg/middleware"
+	"streaming-server/pkg/types"
+)
+
+// MetricsMethod - встроенный метод, возвращающий снимок счетчиков сервера
+const MetricsMethod = "rpc.metrics"
+
+// Классы ошибок в снимке метрик
+const (
+	ErrorClassParse          = "parse_error"
+	ErrorClassInvalidRequest = "invalid_request"
+	ErrorClassMethodNotFound = "method_not_found"
+	ErrorClassInvalidParams  = "invalid_params"
+	ErrorClassInternal       = "internal_error"
+	ErrorClassServer         = "server_error"
+	ErrorClassApplication    = "application_error"
+)
+
+// TrafficCounts разделяет вызовы метода на запросы с ID и уведомления
+type TrafficCounts struct {
+	Requests      uint64 `json:"requests"`
+	Notifications uint64 `json:"notifications"`
+}
+
+// MetricsSnapshot - снимок счетчиков сервера, возвращаемый rpc.metrics
+type MetricsSnapshot struct {
+	TotalRequests     uint64                   `json:"total_requests"`
+	Notifications     uint64                   `json:"notifications"`
+	Errors            map[string]uint64        `json:"errors"`
+	Methods           map[string]uint64        `json:"methods"`
+	Traffic           map[string]TrafficCounts `json:"traffic"`
+	ActiveConnections int64                    `json:"active_connections"`
+	UptimeSeconds     float64                  `json:"uptime_seconds"`
+	Logger            middleware.LoggerStats   `json:"logger"`
+}
+
+// serverStats накапливает счетчики обработанных запросов
+type serverStats struct {
+	started           time.Time
+	activeConnections int64
+
+	total         uint64
+	notifications uint64
+	errors        map[string]uint64
+	methods       map[string]uint64
+	traffic       map[string]TrafficCounts
+	mu            sync.Mutex
+}
+
+// newServerStats создает пустой набор счетчиков
+func newServerStats() *serverStats {
+	return &serverStats{
+		started: types.GlobalClock.Now(),
+		errors:  make(map[string]uint64),
+		methods: make(map[string]uint64),
+		traffic: make(map[string]TrafficCounts),
+	}
+}
+
+// record учитывает обработанный запрос. method пуст, если запрос не удалось разобрать;
+// запросы к неизвестным методам не попадают в счетчики по методам, чтобы клиент
+// не мог раздуть таблицу произвольными именами
+func (s *serverStats) record(method string, notification bool, rpcErr *types.RPCError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total++
+	if notification {
+		s.notifications++
+	}
+
+	if rpcErr != nil {
+		s.errors[errorClass(rpcErr.Code)]++
+		if rpcErr.Code == types.MethodNotFound {
+			return
+		}
+	}
+
+	if method != "" {
+		s.methods[method]++
+
+		counts := s.traffic[method]
+		if notification {
+			counts.Notifications++
+		} else {
+			counts.Requests++
+		}
+		s.traffic[method] = counts
+	}
+}
+
+// connectionOpened и connectionClosed отслеживают открытые потоковые соединения
+func (s *serverStats) connectionOpened() {
+	atomic.AddInt64(&s.activeConnections, 1)
+}
+
+func (s *serverStats) connectionClosed() {
+	atomic.AddInt64(&s.activeConnections, -1)
+}
+
+// snapshot возвращает копию счетчиков
+func (s *serverStats) snapshot() MetricsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := MetricsSnapshot{
+		TotalRequests:     s.total,
+		Notifications:     s.notifications,
+		Errors:            make(map[string]uint64, len(s.errors)),
+		Methods:           make(map[string]uint64, len(s.methods)),
+		Traffic:           make(map[string]TrafficCounts, len(s.traffic)),
+		ActiveConnections: atomic.LoadInt64(&s.activeConnections),
+		UptimeSeconds:     types.GlobalClock.Since(s.started).Seconds(),
+	}
+	for class, count := range s.errors {
+		snapshot.Errors[class] = count
+	}
+	for method, count := range s.methods {
+		snapshot.Methods[method] = count
+	}
+	for method, counts := range s.traffic {
+		snapshot.Traffic[method] = counts
+	}
+	return snapshot
+}
+
+// requestsServed возвращает общее количество обработанных запросов и уведомлений
+func (s *serverStats) requestsServed() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// errorClass относит код ошибки JSON-RPC к классу
+func errorClass(code int) string {
+	switch {
+	case code == types.ParseError:
+		return ErrorClassParse
+	case code == types.InvalidRequest:
+		return ErrorClassInvalidRequest
+	case code == types.MethodNotFound:
+		return ErrorClassMethodNotFound
+	case code == types.InvalidParams:
+		return ErrorClassInvalidParams
+	case code == types.InternalError:
+		return ErrorClassInternal
+	case code <= -32000 && code >= -32099:
+		return ErrorClassServer
+	default:
+		return ErrorClassApplication
+	}
+}
+
+// metricsHandler возвращает снимок счетчиков сервера и статистику логгера
+func metricsHandler(stats *serverStats, logger *middleware.Logger) types.Handler {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		snapshot := stats.snapshot()
+		snapshot.Logger = logger.Stats()
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Result:  snapshot,
+			ID:      req.ID,
+		}, nil
+	}
+}
+
+// metricsHandlerInfo описывает rpc.metrics для rpc.discover
+var metricsHandlerInfo = dispatcher.HandlerInfo{
+	Description: "Returns request, error, connection and logger counters",
+}
+
+// StatsMethod - метод, возвращающий рабочие показатели процесса сервера.
+// Дополняет status данными среды выполнения Go
+const StatsMethod = "stats"
+
+// RuntimeStats - ответ метода stats
+type RuntimeStats struct {
+	Goroutines      int     `json:"goroutines"`
+	AllocBytes      uint64  `json:"alloc_bytes"`
+	TotalAllocBytes uint64  `json:"total_alloc_bytes"`
+	SysBytes        uint64  `json:"sys_bytes"`
+	NumGC           uint32  `json:"num_gc"`
+	UptimeSeconds   float64 `json:"uptime_seconds"`
+	RequestsServed  uint64  `json:"requests_served"`
+	Methods         int     `json:"methods"`
+}
+
+// statsHandler возвращает число горутин, память из runtime.MemStats, время
+// работы, количество обработанных запросов и зарегистрированных методов
+func statsHandler(stats *serverStats, d *dispatcher.Dispatcher) types.Handler {
+	return func(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		return &types.JSONRPCResponse{
+			JSONRPC: "2.0",
+			Result: RuntimeStats{
+				Goroutines:      runtime.NumGoroutine(),
+				AllocBytes:      mem.Alloc,
+				TotalAllocBytes: mem.TotalAlloc,
+				SysBytes:        mem.Sys,
+				NumGC:           mem.NumGC,
+				UptimeSeconds:   types.GlobalClock.Since(stats.started).Seconds(),
+				RequestsServed:  stats.requestsServed(),
+				Methods:         d.HandlerCount(),
+			},
+			ID: req.ID,
+		}, nil
+	}
+}
+
+// statsHandlerInfo описывает stats для rpc.discover
+var statsHandlerInfo = dispatcher.HandlerInfo{
+	Description: "Returns goroutine count, memory usage, uptime, requests served and method count",
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"streaming-server/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fetchMetrics(t *testing.T, server *Server) MetricsSnapshot {
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"rpc.metrics","id":"m"}`), ProcessingContext{Transport: "TCP"})
+	require.NotNil(t, response)
+	require.Nil(t, response.Error)
+
+	snapshot, ok := response.Result.(MetricsSnapshot)
+	require.True(t, ok)
+	return snapshot
+}
+
+func TestServer_MetricsSnapshot_ReflectsActivity(t *testing.T) {
+	server, _ := setupTestServer(t)
+	ctx := ProcessingContext{Transport: "HTTP", RemoteAddr: "127.0.0.1"}
+
+	requests := []string{
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"a"},"id":1}`,
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"b"},"id":2}`,
+		`{"jsonrpc":"2.0","method":"calculate","params":{"operation":"divide","a":1,"b":0},"id":3}`,
+		`{"jsonrpc":"2.0","method":"no.such.method","id":4}`,
+		`{"jsonrpc":"2.0","method":"echo","params":{"message":"note"}}`,
+		`{"jsonrpc":"1.0","method":"echo","id":5}`,
+		`{not json`,
+	}
+	for _, request := range requests {
+		server.processor.ProcessSingleRequest([]byte(request), ctx)
+	}
+	server.processor.ProcessBatchRequest([]byte(`[]`), ctx)
+
+	snapshot := fetchMetrics(t, server)
+
+	assert.Equal(t, uint64(8), snapshot.TotalRequests)
+	assert.Equal(t, uint64(1), snapshot.Notifications)
+	assert.Equal(t, uint64(3), snapshot.Methods["echo"])
+	assert.Equal(t, uint64(1), snapshot.Methods["calculate"])
+	assert.NotContains(t, snapshot.Methods, "no.such.method", "unknown methods are not tracked per method")
+
+	assert.Equal(t, uint64(1), snapshot.Errors[ErrorClassParse])
+	assert.Equal(t, uint64(2), snapshot.Errors[ErrorClassInvalidRequest])
+	assert.Equal(t, uint64(1), snapshot.Errors[ErrorClassMethodNotFound])
+	assert.Equal(t, uint64(1), snapshot.Errors[ErrorClassInvalidParams])
+	assert.Zero(t, snapshot.ActiveConnections)
+
+	// The snapshot call itself is counted afterwards
+	assert.Equal(t, uint64(1), fetchMetrics(t, server).Methods[MetricsMethod])
+}
+
+func TestServer_MetricsSnapshot_OverTCP(t *testing.T) {
+	server, logger := setupTestServer(t)
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go server.handleTCPConnection(serverConn, "TCP")
+
+	reader := bufio.NewReader(clientConn)
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	go clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"x"},"id":1}` + "\n"))
+	_, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	// Reserved rpc.* prefix is allowed for the built-in method
+	go clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"rpc.metrics","id":2}` + "\n"))
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var response struct {
+		Result MetricsSnapshot `json:"result"`
+		Error  *types.RPCError `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(line, &response))
+	require.Nil(t, response.Error)
+
+	assert.Equal(t, int64(1), response.Result.ActiveConnections)
+	assert.Equal(t, uint64(1), response.Result.TotalRequests)
+	assert.Equal(t, uint64(1), response.Result.Methods["echo"])
+
+	clientConn.Close()
+	require.Eventually(t, func() bool {
+		return fetchMetrics(t, server).ActiveConnections == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Logging runs asynchronously; the logger counters catch up
+	require.Eventually(t, func() bool {
+		return logger.Stats().Written > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	written := logger.Stats().Written
+	assert.GreaterOrEqual(t, fetchMetrics(t, server).Logger.Written, written)
+}
+
+func TestErrorClass(t *testing.T) {
+	assert.Equal(t, ErrorClassParse, errorClass(types.ParseError))
+	assert.Equal(t, ErrorClassInternal, errorClass(types.InternalError))
+	assert.Equal(t, ErrorClassServer, errorClass(-32000))
+	assert.Equal(t, ErrorClassServer, errorClass(-32099))
+	assert.Equal(t, ErrorClassApplication, errorClass(-32100))
+	assert.Equal(t, ErrorClassApplication, errorClass(42))
+}
+
+func TestServer_MetricsSnapshot_NotificationRatio(t *testing.T) {
+	_, logger := setupTestServer(t)
+	server := NewServer(Config{ServiceName: "test", PrometheusMetrics: true}, logger)
+	ctx := ProcessingContext{Transport: "HTTP"}
+
+	send := func(request string) {
+		server.processor.ProcessSingleRequest([]byte(request), ctx)
+	}
+
+	for i := 0; i < 3; i++ {
+		send(`{"jsonrpc":"2.0","method":"echo","params":{"message":"fire"}}`)
+	}
+	send(`{"jsonrpc":"2.0","method":"echo","params":{"message":"call"},"id":1}`)
+	send(`{"jsonrpc":"2.0","method":"time","id":2}`)
+	send(`{"jsonrpc":"2.0","method":"time","id":3}`)
+	send(`{"jsonrpc":"2.0","method":"status"}`)
+	server.processor.ProcessBatchRequest([]byte(`[
+		{"jsonrpc":"2.0","method":"status"},
+		{"jsonrpc":"2.0","method":"status","id":4}
+	]`), ctx)
+
+	snapshot := fetchMetrics(t, server)
+	assert.Equal(t, TrafficCounts{Requests: 1, Notifications: 3}, snapshot.Traffic["echo"])
+	assert.Equal(t, TrafficCounts{Requests: 2}, snapshot.Traffic["time"])
+	assert.Equal(t, TrafficCounts{Requests: 1, Notifications: 2}, snapshot.Traffic["status"])
+	assert.Equal(t, uint64(5), snapshot.Notifications)
+
+	// The same split is exported to Prometheus
+	w := httptest.NewRecorder()
+	server.newHTTPMux().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, `calls_total{kind="notification",method="echo"} 3`)
+	assert.Contains(t, body, `calls_total{kind="request",method="echo"} 1`)
+	assert.Contains(t, body, `calls_total{kind="notification",method="status"} 2`)
+	assert.Contains(t, body, `calls_total{kind="request",method="time"} 2`)
+}
+
+func fetchStats(t *testing.T, server *Server) map[string]interface{} {
+	response := server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"stats","id":"s"}`), ProcessingContext{Transport: "HTTP"})
+	require.NotNil(t, response)
+	require.Nil(t, response.Error)
+
+	// Decode as a client would see the result
+	data, err := json.Marshal(response.Result)
+	require.NoError(t, err)
+	var result map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &result))
+	return result
+}
+
+func TestServer_StatsHandler(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	first := fetchStats(t, server)
+	for _, key := range []string{"goroutines", "alloc_bytes", "total_alloc_bytes", "sys_bytes", "num_gc", "uptime_seconds", "requests_served", "methods"} {
+		assert.Contains(t, first, key)
+	}
+	assert.Positive(t, first["goroutines"])
+	assert.Positive(t, first["alloc_bytes"])
+	assert.Equal(t, float64(server.dispatcher.HandlerCount()), first["methods"])
+
+	ctx := ProcessingContext{Transport: "HTTP", RemoteAddr: "127.0.0.1"}
+	server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"a"},"id":1}`), ctx)
+	server.processor.ProcessSingleRequest([]byte(`{"jsonrpc":"2.0","method":"echo","params":{"message":"b"}}`), ctx)
+
+	// Two requests plus the first stats call itself
+	second := fetchStats(t, server)
+	assert.Equal(t, first["requests_served"].(float64)+3, second["requests_served"])
+	assert.Equal(t, uint64(second["requests_served"].(float64))+1, server.processor.RequestsServed())
+}
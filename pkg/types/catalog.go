@@ -0,0 +1,112 @@
+package types
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale - язык сообщений об ошибках, в котором их создает сервер
+const DefaultLocale = "en"
+
+// ErrorMessages - стандартные сообщения ошибок JSON-RPC 2.0 на языке по умолчанию
+var ErrorMessages = map[int]string{
+	ParseError:     "Parse error",
+	InvalidRequest: "Invalid Request",
+	MethodNotFound: "Method not found",
+	InvalidParams:  "Invalid params",
+	InternalError:  "Internal error",
+}
+
+// ErrorCatalog хранит переводы сообщений об ошибках: язык -> код -> сообщение.
+// Переводится сообщение, совпадающее с исходным сообщением кода, или его начало
+// перед ": " с подробностями ("Invalid params: a is required"). Исходные
+// сообщения берутся из раздела DefaultLocale каталога, а при его отсутствии -
+// из ErrorMessages; другие сообщения с тем же кодом не меняются. Языки
+// задаются в нижнем регистре: "ru", "pt-br"
+type ErrorCatalog map[string]map[int]string
+
+// Localize возвращает ошибку с сообщением на языке locale или nil, если
+// перевода нет. Исходная ошибка не изменяется
+func (c ErrorCatalog) Localize(err *RPCError, locale string) *RPCError {
+	if err == nil || locale == "" || locale == DefaultLocale {
+		return nil
+	}
+
+	translated, ok := c[locale][err.Code]
+	if !ok {
+		return nil
+	}
+
+	source, ok := c[DefaultLocale][err.Code]
+	if !ok {
+		if source, ok = ErrorMessages[err.Code]; !ok {
+			return nil
+		}
+	}
+
+	var message string
+	switch {
+	case err.Message == source:
+		message = translated
+	case strings.HasPrefix(err.Message, source+": "):
+		message = translated + err.Message[len(source):]
+	default:
+		return nil
+	}
+
+	localized := *err
+	localized.Message = message
+	return &localized
+}
+
+// MatchLanguage выбирает язык каталога по значению заголовка Accept-Language
+// с учетом весов q. Для "pt-BR" подходит и раздел "pt". Если ни один язык не
+// найден, возвращается fallback
+func (c ErrorCatalog) MatchLanguage(acceptLanguage, fallback string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, candidate := range candidates {
+		if c.hasLanguage(candidate.tag) {
+			return candidate.tag
+		}
+		if base, _, found := strings.Cut(candidate.tag, "-"); found && c.hasLanguage(base) {
+			return base
+		}
+	}
+	return fallback
+}
+
+// hasLanguage сообщает, есть ли в каталоге язык; язык по умолчанию есть всегда
+func (c ErrorCatalog) hasLanguage(tag string) bool {
+	if tag == DefaultLocale {
+		return true
+	}
+	_, ok := c[tag]
+	return ok
+}
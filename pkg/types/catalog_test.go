@@ -0,0 +1,68 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCatalog = ErrorCatalog{
+	"en": {-32003: "Rate limit exceeded"},
+	"ru": {
+		MethodNotFound: "Метод не найден",
+		InvalidParams:  "Неверные параметры",
+		-32003:         "Превышен лимит запросов",
+	},
+	"pt-br": {MethodNotFound: "Método não encontrado"},
+}
+
+func TestErrorCatalog_Localize(t *testing.T) {
+	original := NewMethodNotFoundError("missing")
+	localized := testCatalog.Localize(original, "ru")
+	require.NotNil(t, localized)
+	assert.Equal(t, "Метод не найден", localized.Message)
+	assert.Equal(t, MethodNotFound, localized.Code)
+	assert.Equal(t, "missing", localized.Data)
+	assert.Equal(t, "Method not found", original.Message, "the original error is not modified")
+
+	// Details after the standard message are kept
+	localized = testCatalog.Localize(NewInvalidParamsError("a is required"), "ru")
+	require.NotNil(t, localized)
+	assert.Equal(t, "Неверные параметры: a is required", localized.Message)
+
+	// Server codes use the source message from the default locale section
+	localized = testCatalog.Localize(NewServerError(-32003, "Rate limit exceeded"), "ru")
+	require.NotNil(t, localized)
+	assert.Equal(t, "Превышен лимит запросов", localized.Message)
+
+	// No translation: unknown locale, untranslated code, custom message, English
+	assert.Nil(t, testCatalog.Localize(NewMethodNotFoundError("missing"), "de"))
+	assert.Nil(t, testCatalog.Localize(NewInternalError(nil), "ru"))
+	assert.Nil(t, testCatalog.Localize(NewServerError(-32003, "Rate limiter unavailable"), "ru"))
+	assert.Nil(t, testCatalog.Localize(NewMethodNotFoundError("missing"), DefaultLocale))
+}
+
+func TestErrorCatalog_MatchLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "ru", want: "ru"},
+		{header: "ru-RU,ru;q=0.9,en;q=0.8", want: "ru"},
+		{header: "pt-BR", want: "pt-br"},
+		{header: "de-DE,de;q=0.9", want: "fr"},
+		{header: "de;q=0.9,ru;q=0.5", want: "ru"},
+		{header: "en;q=0.5,ru;q=0.8", want: "ru"},
+		{header: "ru;q=0.5,en", want: "en"},
+		{header: "ru;q=0", want: "fr"},
+		{header: "*", want: "fr"},
+		{header: "", want: "fr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, testCatalog.MatchLanguage(tt.header, "fr"))
+		})
+	}
+}
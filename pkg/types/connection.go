@@ -0,0 +1,49 @@
+package types
+
+import "sync"
+
+// ConnectionState хранит данные, общие для всех запросов одного соединения.
+// Создается потоковыми транспортами (TCP, TLS, WebSocket) один раз на соединение
+type ConnectionState struct {
+	ID   string
+	data map[string]interface{}
+	mu   sync.Mutex
+}
+
+// NewConnectionState создает новое состояние соединения
+func NewConnectionState() *ConnectionState {
+	return &ConnectionState{
+		ID:   generateRequestID(),
+		data: make(map[string]interface{}),
+	}
+}
+
+// Get возвращает значение, сохраненное для соединения
+func (cs *ConnectionState) Get(key string) (interface{}, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	value, exists := cs.data[key]
+	return value, exists
+}
+
+// Set сохраняет значение для соединения
+func (cs *ConnectionState) Set(key string, value interface{}) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.data[key] = value
+}
+
+// Update атомарно обновляет значение по ключу. fn получает текущее значение и
+// возвращает новое значение и признак того, что его нужно сохранить.
+// Возвращает признак сохранения
+func (cs *ConnectionState) Update(key string, fn func(current interface{}, exists bool) (interface{}, bool)) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	current, exists := cs.data[key]
+	next, store := fn(current, exists)
+	if store {
+		cs.data[key] = next
+	}
+	return store
+}
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MaxLocalCallDepth ограничивает вложенность вызовов CallLocal для защиты от бесконечной рекурсии
+const MaxLocalCallDepth = 8
+
+// ErrCallDepthExceeded возвращается CallLocal при превышении MaxLocalCallDepth
+var ErrCallDepthExceeded = errors.New("local call depth exceeded")
+
+// LocalDispatcher выполняет запрос через зарегистрированные обработчики
+type LocalDispatcher interface {
+	Dispatch(request *JSONRPCRequest, ctx *RequestContext) (*JSONRPCResponse, error)
+}
+
+// SetLocalDispatcher устанавливает диспетчер, используемый CallLocal
+func (rc *RequestContext) SetLocalDispatcher(dispatcher LocalDispatcher) {
+	rc.dispatcher = dispatcher
+}
+
+// LocalDispatcher возвращает диспетчер, используемый CallLocal
+func (rc *RequestContext) LocalDispatcher() LocalDispatcher {
+	return rc.dispatcher
+}
+
+// CallDepth возвращает глубину вложенности текущего вызова CallLocal (0 для внешнего запроса)
+func (rc *RequestContext) CallDepth() int {
+	return rc.callDepth
+}
+
+// CallLocal вызывает другой зарегистрированный метод в том же процессе, позволяя
+// составным обработчикам переиспользовать существующие. Вложенный вызов проходит
+// через ту же цепочку middleware и получает собственную копию данных контекста
+func (rc *RequestContext) CallLocal(method string, params interface{}) (*JSONRPCResponse, error) {
+	if rc.dispatcher == nil {
+		return nil, errors.New("local dispatcher is not set")
+	}
+
+	if rc.callDepth >= MaxLocalCallDepth {
+		return nil, fmt.Errorf("%w: %s at depth %d", ErrCallDepthExceeded, method, rc.callDepth)
+	}
+
+	var rawParams json.RawMessage
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal params: %w", err)
+		}
+		rawParams = data
+	}
+
+	request := &JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  rawParams,
+		ID:      rc.RequestID,
+	}
+
+	child := *rc
+	child.callDepth++
+	child.SelectedHandler = ""
+	child.RawRequest = nil
+	child.Data = make(map[string]interface{}, len(rc.Data))
+	for key, value := range rc.Data {
+		child.Data[key] = value
+	}
+	child.WithValue("method", method)
+
+	return rc.dispatcher.Dispatch(request, &child)
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dispatcherFunc adapts a function to the LocalDispatcher interface
+type dispatcherFunc func(*JSONRPCRequest, *RequestContext) (*JSONRPCResponse, error)
+
+func (f dispatcherFunc) Dispatch(req *JSONRPCRequest, ctx *RequestContext) (*JSONRPCResponse, error) {
+	return f(req, ctx)
+}
+
+func TestRequestContext_CallLocal(t *testing.T) {
+	ctx := NewRequestContext(context.Background(), "test", "127.0.0.1")
+	ctx.WithValue("method", "outer")
+
+	_, err := ctx.CallLocal("inner", nil)
+	assert.Error(t, err, "calls without a dispatcher fail")
+
+	var received *JSONRPCRequest
+	var childCtx *RequestContext
+	ctx.SetLocalDispatcher(dispatcherFunc(func(req *JSONRPCRequest, c *RequestContext) (*JSONRPCResponse, error) {
+		received = req
+		childCtx = c
+		c.WithValue("scratch", true)
+		return &JSONRPCResponse{JSONRPC: "2.0", Result: "ok", ID: req.ID}, nil
+	}))
+
+	response, err := ctx.CallLocal("inner", map[string]int{"a": 1})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", response.Result)
+
+	assert.Equal(t, "inner", received.Method)
+	assert.JSONEq(t, `{"a":1}`, string(received.Params))
+	assert.False(t, received.IsNotification())
+
+	assert.Equal(t, 1, childCtx.CallDepth())
+	assert.Equal(t, 0, ctx.CallDepth())
+	assert.Equal(t, "inner", childCtx.Data["method"])
+	assert.Equal(t, "outer", ctx.Data["method"])
+	_, leaked := ctx.GetValue("scratch")
+	assert.False(t, leaked)
+}
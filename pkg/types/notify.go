@@ -0,0 +1,38 @@
+package types
+
+import "errors"
+
+// ProgressMethod - метод уведомлений о ходе выполнения долгого вызова
+const ProgressMethod = "$/progress"
+
+// ProgressParams - параметры уведомления ProgressMethod: ID исходного запроса,
+// по которому клиент сопоставляет прогресс с вызовом, и значение прогресса
+type ProgressParams struct {
+	ID    interface{} `json:"id"`
+	Value interface{} `json:"value"`
+}
+
+// ErrNotifyUnsupported возвращается Notify, если транспорт запроса не может
+// доставить уведомление до ответа (HTTP, TCP)
+var ErrNotifyUnsupported = errors.New("notifications are not supported on this transport")
+
+// Notifier отправляет JSON-RPC уведомление клиенту по соединению запроса
+type Notifier func(method string, params interface{}) error
+
+// SetNotifier устанавливает функцию отправки уведомлений для Notify
+func (rc *RequestContext) SetNotifier(notifier Notifier) {
+	rc.notifier = notifier
+}
+
+// Notify отправляет клиенту JSON-RPC уведомление по текущему потоковому
+// соединению до ответа на запрос, например прогресс долгого вызова:
+//
+//	ctx.Notify(types.ProgressMethod, types.ProgressParams{ID: req.ID, Value: 50})
+//
+// Вложенные вызовы CallLocal отправляют уведомления по тому же соединению
+func (rc *RequestContext) Notify(method string, params interface{}) error {
+	if rc.notifier == nil {
+		return ErrNotifyUnsupported
+	}
+	return rc.notifier(method, params)
+}
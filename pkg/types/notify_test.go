@@ -0,0 +1,41 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContext_Notify(t *testing.T) {
+	ctx := NewRequestContext(context.Background(), "test", "127.0.0.1")
+	assert.ErrorIs(t, ctx.Notify(ProgressMethod, nil), ErrNotifyUnsupported)
+
+	type notification struct {
+		method string
+		params interface{}
+	}
+	var sent []notification
+	ctx.SetNotifier(func(method string, params interface{}) error {
+		sent = append(sent, notification{method, params})
+		return nil
+	})
+
+	require.NoError(t, ctx.Notify(ProgressMethod, ProgressParams{ID: 7, Value: "half"}))
+	assert.Equal(t, []notification{{ProgressMethod, ProgressParams{ID: 7, Value: "half"}}}, sent)
+
+	// Nested local calls report progress over the same connection
+	ctx.SetLocalDispatcher(dispatcherFunc(func(req *JSONRPCRequest, c *RequestContext) (*JSONRPCResponse, error) {
+		return nil, c.Notify("inner.progress", nil)
+	}))
+	_, err := ctx.CallLocal("inner", nil)
+	require.NoError(t, err)
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "inner.progress", sent[1].method)
+
+	failure := errors.New("connection closed")
+	ctx.SetNotifier(func(string, interface{}) error { return failure })
+	assert.ErrorIs(t, ctx.Notify(ProgressMethod, nil), failure)
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
@@ -26,12 +27,33 @@ func (r *JSONRPCRequest) IsNotification() bool {
 	return r.ID == nil
 }
 
+// HasParams проверяет, присутствует ли поле params в запросе.
+// Явно переданный "params": null тоже считается присутствующим
+func (r *JSONRPCRequest) HasParams() bool {
+	return len(r.Params) > 0
+}
+
+// HasNullParams проверяет, передано ли поле params явно со значением null
+func (r *JSONRPCRequest) HasNullParams() bool {
+	return bytes.Equal(bytes.TrimSpace(r.Params), []byte("null"))
+}
+
 // JSONRPCResponse представляет ответ JSON-RPC 2.0
 type JSONRPCResponse struct {
 	JSONRPC string      `json:"jsonrpc"`
 	Result  interface{} `json:"result,omitempty"`
 	Error   *RPCError   `json:"error,omitempty"`
 	ID      interface{} `json:"id"`
+	Debug   *DebugInfo  `json:"_debug,omitempty"` // Заполняется только в режиме отладки
+	Method  string      `json:"-"`                // Метод запроса, на который дан ответ; не сериализуется
+}
+
+// DebugInfo содержит диагностические данные обработки запроса
+type DebugInfo struct {
+	Transport string `json:"transport"`
+	Handler   string `json:"handler"`
+	Duration  string `json:"duration"`
+	RequestID string `json:"request_id"`
 }
 
 // RPCError представляет ошибку JSON-RPC 2.0
@@ -41,6 +63,11 @@ type RPCError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// Error реализует интерфейс error
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+}
+
 // Стандартные коды ошибок JSON-RPC 2.0
 const (
 	// Предопределенные коды ошибок
@@ -106,6 +133,15 @@ func NewInternalError(data interface{}) *RPCError {
 	}
 }
 
+// NewServerError создает ошибку сервера с кодом из зарезервированного диапазона
+// от ServerErrorStart до ServerErrorEnd
+func NewServerError(code int, message string) *RPCError {
+	return &RPCError{
+		Code:    code,
+		Message: message,
+	}
+}
+
 // RequestContext содержит данные и метаданные, специфичные для запроса
 type RequestContext struct {
 	ctx             context.Context
@@ -118,8 +154,13 @@ type RequestContext struct {
 	Data            map[string]interface{}
 	Span            interface{} // Используем interface{} чтобы избежать зависимости импорта
 	HTTPRequest     *http.Request
+	Connection      *ConnectionState // nil для транспортов без постоянного соединения
+	RawRequest      json.RawMessage  // исходные байты запроса, если они известны
 	SelectedHandler string
-	clock           Clock // Внедряемые часы для тестирования
+	clock           Clock           // Внедряемые часы для тестирования
+	dispatcher      LocalDispatcher // Диспетчер для вызовов CallLocal
+	callDepth       int             // Глубина вложенных вызовов CallLocal
+	notifier        Notifier        // Отправка уведомлений по соединению запроса (Notify)
 }
 
 // NewRequestContext создает новый контекст запроса
@@ -146,6 +187,14 @@ func (rc *RequestContext) Context() context.Context {
 	return rc.ctx
 }
 
+// WithContext возвращает копию контекста запроса с замененным базовым context.Context.
+// Данные запроса (Data, Headers) остаются общими с исходным контекстом
+func (rc *RequestContext) WithContext(ctx context.Context) *RequestContext {
+	copied := *rc
+	copied.ctx = ctx
+	return &copied
+}
+
 // WithValue добавляет пару ключ-значение в данные контекста запроса
 func (rc *RequestContext) WithValue(key string, value interface{}) {
 	rc.Data[key] = value
@@ -168,6 +217,9 @@ func (rc *RequestContext) Duration() time.Duration {
 // Handler представляет обработчик метода JSON-RPC
 type Handler func(*JSONRPCRequest, *RequestContext) (*JSONRPCResponse, error)
 
+// RawHandler представляет обработчик метода, получающий исходные байты запроса
+type RawHandler func(json.RawMessage, *RequestContext) (*JSONRPCResponse, error)
+
 // Middleware представляет функцию промежуточного слоя
 type Middleware func(*JSONRPCRequest, *RequestContext, Handler) (*JSONRPCResponse, error)
 
@@ -192,6 +244,39 @@ func (g *DefaultIDGenerator) Generate() string {
 // Глобальный генератор ID - может быть заменен для тестирования
 var GlobalIDGenerator IDGenerator = &DefaultIDGenerator{}
 
+// SetIDGenerator заменяет глобальный генератор ID запросов и возвращает прежний,
+// чтобы его можно было восстановить. nil возвращает генератор по умолчанию.
+// Как и GlobalClock, генератор заменяется до начала обработки запросов
+func SetIDGenerator(generator IDGenerator) IDGenerator {
+	previous := GlobalIDGenerator
+	if generator == nil {
+		generator = &DefaultIDGenerator{}
+	}
+	GlobalIDGenerator = generator
+	return previous
+}
+
+// PrefixedIDGenerator добавляет префикс, например имя сервиса, к ID другого
+// генератора, чтобы запросы разных сервисов различались в общих логах
+type PrefixedIDGenerator struct {
+	Prefix string
+	Next   IDGenerator
+}
+
+// NewPrefixedIDGenerator создает генератор с префиксом. nil в next означает
+// генератор по умолчанию
+func NewPrefixedIDGenerator(prefix string, next IDGenerator) *PrefixedIDGenerator {
+	if next == nil {
+		next = &DefaultIDGenerator{}
+	}
+	return &PrefixedIDGenerator{Prefix: prefix, Next: next}
+}
+
+// Generate возвращает ID исходного генератора с префиксом
+func (g *PrefixedIDGenerator) Generate() string {
+	return g.Prefix + g.Next.Generate()
+}
+
 // generateRequestID генерирует уникальный идентификатор запроса
 func generateRequestID() string {
 	return GlobalIDGenerator.Generate()
@@ -3,6 +3,7 @@ package types
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -62,6 +63,50 @@ func TestJSONRPCRequest_IsNotification(t *testing.T) {
 	}
 }
 
+func TestJSONRPCRequest_HasParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		hasParams  bool
+		nullParams bool
+	}{
+		{
+			name:       "Omitted params",
+			raw:        `{"jsonrpc":"2.0","method":"test","id":1}`,
+			hasParams:  false,
+			nullParams: false,
+		},
+		{
+			name:       "Explicit null params",
+			raw:        `{"jsonrpc":"2.0","method":"test","params":null,"id":1}`,
+			hasParams:  true,
+			nullParams: true,
+		},
+		{
+			name:       "Empty object params",
+			raw:        `{"jsonrpc":"2.0","method":"test","params":{},"id":1}`,
+			hasParams:  true,
+			nullParams: false,
+		},
+		{
+			name:       "Empty array params",
+			raw:        `{"jsonrpc":"2.0","method":"test","params":[],"id":1}`,
+			hasParams:  true,
+			nullParams: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request JSONRPCRequest
+			require.NoError(t, json.Unmarshal([]byte(tt.raw), &request))
+
+			assert.Equal(t, tt.hasParams, request.HasParams())
+			assert.Equal(t, tt.nullParams, request.HasNullParams())
+		})
+	}
+}
+
 func TestJSONRPCRequest_Validation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -283,6 +328,10 @@ func TestRPCError_CustomError(t *testing.T) {
 	assert.Equal(t, customErr.Code, unmarshaled.Code)
 	assert.Equal(t, customErr.Message, unmarshaled.Message)
 	assert.Equal(t, customErr.Data, unmarshaled.Data)
+
+	// RPCError can be passed around as a Go error
+	var goErr error = customErr
+	assert.Equal(t, "JSON-RPC error -32000: Custom application error", goErr.Error())
 }
 
 // Test RequestContext
@@ -301,6 +350,31 @@ func TestNewRequestContext(t *testing.T) {
 	assert.NotNil(t, reqCtx.Context())
 }
 
+func TestSetIDGenerator(t *testing.T) {
+	previous := SetIDGenerator(NewMockIDGenerator("a", "b"))
+	defer SetIDGenerator(previous)
+
+	assert.Equal(t, "a", NewRequestContext(context.Background(), "test", "").RequestID)
+	assert.Equal(t, "b", NewRequestContext(context.Background(), "test", "").RequestID)
+
+	// nil restores the random default
+	mock := SetIDGenerator(nil)
+	assert.IsType(t, &MockIDGenerator{}, mock)
+	assert.IsType(t, &DefaultIDGenerator{}, GlobalIDGenerator)
+	assert.Len(t, NewRequestContext(context.Background(), "test", "").RequestID, 16)
+}
+
+func TestPrefixedIDGenerator(t *testing.T) {
+	generator := NewPrefixedIDGenerator("billing-", NewMockIDGenerator("1", "2"))
+	assert.Equal(t, "billing-1", generator.Generate())
+	assert.Equal(t, "billing-2", generator.Generate())
+
+	random := NewPrefixedIDGenerator("billing-", nil)
+	id := random.Generate()
+	assert.True(t, strings.HasPrefix(id, "billing-"), id)
+	assert.NotEqual(t, id, random.Generate())
+}
+
 func TestRequestContext_WithValue(t *testing.T) {
 	ctx := NewRequestContext(context.Background(), "test-service", "127.0.0.1")
 
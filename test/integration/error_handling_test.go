@@ -145,7 +145,7 @@ func (suite *IntegrationTestSuite) TestErrorHandling_Timeout() {
 
 	request := types.JSONRPCRequest{
 		JSONRPC: "2.0",
-		Method:  "test_slow", // This handler sleeps for 2 seconds
+		Method:  "test_slow", // This handler sleeps for 2 seconds unless cancelled
 		ID:      "timeout-test",
 	}
 
@@ -160,6 +160,16 @@ func (suite *IntegrationTestSuite) TestErrorHandling_Timeout() {
 	if resp != nil {
 		defer resp.Body.Close()
 	}
+
+	// The local handler stops as soon as the client gives up instead of
+	// sleeping for the full 2 seconds
+	if !suite.env.IsDocker {
+		select {
+		case <-suite.slowCancelled:
+		case <-time.After(time.Second):
+			t.Error("test_slow handler did not observe client cancellation")
+		}
+	}
 }
 
 // TestErrorHandling_LargePayload tests large payload handling
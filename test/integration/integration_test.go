@@ -113,6 +113,8 @@ type IntegrationTestSuite struct {
 	mu             sync.Mutex
 	progressGroup  *testutil.ProgressGroup
 	allocatedPorts []int
+	// slowCancelled receives a value when test_slow observes request cancellation
+	slowCancelled chan struct{}
 }
 
 // SetupSuite initializes the test suite with automatic environment detection
@@ -314,6 +316,7 @@ func (suite *IntegrationTestSuite) registerTestHandlers() {
 	suite.server.RegisterHandler("status", handlers.StatusHandler)
 	suite.server.RegisterHandler("calculate", handlers.CalculateHandler)
 	suite.server.RegisterHandler("test_error", suite.errorHandler)
+	suite.slowCancelled = make(chan struct{}, 1)
 	suite.server.RegisterHandler("test_slow", suite.slowHandler)
 }
 
@@ -437,7 +440,15 @@ func (suite *IntegrationTestSuite) errorHandler(req *types.JSONRPCRequest, ctx *
 }
 
 func (suite *IntegrationTestSuite) slowHandler(req *types.JSONRPCRequest, ctx *types.RequestContext) (*types.JSONRPCResponse, error) {
-	time.Sleep(2 * time.Second)
+	select {
+	case <-time.After(2 * time.Second):
+	case <-ctx.Context().Done():
+		select {
+		case suite.slowCancelled <- struct{}{}:
+		default:
+		}
+		return nil, ctx.Context().Err()
+	}
 	return &types.JSONRPCResponse{
 		JSONRPC: "2.0",
 		Result:  "slow response",